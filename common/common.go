@@ -212,9 +212,9 @@ func IsEnabled(isEnabled bool) string {
 func IsValidCryptoAddress(address, crypto string) (bool, error) {
 	switch StringToLower(crypto) {
 	case "btc":
-		return regexp.MatchString("^[13][a-km-zA-HJ-NP-Z1-9]{25,34}$", address)
+		return regexp.MatchString("^([13][a-km-zA-HJ-NP-Z1-9]{25,34}|bc1[02-9ac-hj-np-z]{11,71})$", address)
 	case "ltc":
-		return regexp.MatchString("^[L3M][a-km-zA-HJ-NP-Z1-9]{25,34}$", address)
+		return regexp.MatchString("^([L3M][a-km-zA-HJ-NP-Z1-9]{25,34}|ltc1[02-9ac-hj-np-z]{11,71})$", address)
 	case "eth":
 		return regexp.MatchString("^0x[a-km-z0-9]{40}$", address)
 	default:
@@ -262,7 +262,7 @@ func CalculateNetProfit(amount, priceThen, priceNow, costs float64) float64 {
 func SendHTTPRequest(method, path string, headers map[string]string, body io.Reader) (string, error) {
 	upperMethod := strings.ToUpper(method)
 
-	if upperMethod != "POST" && upperMethod != "GET" && upperMethod != "DELETE" {
+	if upperMethod != "POST" && upperMethod != "GET" && upperMethod != "DELETE" && upperMethod != "PUT" {
 		return "", errors.New("invalid HTTP method specified")
 	}
 
@@ -347,6 +347,46 @@ func SendHTTPRequest2(method, path string, headers http.Header, body io.Reader)
 	return string(contents), resp.StatusCode, nil
 }
 
+// SendHTTPRequestPrivate sends an HTTP request carrying credentials, e.g. an
+// Authorization header or an API key in the URL. Unlike SendHTTPRequest2 the
+// request isn't dumped, and errors don't include the URL.
+// Returns the response body and status code, or an error.
+func SendHTTPRequestPrivate(method, path string, headers http.Header, body io.Reader) (string, int, error) {
+	upperMethod := strings.ToUpper(method)
+
+	if upperMethod != "POST" && upperMethod != "GET" && upperMethod != "DELETE" {
+		return "", 0, errors.New("invalid HTTP method specified")
+	}
+
+	req, err := http.NewRequest(upperMethod, path, body)
+	if err != nil {
+		return "", 0, errors.New("invalid request URL")
+	}
+
+	req.Header = headers
+
+	timeout := time.Duration(3 * time.Second)
+	if upperMethod == "POST" {
+		timeout = time.Duration(15 * time.Second)
+	}
+	httpClient := &http.Client{Timeout: timeout}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		if urlErr, ok := err.(*url.Error); ok {
+			return "", 0, fmt.Errorf("%s request failed: %s", upperMethod, urlErr.Err)
+		}
+		return "", 0, err
+	}
+	defer resp.Body.Close()
+
+	contents, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return "", 0, err
+	}
+
+	return string(contents), resp.StatusCode, nil
+}
+
 // SendHTTPGetRequest sends a simple get request using a url string & JSON
 // decodes the response into a struct pointer you have supplied. Returns an error
 // on failure.
@@ -3,6 +3,8 @@ package common
 import (
 	"bytes"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"net/url"
 	"reflect"
 	"strings"
@@ -44,6 +46,14 @@ func TestIsValidCryptoAddress(t *testing.T) {
 	if err == nil && b {
 		t.Error("Test Failed - Common IsValidCryptoAddress error")
 	}
+	b, err = IsValidCryptoAddress("bc1qw508d6qejxtdg4y5r3zarvary0c5xw7kv8f3t4", "btc")
+	if err != nil || !b {
+		t.Error("Test Failed - Common IsValidCryptoAddress error")
+	}
+	b, err = IsValidCryptoAddress("bc1qw508d6qejxtdg4y5r3zarvary0c5xw7kv8f3tb", "btc")
+	if err == nil && b {
+		t.Error("Test Failed - Common IsValidCryptoAddress error")
+	}
 	b, err = IsValidCryptoAddress("3CDJNfdWX8m2NwuGUV3nhXHXEeLygMXoAj", "ltc")
 	if err != nil && !b {
 		t.Errorf("Test Failed - Common IsValidCryptoAddress error: %s", err)
@@ -502,6 +512,31 @@ func TestSendHTTPGetRequest(t *testing.T) {
 	}
 }
 
+func TestSendHTTPRequestPrivate(t *testing.T) {
+	t.Parallel()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Header.Get("Authorization")))
+	}))
+	headers := http.Header{}
+	headers.Set("Authorization", "Basic secret")
+
+	resp, status, err := SendHTTPRequestPrivate("POST", server.URL, headers, nil)
+	if err != nil || status != http.StatusOK || resp != "Basic secret" {
+		t.Errorf("Test failed - common SendHTTPRequestPrivate unexpected response %s %d, %v", resp, status, err)
+	}
+
+	server.Close()
+	_, _, err = SendHTTPRequestPrivate("GET", server.URL+"?key=secret", headers, nil)
+	if err == nil || StringContains(err.Error(), "secret") {
+		t.Errorf("Test failed - common SendHTTPRequestPrivate error exposes the URL: %v", err)
+	}
+
+	_, _, err = SendHTTPRequestPrivate("PATCH", server.URL, headers, nil)
+	if err == nil {
+		t.Error("Test failed - common SendHTTPRequestPrivate invalid method accepted")
+	}
+}
+
 func TestJSONEncode(t *testing.T) {
 	type test struct {
 		Status int `json:"status"`
@@ -1,6 +1,7 @@
 package config
 
 import (
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"flag"
@@ -13,6 +14,7 @@ import (
 
 	"github.com/mattkanwisher/cryptofiend/common"
 	"github.com/mattkanwisher/cryptofiend/currency"
+	"github.com/mattkanwisher/cryptofiend/currency/pair"
 	"github.com/mattkanwisher/cryptofiend/portfolio"
 	"github.com/mattkanwisher/cryptofiend/smsglobal"
 )
@@ -46,7 +48,13 @@ var (
 	WarningWebserverListenAddressInvalid            = "WARNING -- Webserver support disabled due to invalid listen address."
 	WarningWebserverRootWebFolderNotFound           = "WARNING -- Webserver support disabled due to missing web folder."
 	WarningExchangeAuthAPIDefaultOrEmptyValues      = "WARNING -- Exchange %s: Authenticated API support disabled due to default/empty APIKey/Secret/ClientID values."
-	WarningCurrencyExchangeProvider                 = "WARNING -- Currency exchange provider invalid valid. Reset to Fixer."
+	WarningCurrencyRateProviderInvalid              = "WARNING -- Currency rate provider %s invalid: %s. Provider removed."
+	WarningNotifierInvalid                          = "WARNING -- Notification channel %q disabled due to invalid or missing values."
+	WarningRebalanceWeightsInvalid                  = "WARNING -- Rebalance target weights add up to %f instead of 100. Rebalancing disabled."
+	WarningTokenInvalid                             = "WARNING -- Token %s contract %q invalid. Token removed."
+	WarningBalanceProviderInvalid                   = "WARNING -- %s balance provider %s invalid: %s. Provider removed."
+	WarningLedgerMethodInvalid                      = "WARNING -- Ledger cost basis method %q invalid. Reset to FIFO."
+	WarningWebhookInvalid                           = "WARNING -- Webhook #%d disabled due to missing URL or secret."
 	RenamingConfigFile                              = "Renaming config file %s to %s."
 	Cfg                                             Config
 )
@@ -61,6 +69,167 @@ type WebserverConfig struct {
 	WebsocketAllowInsecureOrigin bool
 }
 
+// ArbitrageConfig holds the settings of the arbitrage scanner. ScanDelay is in
+// seconds and WithdrawalFees maps a currency code to the amount of it charged
+// to move it between exchanges. Triangular enables the search for cycles
+// within each exchange, which are traded if ExecuteTriangular is set.
+// Orderbooks older than TriangularMaxBookAge seconds are left out of the
+// search and a cycle isn't traded again within TriangularCooldown seconds.
+type ArbitrageConfig struct {
+	Enabled              bool
+	ScanDelay            time.Duration
+	MinProfitPercent     float64
+	WithdrawalFees       map[string]float64 `json:",omitempty"`
+	Triangular           bool
+	ExecuteTriangular    bool
+	TriangularMaxBookAge time.Duration
+	TriangularCooldown   time.Duration
+}
+
+// EventsConfig holds the settings of the events system and the saved events.
+// Trading actions only log the orders they would place when DryRun is set, and
+// MaxOrderAmounts maps a currency code to the largest amount of it a single
+// order may trade.
+type EventsConfig struct {
+	DryRun          bool
+	MaxOrderAmounts map[string]float64 `json:",omitempty"`
+	Events          []EventConfig      `json:",omitempty"`
+}
+
+// EventRuleConfig is a saved condition of an event
+type EventRuleConfig struct {
+	Exchange        string
+	Item            string
+	Condition       string
+	Pair            pair.CurrencyPair
+	Asset           string
+	CompareExchange string `json:",omitempty"`
+}
+
+// EventConfig is a saved event. Cooldown is in seconds.
+type EventConfig struct {
+	ID int
+	EventRuleConfig
+	Rules         []EventRuleConfig `json:",omitempty"`
+	Logic         string            `json:",omitempty"`
+	Action        string
+	Enabled       bool
+	Repeat        bool
+	Cooldown      time.Duration
+	Executed      bool
+	LastTriggered time.Time
+}
+
+// NotificationsConfig holds the notification channels and the names of the
+// channels each type of alert is sent to. Alerts of a type missing from Alerts
+// are sent to every channel.
+type NotificationsConfig struct {
+	Channels []NotifierConfig
+	Alerts   map[string][]string `json:",omitempty"`
+}
+
+// NotifierConfig holds the settings of a notification channel. Type is one of
+// SMS, SMTP, WEBHOOK, SLACK or TELEGRAM. RateLimit is the minimum number of
+// seconds between messages sent over the channel and MaxRetries the number of
+// times a message that fails is resent. To holds the SMS contact names or
+// email addresses messages are sent to, or the chat ID for TELEGRAM.
+type NotifierConfig struct {
+	Name       string
+	Type       string
+	Enabled    bool
+	RateLimit  time.Duration
+	MaxRetries int
+	To         []string `json:",omitempty"`
+	Host       string   `json:",omitempty"`
+	Port       int      `json:",omitempty"`
+	Username   string   `json:",omitempty"`
+	Password   string   `json:",omitempty"`
+	From       string   `json:",omitempty"`
+	URL        string   `json:",omitempty"`
+}
+
+// BalanceProvidersConfig holds the providers queried for the balances of the
+// portfolio addresses. Coins maps a coin to its providers, which are tried in
+// order until one succeeds, and balances are cached for CacheDuration
+// seconds. The addresses of HD wallets are derived until GapLimit addresses
+// in a row are unused. The balances of the ERC-20 Tokens held by Ethereum
+// addresses are looked up with the JSON-RPC endpoint at EthereumRPC.
+type BalanceProvidersConfig struct {
+	CacheDuration time.Duration
+	GapLimit      int
+	Coins         map[string][]portfolio.ProviderConfig `json:",omitempty"`
+	EthereumRPC   string                                `json:",omitempty"`
+	Tokens        []portfolio.TokenConfig               `json:",omitempty"`
+}
+
+// CurrencyRatesConfig holds the providers fiat currency rates are fetched
+// from, tried in order of priority until one succeeds. The rates are refreshed
+// every RefreshInterval seconds and reported as stale when they haven't been
+// refreshed for StaleAfter seconds.
+type CurrencyRatesConfig struct {
+	Providers       []currency.ProviderConfig
+	RefreshInterval time.Duration
+	StaleAfter      time.Duration
+}
+
+// PortfolioHistoryConfig holds the settings of the portfolio history. A
+// snapshot of the portfolio valued in the fiat display currency is taken every
+// Interval seconds and appended to File. Snapshots older than MaxAge days are
+// dropped, or none if it is zero.
+type PortfolioHistoryConfig struct {
+	Enabled  bool
+	Interval time.Duration
+	File     string
+	MaxAge   int
+}
+
+// RebalanceConfig holds the target allocations of the portfolio. Targets maps
+// a coin to the percentage of the value of the targeted coins it should make
+// up, and Tolerance is how many percentage points it may drift from it before
+// the portfolio is rebalanced, which Tolerances overrides for single coins.
+// A plan is worked out every Interval seconds and only logged unless Execute
+// is set.
+type RebalanceConfig struct {
+	Enabled    bool
+	Targets    map[string]float64
+	Tolerance  float64
+	Tolerances map[string]float64 `json:",omitempty"`
+	Interval   time.Duration
+	Execute    bool
+}
+
+// LedgerConfig holds the settings of the cost basis ledger. Method is FIFO,
+// LIFO or AVERAGE and Currency is the fiat currency assets are valued in, the
+// fiat display currency if it isn't set. Recorded trades and transfers are
+// appended to File.
+type LedgerConfig struct {
+	Enabled  bool
+	Method   string
+	Currency string `json:",omitempty"`
+	File     string
+}
+
+// WebhooksConfig holds the endpoints bot activity is posted to. Failed
+// deliveries are first retried after RetryDelay seconds, doubling after each
+// attempt, and appended to DeadLetterFile once MaxRetries are exhausted.
+type WebhooksConfig struct {
+	Enabled        bool
+	MaxRetries     int
+	RetryDelay     time.Duration
+	DeadLetterFile string
+	Endpoints      []WebhookConfig
+}
+
+// WebhookConfig holds an endpoint activity is posted to and the secret its
+// bodies are signed with. Only the activity types in Events are posted, or all
+// of them if it is empty.
+type WebhookConfig struct {
+	URL     string
+	Secret  string
+	Events  []string `json:",omitempty"`
+	Enabled bool
+}
+
 // SMSGlobalConfig structure holds all the variables you need for instant
 // messaging and broadcast used by SMSGlobal
 type SMSGlobalConfig struct {
@@ -86,16 +255,24 @@ type CurrencyPairFormatConfig struct {
 // Config is the overarching object that holds all the information for
 // prestart management of portfolio, SMSGlobal, webserver and enabled exchange
 type Config struct {
-	Name                     string
-	EncryptConfig            int
-	Cryptocurrencies         string
-	CurrencyExchangeProvider string
-	CurrencyPairFormat       *CurrencyPairFormatConfig `json:"CurrencyPairFormat"`
-	FiatDisplayCurrency      string
-	Portfolio                portfolio.Base   `json:"PortfolioAddresses"`
-	SMS                      SMSGlobalConfig  `json:"SMSGlobal"`
-	Webserver                WebserverConfig  `json:"Webserver"`
-	Exchanges                []ExchangeConfig `json:"Exchanges"`
+	Name                string
+	EncryptConfig       int
+	Cryptocurrencies    string
+	CurrencyRates       CurrencyRatesConfig       `json:"CurrencyRates"`
+	CurrencyPairFormat  *CurrencyPairFormatConfig `json:"CurrencyPairFormat"`
+	FiatDisplayCurrency string
+	Portfolio           portfolio.Base         `json:"PortfolioAddresses"`
+	BalanceProviders    BalanceProvidersConfig `json:"BalanceProviders"`
+	PortfolioHistory    PortfolioHistoryConfig `json:"PortfolioHistory"`
+	Ledger              LedgerConfig           `json:"Ledger"`
+	Rebalance           RebalanceConfig        `json:"Rebalance"`
+	SMS                 SMSGlobalConfig        `json:"SMSGlobal"`
+	Webserver           WebserverConfig        `json:"Webserver"`
+	Arbitrage           ArbitrageConfig        `json:"Arbitrage"`
+	Events              EventsConfig           `json:"Events"`
+	Notifications       NotificationsConfig    `json:"Notifications"`
+	Webhooks            WebhooksConfig         `json:"Webhooks"`
+	Exchanges           []ExchangeConfig       `json:"Exchanges"`
 }
 
 // ExchangeConfig holds all the information needed for each enabled Exchange.
@@ -176,6 +353,189 @@ func (c *Config) CheckSMSGlobalConfigValues() error {
 	return nil
 }
 
+// CheckNotificationsConfigValues disables notification channels which are
+// missing the values their type needs
+func (c *Config) CheckNotificationsConfigValues() {
+	for i := range c.Notifications.Channels {
+		n := &c.Notifications.Channels[i]
+		if !n.Enabled {
+			continue
+		}
+
+		n.Type = common.StringToUpper(n.Type)
+		valid := n.Name != "" && n.RateLimit >= 0 && n.MaxRetries >= 0
+		switch n.Type {
+		case "SMS":
+		case "SMTP":
+			valid = valid && n.Host != "" && n.Port > 0 && n.From != "" && len(n.To) > 0
+		case "WEBHOOK", "SLACK":
+			valid = valid && n.URL != ""
+		case "TELEGRAM":
+			valid = valid && n.URL != "" && len(n.To) > 0
+		default:
+			valid = false
+		}
+
+		if !valid {
+			log.Printf(WarningNotifierInvalid, n.Name)
+			n.Enabled = false
+		}
+	}
+}
+
+// CheckBalanceProvidersConfigValues sets the default balance provider values
+// and removes invalid providers
+func (c *Config) CheckBalanceProvidersConfigValues() {
+	if c.BalanceProviders.CacheDuration <= 0 {
+		c.BalanceProviders.CacheDuration = 60
+	}
+	if c.BalanceProviders.GapLimit <= 0 {
+		c.BalanceProviders.GapLimit = 20
+	}
+
+	coins := make(map[string][]portfolio.ProviderConfig)
+	for coin, providers := range c.BalanceProviders.Coins {
+		coin = common.StringToUpper(coin)
+		for _, x := range providers {
+			_, err := portfolio.NewProvider(x)
+			if err != nil {
+				log.Printf(WarningBalanceProviderInvalid, coin, x.Type, err)
+				continue
+			}
+			coins[coin] = append(coins[coin], x)
+		}
+	}
+	c.BalanceProviders.Coins = coins
+
+	var tokens []portfolio.TokenConfig
+	for _, x := range c.BalanceProviders.Tokens {
+		x.Symbol = common.StringToUpper(x.Symbol)
+		_, err := hex.DecodeString(strings.TrimPrefix(x.Contract, "0x"))
+		if x.Symbol == "" || len(x.Contract) != 42 || !strings.HasPrefix(x.Contract, "0x") || err != nil {
+			log.Printf(WarningTokenInvalid, x.Symbol, x.Contract)
+			continue
+		}
+		tokens = append(tokens, x)
+	}
+	c.BalanceProviders.Tokens = tokens
+	if len(tokens) > 0 && c.BalanceProviders.EthereumRPC == "" {
+		c.BalanceProviders.EthereumRPC = "http://127.0.0.1:8545"
+	}
+}
+
+// CheckCurrencyRatesConfigValues sets the default currency rate values and
+// removes invalid providers, falling back to the ECB reference rates
+func (c *Config) CheckCurrencyRatesConfigValues() {
+	if c.CurrencyRates.RefreshInterval <= 0 {
+		c.CurrencyRates.RefreshInterval = 3600
+	}
+	if c.CurrencyRates.StaleAfter <= 0 {
+		c.CurrencyRates.StaleAfter = 86400
+	}
+
+	var providers []currency.ProviderConfig
+	for _, x := range c.CurrencyRates.Providers {
+		_, err := currency.NewProvider(x)
+		if err != nil {
+			log.Printf(WarningCurrencyRateProviderInvalid, x.Name, err)
+			continue
+		}
+		x.Name = common.StringToLower(x.Name)
+		providers = append(providers, x)
+	}
+	if len(providers) == 0 {
+		providers = append(providers, currency.ProviderConfig{Name: currency.ProviderECB, Priority: 1})
+	}
+	c.CurrencyRates.Providers = providers
+}
+
+// CheckPortfolioHistoryConfigValues sets the default portfolio history values
+func (c *Config) CheckPortfolioHistoryConfigValues() {
+	if !c.PortfolioHistory.Enabled {
+		return
+	}
+
+	if c.PortfolioHistory.Interval <= 0 {
+		c.PortfolioHistory.Interval = 3600
+	}
+	if c.PortfolioHistory.File == "" {
+		c.PortfolioHistory.File = "portfolio_history.dat"
+	}
+	if c.PortfolioHistory.MaxAge < 0 {
+		c.PortfolioHistory.MaxAge = 0
+	}
+}
+
+// CheckRebalanceConfigValues sets the default rebalancer values and disables
+// it if the target weights don't add up to 100
+func (c *Config) CheckRebalanceConfigValues() {
+	if c.Rebalance.Tolerance <= 0 {
+		c.Rebalance.Tolerance = 5
+	}
+	if c.Rebalance.Interval <= 0 {
+		c.Rebalance.Interval = 86400
+	}
+	if !c.Rebalance.Enabled {
+		return
+	}
+
+	targets := make(map[string]float64)
+	var weights float64
+	for coin, weight := range c.Rebalance.Targets {
+		targets[common.StringToUpper(coin)] = weight
+		weights += weight
+	}
+	c.Rebalance.Targets = targets
+	if weights < 99.99 || weights > 100.01 {
+		log.Printf(WarningRebalanceWeightsInvalid, weights)
+		c.Rebalance.Enabled = false
+	}
+}
+
+// CheckLedgerConfigValues sets the default ledger values
+func (c *Config) CheckLedgerConfigValues() {
+	if !c.Ledger.Enabled {
+		return
+	}
+
+	c.Ledger.Method = common.StringToUpper(c.Ledger.Method)
+	switch c.Ledger.Method {
+	case "FIFO", "LIFO", "AVERAGE":
+	default:
+		log.Printf(WarningLedgerMethodInvalid, c.Ledger.Method)
+		c.Ledger.Method = "FIFO"
+	}
+	if c.Ledger.File == "" {
+		c.Ledger.File = "ledger.dat"
+	}
+}
+
+// CheckWebhooksConfigValues sets default webhook retry values and disables
+// endpoints missing a URL or secret
+func (c *Config) CheckWebhooksConfigValues() {
+	if !c.Webhooks.Enabled {
+		return
+	}
+
+	if c.Webhooks.MaxRetries < 0 {
+		c.Webhooks.MaxRetries = 0
+	}
+	if c.Webhooks.RetryDelay <= 0 {
+		c.Webhooks.RetryDelay = 5
+	}
+	if c.Webhooks.DeadLetterFile == "" {
+		c.Webhooks.DeadLetterFile = "webhooks_deadletter.log"
+	}
+
+	for i := range c.Webhooks.Endpoints {
+		x := &c.Webhooks.Endpoints[i]
+		if x.Enabled && (x.URL == "" || x.Secret == "") {
+			log.Printf(WarningWebhookInvalid, i)
+			x.Enabled = false
+		}
+	}
+}
+
 // CheckExchangeConfigValues returns configuation values for all enabled
 // exchanges
 func (c *Config) CheckExchangeConfigValues() error {
@@ -248,6 +608,26 @@ func (c *Config) CheckWebserverConfigValues() error {
 	return nil
 }
 
+// CheckArbitrageConfigValues sets defaults for missing arbitrage scanner
+// values
+func (c *Config) CheckArbitrageConfigValues() {
+	if c.Arbitrage.ScanDelay <= 0 {
+		c.Arbitrage.ScanDelay = 10
+	}
+
+	if c.Arbitrage.MinProfitPercent < 0 {
+		c.Arbitrage.MinProfitPercent = 0
+	}
+
+	if c.Arbitrage.TriangularMaxBookAge <= 0 {
+		c.Arbitrage.TriangularMaxBookAge = 30
+	}
+
+	if c.Arbitrage.TriangularCooldown <= 0 {
+		c.Arbitrage.TriangularCooldown = 300
+	}
+}
+
 // RetrieveConfigCurrencyPairs splits, assigns and verifies enabled currency
 // pairs either cryptoCurrencies or fiatCurrencies
 func (c *Config) RetrieveConfigCurrencyPairs() error {
@@ -437,14 +817,15 @@ func (c *Config) LoadConfig(configPath string) error {
 		}
 	}
 
-	if c.CurrencyExchangeProvider == "" {
-		c.CurrencyExchangeProvider = "fixer"
-	} else {
-		if c.CurrencyExchangeProvider != "yahoo" && c.CurrencyExchangeProvider != "fixer" {
-			log.Println(WarningCurrencyExchangeProvider)
-			c.CurrencyExchangeProvider = "fixer"
-		}
-	}
+	c.CheckArbitrageConfigValues()
+	c.CheckNotificationsConfigValues()
+	c.CheckWebhooksConfigValues()
+	c.CheckBalanceProvidersConfigValues()
+	c.CheckPortfolioHistoryConfigValues()
+	c.CheckLedgerConfigValues()
+	c.CheckRebalanceConfigValues()
+
+	c.CheckCurrencyRatesConfigValues()
 
 	if c.CurrencyPairFormat == nil {
 		c.CurrencyPairFormat = &CurrencyPairFormatConfig{
@@ -476,7 +857,14 @@ func (c *Config) UpdateConfig(configPath string, newCfg Config) error {
 		c.CurrencyPairFormat = newCfg.CurrencyPairFormat
 	}
 
+	c.CurrencyRates = newCfg.CurrencyRates
 	c.Portfolio = newCfg.Portfolio
+	c.BalanceProviders = newCfg.BalanceProviders
+	c.PortfolioHistory = newCfg.PortfolioHistory
+	c.Ledger = newCfg.Ledger
+
+	newCfg.CheckRebalanceConfigValues()
+	c.Rebalance = newCfg.Rebalance
 
 	err = newCfg.CheckSMSGlobalConfigValues()
 	if err != nil {
@@ -484,6 +872,12 @@ func (c *Config) UpdateConfig(configPath string, newCfg Config) error {
 	}
 	c.SMS = newCfg.SMS
 
+	newCfg.CheckArbitrageConfigValues()
+	c.Arbitrage = newCfg.Arbitrage
+	c.Events = newCfg.Events
+	c.Notifications = newCfg.Notifications
+	c.Webhooks = newCfg.Webhooks
+
 	err = c.SaveConfig(configPath)
 	if err != nil {
 		return err
@@ -2,6 +2,9 @@ package config
 
 import (
 	"testing"
+
+	"github.com/mattkanwisher/cryptofiend/currency"
+	"github.com/mattkanwisher/cryptofiend/portfolio"
 )
 
 func TestGetConfigEnabledExchanges(t *testing.T) {
@@ -262,6 +265,130 @@ func TestCheckWebserverConfigValues(t *testing.T) {
 	}
 }
 
+func TestCheckNotificationsConfigValues(t *testing.T) {
+	c := Config{}
+	c.Notifications.Channels = []NotifierConfig{
+		{Name: "Email", Type: "smtp", Enabled: true, Host: "localhost", Port: 25, From: "a@example.com", To: []string{"b@example.com"}},
+		{Name: "Email2", Type: "SMTP", Enabled: true, Host: "localhost"},
+		{Name: "Slack", Type: "SLACK", Enabled: true},
+		{Name: "Fax", Type: "FAX", Enabled: true},
+		{Name: "SMS", Type: "SMS", Enabled: true},
+	}
+	c.CheckNotificationsConfigValues()
+
+	expected := []bool{true, false, false, false, true}
+	for i, x := range c.Notifications.Channels {
+		if x.Enabled != expected[i] {
+			t.Errorf("Test failed. TestCheckNotificationsConfigValues %s: Incorrect values", x.Name)
+		}
+	}
+	if c.Notifications.Channels[0].Type != "SMTP" {
+		t.Error("Test failed. TestCheckNotificationsConfigValues: type not normalised")
+	}
+}
+
+func TestCheckWebhooksConfigValues(t *testing.T) {
+	c := Config{}
+	c.Webhooks = WebhooksConfig{
+		Enabled:    true,
+		MaxRetries: -1,
+		Endpoints: []WebhookConfig{
+			{URL: "https://example.com", Secret: "secret", Enabled: true},
+			{URL: "https://example.com", Enabled: true},
+		},
+	}
+	c.CheckWebhooksConfigValues()
+
+	if c.Webhooks.MaxRetries != 0 || c.Webhooks.RetryDelay != 5 || c.Webhooks.DeadLetterFile == "" {
+		t.Error("Test failed. TestCheckWebhooksConfigValues: defaults not set")
+	}
+	if !c.Webhooks.Endpoints[0].Enabled || c.Webhooks.Endpoints[1].Enabled {
+		t.Error("Test failed. TestCheckWebhooksConfigValues: Incorrect values")
+	}
+}
+
+func TestCheckLedgerConfigValues(t *testing.T) {
+	c := Config{}
+	c.Ledger = LedgerConfig{Enabled: true, Method: "hifo"}
+	c.CheckLedgerConfigValues()
+	if c.Ledger.Method != "FIFO" || c.Ledger.File != "ledger.dat" {
+		t.Error("Test failed. TestCheckLedgerConfigValues: defaults not set")
+	}
+
+	c.Ledger.Method = "lifo"
+	c.CheckLedgerConfigValues()
+	if c.Ledger.Method != "LIFO" {
+		t.Error("Test failed. TestCheckLedgerConfigValues: Incorrect values")
+	}
+}
+
+func TestCheckRebalanceConfigValues(t *testing.T) {
+	c := Config{}
+	c.Rebalance = RebalanceConfig{Enabled: true, Targets: map[string]float64{"btc": 60, "ETH": 40}}
+	c.CheckRebalanceConfigValues()
+	if !c.Rebalance.Enabled || c.Rebalance.Targets["BTC"] != 60 || c.Rebalance.Tolerance != 5 ||
+		c.Rebalance.Interval != 86400 {
+		t.Error("Test failed. TestCheckRebalanceConfigValues: defaults not set")
+	}
+
+	c.Rebalance.Targets["LTC"] = 10
+	c.CheckRebalanceConfigValues()
+	if c.Rebalance.Enabled {
+		t.Error("Test failed. TestCheckRebalanceConfigValues: enabled with invalid weights")
+	}
+}
+
+func TestCheckBalanceProvidersConfigValues(t *testing.T) {
+	c := Config{}
+	c.BalanceProviders.Coins = map[string][]portfolio.ProviderConfig{
+		"btc": {
+			{Type: "bitcoind", URL: "http://127.0.0.1:8332"},
+			{Type: "bitcoind"},
+			{Type: "blockchain"},
+			{Type: "cryptoid"},
+		},
+	}
+	c.BalanceProviders.Tokens = []portfolio.TokenConfig{
+		{Symbol: "omg", Contract: "0xd26114cd6EE289AccF82350c8d8487fedB8A0C07"},
+		{Symbol: "BAD", Contract: "0xd26114cd6EE289AccF82350c8d8487fedB8A0C0"},
+		{Symbol: "BAD", Contract: "0xz26114cd6EE289AccF82350c8d8487fedB8A0C07"},
+	}
+	c.CheckBalanceProvidersConfigValues()
+
+	if c.BalanceProviders.CacheDuration != 60 || c.BalanceProviders.GapLimit != 20 {
+		t.Error("Test failed. TestCheckBalanceProvidersConfigValues: defaults not set")
+	}
+	if len(c.BalanceProviders.Coins["BTC"]) != 2 || c.BalanceProviders.Coins["BTC"][1].Type != "cryptoid" {
+		t.Errorf("Test failed. TestCheckBalanceProvidersConfigValues: unexpected providers %v",
+			c.BalanceProviders.Coins)
+	}
+	if len(c.BalanceProviders.Tokens) != 1 || c.BalanceProviders.Tokens[0].Symbol != "OMG" ||
+		c.BalanceProviders.EthereumRPC == "" {
+		t.Errorf("Test failed. TestCheckBalanceProvidersConfigValues: unexpected tokens %v",
+			c.BalanceProviders.Tokens)
+	}
+}
+
+func TestCheckCurrencyRatesConfigValues(t *testing.T) {
+	c := Config{}
+	c.CheckCurrencyRatesConfigValues()
+	if c.CurrencyRates.RefreshInterval != 3600 || c.CurrencyRates.StaleAfter != 86400 ||
+		len(c.CurrencyRates.Providers) != 1 || c.CurrencyRates.Providers[0].Name != currency.ProviderECB {
+		t.Errorf("Test failed. TestCheckCurrencyRatesConfigValues: unexpected defaults %v", c.CurrencyRates)
+	}
+
+	c.CurrencyRates.Providers = []currency.ProviderConfig{
+		{Name: "Fixer", APIKey: "key", Priority: 1},
+		{Name: "openexchangerates", Priority: 2},
+		{Name: "yahoo", Priority: 3},
+	}
+	c.CheckCurrencyRatesConfigValues()
+	if len(c.CurrencyRates.Providers) != 1 || c.CurrencyRates.Providers[0].Name != currency.ProviderFixer {
+		t.Errorf("Test failed. TestCheckCurrencyRatesConfigValues: unexpected providers %v",
+			c.CurrencyRates.Providers)
+	}
+}
+
 func TestRetrieveConfigCurrencyPairs(t *testing.T) {
 	retrieveConfigCurrencyPairs := GetConfig()
 	err := retrieveConfigCurrencyPairs.LoadConfig(ConfigTestFile)
@@ -335,3 +462,25 @@ func TestGetFilePath(t *testing.T) {
 		t.Errorf("Test failed. TestGetFilePath: expected %s got %s", expected, result)
 	}
 }
+
+func TestUpdateConfig(t *testing.T) {
+	c := Config{}
+	err := c.LoadConfig(ConfigTestFile)
+	if err != nil {
+		t.Fatalf("Test failed. TestUpdateConfig.LoadConfig: %s", err)
+	}
+
+	newCfg := c
+	newCfg.Arbitrage.ScanDelay = 0
+	newCfg.Rebalance.Interval = 0
+	err = c.UpdateConfig(ConfigTestFile, newCfg)
+	if err != nil {
+		t.Fatalf("Test failed. TestUpdateConfig: %s", err)
+	}
+	if c.Arbitrage.ScanDelay != 10 {
+		t.Errorf("Test failed. TestUpdateConfig: arbitrage scan delay %d saved", c.Arbitrage.ScanDelay)
+	}
+	if c.Rebalance.Interval != 86400 {
+		t.Errorf("Test failed. TestUpdateConfig: rebalance interval %d saved", c.Rebalance.Interval)
+	}
+}
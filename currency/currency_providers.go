@@ -0,0 +1,433 @@
+package currency
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/mattkanwisher/cryptofiend/common"
+)
+
+// Supported currency rate providers
+const (
+	ProviderECB               = "ecb"
+	ProviderOpenExchangeRates = "openexchangerates"
+	ProviderFixer             = "fixer"
+
+	ecbAPIURL               = "https://www.ecb.europa.eu/stats/eurofxref/eurofxref-daily.xml"
+	openExchangeRatesAPIURL = "https://openexchangerates.org/api/latest.json"
+	fixerAPIURL             = "http://data.fixer.io/api/latest"
+
+	// DefaultRefreshInterval is how often the currency rates are refreshed
+	DefaultRefreshInterval = time.Hour
+	// DefaultStaleAfter is how long the currency rates can go without a
+	// refresh before they're reported as stale
+	DefaultStaleAfter = time.Hour * 24
+
+	// rateRetryDelay is how long failing rate providers are left before the
+	// rates are refreshed again
+	rateRetryDelay = time.Minute
+)
+
+var errAPIKeyRequired = errors.New("API key required")
+
+// RateProvider fetches the exchange rates of fiat currencies, as the amount of
+// each currency per unit of a base currency
+type RateProvider interface {
+	GetName() string
+	GetRates() (base string, rates map[string]float64, err error)
+}
+
+// ProviderConfig sets up a currency rate provider. Providers are tried in
+// order of Priority, lowest first. URL overrides the API endpoint of the
+// provider.
+type ProviderConfig struct {
+	Name     string
+	APIKey   string `json:",omitempty"`
+	URL      string `json:",omitempty"`
+	Priority int
+}
+
+// StaleRatesError is returned along with a converted amount when the rates it
+// was converted with haven't been refreshed for longer than the stale
+// threshold
+type StaleRatesError struct {
+	Provider string
+	Updated  time.Time
+}
+
+func (e *StaleRatesError) Error() string {
+	return fmt.Sprintf("currency rates from %s are stale, last updated %s", e.Provider,
+		e.Updated.Format(time.RFC3339))
+}
+
+// IsStale returns whether err reports an amount converted with stale rates,
+// which can still be used with care
+func IsStale(err error) bool {
+	_, ok := err.(*StaleRatesError)
+	return ok
+}
+
+// rates is the store of the currency rates, as the amount of each currency per
+// USD, and the providers they are fetched from. fetch serialises refreshes so
+// that the rate providers are only queried once when the rates expire.
+var rates struct {
+	m          sync.RWMutex
+	fetch      sync.Mutex
+	providers  []RateProvider
+	ttl        time.Duration
+	staleAfter time.Duration
+	store      map[string]float64
+	provider   string
+	updated    time.Time
+	attempted  time.Time
+}
+
+func init() {
+	SetProviders([]RateProvider{&ECBProvider{}}, DefaultRefreshInterval, DefaultStaleAfter)
+}
+
+// NewProvider returns the currency rate provider set up by config
+func NewProvider(config ProviderConfig) (RateProvider, error) {
+	switch common.StringToLower(config.Name) {
+	case ProviderECB:
+		return &ECBProvider{URL: config.URL}, nil
+	case ProviderOpenExchangeRates:
+		if config.APIKey == "" {
+			return nil, errAPIKeyRequired
+		}
+		return &OpenExchangeRatesProvider{AppID: config.APIKey, URL: config.URL}, nil
+	case ProviderFixer:
+		if config.APIKey == "" {
+			return nil, errAPIKeyRequired
+		}
+		return &FixerProvider{AccessKey: config.APIKey, URL: config.URL}, nil
+	}
+	return nil, fmt.Errorf("unsupported currency rate provider %q", config.Name)
+}
+
+// SetupProviders sets up the currency rate providers in order of priority.
+// The rates are refreshed every ttl and reported as stale after staleAfter
+// without a refresh, which disables the check when zero.
+func SetupProviders(configs []ProviderConfig, ttl, staleAfter time.Duration) error {
+	sorted := append([]ProviderConfig(nil), configs...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Priority < sorted[j].Priority
+	})
+
+	var providers []RateProvider
+	for _, x := range sorted {
+		p, err := NewProvider(x)
+		if err != nil {
+			return fmt.Errorf("%s: %s", x.Name, err)
+		}
+		providers = append(providers, p)
+	}
+	SetProviders(providers, ttl, staleAfter)
+	return nil
+}
+
+// SetProviders replaces the currency rate providers, which are tried in order,
+// and clears the rates fetched from the previous ones
+func SetProviders(providers []RateProvider, ttl, staleAfter time.Duration) {
+	rates.m.Lock()
+	defer rates.m.Unlock()
+
+	if ttl <= 0 {
+		ttl = DefaultRefreshInterval
+	}
+	rates.providers = providers
+	rates.ttl = ttl
+	rates.staleAfter = staleAfter
+	rates.store = nil
+	rates.provider = ""
+	rates.updated = time.Time{}
+	rates.attempted = time.Time{}
+}
+
+// GetProvider returns the name of the currency rate provider the current
+// rates were fetched from
+func GetProvider() string {
+	rates.m.RLock()
+	defer rates.m.RUnlock()
+
+	return rates.provider
+}
+
+// Rates is a copy of the currency rates, as the amount of each currency per
+// USD
+type Rates struct {
+	Provider string             `json:"provider"`
+	Rates    map[string]float64 `json:"rates"`
+	Updated  time.Time          `json:"updated"`
+	Stale    bool               `json:"stale"`
+}
+
+// GetRates returns a copy of the current currency rates
+func GetRates() Rates {
+	rates.m.RLock()
+	defer rates.m.RUnlock()
+
+	result := Rates{
+		Provider: rates.provider,
+		Rates:    make(map[string]float64),
+		Updated:  rates.updated,
+		Stale:    rates.staleAfter > 0 && time.Since(rates.updated) > rates.staleAfter,
+	}
+	for x, y := range rates.store {
+		result.Rates[x] = y
+	}
+	return result
+}
+
+// refreshRates fetches the rates from the first rate provider which succeeds,
+// once they're older than the refresh interval unless force is set. Failing
+// providers aren't retried for rateRetryDelay, the previous rates are kept
+// until a refresh succeeds.
+func refreshRates(force bool) error {
+	rates.fetch.Lock()
+	defer rates.fetch.Unlock()
+
+	rates.m.RLock()
+	due := time.Since(rates.updated) > rates.ttl && time.Since(rates.attempted) > rateRetryDelay
+	providers := rates.providers
+	rates.m.RUnlock()
+	if !force && !due {
+		return nil
+	}
+	if len(providers) == 0 {
+		return ErrNoRateProviders
+	}
+
+	var lastErr error
+	for _, p := range providers {
+		base, result, err := p.GetRates()
+		if err == nil {
+			result, err = normaliseRates(base, result)
+		}
+		if err != nil {
+			log.Printf("Failed to get currency rates from %s. Error: %s\n", p.GetName(), err)
+			lastErr = err
+			continue
+		}
+
+		rates.m.Lock()
+		rates.store = result
+		rates.provider = p.GetName()
+		rates.updated = time.Now()
+		rates.attempted = rates.updated
+		rates.m.Unlock()
+		return nil
+	}
+
+	rates.m.Lock()
+	rates.attempted = time.Now()
+	rates.m.Unlock()
+	return fmt.Errorf("all currency rate providers failed, last error: %s", lastErr)
+}
+
+// StartRatesWatcher refreshes the currency rates in the background once they
+// are older than the refresh interval, failed refreshes are retried after
+// rateRetryDelay
+func StartRatesWatcher() {
+	log.Println("Currency rates watcher started.")
+	for range time.NewTicker(rateRetryDelay).C {
+		err := refreshRates(false)
+		if err != nil {
+			log.Printf("Failed to refresh currency rates. Error: %s\n", err)
+		}
+	}
+}
+
+// normaliseRates converts rates against a base currency into the amount of
+// each currency per USD
+func normaliseRates(base string, input map[string]float64) (map[string]float64, error) {
+	base = common.StringToUpper(base)
+	usd := float64(1)
+	if base != "USD" {
+		usd = input["USD"]
+		if usd <= 0 {
+			return nil, fmt.Errorf("no USD rate against %s", base)
+		}
+	}
+
+	result := map[string]float64{"USD": 1}
+	if base != "USD" {
+		result[base] = 1 / usd
+	}
+	for x, y := range input {
+		if y > 0 {
+			result[common.StringToUpper(x)] = y / usd
+		}
+	}
+	return result, nil
+}
+
+// ECBProvider fetches the euro foreign exchange reference rates published
+// daily by the European Central Bank, which need no API key
+type ECBProvider struct {
+	URL string
+}
+
+// ecbEnvelope is the XML document of the ECB reference rates
+type ecbEnvelope struct {
+	Cube struct {
+		Cube []struct {
+			Time string `xml:"time,attr"`
+			Cube []struct {
+				Currency string  `xml:"currency,attr"`
+				Rate     float64 `xml:"rate,attr"`
+			} `xml:"Cube"`
+		} `xml:"Cube"`
+	} `xml:"Cube"`
+}
+
+// GetName returns the name of the provider
+func (e *ECBProvider) GetName() string {
+	return ProviderECB
+}
+
+// GetRates returns the reference rates against EUR
+func (e *ECBProvider) GetRates() (string, map[string]float64, error) {
+	path := e.URL
+	if path == "" {
+		path = ecbAPIURL
+	}
+
+	resp, status, err := common.SendHTTPRequest2("GET", path, http.Header{}, nil)
+	if err != nil {
+		return "", nil, err
+	}
+	if status != http.StatusOK {
+		return "", nil, fmt.Errorf("HTTP status code %d", status)
+	}
+
+	var result ecbEnvelope
+	err = xml.Unmarshal([]byte(resp), &result)
+	if err != nil {
+		return "", nil, err
+	}
+	if len(result.Cube.Cube) == 0 || len(result.Cube.Cube[0].Cube) == 0 {
+		return "", nil, errors.New("no reference rates published")
+	}
+
+	output := make(map[string]float64)
+	for _, x := range result.Cube.Cube[0].Cube {
+		output[x.Currency] = x.Rate
+	}
+	return "EUR", output, nil
+}
+
+// OpenExchangeRatesResponse contains the data fields for the
+// openexchangerates API response
+type OpenExchangeRatesResponse struct {
+	Base        string             `json:"base"`
+	Timestamp   int64              `json:"timestamp"`
+	Rates       map[string]float64 `json:"rates"`
+	Error       bool               `json:"error"`
+	Message     string             `json:"message"`
+	Description string             `json:"description"`
+}
+
+// OpenExchangeRatesProvider fetches the latest rates from openexchangerates
+// with an app ID
+type OpenExchangeRatesProvider struct {
+	AppID string
+	URL   string
+}
+
+// GetName returns the name of the provider
+func (o *OpenExchangeRatesProvider) GetName() string {
+	return ProviderOpenExchangeRates
+}
+
+// GetRates returns the latest rates, against USD on the free plan
+func (o *OpenExchangeRatesProvider) GetRates() (string, map[string]float64, error) {
+	path := o.URL
+	if path == "" {
+		path = openExchangeRatesAPIURL
+	}
+	values := url.Values{}
+	values.Set("app_id", o.AppID)
+
+	// The app ID is sent in the URL, so the request mustn't be logged
+	resp, status, err := common.SendHTTPRequestPrivate("GET", common.EncodeURLValues(path, values),
+		http.Header{}, nil)
+	if err != nil {
+		return "", nil, err
+	}
+
+	var result OpenExchangeRatesResponse
+	err = common.JSONDecode([]byte(resp), &result)
+	if err != nil {
+		if status != http.StatusOK {
+			return "", nil, fmt.Errorf("HTTP status code %d", status)
+		}
+		return "", nil, err
+	}
+	if result.Error {
+		return "", nil, fmt.Errorf("%s: %s", result.Message, result.Description)
+	}
+	return result.Base, result.Rates, nil
+}
+
+// FixerResponse contains the data fields for the Fixer API response
+type FixerResponse struct {
+	Success bool               `json:"success"`
+	Base    string             `json:"base"`
+	Date    string             `json:"date"`
+	Rates   map[string]float64 `json:"rates"`
+	Error   struct {
+		Code int    `json:"code"`
+		Type string `json:"type"`
+		Info string `json:"info"`
+	} `json:"error"`
+}
+
+// FixerProvider fetches the latest rates from Fixer with an access key
+type FixerProvider struct {
+	AccessKey string
+	URL       string
+}
+
+// GetName returns the name of the provider
+func (f *FixerProvider) GetName() string {
+	return ProviderFixer
+}
+
+// GetRates returns the latest rates, against EUR on the free plan
+func (f *FixerProvider) GetRates() (string, map[string]float64, error) {
+	path := f.URL
+	if path == "" {
+		path = fixerAPIURL
+	}
+	values := url.Values{}
+	values.Set("access_key", f.AccessKey)
+
+	// Sent privately as the access key is part of the URL
+	resp, status, err := common.SendHTTPRequestPrivate("GET", common.EncodeURLValues(path, values),
+		http.Header{}, nil)
+	if err != nil {
+		return "", nil, err
+	}
+
+	var result FixerResponse
+	err = common.JSONDecode([]byte(resp), &result)
+	if err != nil {
+		if status != http.StatusOK {
+			return "", nil, fmt.Errorf("HTTP status code %d", status)
+		}
+		return "", nil, err
+	}
+	if !result.Success {
+		return "", nil, fmt.Errorf("error %d %s: %s", result.Error.Code, result.Error.Type,
+			result.Error.Info)
+	}
+	return result.Base, result.Rates, nil
+}
@@ -0,0 +1,235 @@
+package currency
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+type testRateProvider struct {
+	name  string
+	base  string
+	rates map[string]float64
+	err   error
+	calls int
+}
+
+func newTestRateProvider() *testRateProvider {
+	return &testRateProvider{
+		name:  "test",
+		base:  "EUR",
+		rates: map[string]float64{"USD": 1.25, "AUD": 1.625, "CNY": 8},
+	}
+}
+
+func (p *testRateProvider) GetName() string {
+	return p.name
+}
+
+func (p *testRateProvider) GetRates() (string, map[string]float64, error) {
+	p.calls++
+	return p.base, p.rates, p.err
+}
+
+func TestNewProvider(t *testing.T) {
+	p, err := NewProvider(ProviderConfig{Name: "ECB"})
+	if err != nil || p.GetName() != ProviderECB {
+		t.Errorf("Test failed. TestNewProvider: unexpected provider %v, %v", p, err)
+	}
+
+	_, err = NewProvider(ProviderConfig{Name: ProviderFixer})
+	if err != errAPIKeyRequired {
+		t.Errorf("Test failed. TestNewProvider: Fixer without key, %v", err)
+	}
+
+	_, err = NewProvider(ProviderConfig{Name: "yahoo"})
+	if err == nil {
+		t.Error("Test failed. TestNewProvider: unsupported provider accepted")
+	}
+}
+
+func TestSetupProviders(t *testing.T) {
+	defer SetProviders([]RateProvider{&ECBProvider{}}, DefaultRefreshInterval, DefaultStaleAfter)
+
+	err := SetupProviders([]ProviderConfig{
+		{Name: ProviderFixer, APIKey: "key", Priority: 2},
+		{Name: ProviderECB, Priority: 3},
+		{Name: ProviderOpenExchangeRates, APIKey: "key", Priority: 1},
+	}, 0, time.Hour)
+	if err != nil {
+		t.Fatalf("Test failed. TestSetupProviders: Error, %s", err)
+	}
+
+	expected := []string{ProviderOpenExchangeRates, ProviderFixer, ProviderECB}
+	if len(rates.providers) != len(expected) || rates.ttl != DefaultRefreshInterval {
+		t.Fatalf("Test failed. TestSetupProviders: unexpected providers %v", rates.providers)
+	}
+	for i := range expected {
+		if rates.providers[i].GetName() != expected[i] {
+			t.Errorf("Test failed. TestSetupProviders: provider %d is %s", i, rates.providers[i].GetName())
+		}
+	}
+
+	err = SetupProviders([]ProviderConfig{{Name: ProviderOpenExchangeRates}}, time.Hour, 0)
+	if err == nil {
+		t.Error("Test failed. TestSetupProviders: invalid provider accepted")
+	}
+}
+
+func TestRefreshRatesFailover(t *testing.T) {
+	defer SetProviders([]RateProvider{&ECBProvider{}}, DefaultRefreshInterval, DefaultStaleAfter)
+
+	failing := &testRateProvider{name: "failing", err: errors.New("unavailable")}
+	noUSD := &testRateProvider{name: "nousd", base: "EUR", rates: map[string]float64{"AUD": 1.6}}
+	working := newTestRateProvider()
+	SetProviders([]RateProvider{failing, noUSD, working}, time.Hour, 0)
+
+	if _, err := ConvertCurrency(1, "EUR", "USD"); err != ErrCurrencyDataNotFetched {
+		t.Errorf("Test failed. TestRefreshRatesFailover: converted before rates were fetched, %v", err)
+	}
+
+	err := refreshRates(false)
+	result, err2 := ConvertCurrency(1, "EUR", "USD")
+	if err != nil || err2 != nil || result != 1.25 || GetProvider() != "test" {
+		t.Errorf("Test failed. TestRefreshRatesFailover: %f from %s, %v, %v", result, GetProvider(), err, err2)
+	}
+
+	// Rates aren't refreshed again until they expire
+	refreshRates(false)
+	ConvertCurrency(1, "EUR", "USD")
+	if failing.calls != 1 || working.calls != 1 {
+		t.Errorf("Test failed. TestRefreshRatesFailover: providers called %d and %d times",
+			failing.calls, working.calls)
+	}
+
+	rates.m.Lock()
+	rates.updated = time.Now().Add(-time.Hour * 2)
+	rates.attempted = rates.updated
+	rates.m.Unlock()
+	working.rates = map[string]float64{"USD": 1.5}
+	refreshRates(false)
+	result, _ = ConvertCurrency(1, "EUR", "USD")
+	if result != 1.5 || working.calls != 2 {
+		t.Errorf("Test failed. TestRefreshRatesFailover: expired rates not refreshed, %f", result)
+	}
+
+	SetProviders([]RateProvider{failing}, time.Hour, 0)
+	err = refreshRates(false)
+	if err == nil {
+		t.Error("Test failed. TestRefreshRatesFailover: failing providers returned rates")
+	}
+}
+
+func TestStaleRates(t *testing.T) {
+	defer SetProviders([]RateProvider{&ECBProvider{}}, DefaultRefreshInterval, DefaultStaleAfter)
+
+	p := newTestRateProvider()
+	SetProviders([]RateProvider{p}, time.Hour, time.Hour*3)
+	err := SeedCurrencyData("")
+	if err != nil {
+		t.Fatalf("Test failed. TestStaleRates: Error, %s", err)
+	}
+
+	p.err = errors.New("unavailable")
+	rates.m.Lock()
+	rates.updated = time.Now().Add(-time.Hour * 4)
+	rates.attempted = rates.updated
+	rates.m.Unlock()
+	refreshRates(false)
+
+	result, err := ConvertCurrency(1, "EUR", "USD")
+	if !IsStale(err) || result != 1.25 {
+		t.Errorf("Test failed. TestStaleRates: %f, %v", result, err)
+	}
+	if !GetRates().Stale || GetRates().Rates["EUR"] != 0.8 {
+		t.Errorf("Test failed. TestStaleRates: unexpected rates %v", GetRates())
+	}
+	if IsStale(errors.New("test")) || IsStale(nil) {
+		t.Error("Test failed. TestStaleRates: error reported as stale")
+	}
+}
+
+func TestNormaliseRates(t *testing.T) {
+	result, err := normaliseRates("eur", map[string]float64{"USD": 1.25, "AUD": 1.625, "XXX": 0})
+	if err != nil || result["USD"] != 1 || result["EUR"] != 0.8 || result["AUD"] != 1.3 {
+		t.Errorf("Test failed. TestNormaliseRates: unexpected rates %v, %v", result, err)
+	}
+	if _, ok := result["XXX"]; ok {
+		t.Error("Test failed. TestNormaliseRates: zero rate kept")
+	}
+
+	result, err = normaliseRates("USD", map[string]float64{"AUD": 1.3})
+	if err != nil || result["USD"] != 1 || result["AUD"] != 1.3 {
+		t.Errorf("Test failed. TestNormaliseRates: unexpected rates %v, %v", result, err)
+	}
+
+	_, err = normaliseRates("EUR", map[string]float64{"AUD": 1.6})
+	if err == nil {
+		t.Error("Test failed. TestNormaliseRates: rates without USD accepted")
+	}
+}
+
+func TestRateProviders(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/ecb":
+			w.Write([]byte(`<?xml version="1.0" encoding="UTF-8"?>
+<gesmes:Envelope xmlns:gesmes="http://www.gesmes.org/xml/2002-08-01" xmlns="http://www.ecb.int/vocabulary/2002-08-01/eurofxref">
+	<gesmes:subject>Reference rates</gesmes:subject>
+	<Cube>
+		<Cube time="2018-03-02">
+			<Cube currency="USD" rate="1.2307"/>
+			<Cube currency="AUD" rate="1.5913"/>
+		</Cube>
+	</Cube>
+</gesmes:Envelope>`))
+		case "/oxr":
+			if r.URL.Query().Get("app_id") != "key" {
+				w.Write([]byte(`{"error":true,"status":401,"message":"invalid_app_id","description":"Invalid App ID"}`))
+				return
+			}
+			w.Write([]byte(`{"timestamp":1519989600,"base":"USD","rates":{"AUD":1.293,"EUR":0.8125}}`))
+		case "/fixer":
+			if r.URL.Query().Get("access_key") != "key" {
+				w.Write([]byte(`{"success":false,"error":{"code":101,"type":"invalid_access_key","info":"Invalid key"}}`))
+				return
+			}
+			w.Write([]byte(`{"success":true,"base":"EUR","date":"2018-03-02","rates":{"USD":1.2307}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		provider RateProvider
+		base     string
+		currency string
+		rate     float64
+	}{
+		{&ECBProvider{URL: server.URL + "/ecb"}, "EUR", "AUD", 1.5913},
+		{&OpenExchangeRatesProvider{AppID: "key", URL: server.URL + "/oxr"}, "USD", "EUR", 0.8125},
+		{&FixerProvider{AccessKey: "key", URL: server.URL + "/fixer"}, "EUR", "USD", 1.2307},
+	}
+	for _, test := range tests {
+		base, result, err := test.provider.GetRates()
+		if err != nil || base != test.base || result[test.currency] != test.rate {
+			t.Errorf("Test failed. TestRateProviders: unexpected %s rates %s %v, %v",
+				test.provider.GetName(), base, result, err)
+		}
+	}
+
+	failing := []RateProvider{
+		&ECBProvider{URL: server.URL + "/missing"},
+		&OpenExchangeRatesProvider{AppID: "wrong", URL: server.URL + "/oxr"},
+		&FixerProvider{AccessKey: "wrong", URL: server.URL + "/fixer"},
+	}
+	for _, p := range failing {
+		_, _, err := p.GetRates()
+		if err == nil {
+			t.Errorf("Test failed. TestRateProviders: %s error not returned", p.GetName())
+		}
+	}
+}
@@ -1,71 +1,13 @@
 package currency
 
 import (
-	"reflect"
+	"math"
 	"testing"
+	"time"
 
 	"github.com/mattkanwisher/cryptofiend/common"
 )
 
-func TestSetProvider(t *testing.T) {
-	defaultVal := YahooEnabled
-	expected := "yahoo"
-	SetProvider(true)
-	actual := GetProvider()
-	if expected != actual {
-		t.Errorf("Test failed. TestGetProvider expected %s got %s", expected, actual)
-	}
-
-	SetProvider(false)
-	expected = "fixer"
-	actual = GetProvider()
-	if expected != actual {
-		t.Errorf("Test failed. TestGetProvider expected %s got %s", expected, actual)
-	}
-
-	SetProvider(defaultVal)
-}
-
-func TestSwapProvider(t *testing.T) {
-	defaultVal := YahooEnabled
-	expected := "fixer"
-	SetProvider(true)
-	SwapProvider()
-	actual := GetProvider()
-	if expected != actual {
-		t.Errorf("Test failed. TestGetProvider expected %s got %s", expected, actual)
-	}
-
-	SetProvider(false)
-	SwapProvider()
-	expected = "yahoo"
-	actual = GetProvider()
-	if expected != actual {
-		t.Errorf("Test failed. TestGetProvider expected %s got %s", expected, actual)
-	}
-
-	SetProvider(defaultVal)
-}
-
-func TestGetProvider(t *testing.T) {
-	defaultVal := YahooEnabled
-	SetProvider(true)
-	expected := "yahoo"
-	actual := GetProvider()
-	if expected != actual {
-		t.Errorf("Test failed. TestGetProvider expected %s got %s", expected, actual)
-	}
-
-	SetProvider(false)
-	expected = "fixer"
-	actual = GetProvider()
-	if expected != actual {
-		t.Errorf("Test failed. TestGetProvider expected %s got %s", expected, actual)
-	}
-
-	SetProvider(defaultVal)
-}
-
 func TestIsDefaultCurrency(t *testing.T) {
 	t.Parallel()
 
@@ -322,43 +264,6 @@ func TestCheckAndAddCurrency(t *testing.T) {
 	}
 }
 
-func TestSeedCurrencyData(t *testing.T) {
-	//	SetProvider(true)
-	if YahooEnabled {
-		currencyRequestDefault := ""
-		currencyRequestUSDAUD := "USD,AUD"
-		currencyRequestObtuse := "WigWham"
-
-		err := SeedCurrencyData(currencyRequestDefault)
-		if err != nil {
-			t.Errorf(
-				"Test Failed. SeedCurrencyData: Error %s with currency as %s.",
-				err, currencyRequestDefault,
-			)
-		}
-		err2 := SeedCurrencyData(currencyRequestUSDAUD)
-		if err2 != nil {
-			t.Errorf(
-				"Test Failed. SeedCurrencyData: Error %s with currency as %s.",
-				err2, currencyRequestUSDAUD,
-			)
-		}
-		err3 := SeedCurrencyData(currencyRequestObtuse)
-		if err3 == nil {
-			t.Errorf(
-				"Test Failed. SeedCurrencyData: Error %s with currency as %s.",
-				err3, currencyRequestObtuse,
-			)
-		}
-	}
-
-	//SetProvider(false)
-	err := SeedCurrencyData("")
-	if err != nil {
-		t.Errorf("Test failed. SeedCurrencyData via Fixer. Error: %s", err)
-	}
-}
-
 func TestMakecurrencyPairs(t *testing.T) {
 	t.Parallel()
 
@@ -372,45 +277,49 @@ func TestMakecurrencyPairs(t *testing.T) {
 	}
 }
 
-func TestConvertCurrency(t *testing.T) {
-	//	SetProvider(true)
-	if YahooEnabled {
-		fiatCurrencies := DefaultCurrencies
-		for _, currencyFrom := range common.SplitStrings(fiatCurrencies, ",") {
-			for _, currencyTo := range common.SplitStrings(fiatCurrencies, ",") {
-				floatyMcfloat, err := ConvertCurrency(1000, currencyFrom, currencyTo)
-				if err != nil {
-					t.Errorf(
-						"Test Failed. ConvertCurrency: Error %s with return: %.2f Currency 1: %s Currency 2: %s",
-						err, floatyMcfloat, currencyFrom, currencyTo,
-					)
-				}
-				if reflect.TypeOf(floatyMcfloat).String() != "float64" {
-					t.Error("Test Failed. ConvertCurrency: Error, incorrect return type")
-				}
-				if floatyMcfloat <= 0 {
-					t.Error(
-						"Test Failed. ConvertCurrency: Error, negative return or a serious issue with current fiat",
-					)
-				}
-			}
-		}
-	}
+func TestSeedCurrencyData(t *testing.T) {
+	defer SetProviders([]RateProvider{&ECBProvider{}}, DefaultRefreshInterval, DefaultStaleAfter)
 
-	//	SetProvider(false)
-	_, err := ConvertCurrency(1000, "USD", "AUD")
-	if err != nil {
-		t.Errorf("Test failed. ConvertCurrency USD -> AUD. Error %s", err)
+	SetProviders(nil, time.Hour, 0)
+	err := SeedCurrencyData("")
+	if err != ErrNoRateProviders {
+		t.Errorf("Test failed. SeedCurrencyData without providers. Error: %v", err)
 	}
 
-	_, err = ConvertCurrency(1000, "AUD", "USD")
+	SetProviders([]RateProvider{newTestRateProvider()}, time.Hour, 0)
+	err = SeedCurrencyData("USD,WigWham")
 	if err != nil {
-		t.Errorf("Test failed. ConvertCurrency AUD -> AUD. Error %s", err)
+		t.Errorf("Test failed. SeedCurrencyData via test provider. Error: %s", err)
 	}
+	if GetProvider() != "test" {
+		t.Errorf("Test failed. SeedCurrencyData: rates fetched from %q", GetProvider())
+	}
+}
 
-	_, err = ConvertCurrency(1000, "CNY", "AUD")
+func TestConvertCurrency(t *testing.T) {
+	defer SetProviders([]RateProvider{&ECBProvider{}}, DefaultRefreshInterval, DefaultStaleAfter)
+	SetProviders([]RateProvider{newTestRateProvider()}, time.Hour, 0)
+	err := SeedCurrencyData("")
 	if err != nil {
-		t.Errorf("Test failed. ConvertCurrency USD -> AUD. Error %s", err)
+		t.Fatalf("Test failed. ConvertCurrency seeding rates. Error: %s", err)
+	}
+
+	tests := []struct {
+		from, to string
+		expected float64
+	}{
+		{"USD", "AUD", 1300},
+		{"AUD", "USD", 769.230769230769},
+		{"cny", "aud", 203.125},
+		{"EUR", "CNY", 8000},
+		{"AUD", "AUD", 1000},
+	}
+	for _, test := range tests {
+		result, err := ConvertCurrency(1000, test.from, test.to)
+		if err != nil || math.Abs(result-test.expected) > 1e-9 {
+			t.Errorf("Test failed. ConvertCurrency %s -> %s: %f, %v", test.from, test.to,
+				result, err)
+		}
 	}
 
 	// Test non-existent currencies
@@ -435,50 +344,3 @@ func TestConvertCurrency(t *testing.T) {
 		t.Errorf("Test failed. ConvertCurrency non-existent currency -> non-existent currency. Error %s", err)
 	}
 }
-
-func TestFetchFixerCurrencyData(t *testing.T) {
-	err := FetchFixerCurrencyData()
-	if err != nil {
-		t.Errorf("Test failed. FetchFixerCurrencyData returned %s", err)
-	}
-}
-
-func TestFetchYahooCurrencyData(t *testing.T) {
-	if !YahooEnabled {
-		t.Skip()
-	}
-
-	t.Parallel()
-	var fetchData []string
-	fiatCurrencies := DefaultCurrencies
-
-	for _, currencyOne := range common.SplitStrings(fiatCurrencies, ",") {
-		for _, currencyTwo := range common.SplitStrings(fiatCurrencies, ",") {
-			if currencyOne == currencyTwo {
-				continue
-			} else {
-				fetchData = append(fetchData, currencyOne+currencyTwo)
-			}
-		}
-	}
-	err := FetchYahooCurrencyData(fetchData)
-	if err != nil {
-		t.Errorf("Test Failed. FetchYahooCurrencyData: Error %s", err)
-	}
-}
-
-func TestQueryYahooCurrencyValues(t *testing.T) {
-	if !YahooEnabled {
-		t.Skip()
-	}
-
-	err := QueryYahooCurrencyValues(DefaultCurrencies)
-	if err != nil {
-		t.Errorf("Test Failed. QueryYahooCurrencyValues: Error, %s", err)
-	}
-
-	err = QueryYahooCurrencyValues(DefaultCryptoCurrencies)
-	if err == nil {
-		t.Errorf("Test Failed. QueryYahooCurrencyValues: Error, %s", err)
-	}
-}
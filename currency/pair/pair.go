@@ -23,6 +23,23 @@ func (c CurrencyItem) String() string {
 	return string(c)
 }
 
+// currencyAliases maps currency codes used by some exchanges to the code used
+// by the rest
+var currencyAliases = map[CurrencyItem]CurrencyItem{
+	"XBT": "BTC",
+	"XDG": "DOGE",
+}
+
+// Normalise converts the CurrencyItem object c to uppercase and replaces
+// exchange specific codes with their common ones, e.g. XBT -> BTC
+func (c CurrencyItem) Normalise() CurrencyItem {
+	c = c.Upper()
+	if alias, ok := currencyAliases[c]; ok {
+		return alias
+	}
+	return c
+}
+
 // CurrencyPair holds currency pair information
 type CurrencyPair struct {
 	Delimiter      string       `json:"delimiter"`
@@ -88,6 +105,15 @@ func (c CurrencyPair) Equal(p CurrencyPair) bool {
 	return false
 }
 
+// Normalise returns the pair without a delimiter and with both currencies
+// normalised, so that pairs from different exchanges can be compared
+func (c CurrencyPair) Normalise() CurrencyPair {
+	return CurrencyPair{
+		FirstCurrency:  c.FirstCurrency.Normalise(),
+		SecondCurrency: c.SecondCurrency.Normalise(),
+	}
+}
+
 // Invert returns the result of swaping the currencies in the pair, e.g. ETH/BTC -> BTC/ETH
 func (c CurrencyPair) Invert() CurrencyPair {
 	ret := c
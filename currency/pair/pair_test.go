@@ -129,6 +129,19 @@ func TestEqual(t *testing.T) {
 	}
 }
 
+func TestNormalise(t *testing.T) {
+	t.Parallel()
+	pair := NewCurrencyPairDelimiter("xbt-usd", "-")
+	actual := pair.Normalise()
+	expected := NewCurrencyPair("BTC", "USD")
+	if actual != expected {
+		t.Errorf(
+			"Test failed. Normalise(): %v was not equal to expected value: %v",
+			actual, expected,
+		)
+	}
+}
+
 func TestNewCurrencyPair(t *testing.T) {
 	t.Parallel()
 	pair := NewCurrencyPair("BTC", "USD")
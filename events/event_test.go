@@ -2,10 +2,15 @@ package events
 
 import (
 	"testing"
+	"time"
 
 	"github.com/mattkanwisher/cryptofiend/config"
 	"github.com/mattkanwisher/cryptofiend/currency/pair"
+	"github.com/mattkanwisher/cryptofiend/exchanges/arbitrage"
+	"github.com/mattkanwisher/cryptofiend/exchanges/orderbook"
 	"github.com/mattkanwisher/cryptofiend/exchanges/ticker"
+	"github.com/mattkanwisher/cryptofiend/notifications"
+	"github.com/mattkanwisher/cryptofiend/portfolio"
 	"github.com/mattkanwisher/cryptofiend/smsglobal"
 )
 
@@ -25,6 +30,19 @@ func testSetup(t *testing.T) {
 	}
 }
 
+// getEvent returns the stored event with the ID, so that tests can change it
+func getEvent(id int) *Event {
+	m.Lock()
+	defer m.Unlock()
+
+	for _, x := range Events {
+		if x.ID == id {
+			return x
+		}
+	}
+	return nil
+}
+
 func TestAddEvent(t *testing.T) {
 	testSetup(t)
 
@@ -88,7 +106,7 @@ func TestGetEventCounter(t *testing.T) {
 		t.Errorf("Test Failed. GetEventCounter: Error, %s", err)
 	}
 
-	Events[three-1].Executed = true
+	getEvent(three).Executed = true
 
 	total, _ := GetEventCounter()
 	if total <= 0 {
@@ -118,7 +136,7 @@ func TestExecuteAction(t *testing.T) {
 	if err != nil {
 		t.Fatalf("Test Failed. ExecuteAction: Error, %s", err)
 	}
-	isExecuted := Events[one].ExecuteAction()
+	isExecuted := GetEventByID(one).ExecuteAction()
 	if !isExecuted {
 		t.Error("Test Failed. ExecuteAction: Error, error removing event")
 	}
@@ -132,7 +150,7 @@ func TestExecuteAction(t *testing.T) {
 		t.Fatalf("Test Failed. ExecuteAction: Error, %s", err)
 	}
 
-	isExecuted = Events[one].ExecuteAction()
+	isExecuted = GetEventByID(one).ExecuteAction()
 	if !isExecuted {
 		t.Error("Test Failed. ExecuteAction: Error, error removing event")
 	}
@@ -146,7 +164,7 @@ func TestExecuteAction(t *testing.T) {
 		t.Fatalf("Test Failed. ExecuteAction: Error, %s", err)
 	}
 
-	isExecuted = Events[one].ExecuteAction()
+	isExecuted = GetEventByID(one).ExecuteAction()
 	if !isExecuted {
 		t.Error("Test Failed. ExecuteAction: Error, error removing event")
 	}
@@ -156,6 +174,42 @@ func TestExecuteAction(t *testing.T) {
 	// More tests when ExecuteAction is expanded
 }
 
+type testNotifier chan notifications.Message
+
+func (n testNotifier) Send(m notifications.Message) error {
+	n <- m
+	return nil
+}
+
+func TestExecuteActionNotify(t *testing.T) {
+	testSetup(t)
+
+	n := make(testNotifier, 1)
+	notifications.Register("TestExecuteActionNotify", n, 0, 0)
+	defer notifications.Unregister("TestExecuteActionNotify")
+
+	event := &Event{
+		Rule:   Rule{Exchange: "ANX", Item: "price", Condition: ">,1", Pair: pair.NewCurrencyPair("BTC", "USD"), Asset: "SPOT"},
+		Action: "NOTIFY,TestExecuteActionNotify",
+	}
+	err := isValidEventConfig(event)
+	if err != nil {
+		t.Fatalf("Test failed. TestExecuteActionNotify: Error, %s", err)
+	}
+
+	if !event.ExecuteAction() {
+		t.Fatal("Test failed. TestExecuteActionNotify: action not executed")
+	}
+	select {
+	case m := <-n:
+		if m.AlertType != notifications.AlertEvent || m.Body != event.String() {
+			t.Errorf("Test failed. TestExecuteActionNotify: unexpected message %+v", m)
+		}
+	case <-time.After(time.Second * 5):
+		t.Error("Test failed. TestExecuteActionNotify: notification not sent")
+	}
+}
+
 func TestEventToString(t *testing.T) {
 	testSetup(t)
 
@@ -165,7 +219,7 @@ func TestEventToString(t *testing.T) {
 		t.Errorf("Test Failed. EventToString: Error, %s", err)
 	}
 
-	eventString := Events[one].String()
+	eventString := GetEventByID(one).String()
 	if eventString != "If the BTCUSD [SPOT] price on ANX is > == then ACTION_TEST." {
 		t.Error("Test Failed. EventToString: Error, incorrect return string")
 	}
@@ -184,7 +238,7 @@ func TestCheckCondition(t *testing.T) {
 	if err != nil {
 		t.Errorf("Test Failed. CheckCondition: Error, %s", err)
 	}
-	conditionBool := Events[one].CheckCondition()
+	conditionBool := GetEventByID(one).CheckCondition()
 	if conditionBool {
 		t.Error("Test Failed. CheckCondition: Error, wrong conditional.")
 	}
@@ -194,8 +248,8 @@ func TestCheckCondition(t *testing.T) {
 	tickerNew.Last = 0
 	newPair = pair.NewCurrencyPair("BTC", "USD")
 	ticker.ProcessTicker("ANX", newPair, tickerNew, ticker.Spot)
-	Events[one].Pair = newPair
-	conditionBool = Events[one].CheckCondition()
+	getEvent(one).Pair = newPair
+	conditionBool = getEvent(one).CheckCondition()
 	if conditionBool {
 		t.Error("Test Failed. CheckCondition: Error, wrong conditional.")
 	}
@@ -203,41 +257,41 @@ func TestCheckCondition(t *testing.T) {
 	// Test last pricce > 0 and conditional logic
 	tickerNew.Last = 11
 	ticker.ProcessTicker("ANX", newPair, tickerNew, ticker.Spot)
-	Events[one].Condition = ">,10"
-	conditionBool = Events[one].CheckCondition()
+	getEvent(one).Condition = ">,10"
+	conditionBool = getEvent(one).CheckCondition()
 	if !conditionBool {
 		t.Error("Test Failed. CheckCondition: Error, wrong conditional.")
 	}
 
 	// Test last price >= 10
-	Events[one].Condition = ">=,10"
-	conditionBool = Events[one].CheckCondition()
+	getEvent(one).Condition = ">=,10"
+	conditionBool = getEvent(one).CheckCondition()
 	if !conditionBool {
 		t.Error("Test Failed. CheckCondition: Error, wrong conditional.")
 	}
 
 	// Test last price <= 10
-	Events[one].Condition = "<,100"
-	conditionBool = Events[one].CheckCondition()
+	getEvent(one).Condition = "<,100"
+	conditionBool = getEvent(one).CheckCondition()
 	if !conditionBool {
 		t.Error("Test Failed. CheckCondition: Error, wrong conditional.")
 	}
 
 	// Test last price <= 10
-	Events[one].Condition = "<=,100"
-	conditionBool = Events[one].CheckCondition()
+	getEvent(one).Condition = "<=,100"
+	conditionBool = getEvent(one).CheckCondition()
 	if !conditionBool {
 		t.Error("Test Failed. CheckCondition: Error, wrong conditional.")
 	}
 
-	Events[one].Condition = "==,11"
-	conditionBool = Events[one].CheckCondition()
+	getEvent(one).Condition = "==,11"
+	conditionBool = getEvent(one).CheckCondition()
 	if !conditionBool {
 		t.Error("Test Failed. CheckCondition: Error, wrong conditional.")
 	}
 
-	Events[one].Condition = "^,11"
-	conditionBool = Events[one].CheckCondition()
+	getEvent(one).Condition = "^,11"
+	conditionBool = getEvent(one).CheckCondition()
 	if conditionBool {
 		t.Error("Test Failed. CheckCondition: Error, wrong conditional.")
 	}
@@ -247,6 +301,440 @@ func TestCheckCondition(t *testing.T) {
 	}
 }
 
+func TestCheckConditionPriceChange(t *testing.T) {
+	testSetup(t)
+
+	newPair := pair.NewCurrencyPair("ETH", "USD")
+	id, err := AddEvent("ANX", "price_change", ">=,5,1h", newPair, "SPOT", actionTest)
+	if err != nil {
+		t.Fatalf("Test failed. TestCheckConditionPriceChange: Error, %s", err)
+	}
+	event := GetEventByID(id)
+	if event.Window() != time.Hour {
+		t.Errorf("Test failed. TestCheckConditionPriceChange: unexpected window %s", event.Window())
+	}
+
+	ticker.HistoryResolution = 0
+	ticker.ProcessTicker("ANX", newPair, ticker.Price{Last: 100}, ticker.Spot)
+	ticker.ProcessTicker("ANX", newPair, ticker.Price{Last: 104}, ticker.Spot)
+	if event.CheckCondition() {
+		t.Error("Test failed. TestCheckConditionPriceChange: Error, wrong conditional.")
+	}
+
+	ticker.ProcessTicker("ANX", newPair, ticker.Price{Last: 106}, ticker.Spot)
+	if !event.CheckCondition() {
+		t.Error("Test failed. TestCheckConditionPriceChange: Error, wrong conditional.")
+	}
+
+	_, err = AddEvent("ANX", "price_change", ">=,5,soon", newPair, "SPOT", actionTest)
+	if err == nil {
+		t.Error("Test failed. TestCheckConditionPriceChange: invalid window accepted")
+	}
+
+	if !RemoveEvent(id) {
+		t.Error("Test failed. TestCheckConditionPriceChange: Error, error removing event")
+	}
+}
+
+func TestCheckConditionTicker(t *testing.T) {
+	testSetup(t)
+
+	newPair := pair.NewCurrencyPair("BCH", "USD")
+	ticker.ProcessTicker("ANX", newPair, ticker.Price{Last: 100, Bid: 99, Ask: 102, Volume: 50}, ticker.Spot)
+	ticker.ProcessTicker("GDAX", newPair, ticker.Price{Last: 96, Bid: 95, Ask: 97, Volume: 80}, ticker.Spot)
+
+	tests := []struct {
+		rule Rule
+		met  bool
+	}{
+		{Rule{Item: "bid", Condition: "==,99"}, true},
+		{Rule{Item: "ask", Condition: ">,102"}, false},
+		{Rule{Item: "spread", Condition: "==,3"}, true},
+		{Rule{Item: "volume", Condition: "<,50"}, false},
+		{Rule{Item: "price", Condition: "==,4", CompareExchange: "GDAX"}, true},
+		{Rule{Item: "volume", Condition: "<,0", CompareExchange: "GDAX"}, true},
+		{Rule{Item: "price", Condition: ">,0", CompareExchange: "Bitstamp"}, false},
+	}
+
+	for i, test := range tests {
+		test.rule.Exchange = "ANX"
+		test.rule.Pair = newPair
+		test.rule.Asset = ticker.Spot
+		if test.rule.IsMet() != test.met {
+			t.Errorf("Test failed. TestCheckConditionTicker %d: Error, wrong conditional.", i)
+		}
+	}
+}
+
+func TestCheckConditionDepth(t *testing.T) {
+	testSetup(t)
+
+	newPair := pair.NewCurrencyPair("BTC", "EUR")
+	GetOrderbook = func(exchangeName string, p pair.CurrencyPair, assetType string) (orderbook.Base, error) {
+		return orderbook.Base{
+			Bids: []orderbook.Item{{Price: 100, Amount: 1}, {Price: 99, Amount: 2}, {Price: 90, Amount: 10}},
+			Asks: []orderbook.Item{{Price: 101, Amount: 3}},
+		}, nil
+	}
+	defer func() { GetOrderbook = nil }()
+
+	id, err := AddEvent("ANX", "bid_depth", ">=,3,2", newPair, "SPOT", actionTest)
+	if err != nil {
+		t.Fatalf("Test failed. TestCheckConditionDepth: Error, %s", err)
+	}
+	event := GetEventByID(id)
+	if !event.CheckCondition() {
+		t.Error("Test failed. TestCheckConditionDepth: Error, wrong conditional.")
+	}
+
+	event.Condition = ">,3,2"
+	if event.CheckCondition() {
+		t.Error("Test failed. TestCheckConditionDepth: Error, wrong conditional.")
+	}
+
+	event.Condition = "==,13"
+	if !event.CheckCondition() {
+		t.Error("Test failed. TestCheckConditionDepth: Error, wrong conditional.")
+	}
+
+	event.Item = "ask_depth"
+	if event.CheckCondition() {
+		t.Error("Test failed. TestCheckConditionDepth: Error, wrong conditional.")
+	}
+
+	_, err = AddEvent("ANX", "ask_depth", ">=,3,wide", newPair, "SPOT", actionTest)
+	if err == nil {
+		t.Error("Test failed. TestCheckConditionDepth: invalid band accepted")
+	}
+
+	if !RemoveEvent(id) {
+		t.Error("Test failed. TestCheckConditionDepth: Error, error removing event")
+	}
+}
+
+func TestCheckConditionBalance(t *testing.T) {
+	testSetup(t)
+
+	newPair := pair.NewCurrencyPair("DASH", "USD")
+	id, err := AddEvent("ANX", "balance", "<,5", newPair, "SPOT", actionTest)
+	if err != nil {
+		t.Fatalf("Test failed. TestCheckConditionBalance: Error, %s", err)
+	}
+	event := GetEventByID(id)
+	portfolio.GetPortfolio().AddExchangeAddress("ANX", "DASH", 10)
+	if event.CheckCondition() {
+		t.Error("Test failed. TestCheckConditionBalance: Error, wrong conditional.")
+	}
+
+	portfolio.GetPortfolio().AddExchangeAddress("ANX", "DASH", 2)
+	if !event.CheckCondition() {
+		t.Error("Test failed. TestCheckConditionBalance: Error, wrong conditional.")
+	}
+
+	if !RemoveEvent(id) {
+		t.Error("Test failed. TestCheckConditionBalance: Error, error removing event")
+	}
+}
+
+func TestAddCompoundEvent(t *testing.T) {
+	testSetup(t)
+
+	newPair := pair.NewCurrencyPair("XRP", "USD")
+	ticker.ProcessTicker("ANX", newPair, ticker.Price{Last: 1, Bid: 0.9, Ask: 1.1}, ticker.Spot)
+	rules := []Rule{
+		{Exchange: "ANX", Item: "price", Condition: ">=,1", Pair: newPair, Asset: "SPOT"},
+		{Exchange: "ANX", Item: "spread", Condition: "<,0.1", Pair: newPair, Asset: "SPOT"},
+	}
+
+	id, err := AddCompoundEvent(rules, "and", actionTest)
+	if err != nil {
+		t.Fatalf("Test failed. TestAddCompoundEvent: Error, %s", err)
+	}
+	event := GetEventByID(id)
+	if event.CheckCondition() {
+		t.Error("Test failed. TestAddCompoundEvent: Error, wrong conditional.")
+	}
+
+	event.Logic = "OR"
+	if !event.CheckCondition() {
+		t.Error("Test failed. TestAddCompoundEvent: Error, wrong conditional.")
+	}
+
+	expected := "If the XRPUSD [SPOT] price on ANX is >= 1 or the XRPUSD [SPOT] spread on ANX is < 0.1 then ACTION_TEST."
+	if event.String() != expected {
+		t.Errorf("Test failed. TestAddCompoundEvent: unexpected string %s", event.String())
+	}
+
+	if !event.watches(func(r *Rule) bool { return r.Item == "spread" }) {
+		t.Error("Test failed. TestAddCompoundEvent: Error, rule not watched")
+	}
+
+	_, err = AddCompoundEvent(rules, "XOR", actionTest)
+	if err == nil {
+		t.Error("Test failed. TestAddCompoundEvent: invalid logic accepted")
+	}
+	_, err = AddCompoundEvent(nil, "", actionTest)
+	if err == nil {
+		t.Error("Test failed. TestAddCompoundEvent: empty rules accepted")
+	}
+
+	if !RemoveEvent(id) {
+		t.Error("Test failed. TestAddCompoundEvent: Error, error removing event")
+	}
+}
+
+func TestCheckConditionArbitrage(t *testing.T) {
+	testSetup(t)
+
+	newPair := pair.NewCurrencyPair("LTC", "USD")
+	id, err := AddEvent("ANX", "arbitrage", ">=,1", newPair, "SPOT", actionTest)
+	if err != nil {
+		t.Fatalf("Test failed. TestCheckConditionArbitrage: Error, %s", err)
+	}
+	event := GetEventByID(id)
+	if event.CheckCondition() {
+		t.Error("Test failed. TestCheckConditionArbitrage: Error, wrong conditional.")
+	}
+
+	arbitrage.SetOpportunities(newPair, []arbitrage.Opportunity{
+		{Pair: newPair, BuyExchange: "ANX", SellExchange: "GDAX", ProfitPercent: 0.5},
+	})
+	if event.CheckCondition() {
+		t.Error("Test failed. TestCheckConditionArbitrage: Error, wrong conditional.")
+	}
+
+	arbitrage.SetOpportunities(newPair, []arbitrage.Opportunity{
+		{Pair: newPair, BuyExchange: "Kraken", SellExchange: "ANX", ProfitPercent: 1.5},
+	})
+	if !event.CheckCondition() {
+		t.Error("Test failed. TestCheckConditionArbitrage: Error, wrong conditional.")
+	}
+	arbitrage.SetOpportunities(newPair, nil)
+
+	if !RemoveEvent(id) {
+		t.Error("Test failed. TestCheckConditionArbitrage: Error, error removing event")
+	}
+}
+
+func TestCheckConditionTriangular(t *testing.T) {
+	testSetup(t)
+
+	newPair := pair.NewCurrencyPair("ETH", "BTC")
+	id, err := AddEvent("ANX", "triangular_arbitrage", ">=,1", newPair, "SPOT", actionTest)
+	if err != nil {
+		t.Fatalf("Test failed. TestCheckConditionTriangular: Error, %s", err)
+	}
+	event := GetEventByID(id)
+	if event.CheckCondition() {
+		t.Error("Test failed. TestCheckConditionTriangular: Error, wrong conditional.")
+	}
+
+	legs := []arbitrage.Leg{
+		{Pair: pair.NewCurrencyPair("BTC", "USD")},
+		{Pair: pair.NewCurrencyPair("ETH", "USD")},
+	}
+	arbitrage.SetCycles("ANX", []arbitrage.Cycle{
+		{Exchange: "ANX", Legs: legs, ProfitPercent: 2},
+	})
+	if event.CheckCondition() {
+		t.Error("Test failed. TestCheckConditionTriangular: Error, wrong conditional.")
+	}
+
+	legs = append(legs, arbitrage.Leg{Pair: pair.NewCurrencyPair("ETH", "XBT")})
+	arbitrage.SetCycles("ANX", []arbitrage.Cycle{
+		{Exchange: "ANX", Legs: legs, ProfitPercent: 2},
+	})
+	if !event.CheckCondition() {
+		t.Error("Test failed. TestCheckConditionTriangular: Error, wrong conditional.")
+	}
+	arbitrage.SetCycles("ANX", nil)
+
+	if !RemoveEvent(id) {
+		t.Error("Test failed. TestCheckConditionTriangular: Error, error removing event")
+	}
+}
+
+func TestEventIDs(t *testing.T) {
+	testSetup(t)
+
+	newPair := pair.NewCurrencyPair("BTC", "USD")
+	one, err := AddEvent("ANX", "price", ">,10", newPair, "SPOT", actionTest)
+	if err != nil {
+		t.Fatalf("Test failed. TestEventIDs: Error, %s", err)
+	}
+	two, err := AddEvent("ANX", "price", ">,10", newPair, "SPOT", actionTest)
+	if err != nil {
+		t.Fatalf("Test failed. TestEventIDs: Error, %s", err)
+	}
+	if !RemoveEvent(one) {
+		t.Fatal("Test failed. TestEventIDs: Error, error removing event")
+	}
+
+	three, err := AddEvent("ANX", "price", ">,10", newPair, "SPOT", actionTest)
+	if err != nil {
+		t.Fatalf("Test failed. TestEventIDs: Error, %s", err)
+	}
+	if three == one || three == two {
+		t.Errorf("Test failed. TestEventIDs: ID %d reused", three)
+	}
+	if GetEventByID(one) != nil || GetEventByID(three) == nil {
+		t.Error("Test failed. TestEventIDs: Error, wrong event returned")
+	}
+
+	RemoveEvent(two)
+	RemoveEvent(three)
+}
+
+func TestGetEventByID(t *testing.T) {
+	testSetup(t)
+
+	newPair := pair.NewCurrencyPair("BTC", "USD")
+	id, err := AddCompoundEvent([]Rule{
+		{Exchange: "ANX", Item: "price", Condition: ">,10", Pair: newPair, Asset: "SPOT"},
+		{Exchange: "ANX", Item: "bid", Condition: ">,10", Pair: newPair, Asset: "SPOT"},
+	}, logicAnd, actionTest)
+	if err != nil {
+		t.Fatalf("Test failed. TestGetEventByID: Error, %s", err)
+	}
+
+	event := GetEventByID(id)
+	event.Enabled = false
+	event.Rules[0].Condition = "<,10"
+	if x := getEvent(id); !x.Enabled || x.Rules[0].Condition != ">,10" {
+		t.Error("Test failed. TestGetEventByID: Error, stored event changed through copy")
+	}
+
+	RemoveEvent(id)
+}
+
+func TestEnableEvent(t *testing.T) {
+	testSetup(t)
+
+	newPair := pair.NewCurrencyPair("BTC", "USD")
+	ticker.ProcessTicker("ANX", newPair, ticker.Price{Last: 11}, ticker.Spot)
+	id, err := AddEvent("ANX", "price", ">,10", newPair, "SPOT", actionTest)
+	if err != nil {
+		t.Fatalf("Test failed. TestEnableEvent: Error, %s", err)
+	}
+	event := getEvent(id)
+	match := func(r *Rule) bool { return r.Exchange == "ANX" }
+
+	if EnableEvent(id, false) != nil || event.isPending(time.Now()) {
+		t.Error("Test failed. TestEnableEvent: disabled event is pending")
+	}
+	checkEvents(match)
+	if event.Executed {
+		t.Error("Test failed. TestEnableEvent: disabled event was triggered")
+	}
+
+	EnableEvent(id, true)
+	checkEvents(match)
+	if !event.Executed || event.isPending(time.Now()) {
+		t.Error("Test failed. TestEnableEvent: event wasn't triggered once")
+	}
+
+	EnableEvent(id, true)
+	if !event.isPending(time.Now()) {
+		t.Error("Test failed. TestEnableEvent: re-enabled event isn't pending")
+	}
+	if EnableEvent(1234, true) != ErrEventNotFound {
+		t.Error("Test failed. TestEnableEvent: Error, enabled missing event")
+	}
+
+	event.Condition = ">"
+	EnableEvent(id, false)
+	if EnableEvent(id, true) == nil || event.Enabled {
+		t.Error("Test failed. TestEnableEvent: Error, enabled invalid event")
+	}
+	if event.IsMet() || event.String() == "" {
+		t.Error("Test failed. TestEnableEvent: Error, malformed condition met")
+	}
+
+	RemoveEvent(id)
+}
+
+func TestRepeatEvent(t *testing.T) {
+	testSetup(t)
+
+	newPair := pair.NewCurrencyPair("BTC", "USD")
+	ticker.ProcessTicker("ANX", newPair, ticker.Price{Last: 11}, ticker.Spot)
+	id, err := AddEventConfig(config.EventConfig{
+		EventRuleConfig: config.EventRuleConfig{
+			Exchange: "ANX", Item: "price", Condition: ">,10", Pair: newPair, Asset: "SPOT",
+		},
+		Action:   actionTest,
+		Repeat:   true,
+		Cooldown: 60,
+	})
+	if err != nil {
+		t.Fatalf("Test failed. TestRepeatEvent: Error, %s", err)
+	}
+	event := getEvent(id)
+	triggered := Subscribe()
+
+	checkEvents(func(r *Rule) bool { return r.Exchange == "ANX" })
+	if event.Executed || event.LastTriggered.IsZero() {
+		t.Fatal("Test failed. TestRepeatEvent: event wasn't triggered")
+	}
+	published := false
+	for len(triggered) > 0 {
+		if x := <-triggered; x.ID == id {
+			published = true
+		}
+	}
+	if !published {
+		t.Error("Test failed. TestRepeatEvent: triggered event not published")
+	}
+	if event.isPending(event.LastTriggered.Add(time.Second * 59)) {
+		t.Error("Test failed. TestRepeatEvent: event pending during cooldown")
+	}
+	if !event.isPending(event.LastTriggered.Add(time.Minute)) {
+		t.Error("Test failed. TestRepeatEvent: event not pending after cooldown")
+	}
+
+	_, err = AddEventConfig(config.EventConfig{EventRuleConfig: event.toConfig().EventRuleConfig,
+		Action: actionTest, Cooldown: -1})
+	if err == nil {
+		t.Error("Test failed. TestRepeatEvent: negative cooldown accepted")
+	}
+
+	RemoveEvent(id)
+}
+
+func TestLoadEvents(t *testing.T) {
+	testSetup(t)
+
+	saved := GetEventConfigs()
+	defer LoadEvents(saved)
+
+	rule := config.EventRuleConfig{
+		Exchange: "ANX", Item: "price", Condition: ">,10",
+		Pair: pair.NewCurrencyPair("BTC", "USD"), Asset: "SPOT",
+	}
+	LoadEvents([]config.EventConfig{
+		{ID: 4, EventRuleConfig: rule, Action: actionTest, Enabled: true, Executed: true},
+		{ID: 4, EventRuleConfig: rule, Action: actionTest, Enabled: true},
+		{ID: 2, EventRuleConfig: config.EventRuleConfig{Exchange: "Testys"}, Action: actionTest, Enabled: true},
+	})
+
+	if len(Events) != 3 || !GetEventByID(4).Executed || GetEventByID(5) == nil {
+		t.Fatal("Test failed. TestLoadEvents: colliding IDs weren't renumbered")
+	}
+	if GetEventByID(2).Enabled {
+		t.Error("Test failed. TestLoadEvents: invalid event wasn't disabled")
+	}
+
+	id, err := AddEvent("ANX", "price", ">,10", rule.Pair, "SPOT", actionTest)
+	if err != nil || id != 6 {
+		t.Errorf("Test failed. TestLoadEvents: unexpected new ID %d, %v", id, err)
+	}
+
+	configs := GetEventConfigs()
+	if len(configs) != 4 || configs[0].EventRuleConfig != rule || !configs[0].Executed {
+		t.Errorf("Test failed. TestLoadEvents: unexpected saved events %+v", configs)
+	}
+}
+
 func TestIsValidEvent(t *testing.T) {
 	testSetup(t)
 
@@ -266,6 +754,19 @@ func TestIsValidEvent(t *testing.T) {
 	if err == nil {
 		t.Errorf("Test Failed. IsValidEvent: %s", err)
 	}
+	err = IsValidEvent("ANX", "price", ">,10,1h", actionTest)
+	if err == nil {
+		t.Errorf("Test Failed. IsValidEvent: %s", err)
+	}
+
+	err = IsValidRule(Rule{Exchange: "ANX", Item: "arbitrage", Condition: ">,1", CompareExchange: "GDAX"})
+	if err == nil {
+		t.Errorf("Test Failed. IsValidRule: %s", err)
+	}
+	err = IsValidRule(Rule{Exchange: "ANX", Item: "price", Condition: ">,1", CompareExchange: "Testys"})
+	if err == nil {
+		t.Errorf("Test Failed. IsValidRule: %s", err)
+	}
 
 	action := "blah,blah"
 	err = IsValidEvent("ANX", "price", ">=,10", action)
@@ -344,6 +845,10 @@ func TestIsValidAction(t *testing.T) {
 	if !boolean {
 		t.Error("Test Failed. IsValidAction: Error, incorrect Action")
 	}
+	boolean = IsValidAction("notify")
+	if !boolean {
+		t.Error("Test Failed. IsValidAction: Error, incorrect Action")
+	}
 	boolean = IsValidAction(actionTest)
 	if !boolean {
 		t.Error("Test Failed. IsValidAction: Error, incorrect Action")
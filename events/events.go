@@ -4,79 +4,344 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"math"
 	"strconv"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/mattkanwisher/cryptofiend/common"
 	"github.com/mattkanwisher/cryptofiend/config"
 	"github.com/mattkanwisher/cryptofiend/currency/pair"
+	"github.com/mattkanwisher/cryptofiend/exchanges/arbitrage"
+	"github.com/mattkanwisher/cryptofiend/exchanges/orderbook"
 	"github.com/mattkanwisher/cryptofiend/exchanges/ticker"
+	"github.com/mattkanwisher/cryptofiend/notifications"
+	"github.com/mattkanwisher/cryptofiend/portfolio"
 	"github.com/mattkanwisher/cryptofiend/smsglobal"
 )
 
 const (
 	itemPrice          = "PRICE"
+	itemBid            = "BID"
+	itemAsk            = "ASK"
+	itemSpread         = "SPREAD"
+	itemVolume         = "VOLUME"
+	itemPriceChange    = "PRICE_CHANGE"
+	itemBidDepth       = "BID_DEPTH"
+	itemAskDepth       = "ASK_DEPTH"
+	itemBalance        = "BALANCE"
+	itemArbitrage      = "ARBITRAGE"
+	itemTriangular     = "TRIANGULAR_ARBITRAGE"
 	greaterThan        = ">"
 	greaterThanOrEqual = ">="
 	lessThan           = "<"
 	lessThanOrEqual    = "<="
 	isEqual            = "=="
+	logicAnd           = "AND"
+	logicOr            = "OR"
 	actionSMSNotify    = "SMS"
+	actionNotify       = "NOTIFY"
 	actionConsolePrint = "CONSOLE_PRINT"
 	actionTest         = "ACTION_TEST"
+
+	// defaultChangeWindow is used by PRICE_CHANGE conditions without a window
+	defaultChangeWindow = time.Hour * 24
+
+	// SubscriberBufferSize is the number of undelivered triggered events held
+	// for each subscriber before further events are dropped
+	SubscriberBufferSize = 256
 )
 
 var (
 	errInvalidItem      = errors.New("invalid item")
 	errInvalidCondition = errors.New("invalid conditional option")
 	errInvalidAction    = errors.New("invalid action")
+	errInvalidLogic     = errors.New("invalid logic operator")
+	errNoRules          = errors.New("no conditions given")
+	errInvalidCooldown  = errors.New("invalid cooldown")
 	errExchangeDisabled = errors.New("desired exchange is disabled")
+
+	// ErrEventNotFound is returned when no event has the given ID
+	ErrEventNotFound = errors.New("event not found")
 )
 
-// Event struct holds the event variables
+// GetOrderbook returns the cached orderbook of an exchange for BID_DEPTH and
+// ASK_DEPTH conditions, it is set by the bot on startup
+var GetOrderbook func(exchangeName string, p pair.CurrencyPair, assetType string) (orderbook.Base, error)
+
+// Rule is a condition on an item of a currency pair on an exchange. If
+// CompareExchange is set the condition is on the item on Exchange minus the
+// item on CompareExchange.
+type Rule struct {
+	Exchange        string
+	Item            string
+	Condition       string
+	Pair            pair.CurrencyPair
+	Asset           string
+	CompareExchange string `json:",omitempty"`
+}
+
+// Event struct holds the event variables. Rules are further conditions which
+// are combined with the event's own by Logic, AND or OR, AND if not set.
+// Disabled events aren't checked, and events with Repeat set are triggered
+// again once Cooldown seconds have passed instead of only once.
 type Event struct {
-	ID        int
-	Exchange  string
-	Item      string
-	Condition string
-	Pair      pair.CurrencyPair
-	Asset     string
-	Action    string
-	Executed  bool
+	ID int
+	Rule
+	Rules         []Rule `json:",omitempty"`
+	Logic         string `json:",omitempty"`
+	Action        string
+	Enabled       bool
+	Repeat        bool
+	Cooldown      time.Duration
+	Executed      bool
+	LastTriggered time.Time
 }
 
 // Events variable is a pointer array to the event structures that will be
-// appended
+// appended. It is shared with the routine checking events, so must only be
+// changed through the functions of this package.
 var Events []*Event
 
-// AddEvent adds an event to the Events chain and returns an index/eventID
-// and an error
+var (
+	m           sync.Mutex
+	nextEventID int
+	subscribers []chan Event
+)
+
+// Subscribe returns a channel which receives a copy of every event triggered
+// from then on
+func Subscribe() <-chan Event {
+	m.Lock()
+	defer m.Unlock()
+
+	ch := make(chan Event, SubscriberBufferSize)
+	subscribers = append(subscribers, ch)
+	return ch
+}
+
+// notify sends a copy of a triggered event to the subscribers, m must be held
+func notify(e *Event) {
+	for _, ch := range subscribers {
+		select {
+		case ch <- *e:
+		default:
+		}
+	}
+}
+
+// AddEvent adds an enabled event to the Events chain and returns an
+// index/eventID and an error
 func AddEvent(Exchange, Item, Condition string, CurrencyPair pair.CurrencyPair, Asset, Action string) (int, error) {
-	err := IsValidEvent(Exchange, Item, Condition, Action)
+	rule := Rule{
+		Exchange:  Exchange,
+		Item:      Item,
+		Condition: Condition,
+		Pair:      CurrencyPair,
+		Asset:     Asset,
+	}
+	return AddCompoundEvent([]Rule{rule}, "", Action)
+}
+
+// AddCompoundEvent adds an enabled event triggered when its rules combined by
+// logic, AND or OR, are met and returns an index/eventID and an error
+func AddCompoundEvent(rules []Rule, logic, Action string) (int, error) {
+	if len(rules) == 0 {
+		return 0, errNoRules
+	}
+
+	return addEvent(&Event{
+		Rule:    rules[0],
+		Rules:   rules[1:],
+		Logic:   common.StringToUpper(logic),
+		Action:  Action,
+		Enabled: true,
+	})
+}
+
+// AddEventConfig adds an enabled event from its saved form, e.g. as received
+// by the API, and returns an index/eventID and an error. The ID and trigger
+// state of the config are ignored.
+func AddEventConfig(c config.EventConfig) (int, error) {
+	event := eventFromConfig(c)
+	event.Logic = common.StringToUpper(event.Logic)
+	event.Enabled = true
+	event.Executed = false
+	event.LastTriggered = time.Time{}
+	return addEvent(event)
+}
+
+func addEvent(event *Event) (int, error) {
+	err := isValidEventConfig(event)
 	if err != nil {
 		return 0, err
 	}
 
-	Event := &Event{}
+	m.Lock()
+	defer m.Unlock()
 
-	if len(Events) == 0 {
-		Event.ID = 0
-	} else {
-		Event.ID = len(Events) + 1
+	event.ID = nextEventID
+	nextEventID++
+	Events = append(Events, event)
+	return event.ID, nil
+}
+
+// isValidEventConfig checks the rules, logic, action and cooldown of an event
+func isValidEventConfig(e *Event) error {
+	if e.Logic != "" && e.Logic != logicAnd && e.Logic != logicOr {
+		return errInvalidLogic
+	}
+
+	err := IsValidRule(e.Rule)
+	if err != nil {
+		return err
+	}
+
+	for _, x := range e.Rules {
+		err = IsValidRule(x)
+		if err != nil {
+			return err
+		}
+	}
+
+	if e.Cooldown < 0 {
+		return errInvalidCooldown
+	}
+	return isValidEventAction(e.Action)
+}
+
+// LoadEvents replaces the events with those saved in the config. Events that
+// are no longer valid, e.g. as their exchange has been disabled, are kept but
+// disabled.
+func LoadEvents(configs []config.EventConfig) {
+	m.Lock()
+	defer m.Unlock()
+
+	Events = nil
+	nextEventID = 0
+	for _, x := range configs {
+		event := eventFromConfig(x)
+		err := isValidEventConfig(event)
+		if err != nil {
+			log.Printf("Event %d disabled as it is invalid. Error: %s\n", event.ID, err)
+			event.Enabled = false
+		}
+
+		if event.ID >= nextEventID {
+			nextEventID = event.ID + 1
+		}
+		Events = append(Events, event)
+	}
+
+	// Events with colliding IDs are renumbered
+	ids := make(map[int]bool)
+	for _, x := range Events {
+		if ids[x.ID] {
+			x.ID = nextEventID
+			nextEventID++
+		}
+		ids[x.ID] = true
+	}
+}
+
+// GetEventConfigs returns the events in their saved form
+func GetEventConfigs() []config.EventConfig {
+	m.Lock()
+	defer m.Unlock()
+
+	var result []config.EventConfig
+	for _, x := range Events {
+		result = append(result, x.toConfig())
+	}
+	return result
+}
+
+func eventFromConfig(c config.EventConfig) *Event {
+	event := &Event{
+		ID:            c.ID,
+		Rule:          Rule(c.EventRuleConfig),
+		Logic:         c.Logic,
+		Action:        c.Action,
+		Enabled:       c.Enabled,
+		Repeat:        c.Repeat,
+		Cooldown:      c.Cooldown,
+		Executed:      c.Executed,
+		LastTriggered: c.LastTriggered,
+	}
+	for _, x := range c.Rules {
+		event.Rules = append(event.Rules, Rule(x))
+	}
+	return event
+}
+
+func (e *Event) toConfig() config.EventConfig {
+	c := config.EventConfig{
+		ID:              e.ID,
+		EventRuleConfig: config.EventRuleConfig(e.Rule),
+		Logic:           e.Logic,
+		Action:          e.Action,
+		Enabled:         e.Enabled,
+		Repeat:          e.Repeat,
+		Cooldown:        e.Cooldown,
+		Executed:        e.Executed,
+		LastTriggered:   e.LastTriggered,
+	}
+	for _, x := range e.Rules {
+		c.Rules = append(c.Rules, config.EventRuleConfig(x))
+	}
+	return c
+}
+
+// GetEventByID returns a copy of the event with the ID, or nil if it doesn't
+// exist
+func GetEventByID(EventID int) *Event {
+	m.Lock()
+	defer m.Unlock()
+
+	for _, x := range Events {
+		if x.ID == EventID {
+			return x.copy()
+		}
 	}
+	return nil
+}
 
-	Event.Exchange = Exchange
-	Event.Item = Item
-	Event.Condition = Condition
-	Event.Pair = CurrencyPair
-	Event.Asset = Asset
-	Event.Action = Action
-	Event.Executed = false
-	Events = append(Events, Event)
-	return Event.ID, nil
+// copy returns a copy of an event which doesn't share its rules
+func (e *Event) copy() *Event {
+	result := *e
+	result.Rules = append([]Rule(nil), e.Rules...)
+	return &result
+}
+
+// EnableEvent enables or disables an event by its ID. Enabling an event which
+// has already been triggered allows it to be triggered again, events which are
+// no longer valid, e.g. as their exchange has been disabled, can't be enabled.
+func EnableEvent(EventID int, enabled bool) error {
+	m.Lock()
+	defer m.Unlock()
+
+	for _, x := range Events {
+		if x.ID == EventID {
+			if enabled {
+				err := isValidEventConfig(x)
+				if err != nil {
+					return err
+				}
+				x.Executed = false
+			}
+			x.Enabled = enabled
+			return nil
+		}
+	}
+	return ErrEventNotFound
 }
 
 // RemoveEvent deletes and event by its ID
 func RemoveEvent(EventID int) bool {
+	m.Lock()
+	defer m.Unlock()
+
 	for i, x := range Events {
 		if x.ID == EventID {
 			Events = append(Events[:i], Events[i+1:]...)
@@ -89,6 +354,9 @@ func RemoveEvent(EventID int) bool {
 // GetEventCounter displays the emount of total events on the chain and the
 // events that have been executed.
 func GetEventCounter() (int, int) {
+	m.Lock()
+	defer m.Unlock()
+
 	total := len(Events)
 	executed := 0
 
@@ -100,94 +368,337 @@ func GetEventCounter() (int, int) {
 	return total, executed
 }
 
-// ExecuteAction will execute the action pending on the chain
+// isPending checks whether an event can currently be triggered
+func (e *Event) isPending(now time.Time) bool {
+	if !e.Enabled || e.Executed {
+		return false
+	}
+	return !e.Repeat || e.LastTriggered.IsZero() ||
+		now.Sub(e.LastTriggered) >= time.Second*e.Cooldown
+}
+
+// ExecuteAction will execute the action pending on the chain. BUY, SELL and
+// CANCEL_ALL actions trade on the exchange and pair of the event and return
+// false if the exchange rejects them. SMS and NOTIFY actions queue a
+// notification, "NOTIFY" alone sends it to the channels EVENT alerts are
+// routed to and "NOTIFY,a,b" to the named channels.
 func (e *Event) ExecuteAction() bool {
-	if common.StringContains(e.Action, ",") {
-		action := common.SplitStrings(e.Action, ",")
-		if action[0] == actionSMSNotify {
-			message := fmt.Sprintf("Event triggered: %s", e.String())
-			s := smsglobal.SMSGlobal
-			if action[1] == "ALL" {
-				s.SendMessageToAll(message)
-			} else {
-				contact, _ := s.GetContactByName(action[1])
-				s.SendMessage(contact.Number, message)
-			}
+	if isOrderAction(e.Action) || common.StringToUpper(e.Action) == actionCancelAll {
+		err := e.executeTrade()
+		if err != nil {
+			log.Printf("Event %d failed to trade on %s. Error: %s\n", e.ID, e.Exchange, err)
+			return false
 		}
-	} else {
+		return true
+	}
+
+	action := common.SplitStrings(e.Action, ",")
+	switch common.StringToUpper(action[0]) {
+	case actionNotify:
+		if len(action) == 1 {
+			notifications.Notify(notifications.AlertEvent, "Event triggered", e.String())
+			break
+		}
+		for _, channel := range action[1:] {
+			e.notify(channel, "")
+		}
+	case actionSMSNotify:
+		to := action[1]
+		if common.StringToUpper(to) == "ALL" {
+			to = ""
+		}
+		e.notify(notifications.SMSChannel, to)
+	default:
 		log.Printf("Event triggered: %s", e.String())
 	}
 	return true
 }
 
-// EventToString turns the structure event into a string
-func (e *Event) String() string {
-	condition := common.SplitStrings(e.Condition, ",")
+// notify queues a message that the event triggered on a notification channel
+func (e *Event) notify(channel, to string) {
+	err := notifications.Send(channel, notifications.Message{
+		AlertType: notifications.AlertEvent,
+		Subject:   "Event triggered",
+		Body:      e.String(),
+		To:        to,
+	})
+	if err != nil {
+		log.Printf("Event %d failed to notify %s. Error: %s\n", e.ID, channel, err)
+	}
+}
+
+// String describes the condition of a rule
+func (r *Rule) String() string {
+	condition := common.SplitStrings(r.Condition, ",")
+	if len(condition) < 2 {
+		condition = append(condition, "")
+	}
+	item := r.Item
+	switch common.StringToUpper(r.Item) {
+	case itemPriceChange:
+		item = fmt.Sprintf("%s over %s", r.Item, r.Window())
+	case itemBidDepth, itemAskDepth:
+		if band := r.band(); band > 0 {
+			item = fmt.Sprintf("%s within %v%%", r.Item, band)
+		}
+	}
+	exchange := r.Exchange
+	if r.CompareExchange != "" {
+		exchange = fmt.Sprintf("%s minus %s", r.Exchange, r.CompareExchange)
+	}
 	return fmt.Sprintf(
-		"If the %s%s [%s] %s on %s is %s then %s.", e.Pair.FirstCurrency.String(),
-		e.Pair.SecondCurrency.String(), e.Asset, e.Item, e.Exchange, condition[0]+" "+condition[1], e.Action,
+		"the %s%s [%s] %s on %s is %s", r.Pair.FirstCurrency.String(),
+		r.Pair.SecondCurrency.String(), r.Asset, item, exchange, condition[0]+" "+condition[1],
 	)
 }
 
-// CheckCondition will check the event structure to see if there is a condition
-// met
-func (e *Event) CheckCondition() bool {
-	condition := common.SplitStrings(e.Condition, ",")
-	targetPrice, _ := strconv.ParseFloat(condition[1], 64)
+// EventToString turns the structure event into a string
+func (e *Event) String() string {
+	conditions := []string{e.Rule.String()}
+	for i := range e.Rules {
+		conditions = append(conditions, e.Rules[i].String())
+	}
+	logic := " and "
+	if common.StringToUpper(e.Logic) == logicOr {
+		logic = " or "
+	}
+	return fmt.Sprintf("If %s then %s.", strings.Join(conditions, logic), e.Action)
+}
 
-	t, err := ticker.GetTicker(e.Exchange, e.Pair, e.Asset)
-	if err != nil {
-		return false
+func (r *Rule) isArbitrage() bool {
+	return common.StringToUpper(r.Item) == itemArbitrage
+}
+
+func (r *Rule) isTriangular() bool {
+	return common.StringToUpper(r.Item) == itemTriangular
+}
+
+// tradesPair checks whether a triangular arbitrage cycle has a leg on the
+// rule's currency pair
+func (r *Rule) tradesPair(c arbitrage.Cycle) bool {
+	for _, leg := range c.Legs {
+		if leg.Pair.Normalise() == r.Pair.Normalise() {
+			return true
+		}
 	}
+	return false
+}
 
-	lastPrice := t.Last
+func (r *Rule) isDepth() bool {
+	item := common.StringToUpper(r.Item)
+	return item == itemBidDepth || item == itemAskDepth
+}
 
-	if lastPrice == 0 {
+// watches checks whether a rule depends on the market data of a pair on an
+// exchange
+func (r *Rule) watches(exchangeName string, p pair.CurrencyPair, assetType string) bool {
+	if r.isArbitrage() || r.isTriangular() {
 		return false
 	}
+	return (r.Exchange == exchangeName || r.CompareExchange == exchangeName) &&
+		r.Asset == assetType && r.Pair.Equal(p)
+}
 
-	switch condition[0] {
-	case greaterThan:
-		{
-			if lastPrice > targetPrice {
-				return e.ExecuteAction()
-			}
+// parameter returns the optional third part of the condition
+func (r *Rule) parameter() string {
+	condition := common.SplitStrings(r.Condition, ",")
+	if len(condition) < 3 {
+		return ""
+	}
+	return condition[2]
+}
+
+// Window returns the window of a PRICE_CHANGE condition
+func (r *Rule) Window() time.Duration {
+	window, err := time.ParseDuration(r.parameter())
+	if err != nil {
+		return defaultChangeWindow
+	}
+	return window
+}
+
+// band returns the percentage from the best price that BID_DEPTH and ASK_DEPTH
+// conditions sum the orderbook over, zero for the whole side
+func (r *Rule) band() float64 {
+	band, _ := strconv.ParseFloat(r.parameter(), 64)
+	return band
+}
+
+// depth returns the amount on one side of the orderbook of an exchange within
+// the band of the best price
+func (r *Rule) depth(exchangeName string) (float64, bool) {
+	if GetOrderbook == nil {
+		return 0, false
+	}
+	ob, err := GetOrderbook(exchangeName, r.Pair, r.Asset)
+	if err != nil {
+		return 0, false
+	}
+
+	items := ob.Bids
+	if common.StringToUpper(r.Item) == itemAskDepth {
+		items = ob.Asks
+	}
+	if len(items) == 0 {
+		return 0, false
+	}
+
+	band := r.band()
+	best := items[0].Price
+	total := float64(0)
+	for _, x := range items {
+		if band > 0 && math.Abs(x.Price-best)/best*100 > band {
+			continue
 		}
-	case greaterThanOrEqual:
-		{
-			if lastPrice >= targetPrice {
-				return e.ExecuteAction()
+		total += x.Amount
+	}
+	return total, true
+}
+
+// exchangeValue returns the current value of the rule's item on an exchange,
+// or false if it isn't known
+func (r *Rule) exchangeValue(exchangeName string) (float64, bool) {
+	switch common.StringToUpper(r.Item) {
+	case itemArbitrage:
+		found := false
+		value := float64(0)
+		for _, o := range arbitrage.GetPairOpportunities(r.Pair) {
+			if o.BuyExchange == exchangeName || o.SellExchange == exchangeName {
+				value = math.Max(value, o.ProfitPercent)
+				found = true
 			}
 		}
-	case lessThan:
-		{
-			if lastPrice < targetPrice {
-				return e.ExecuteAction()
+		return value, found
+	case itemTriangular:
+		found := false
+		value := float64(0)
+		for _, c := range arbitrage.GetExchangeCycles(exchangeName) {
+			if r.tradesPair(c) {
+				value = math.Max(value, c.ProfitPercent)
+				found = true
 			}
 		}
-	case lessThanOrEqual:
-		{
-			if lastPrice <= targetPrice {
-				return e.ExecuteAction()
-			}
+		return value, found
+	case itemPriceChange:
+		change, err := ticker.GetChange(exchangeName, r.Pair, r.Asset, r.Window())
+		if err != nil {
+			return 0, false
 		}
+		return change.Percent, true
+	case itemBidDepth, itemAskDepth:
+		return r.depth(exchangeName)
+	case itemBalance:
+		return portfolio.GetPortfolio().GetAddressBalance(exchangeName,
+			r.Pair.FirstCurrency.String(), portfolio.PortfolioAddressExchange)
+	}
+
+	t, err := ticker.GetTicker(exchangeName, r.Pair, r.Asset)
+	if err != nil {
+		return 0, false
+	}
+
+	switch common.StringToUpper(r.Item) {
+	case itemBid:
+		return t.Bid, t.Bid != 0
+	case itemAsk:
+		return t.Ask, t.Ask != 0
+	case itemSpread:
+		return t.Ask - t.Bid, t.Bid != 0 && t.Ask != 0
+	case itemVolume:
+		return t.Volume, true
+	}
+	return t.Last, t.Last != 0
+}
+
+// Value returns the current value of the rule's item, the difference between
+// the exchanges for cross exchange rules
+func (r *Rule) Value() (float64, bool) {
+	value, ok := r.exchangeValue(r.Exchange)
+	if !ok || r.CompareExchange == "" {
+		return value, ok
+	}
+
+	other, ok := r.exchangeValue(r.CompareExchange)
+	if !ok {
+		return 0, false
+	}
+	return value - other, true
+}
+
+// IsMet checks whether the condition of a rule is currently met
+func (r *Rule) IsMet() bool {
+	condition := common.SplitStrings(r.Condition, ",")
+	if len(condition) < 2 {
+		return false
+	}
+	target, _ := strconv.ParseFloat(condition[1], 64)
+
+	value, ok := r.Value()
+	if !ok {
+		return false
+	}
+
+	switch condition[0] {
+	case greaterThan:
+		return value > target
+	case greaterThanOrEqual:
+		return value >= target
+	case lessThan:
+		return value < target
+	case lessThanOrEqual:
+		return value <= target
 	case isEqual:
-		{
-			if lastPrice == targetPrice {
-				return e.ExecuteAction()
-			}
-		}
+		return value == target
 	}
 	return false
 }
 
+// CheckCondition will check the event structure to see if there is a condition
+// met and executes the action if so. PRICE, BID, ASK, SPREAD and VOLUME
+// conditions compare the ticker, PRICE_CHANGE conditions compare the percentage
+// change of the last price over the window given as the third part of the
+// condition, e.g. ">=,5,1h", BID_DEPTH and ASK_DEPTH conditions compare the
+// amount in the orderbook within the percentage of the best price given as the
+// third part, e.g. ">=,100,2", and BALANCE conditions compare the exchange
+// balance of the first currency of the pair. ARBITRAGE conditions compare the
+// profit percentage of the best arbitrage opportunity involving the exchange
+// and TRIANGULAR_ARBITRAGE conditions compare the profit percentage of the best
+// cycle on the exchange that trades the pair.
+func (e *Event) CheckCondition() bool {
+	met := e.Rule.IsMet()
+	for i := range e.Rules {
+		if common.StringToUpper(e.Logic) == logicOr {
+			met = met || e.Rules[i].IsMet()
+		} else {
+			met = met && e.Rules[i].IsMet()
+		}
+	}
+
+	if !met {
+		return false
+	}
+	return e.ExecuteAction()
+}
+
 // IsValidEvent checks the actions to be taken and returns an error if incorrect
 func IsValidEvent(Exchange, Item, Condition, Action string) error {
-	Exchange = common.StringToUpper(Exchange)
-	Item = common.StringToUpper(Item)
-	Action = common.StringToUpper(Action)
+	err := IsValidRule(Rule{Exchange: Exchange, Item: Item, Condition: Condition})
+	if err != nil {
+		return err
+	}
+	return isValidEventAction(Action)
+}
+
+// IsValidRule checks the exchanges, item and condition of a rule and returns
+// an error if incorrect
+func IsValidRule(r Rule) error {
+	Item := common.StringToUpper(r.Item)
 
-	if !IsValidExchange(Exchange) {
+	if !IsValidExchange(r.Exchange) {
+		return errExchangeDisabled
+	}
+
+	if r.CompareExchange != "" && !IsValidExchange(r.CompareExchange) {
 		return errExchangeDisabled
 	}
 
@@ -195,32 +706,77 @@ func IsValidEvent(Exchange, Item, Condition, Action string) error {
 		return errInvalidItem
 	}
 
-	if !common.StringContains(Condition, ",") {
+	if r.CompareExchange != "" && (r.isArbitrage() || r.isTriangular()) {
+		return errInvalidItem
+	}
+
+	if !common.StringContains(r.Condition, ",") {
 		return errInvalidCondition
 	}
 
-	condition := common.SplitStrings(Condition, ",")
+	condition := common.SplitStrings(r.Condition, ",")
 
 	if !IsValidCondition(condition[0]) || len(condition[1]) == 0 {
 		return errInvalidCondition
 	}
 
+	if len(condition) > 2 {
+		switch Item {
+		case itemPriceChange:
+			window, err := time.ParseDuration(condition[2])
+			if err != nil || window <= 0 {
+				return errInvalidCondition
+			}
+		case itemBidDepth, itemAskDepth:
+			band, err := strconv.ParseFloat(condition[2], 64)
+			if err != nil || band <= 0 {
+				return errInvalidCondition
+			}
+		default:
+			return errInvalidCondition
+		}
+	}
+	return nil
+}
+
+func isValidEventAction(Action string) error {
+	Action = common.StringToUpper(Action)
+
+	if isOrderAction(Action) {
+		_, err := parseOrderAction(Action)
+		return err
+	}
+
 	if common.StringContains(Action, ",") {
 		action := common.SplitStrings(Action, ",")
 
+		if action[0] == actionNotify {
+			for _, channel := range action[1:] {
+				if channel == "" {
+					return errInvalidAction
+				}
+			}
+			return nil
+		}
+
 		if action[0] != actionSMSNotify {
 			return errInvalidAction
 		}
 
+		s := smsglobal.SMSGlobal
+		if s == nil {
+			return errInvalidAction
+		}
+
 		if action[1] != "ALL" {
-			s := smsglobal.SMSGlobal
 			_, err := s.GetContactByName(action[1])
 			if err != nil {
 				return errInvalidAction
 			}
 		}
 	} else {
-		if Action != actionConsolePrint && Action != actionTest {
+		if Action != actionConsolePrint && Action != actionTest && Action != actionCancelAll &&
+			Action != actionNotify {
 			return errInvalidAction
 		}
 	}
@@ -228,27 +784,93 @@ func IsValidEvent(Exchange, Item, Condition, Action string) error {
 }
 
 // CheckEvents is the overarching routine that will iterate through the Events
-// chain
+// chain, events are checked whenever the tickers, orderbooks or arbitrage
+// opportunities they watch change. BALANCE conditions are checked on updates
+// of the ticker of their pair.
 func CheckEvents() {
+	tickers := ticker.Subscribe()
+	orderbooks := orderbook.Subscribe()
+	opportunities := arbitrage.Subscribe()
+	cycles := arbitrage.SubscribeCycles()
 	for {
-		total, executed := GetEventCounter()
-		if total > 0 && executed != total {
-			for _, event := range Events {
-				if !event.Executed {
-					success := event.CheckCondition()
-					if success {
-						log.Printf(
-							"Event %d triggered on %s successfully.\n", event.ID,
-							event.Exchange,
-						)
-						event.Executed = true
-					}
-				}
-			}
+		select {
+		case t := <-tickers:
+			checkEvents(func(r *Rule) bool {
+				return !r.isDepth() && r.watches(t.Exchange, t.Pair, t.AssetType)
+			})
+		case o := <-orderbooks:
+			checkEvents(func(r *Rule) bool {
+				return r.isDepth() && r.watches(o.Exchange, o.Pair, o.AssetType)
+			})
+		case o := <-opportunities:
+			checkEvents(func(r *Rule) bool {
+				return r.isArbitrage() && r.Pair.Normalise() == o.Pair.Normalise() &&
+					(r.Exchange == o.BuyExchange || r.Exchange == o.SellExchange)
+			})
+		case c := <-cycles:
+			checkEvents(func(r *Rule) bool {
+				return r.isTriangular() && r.Exchange == c.Exchange && r.tradesPair(c)
+			})
 		}
 	}
 }
 
+// checkEvents checks the conditions of the pending events with a rule
+// selected by match. The conditions are checked and the actions executed on
+// copies of the events without holding m, as actions can take a while, and
+// the triggered events are then updated if they still exist.
+func checkEvents(match func(r *Rule) bool) {
+	now := time.Now()
+	var pending []*Event
+	m.Lock()
+	for _, event := range Events {
+		if event.isPending(now) && event.watches(match) {
+			pending = append(pending, event.copy())
+		}
+	}
+	m.Unlock()
+
+	for _, event := range pending {
+		if !event.CheckCondition() {
+			continue
+		}
+		log.Printf(
+			"Event %d triggered on %s successfully.\n", event.ID,
+			event.Exchange,
+		)
+		setTriggered(event.ID, now)
+	}
+}
+
+// setTriggered records that the event with the ID was triggered and notifies
+// the subscribers
+func setTriggered(EventID int, now time.Time) {
+	m.Lock()
+	defer m.Unlock()
+
+	for _, x := range Events {
+		if x.ID == EventID {
+			x.LastTriggered = now
+			x.Executed = !x.Repeat
+			notify(x)
+			return
+		}
+	}
+}
+
+// watches checks whether any rule of an event is selected by match
+func (e *Event) watches(match func(r *Rule) bool) bool {
+	if match(&e.Rule) {
+		return true
+	}
+	for i := range e.Rules {
+		if match(&e.Rules[i]) {
+			return true
+		}
+	}
+	return false
+}
+
 // IsValidExchange validates the exchange
 func IsValidExchange(Exchange string) bool {
 	Exchange = common.StringToUpper(Exchange)
@@ -274,7 +896,8 @@ func IsValidCondition(Condition string) bool {
 func IsValidAction(Action string) bool {
 	Action = common.StringToUpper(Action)
 	switch Action {
-	case actionSMSNotify, actionConsolePrint, actionTest:
+	case actionSMSNotify, actionNotify, actionConsolePrint, actionTest, actionBuy, actionSell,
+		actionCancelAll:
 		return true
 	}
 	return false
@@ -284,7 +907,8 @@ func IsValidAction(Action string) bool {
 func IsValidItem(Item string) bool {
 	Item = common.StringToUpper(Item)
 	switch Item {
-	case itemPrice:
+	case itemPrice, itemBid, itemAsk, itemSpread, itemVolume, itemPriceChange,
+		itemBidDepth, itemAskDepth, itemBalance, itemArbitrage, itemTriangular:
 		return true
 	}
 	return false
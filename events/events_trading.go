@@ -0,0 +1,234 @@
+package events
+
+import (
+	"errors"
+	"log"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/mattkanwisher/cryptofiend/common"
+	"github.com/mattkanwisher/cryptofiend/config"
+	"github.com/mattkanwisher/cryptofiend/currency/pair"
+	exchange "github.com/mattkanwisher/cryptofiend/exchanges"
+	"github.com/mattkanwisher/cryptofiend/exchanges/ticker"
+)
+
+const (
+	actionBuy       = "BUY"
+	actionSell      = "SELL"
+	actionCancelAll = "CANCEL_ALL"
+
+	// priceMarket prices an order at the opposite side of the book so that it
+	// fills immediately, exchanges only support limit orders
+	priceMarket = "MARKET"
+)
+
+var (
+	errOrderTooLarge       = errors.New("order amount exceeds the configured maximum")
+	errOrderTooSmall       = errors.New("order amount is below the exchange minimum")
+	errNoOrderPrice        = errors.New("no ticker price to place the order at")
+	errTradingNotSupported = errors.New("exchange does not support trading")
+	errNoOrderID           = errors.New("exchange did not return an order ID to track the order by")
+)
+
+// GetExchange returns an enabled exchange which can place orders for trading
+// actions, it is set by the bot on startup
+var GetExchange func(exchangeName string) (exchange.IBotExchangeEx, error)
+
+// orderAction is a BUY or SELL action. Orders are placed at the last price
+// adjusted by offset percent, or at the best price on the other side of the
+// book if market is set.
+type orderAction struct {
+	side   exchange.OrderSide
+	amount float64
+	offset float64
+	market bool
+}
+
+func isOrderAction(action string) bool {
+	action = common.StringToUpper(common.SplitStrings(action, ",")[0])
+	return action == actionBuy || action == actionSell
+}
+
+// parseOrderAction parses actions in the form "BUY,amount[,offset]", e.g.
+// "SELL,0.5,-1" places a sell order for 0.5 at 1% below the last price and
+// "BUY,0.5,MARKET" buys 0.5 at the lowest ask
+func parseOrderAction(action string) (orderAction, error) {
+	parts := common.SplitStrings(common.StringToUpper(action), ",")
+	if len(parts) < 2 || len(parts) > 3 {
+		return orderAction{}, errInvalidAction
+	}
+
+	o := orderAction{side: exchange.OrderSideBuy}
+	if parts[0] == actionSell {
+		o.side = exchange.OrderSideSell
+	}
+
+	amount, err := strconv.ParseFloat(parts[1], 64)
+	if err != nil || amount <= 0 {
+		return orderAction{}, errInvalidAction
+	}
+	o.amount = amount
+
+	if len(parts) == 3 {
+		if parts[2] == priceMarket {
+			o.market = true
+		} else {
+			o.offset, err = strconv.ParseFloat(parts[2], 64)
+			if err != nil || o.offset <= -100 {
+				return orderAction{}, errInvalidAction
+			}
+		}
+	}
+	return o, nil
+}
+
+// checkOrderAmount checks an order amount against the configured maximum for
+// the currency
+func checkOrderAmount(currency pair.CurrencyItem, amount float64) error {
+	limits := config.GetConfig().Events.MaxOrderAmounts
+	max, ok := limits[currency.Upper().String()]
+	if ok && amount > max {
+		return errOrderTooLarge
+	}
+	return nil
+}
+
+// roundDecimalPlaces rounds x to a number of decimal places, down if floor is
+// set, leaving it unchanged if places isn't defined
+func roundDecimalPlaces(x float64, places int32, floor bool) float64 {
+	if places < 0 {
+		return x
+	}
+	scale := math.Pow(10, float64(places))
+	if floor {
+		return math.Floor(x*scale) / scale
+	}
+	return math.Floor(x*scale+0.5) / scale
+}
+
+// orderPrice returns the price to place an order at from the ticker
+func (e *Event) orderPrice(o orderAction) (float64, error) {
+	t, err := ticker.GetTicker(e.Exchange, e.Pair, e.Asset)
+	if err != nil {
+		return 0, err
+	}
+
+	price := t.Last * (1 + o.offset/100)
+	if o.market {
+		price = t.Bid
+		if o.side == exchange.OrderSideBuy {
+			price = t.Ask
+		}
+	}
+
+	if price <= 0 {
+		return 0, errNoOrderPrice
+	}
+	return price, nil
+}
+
+// executeTrade places or cancels orders on the event's exchange and pair. In
+// dry run mode the orders are only logged.
+func (e *Event) executeTrade() error {
+	dryRun := config.GetConfig().Events.DryRun
+	p := exchange.FormatExchangeCurrencyPair(e.Exchange, e.Pair)
+
+	var exch exchange.IBotExchangeEx
+	if !dryRun {
+		if GetExchange == nil {
+			return errTradingNotSupported
+		}
+		var err error
+		exch, err = GetExchange(e.Exchange)
+		if err != nil {
+			return err
+		}
+	}
+
+	if common.StringToUpper(e.Action) == actionCancelAll {
+		if dryRun {
+			log.Printf("Event %d dry run: cancel all %s orders on %s.\n", e.ID,
+				p.Pair().String(), e.Exchange)
+			return nil
+		}
+		return cancelOrders(exch, p)
+	}
+
+	o, err := parseOrderAction(e.Action)
+	if err != nil {
+		return err
+	}
+
+	err = checkOrderAmount(e.Pair.Normalise().FirstCurrency, o.amount)
+	if err != nil {
+		return err
+	}
+
+	price, err := e.orderPrice(o)
+	if err != nil {
+		return err
+	}
+
+	if dryRun {
+		log.Printf("Event %d dry run: %s %f %s at %f on %s.\n", e.ID, o.side,
+			o.amount, p.Pair().String(), price, e.Exchange)
+		return nil
+	}
+
+	limits := exch.GetLimits()
+	if limits == nil {
+		limits = &exchange.DefaultExchangeLimits{}
+	}
+	amount := roundDecimalPlaces(o.amount, limits.GetAmountDecimalPlaces(p), true)
+	price = roundDecimalPlaces(price, limits.GetPriceDecimalPlaces(p), false)
+	if amount < limits.GetMinAmount(p) || amount*price < limits.GetMinTotal(p) {
+		return errOrderTooSmall
+	}
+
+	orderID, err := exch.NewOrder(p, amount, price, o.side, exchange.OrderTypeExchangeLimit)
+	if err != nil {
+		return err
+	}
+	// Without an ID the order can't be followed up, it may have been filled
+	// or left open on the book
+	if orderID == "" {
+		return errNoOrderID
+	}
+	log.Printf("Event %d placed %s order %s for %f %s at %f on %s.\n", e.ID, o.side,
+		orderID, amount, p.Pair().String(), price, e.Exchange)
+
+	order := exchange.Order{
+		CurrencyPair:    p,
+		Type:            exchange.OrderTypeExchangeLimit,
+		Side:            o.side,
+		Amount:          amount,
+		RemainingAmount: amount,
+		Rate:            price,
+		CreatedAt:       time.Now().Unix(),
+		Status:          exchange.OrderStatusActive,
+		OrderID:         orderID,
+	}
+	exchange.PublishOrderEvent(e.Exchange, exchange.OrderEventPlaced, order)
+	return nil
+}
+
+// cancelOrders cancels every active order on a currency pair
+func cancelOrders(exch exchange.IBotExchangeEx, p pair.CurrencyPair) error {
+	orders, err := exch.GetOrders([]pair.CurrencyPair{p})
+	if err != nil {
+		return err
+	}
+
+	for _, x := range orders {
+		err = exch.CancelOrder(x.OrderID, p)
+		if err != nil {
+			return err
+		}
+		order := *x
+		order.Status = exchange.OrderStatusAborted
+		exchange.PublishOrderEvent(exch.GetName(), exchange.OrderEventCancelled, order)
+	}
+	return nil
+}
@@ -0,0 +1,145 @@
+package events
+
+import (
+	"math"
+	"testing"
+
+	"github.com/mattkanwisher/cryptofiend/config"
+	"github.com/mattkanwisher/cryptofiend/currency/pair"
+	exchange "github.com/mattkanwisher/cryptofiend/exchanges"
+	"github.com/mattkanwisher/cryptofiend/exchanges/ticker"
+)
+
+type testOrder struct {
+	pair   pair.CurrencyPair
+	amount float64
+	price  float64
+	side   exchange.OrderSide
+}
+
+type testExchange struct {
+	exchange.IBotExchangeEx
+	orders    []testOrder
+	cancelled []string
+	noOrderID bool
+}
+
+func (e *testExchange) GetName() string {
+	return "ANX"
+}
+
+func (e *testExchange) NewOrder(p pair.CurrencyPair, amount, price float64, side exchange.OrderSide, orderType exchange.OrderType) (string, error) {
+	e.orders = append(e.orders, testOrder{p, amount, price, side})
+	if e.noOrderID {
+		return "", nil
+	}
+	return "1", nil
+}
+
+func (e *testExchange) CancelOrder(orderID string, p pair.CurrencyPair) error {
+	e.cancelled = append(e.cancelled, orderID)
+	return nil
+}
+
+func (e *testExchange) GetOrders(pairs []pair.CurrencyPair) ([]*exchange.Order, error) {
+	return []*exchange.Order{{OrderID: "2"}, {OrderID: "3"}}, nil
+}
+
+func (e *testExchange) GetLimits() exchange.ILimits {
+	return nil
+}
+
+func TestParseOrderAction(t *testing.T) {
+	tests := []struct {
+		action string
+		valid  bool
+		order  orderAction
+	}{
+		{"buy,0.5", true, orderAction{side: exchange.OrderSideBuy, amount: 0.5}},
+		{"SELL,2,-1.5", true, orderAction{side: exchange.OrderSideSell, amount: 2, offset: -1.5}},
+		{"SELL,2,market", true, orderAction{side: exchange.OrderSideSell, amount: 2, market: true}},
+		{"BUY", false, orderAction{}},
+		{"BUY,-1", false, orderAction{}},
+		{"BUY,1,soon", false, orderAction{}},
+		{"BUY,1,-100", false, orderAction{}},
+	}
+
+	for _, test := range tests {
+		o, err := parseOrderAction(test.action)
+		if (err == nil) != test.valid || o != test.order {
+			t.Errorf("Test failed. TestParseOrderAction %s: unexpected %+v, %v", test.action, o, err)
+		}
+	}
+}
+
+func TestExecuteTrade(t *testing.T) {
+	testSetup(t)
+
+	cfg := config.GetConfig()
+	events := cfg.Events
+	defer func() { cfg.Events = events }()
+
+	exch := &testExchange{}
+	orders := exchange.SubscribeOrderEvents()
+	GetExchange = func(exchangeName string) (exchange.IBotExchangeEx, error) {
+		return exch, nil
+	}
+	defer func() { GetExchange = nil }()
+
+	newPair := pair.NewCurrencyPair("ETC", "USD")
+	ticker.ProcessTicker("ANX", newPair, ticker.Price{Last: 20, Bid: 19, Ask: 21}, ticker.Spot)
+	// The event isn't added so that it can't be triggered by CheckEvents
+	event := &Event{
+		Rule:   Rule{Exchange: "ANX", Item: "price", Condition: "<,25", Pair: newPair, Asset: "SPOT"},
+		Action: "SELL,1.123456789,-1",
+	}
+	err := isValidEventConfig(event)
+	if err != nil {
+		t.Fatalf("Test failed. TestExecuteTrade: Error, %s", err)
+	}
+
+	cfg.Events.DryRun = true
+	if !event.CheckCondition() || len(exch.orders) != 0 {
+		t.Error("Test failed. TestExecuteTrade: dry run placed an order")
+	}
+
+	cfg.Events.DryRun = false
+	if !event.CheckCondition() || len(exch.orders) != 1 {
+		t.Fatal("Test failed. TestExecuteTrade: order not placed")
+	}
+	o := exch.orders[0]
+	if o.side != exchange.OrderSideSell || o.amount != 1.12345678 || math.Abs(o.price-19.8) > 1e-9 {
+		t.Errorf("Test failed. TestExecuteTrade: unexpected order %+v", o)
+	}
+	if x := <-orders; x.Type != exchange.OrderEventPlaced || x.Order.OrderID != "1" || x.Order.Rate != o.price {
+		t.Errorf("Test failed. TestExecuteTrade: unexpected order event %+v", x)
+	}
+
+	event.Action = "BUY,2,MARKET"
+	if !event.ExecuteAction() || exch.orders[1].price != 21 {
+		t.Error("Test failed. TestExecuteTrade: market order not placed at the ask")
+	}
+
+	exch.noOrderID = true
+	if event.ExecuteAction() || len(exch.orders) != 3 {
+		t.Error("Test failed. TestExecuteTrade: order without an ID treated as placed")
+	}
+	exch.noOrderID = false
+
+	cfg.Events.MaxOrderAmounts = map[string]float64{"ETC": 1}
+	if event.ExecuteAction() || len(exch.orders) != 3 {
+		t.Error("Test failed. TestExecuteTrade: maximum order amount ignored")
+	}
+
+	event.Action = "cancel_all"
+	if !event.ExecuteAction() || len(exch.cancelled) != 2 {
+		t.Error("Test failed. TestExecuteTrade: orders not cancelled")
+	}
+	for len(orders) > 0 {
+		x := <-orders
+		if x.Type == exchange.OrderEventCancelled && x.Order.OrderID == "3" {
+			return
+		}
+	}
+	t.Error("Test failed. TestExecuteTrade: cancelled orders not published")
+}
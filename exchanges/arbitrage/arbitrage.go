@@ -0,0 +1,205 @@
+package arbitrage
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/mattkanwisher/cryptofiend/currency/pair"
+	"github.com/mattkanwisher/cryptofiend/exchanges/orderbook"
+)
+
+// SubscriberBufferSize is the number of undelivered opportunities held for
+// each subscriber before further opportunities are dropped
+const SubscriberBufferSize = 256
+
+// Venue is the orderbook of a currency pair on an exchange, along with the
+// costs of trading on it
+type Venue struct {
+	Exchange  string
+	Orderbook orderbook.Base
+	// TakerFee is the percentage fee charged by the exchange when taking
+	// liquidity
+	TakerFee float64
+	// WithdrawalFee is the amount of the base currency charged to withdraw it
+	// from the exchange once bought
+	WithdrawalFee float64
+}
+
+// Opportunity is a price difference between two exchanges that can be traded
+// by buying on one and selling on the other. Amount is the size executable
+// against the current books, BuyPrice and SellPrice are the average fill
+// prices, and Profit is net of taker fees and the withdrawal fee.
+type Opportunity struct {
+	Pair          pair.CurrencyPair `json:"pair"`
+	BuyExchange   string            `json:"buy_exchange"`
+	SellExchange  string            `json:"sell_exchange"`
+	Amount        float64           `json:"amount"`
+	BuyPrice      float64           `json:"buy_price"`
+	SellPrice     float64           `json:"sell_price"`
+	Cost          float64           `json:"cost"`
+	Proceeds      float64           `json:"proceeds"`
+	Profit        float64           `json:"profit"`
+	ProfitPercent float64           `json:"profit_percent"`
+	Timestamp     time.Time         `json:"timestamp"`
+}
+
+// FindOpportunity walks the asks of the buy venue and the bids of the sell
+// venue for as long as buying is cheaper than selling after taker fees. It
+// returns false if nothing can be traded at a profit once the withdrawal fee
+// is paid.
+func FindOpportunity(p pair.CurrencyPair, buy, sell Venue) (Opportunity, bool) {
+	buyFee := buy.TakerFee / 100
+	sellFee := sell.TakerFee / 100
+	asks := buy.Orderbook.Asks
+	bids := sell.Orderbook.Bids
+
+	o := Opportunity{
+		Pair:         p,
+		BuyExchange:  buy.Exchange,
+		SellExchange: sell.Exchange,
+	}
+
+	var i, j int
+	var askLeft, bidLeft float64
+	if len(asks) > 0 {
+		askLeft = asks[0].Amount
+	}
+	if len(bids) > 0 {
+		bidLeft = bids[0].Amount
+	}
+
+	for i < len(asks) && j < len(bids) {
+		ask := asks[i].Price * (1 + buyFee)
+		bid := bids[j].Price * (1 - sellFee)
+		if ask >= bid {
+			break
+		}
+
+		amount := askLeft
+		if bidLeft < amount {
+			amount = bidLeft
+		}
+		o.Amount += amount
+		o.Cost += amount * ask
+		o.Proceeds += amount * bid
+		o.BuyPrice += amount * asks[i].Price
+		o.SellPrice += amount * bids[j].Price
+
+		askLeft -= amount
+		bidLeft -= amount
+		if askLeft <= 0 {
+			i++
+			if i < len(asks) {
+				askLeft = asks[i].Amount
+			}
+		}
+		if bidLeft <= 0 {
+			j++
+			if j < len(bids) {
+				bidLeft = bids[j].Amount
+			}
+		}
+	}
+
+	if o.Amount == 0 {
+		return Opportunity{}, false
+	}
+
+	o.BuyPrice /= o.Amount
+	o.SellPrice /= o.Amount
+	o.Profit = o.Proceeds - o.Cost - buy.WithdrawalFee*o.SellPrice
+	if o.Profit <= 0 {
+		return Opportunity{}, false
+	}
+	o.ProfitPercent = o.Profit / o.Cost * 100
+	o.Timestamp = time.Now()
+	return o, true
+}
+
+// Scan checks every ordered pair of venues for opportunities with a profit of
+// at least minProfitPercent, the most profitable first
+func Scan(p pair.CurrencyPair, venues []Venue, minProfitPercent float64) []Opportunity {
+	var result []Opportunity
+	for _, buy := range venues {
+		for _, sell := range venues {
+			if buy.Exchange == sell.Exchange {
+				continue
+			}
+			o, ok := FindOpportunity(p, buy, sell)
+			if ok && o.ProfitPercent >= minProfitPercent {
+				result = append(result, o)
+			}
+		}
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Profit > result[j].Profit
+	})
+	return result
+}
+
+// opportunities holds the latest opportunities found for each currency pair
+var opportunities = struct {
+	m           sync.RWMutex
+	pairs       map[pair.CurrencyItem][]Opportunity
+	subscribers []chan Opportunity
+}{pairs: make(map[pair.CurrencyItem][]Opportunity)}
+
+// SetOpportunities replaces the opportunities of a currency pair with the
+// result of a new scan and sends them to subscribers
+func SetOpportunities(p pair.CurrencyPair, result []Opportunity) {
+	opportunities.m.Lock()
+	defer opportunities.m.Unlock()
+
+	key := p.Normalise().Pair()
+	if len(result) == 0 {
+		delete(opportunities.pairs, key)
+		return
+	}
+	opportunities.pairs[key] = result
+
+	for _, o := range result {
+		for _, ch := range opportunities.subscribers {
+			select {
+			case ch <- o:
+			default:
+			}
+		}
+	}
+}
+
+// GetOpportunities returns the latest opportunities of every currency pair,
+// the most profitable first
+func GetOpportunities() []Opportunity {
+	opportunities.m.RLock()
+	defer opportunities.m.RUnlock()
+
+	var result []Opportunity
+	for _, x := range opportunities.pairs {
+		result = append(result, x...)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Profit > result[j].Profit
+	})
+	return result
+}
+
+// GetPairOpportunities returns the latest opportunities of a currency pair
+func GetPairOpportunities(p pair.CurrencyPair) []Opportunity {
+	opportunities.m.RLock()
+	defer opportunities.m.RUnlock()
+
+	return append([]Opportunity(nil), opportunities.pairs[p.Normalise().Pair()]...)
+}
+
+// Subscribe returns a channel which receives every opportunity found by
+// subsequent scans
+func Subscribe() <-chan Opportunity {
+	opportunities.m.Lock()
+	defer opportunities.m.Unlock()
+
+	ch := make(chan Opportunity, SubscriberBufferSize)
+	opportunities.subscribers = append(opportunities.subscribers, ch)
+	return ch
+}
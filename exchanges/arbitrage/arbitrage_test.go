@@ -0,0 +1,89 @@
+package arbitrage
+
+import (
+	"math"
+	"testing"
+
+	"github.com/mattkanwisher/cryptofiend/currency/pair"
+	"github.com/mattkanwisher/cryptofiend/exchanges/orderbook"
+)
+
+func testVenues() []Venue {
+	return []Venue{
+		{
+			Exchange: "Kraken",
+			Orderbook: orderbook.Base{
+				Bids: []orderbook.Item{{Price: 99, Amount: 5}},
+				Asks: []orderbook.Item{{Price: 100, Amount: 1}, {Price: 101, Amount: 2}, {Price: 105, Amount: 10}},
+			},
+			TakerFee:      0.1,
+			WithdrawalFee: 0.01,
+		},
+		{
+			Exchange: "GDAX",
+			Orderbook: orderbook.Base{
+				Bids: []orderbook.Item{{Price: 103, Amount: 2}, {Price: 102, Amount: 5}},
+				Asks: []orderbook.Item{{Price: 104, Amount: 5}},
+			},
+			TakerFee: 0.25,
+		},
+	}
+}
+
+func TestFindOpportunity(t *testing.T) {
+	t.Parallel()
+	p := pair.NewCurrencyPair("BTC", "USD")
+	venues := testVenues()
+
+	o, ok := FindOpportunity(p, venues[0], venues[1])
+	if !ok {
+		t.Fatal("Test failed. TestFindOpportunity expected an opportunity")
+	}
+	if o.Amount != 3 || o.BuyExchange != "Kraken" || o.SellExchange != "GDAX" {
+		t.Errorf("Test failed. TestFindOpportunity unexpected opportunity %+v", o)
+	}
+
+	cost := 100*1.001 + 2*101*1.001
+	proceeds := 2*103*0.9975 + 102*0.9975
+	profit := proceeds - cost - 0.01*o.SellPrice
+	if math.Abs(o.Profit-profit) > 1e-9 || math.Abs(o.BuyPrice-302.0/3) > 1e-9 {
+		t.Errorf("Test failed. TestFindOpportunity expected profit %f, got %+v", profit, o)
+	}
+
+	_, ok = FindOpportunity(p, venues[1], venues[0])
+	if ok {
+		t.Error("Test failed. TestFindOpportunity found an opportunity in the wrong direction")
+	}
+
+	// A withdrawal fee larger than the profit rules the opportunity out
+	venues[0].WithdrawalFee = 1
+	_, ok = FindOpportunity(p, venues[0], venues[1])
+	if ok {
+		t.Error("Test failed. TestFindOpportunity ignored the withdrawal fee")
+	}
+}
+
+func TestScan(t *testing.T) {
+	p := pair.NewCurrencyPair("BTC", "USD")
+	result := Scan(p, testVenues(), 0)
+	if len(result) != 1 {
+		t.Fatalf("Test failed. TestScan expected 1 opportunity, got %d", len(result))
+	}
+	if len(Scan(p, testVenues(), 50)) != 0 {
+		t.Error("Test failed. TestScan ignored the minimum profit")
+	}
+
+	ch := Subscribe()
+	SetOpportunities(pair.NewCurrencyPair("xbt", "usd"), result)
+	if o := <-ch; o.BuyExchange != "Kraken" {
+		t.Errorf("Test failed. TestScan unexpected published opportunity %+v", o)
+	}
+	if len(GetPairOpportunities(p)) != 1 || len(GetOpportunities()) != 1 {
+		t.Error("Test failed. TestScan opportunities weren't stored")
+	}
+
+	SetOpportunities(p, nil)
+	if len(GetOpportunities()) != 0 {
+		t.Error("Test failed. TestScan opportunities weren't cleared")
+	}
+}
@@ -0,0 +1,417 @@
+package arbitrage
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/mattkanwisher/cryptofiend/currency/pair"
+	exchange "github.com/mattkanwisher/cryptofiend/exchanges"
+	"github.com/mattkanwisher/cryptofiend/exchanges/orderbook"
+)
+
+// Const values for triangular arbitrage
+const (
+	ErrCycleLegTooSmall   = "Triangular arbitrage leg amount rounds down to zero, remaining legs not placed."
+	ErrCycleLegNoOrderID  = "Triangular arbitrage leg order was placed without an ID, remaining legs not placed."
+	ErrCycleLegNotFilled  = "Triangular arbitrage leg was not filled in time and was cancelled, remaining legs not placed."
+	ErrCycleLegCancelFail = "Triangular arbitrage leg was not filled in time and could not be cancelled, remaining legs not placed. Error: %s"
+
+	// sizeSearchIterations bounds the search for the most profitable size
+	sizeSearchIterations = 100
+)
+
+// Market is the orderbook of a currency pair on the exchange being searched,
+// along with the exchange's limits for it. Pair is in the format used by the
+// exchange so that orders can be placed with it.
+type Market struct {
+	Pair      pair.CurrencyPair
+	Orderbook orderbook.Base
+	MinAmount float64
+	MinTotal  float64
+}
+
+// Leg is one trade of a triangular cycle. Amount is in the base currency of
+// Pair and Price is the worst price the trade is filled at, which is used as
+// the limit price when the cycle is executed.
+type Leg struct {
+	Pair   pair.CurrencyPair `json:"pair"`
+	Side   string            `json:"side"`
+	From   string            `json:"from"`
+	To     string            `json:"to"`
+	Amount float64           `json:"amount"`
+	Price  float64           `json:"price"`
+}
+
+// Cycle is a profitable sequence of three trades on one exchange that starts
+// and ends with the same currency. StartAmount is the most profitable amount
+// of Currency to trade given the depth of the books.
+type Cycle struct {
+	Exchange      string    `json:"exchange"`
+	Currency      string    `json:"currency"`
+	Legs          []Leg     `json:"legs"`
+	StartAmount   float64   `json:"start_amount"`
+	EndAmount     float64   `json:"end_amount"`
+	Profit        float64   `json:"profit"`
+	ProfitPercent float64   `json:"profit_percent"`
+	Timestamp     time.Time `json:"timestamp"`
+}
+
+// edge is a conversion from one currency to another through a market
+type edge struct {
+	market *Market
+	side   string
+	from   string
+	to     string
+}
+
+// convert returns the amount of the to currency received for amount of the
+// from currency after the fee, and the leg traded. It returns false if the
+// book doesn't have enough depth.
+func (e *edge) convert(amount, fee float64) (float64, Leg, bool) {
+	leg := Leg{Pair: e.market.Pair, Side: e.side, From: e.from, To: e.to}
+	received := float64(0)
+	remaining := amount
+
+	if e.side == orderbook.Buy {
+		// Spending the quote currency on the asks
+		for _, x := range e.market.Orderbook.Asks {
+			if remaining <= 0 {
+				break
+			}
+			base := math.Min(x.Amount, remaining/x.Price)
+			leg.Amount += base
+			leg.Price = x.Price
+			received += base
+			remaining -= base * x.Price
+		}
+	} else {
+		// Selling the base currency into the bids
+		for _, x := range e.market.Orderbook.Bids {
+			if remaining <= 0 {
+				break
+			}
+			base := math.Min(x.Amount, remaining)
+			leg.Amount += base
+			leg.Price = x.Price
+			received += base * x.Price
+			remaining -= base
+		}
+	}
+
+	if remaining > amount*1e-9 {
+		return 0, leg, false
+	}
+	return received * (1 - fee), leg, true
+}
+
+// meetsLimits checks a leg against the minimums of its market
+func (e *edge) meetsLimits(leg Leg) bool {
+	return leg.Amount >= e.market.MinAmount && leg.Amount*leg.Price >= e.market.MinTotal
+}
+
+// run converts amount through each edge of a cycle, returning zero if a book
+// runs out of depth
+func run(edges []*edge, amount, fee float64) (float64, []Leg) {
+	var legs []Leg
+	for _, e := range edges {
+		var leg Leg
+		var ok bool
+		amount, leg, ok = e.convert(amount, fee)
+		if !ok {
+			return 0, nil
+		}
+		legs = append(legs, leg)
+	}
+	return amount, legs
+}
+
+// maxStartAmount is the amount of the start currency that uses up the whole
+// first book of a cycle
+func maxStartAmount(e *edge) float64 {
+	total := float64(0)
+	if e.side == orderbook.Buy {
+		for _, x := range e.market.Orderbook.Asks {
+			total += x.Amount * x.Price
+		}
+	} else {
+		for _, x := range e.market.Orderbook.Bids {
+			total += x.Amount
+		}
+	}
+	return total
+}
+
+// evaluate finds the most profitable amount to trade around a cycle. As prices
+// get worse with size the profit rises to a single peak, which is found with a
+// ternary search.
+func evaluate(exchangeName string, edges []*edge, fee float64) (Cycle, bool) {
+	profit := func(x float64) float64 {
+		out, _ := run(edges, x, fee)
+		return out - x
+	}
+
+	low, high := float64(0), maxStartAmount(edges[0])
+	for i := 0; i < sizeSearchIterations && high-low > high*1e-12; i++ {
+		a := low + (high-low)/3
+		b := high - (high-low)/3
+		if profit(a) < profit(b) {
+			low = a
+		} else {
+			high = b
+		}
+	}
+
+	start := (low + high) / 2
+	end, legs := run(edges, start, fee)
+	if start <= 0 || end <= start {
+		return Cycle{}, false
+	}
+	for i, e := range edges {
+		if !e.meetsLimits(legs[i]) {
+			return Cycle{}, false
+		}
+	}
+
+	return Cycle{
+		Exchange:      exchangeName,
+		Currency:      edges[0].from,
+		Legs:          legs,
+		StartAmount:   start,
+		EndAmount:     end,
+		Profit:        end - start,
+		ProfitPercent: (end - start) / start * 100,
+		Timestamp:     time.Now(),
+	}, true
+}
+
+// FindCycles searches the markets of an exchange for profitable three leg
+// cycles after the taker fee percentage, with a profit of at least
+// minProfitPercent. Each cycle is reported once, starting from the currency
+// that sorts first, the most profitable first.
+func FindCycles(exchangeName string, markets []Market, takerFee, minProfitPercent float64) []Cycle {
+	graph := make(map[string][]*edge)
+	for i := range markets {
+		m := &markets[i]
+		p := m.Pair.Normalise()
+		base, quote := p.FirstCurrency.String(), p.SecondCurrency.String()
+		if len(m.Orderbook.Asks) > 0 {
+			graph[quote] = append(graph[quote], &edge{m, orderbook.Buy, quote, base})
+		}
+		if len(m.Orderbook.Bids) > 0 {
+			graph[base] = append(graph[base], &edge{m, orderbook.Sell, base, quote})
+		}
+	}
+
+	fee := takerFee / 100
+	var result []Cycle
+	for start, first := range graph {
+		for _, a := range first {
+			if a.to <= start {
+				continue
+			}
+			for _, b := range graph[a.to] {
+				if b.to <= start || b.market == a.market {
+					continue
+				}
+				for _, c := range graph[b.to] {
+					if c.to != start || c.market == b.market || c.market == a.market {
+						continue
+					}
+					cycle, ok := evaluate(exchangeName, []*edge{a, b, c}, fee)
+					if ok && cycle.ProfitPercent >= minProfitPercent {
+						result = append(result, cycle)
+					}
+				}
+			}
+		}
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].ProfitPercent > result[j].ProfitPercent
+	})
+	return result
+}
+
+// fillPollDelay is how often an order placed for a leg is checked while
+// waiting for it to fill
+var fillPollDelay = time.Second
+
+// ExecuteCycle places a limit order for each leg of a cycle in turn, at the
+// worst price the leg was expected to fill at, and waits for it to fill before
+// placing the next. Amounts are rounded down to the exchange's precision. An
+// order that isn't filled within fillTimeout is cancelled, and the remaining
+// legs aren't placed if an order fails or isn't filled.
+func ExecuteCycle(exch exchange.IBotExchangeEx, c Cycle, fillTimeout time.Duration) ([]string, error) {
+	limits := exch.GetLimits()
+	if limits == nil {
+		limits = &exchange.DefaultExchangeLimits{}
+	}
+
+	var orderIDs []string
+	for _, leg := range c.Legs {
+		amount := leg.Amount
+		if places := limits.GetAmountDecimalPlaces(leg.Pair); places >= 0 {
+			scale := math.Pow(10, float64(places))
+			amount = math.Floor(amount*scale) / scale
+		}
+		if amount <= 0 {
+			return orderIDs, errors.New(ErrCycleLegTooSmall)
+		}
+
+		side := exchange.OrderSideSell
+		if leg.Side == orderbook.Buy {
+			side = exchange.OrderSideBuy
+		}
+		id, err := exch.NewOrder(leg.Pair, amount, leg.Price, side, exchange.OrderTypeExchangeLimit)
+		if err != nil {
+			return orderIDs, err
+		}
+		if id == "" {
+			return orderIDs, errors.New(ErrCycleLegNoOrderID)
+		}
+		orderIDs = append(orderIDs, id)
+
+		err = waitForFill(exch, id, leg.Pair, fillTimeout)
+		if err != nil {
+			return orderIDs, err
+		}
+	}
+	return orderIDs, nil
+}
+
+// waitForFill polls an order until it is filled, cancelling it if it isn't
+// filled within timeout
+func waitForFill(exch exchange.IBotExchangeEx, orderID string, p pair.CurrencyPair, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		order, err := exch.GetOrder(orderID, p)
+		if err == nil {
+			switch order.Status {
+			case exchange.OrderStatusFilled:
+				return nil
+			case exchange.OrderStatusAborted:
+				return errors.New(ErrCycleLegNotFilled)
+			}
+		}
+		if time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(fillPollDelay)
+	}
+
+	err := exch.CancelOrder(orderID, p)
+	if err != nil {
+		return fmt.Errorf(ErrCycleLegCancelFail, err)
+	}
+	return errors.New(ErrCycleLegNotFilled)
+}
+
+// executions holds the exchanges a cycle is being executed on and when each
+// cycle was last executed
+var executions = struct {
+	m       sync.Mutex
+	running map[string]bool
+	last    map[string]time.Time
+}{running: make(map[string]bool), last: make(map[string]time.Time)}
+
+// cycleKey identifies a cycle by its exchange and legs
+func cycleKey(c Cycle) string {
+	key := c.Exchange
+	for _, x := range c.Legs {
+		key += "," + x.Pair.Pair().String() + ":" + x.Side
+	}
+	return key
+}
+
+// StartExecution reserves the exchange of a cycle for it to be executed on. It
+// returns false if another cycle is being executed on the exchange, or if the
+// cycle was executed within cooldown. FinishExecution must be called once the
+// execution is done.
+func StartExecution(c Cycle, cooldown time.Duration) bool {
+	executions.m.Lock()
+	defer executions.m.Unlock()
+
+	key := cycleKey(c)
+	if executions.running[c.Exchange] || time.Since(executions.last[key]) < cooldown {
+		return false
+	}
+	executions.running[c.Exchange] = true
+	executions.last[key] = time.Now()
+	return true
+}
+
+// FinishExecution releases the exchange reserved by StartExecution
+func FinishExecution(c Cycle) {
+	executions.m.Lock()
+	defer executions.m.Unlock()
+
+	delete(executions.running, c.Exchange)
+}
+
+// cycles holds the latest cycles found on each exchange
+var cycles = struct {
+	m           sync.RWMutex
+	exchanges   map[string][]Cycle
+	subscribers []chan Cycle
+}{exchanges: make(map[string][]Cycle)}
+
+// SetCycles replaces the cycles of an exchange with the result of a new
+// search and sends them to subscribers
+func SetCycles(exchangeName string, result []Cycle) {
+	cycles.m.Lock()
+	defer cycles.m.Unlock()
+
+	if len(result) == 0 {
+		delete(cycles.exchanges, exchangeName)
+		return
+	}
+	cycles.exchanges[exchangeName] = result
+
+	for _, c := range result {
+		for _, ch := range cycles.subscribers {
+			select {
+			case ch <- c:
+			default:
+			}
+		}
+	}
+}
+
+// GetCycles returns the latest cycles of every exchange, the most profitable
+// first
+func GetCycles() []Cycle {
+	cycles.m.RLock()
+	defer cycles.m.RUnlock()
+
+	var result []Cycle
+	for _, x := range cycles.exchanges {
+		result = append(result, x...)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].ProfitPercent > result[j].ProfitPercent
+	})
+	return result
+}
+
+// GetExchangeCycles returns the latest cycles of an exchange
+func GetExchangeCycles(exchangeName string) []Cycle {
+	cycles.m.RLock()
+	defer cycles.m.RUnlock()
+
+	return append([]Cycle(nil), cycles.exchanges[exchangeName]...)
+}
+
+// SubscribeCycles returns a channel which receives every cycle found by
+// subsequent searches
+func SubscribeCycles() <-chan Cycle {
+	cycles.m.Lock()
+	defer cycles.m.Unlock()
+
+	ch := make(chan Cycle, SubscriberBufferSize)
+	cycles.subscribers = append(cycles.subscribers, ch)
+	return ch
+}
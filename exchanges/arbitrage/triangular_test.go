@@ -0,0 +1,162 @@
+package arbitrage
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/mattkanwisher/cryptofiend/currency/pair"
+	exchange "github.com/mattkanwisher/cryptofiend/exchanges"
+	"github.com/mattkanwisher/cryptofiend/exchanges/orderbook"
+)
+
+func testMarkets() []Market {
+	return []Market{
+		{
+			Pair: pair.NewCurrencyPair("ETH", "BTC"),
+			Orderbook: orderbook.Base{
+				Bids: []orderbook.Item{{Price: 0.049, Amount: 100}},
+				Asks: []orderbook.Item{{Price: 0.05, Amount: 10}, {Price: 0.06, Amount: 100}},
+			},
+		},
+		{
+			Pair: pair.NewCurrencyPair("XMR", "ETH"),
+			Orderbook: orderbook.Base{
+				Bids: []orderbook.Item{{Price: 0.19, Amount: 1000}},
+				Asks: []orderbook.Item{{Price: 0.2, Amount: 1000}},
+			},
+		},
+		{
+			Pair: pair.NewCurrencyPair("XMR", "XBT"),
+			Orderbook: orderbook.Base{
+				Bids: []orderbook.Item{{Price: 0.0105, Amount: 40}, {Price: 0.009, Amount: 1000}},
+				Asks: []orderbook.Item{{Price: 0.011, Amount: 1000}},
+			},
+		},
+	}
+}
+
+func TestFindCycles(t *testing.T) {
+	t.Parallel()
+	result := FindCycles("Poloniex", testMarkets(), 0.25, 0)
+	if len(result) != 1 {
+		t.Fatalf("Test failed. TestFindCycles expected 1 cycle, got %d", len(result))
+	}
+
+	c := result[0]
+	if c.Currency != "BTC" || len(c.Legs) != 3 || c.Profit <= 0 {
+		t.Fatalf("Test failed. TestFindCycles unexpected cycle %+v", c)
+	}
+	if c.Legs[0].Side != orderbook.Buy || c.Legs[0].To != "ETH" || c.Legs[2].Side != orderbook.Sell {
+		t.Errorf("Test failed. TestFindCycles unexpected legs %+v", c.Legs)
+	}
+	if c.Legs[2].Pair.FirstCurrency != "XMR" || c.Legs[2].Pair.SecondCurrency != "XBT" {
+		t.Error("Test failed. TestFindCycles legs must keep the exchange's pair format")
+	}
+	// Beyond 40 XMR the last leg's price drops below break even
+	if c.Legs[2].Amount > 40*1.0001 || c.Legs[2].Price != 0.0105 {
+		t.Errorf("Test failed. TestFindCycles size not constrained by depth %+v", c.Legs[2])
+	}
+
+	if len(FindCycles("Poloniex", testMarkets(), 5, 0)) != 0 {
+		t.Error("Test failed. TestFindCycles ignored the taker fee")
+	}
+	if len(FindCycles("Poloniex", testMarkets(), 0.25, 10)) != 0 {
+		t.Error("Test failed. TestFindCycles ignored the minimum profit")
+	}
+
+	markets := testMarkets()
+	markets[1].MinAmount = 1000
+	if len(FindCycles("Poloniex", markets, 0.25, 0)) != 0 {
+		t.Error("Test failed. TestFindCycles ignored the minimum amount")
+	}
+}
+
+func TestSetCycles(t *testing.T) {
+	ch := SubscribeCycles()
+	SetCycles("Poloniex", FindCycles("Poloniex", testMarkets(), 0.25, 0))
+	if c := <-ch; c.Exchange != "Poloniex" {
+		t.Errorf("Test failed. TestSetCycles unexpected published cycle %+v", c)
+	}
+	if len(GetExchangeCycles("Poloniex")) != 1 || len(GetCycles()) != 1 {
+		t.Error("Test failed. TestSetCycles cycles weren't stored")
+	}
+
+	SetCycles("Poloniex", nil)
+	if len(GetCycles()) != 0 {
+		t.Error("Test failed. TestSetCycles cycles weren't cleared")
+	}
+}
+
+type testExchange struct {
+	exchange.IBotExchangeEx
+	orders    int
+	unfilled  string
+	cancelled []string
+}
+
+func (e *testExchange) GetLimits() exchange.ILimits {
+	return nil
+}
+
+func (e *testExchange) NewOrder(p pair.CurrencyPair, amount, price float64, side exchange.OrderSide, orderType exchange.OrderType) (string, error) {
+	e.orders++
+	return strconv.Itoa(e.orders), nil
+}
+
+func (e *testExchange) GetOrder(orderID string, p pair.CurrencyPair) (*exchange.Order, error) {
+	if orderID == e.unfilled {
+		return &exchange.Order{OrderID: orderID, Status: exchange.OrderStatusActive}, nil
+	}
+	return &exchange.Order{OrderID: orderID, Status: exchange.OrderStatusFilled}, nil
+}
+
+func (e *testExchange) CancelOrder(orderID string, p pair.CurrencyPair) error {
+	e.cancelled = append(e.cancelled, orderID)
+	return nil
+}
+
+func TestExecuteCycle(t *testing.T) {
+	fillPollDelay = time.Millisecond
+	c := FindCycles("Poloniex", testMarkets(), 0.25, 0)[0]
+
+	exch := &testExchange{}
+	orderIDs, err := ExecuteCycle(exch, c, time.Second)
+	if err != nil || len(orderIDs) != 3 {
+		t.Fatalf("Test failed. TestExecuteCycle placed orders %v. Error: %v", orderIDs, err)
+	}
+
+	exch = &testExchange{unfilled: "2"}
+	orderIDs, err = ExecuteCycle(exch, c, time.Millisecond*10)
+	if err == nil || err.Error() != ErrCycleLegNotFilled {
+		t.Errorf("Test failed. TestExecuteCycle unexpected error %v", err)
+	}
+	if len(orderIDs) != 2 || exch.orders != 2 {
+		t.Errorf("Test failed. TestExecuteCycle placed a leg after an unfilled one %v", orderIDs)
+	}
+	if len(exch.cancelled) != 1 || exch.cancelled[0] != "2" {
+		t.Errorf("Test failed. TestExecuteCycle unfilled leg wasn't cancelled %v", exch.cancelled)
+	}
+}
+
+func TestStartExecution(t *testing.T) {
+	c := FindCycles("Bittrex", testMarkets(), 0.25, 0)[0]
+	other := c
+	other.Legs = c.Legs[1:]
+
+	if !StartExecution(c, time.Hour) {
+		t.Fatal("Test failed. TestStartExecution cycle not started")
+	}
+	if StartExecution(other, 0) {
+		t.Error("Test failed. TestStartExecution started a second cycle on the exchange")
+	}
+	FinishExecution(c)
+
+	if StartExecution(c, time.Hour) {
+		t.Error("Test failed. TestStartExecution cycle started within the cooldown")
+	}
+	if !StartExecution(other, time.Hour) {
+		t.Error("Test failed. TestStartExecution other cycle not started")
+	}
+	FinishExecution(other)
+}
@@ -25,6 +25,7 @@ const (
 	binanceOrderPath        = "api/v3/order"
 	binanceOrderTestPath    = "api/v3/order/test"
 	binanceDepthPath        = "api/v1/depth"
+	binanceUserDataPath     = "api/v1/userDataStream"
 )
 
 // BinanceErrCode enum represents a frequently encountered subset of the error codes documented at:
@@ -49,6 +50,8 @@ type Binance struct {
 	lastAccountInfo AccountInfo
 	lastOpenOrders  map[string][]Order
 	lastMarketData  map[string]*MarketData
+	// User data stream used for private websocket updates
+	userData userDataStream
 }
 
 // CurrencyPairToSymbol converts a currency pair to a symbol (exchange specific market identifier).
@@ -186,6 +189,32 @@ func (b *Binance) DeleteOrder(symbol string, orderID int64, clientOrderID string
 	return err
 }
 
+// CreateListenKey starts a new user data stream and returns the key used to
+// connect to it. The stream is closed unless kept alive at least once an hour.
+func (b *Binance) CreateListenKey() (string, error) {
+	response := ListenKeyResponse{}
+	_, err := b.SendHTTPRequest(http.MethodPost, binanceUserDataPath, nil, RequestSecurityAuth, &response)
+	return response.ListenKey, err
+}
+
+// KeepAliveListenKey extends the lifetime of a user data stream by an hour.
+func (b *Binance) KeepAliveListenKey(listenKey string) error {
+	v := url.Values{}
+	v.Set("listenKey", listenKey)
+	response := struct{}{}
+	_, err := b.SendHTTPRequest(http.MethodPut, binanceUserDataPath, v, RequestSecurityAuth, &response)
+	return err
+}
+
+// DeleteListenKey closes a user data stream.
+func (b *Binance) DeleteListenKey(listenKey string) error {
+	v := url.Values{}
+	v.Set("listenKey", listenKey)
+	response := struct{}{}
+	_, err := b.SendHTTPRequest(http.MethodDelete, binanceUserDataPath, v, RequestSecurityAuth, &response)
+	return err
+}
+
 // FetchMarketData fetches the orderbooks for the given symbol.
 // The limit parameter can be -1, 0, 5, 10, 20, 50, 100, 200, 1000.
 // Set the limit to -1 to use the default value (currently 100), or to 0 to disable the limit
@@ -178,3 +178,71 @@ type MarketData struct {
 	Bids         []OrderbookEntry `json:"bids"`
 	Asks         []OrderbookEntry `json:"asks"`
 }
+
+type ListenKeyResponse struct {
+	ListenKey string `json:"listenKey"`
+}
+
+// UserDataEvent holds the fields common to all user data stream events. Field
+// names in these events differ only by case, so every field present in an
+// event must be declared to stop values being decoded into the wrong field.
+type UserDataEvent struct {
+	EventType string `json:"e"`
+	EventTime int64  `json:"E"`
+}
+
+// ExecutionReport is pushed by the user data stream when one of our orders is
+// created, filled or cancelled
+type ExecutionReport struct {
+	EventType                string      `json:"e"`
+	EventTime                int64       `json:"E"`
+	Symbol                   string      `json:"s"`
+	ClientOrderID            string      `json:"c"`
+	Side                     OrderSide   `json:"S"`
+	Type                     OrderType   `json:"o"`
+	TimeInForce              TimeInForce `json:"f"`
+	Quantity                 float64     `json:"q,string"`
+	Price                    float64     `json:"p,string"`
+	StopPrice                float64     `json:"P,string"`
+	IcebergQuantity          float64     `json:"F,string"`
+	OrderListID              int64       `json:"g"`
+	OrigClientOrderID        string      `json:"C"`
+	ExecutionType            string      `json:"x"`
+	Status                   OrderStatus `json:"X"`
+	RejectReason             string      `json:"r"`
+	OrderID                  int64       `json:"i"`
+	LastExecutedQuantity     float64     `json:"l,string"`
+	CumulativeFilledQuantity float64     `json:"z,string"`
+	LastExecutedPrice        float64     `json:"L,string"`
+	Commission               float64     `json:"n,string"`
+	CommissionAsset          string      `json:"N"`
+	TransactionTime          int64       `json:"T"`
+	TradeID                  int64       `json:"t"`
+	Ignore                   int64       `json:"I"`
+	IsWorking                bool        `json:"w"`
+	IsMaker                  bool        `json:"m"`
+	IgnoreFlag               bool        `json:"M"`
+	CreationTime             int64       `json:"O"`
+	CumulativeQuoteQuantity  float64     `json:"Z,string"`
+	LastQuoteQuantity        float64     `json:"Y,string"`
+	QuoteOrderQuantity       float64     `json:"Q,string"`
+}
+
+// AccountUpdate is pushed by the user data stream when our balances change
+type AccountUpdate struct {
+	EventType        string `json:"e"`
+	EventTime        int64  `json:"E"`
+	MakerCommission  int64  `json:"m"`
+	TakerCommission  int64  `json:"t"`
+	BuyerCommission  int64  `json:"b"`
+	SellerCommission int64  `json:"s"`
+	CanTrade         bool   `json:"T"`
+	CanWithdraw      bool   `json:"W"`
+	CanDeposit       bool   `json:"D"`
+	LastUpdateTime   int64  `json:"u"`
+	Balances         []struct {
+		Asset  string  `json:"a"`
+		Free   float64 `json:"f,string"`
+		Locked float64 `json:"l,string"`
+	} `json:"B"`
+}
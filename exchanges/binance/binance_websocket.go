@@ -0,0 +1,175 @@
+package binance
+
+import (
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/mattkanwisher/cryptofiend/common"
+	exchange "github.com/mattkanwisher/cryptofiend/exchanges"
+)
+
+const (
+	binanceWebsocketURL = "wss://stream.binance.com:9443/ws/"
+	// Listen keys expire after an hour without a keepalive
+	binanceListenKeyKeepAlive = time.Minute * 30
+)
+
+// userDataStream holds the listen key of the user data stream, which is
+// replaced if it expires while the connection is down
+type userDataStream struct {
+	m         sync.Mutex
+	listenKey string
+}
+
+// WebsocketListenKey returns a valid listen key for the user data stream,
+// creating a new one if there isn't one or the current one has expired
+func (b *Binance) WebsocketListenKey() (string, error) {
+	b.userData.m.Lock()
+	defer b.userData.m.Unlock()
+
+	if b.userData.listenKey != "" {
+		if b.KeepAliveListenKey(b.userData.listenKey) == nil {
+			return b.userData.listenKey, nil
+		}
+	}
+
+	listenKey, err := b.CreateListenKey()
+	if err != nil {
+		return "", err
+	}
+	b.userData.listenKey = listenKey
+	return listenKey, nil
+}
+
+// WebsocketKeepAlive keeps the user data stream open while the websocket is
+// enabled
+func (b *Binance) WebsocketKeepAlive() {
+	for b.Enabled && b.Websocket {
+		time.Sleep(binanceListenKeyKeepAlive)
+
+		b.userData.m.Lock()
+		listenKey := b.userData.listenKey
+		b.userData.m.Unlock()
+
+		if listenKey == "" {
+			continue
+		}
+		err := b.KeepAliveListenKey(listenKey)
+		if err != nil {
+			log.Printf("%s failed to keep user data stream alive. Error: %s\n", b.GetName(), err)
+		}
+	}
+}
+
+// WebsocketProcessExecutionReport publishes a change to one of our orders, and
+// the fill that caused it if any, to private stream subscribers
+func (b *Binance) WebsocketProcessExecutionReport(r ExecutionReport) {
+	order := b.convertOrderToExchangeOrder(&Order{
+		Symbol:        r.Symbol,
+		OrderID:       r.OrderID,
+		ClientOrderID: r.ClientOrderID,
+		Price:         r.Price,
+		OrigQty:       r.Quantity,
+		ExecutedQty:   r.CumulativeFilledQuantity,
+		Status:        r.Status,
+		TimeInForce:   r.TimeInForce,
+		Type:          r.Type,
+		Side:          r.Side,
+		StopPrice:     r.StopPrice,
+		IcebergQty:    r.IcebergQuantity,
+		Time:          r.CreationTime,
+		IsWorking:     r.IsWorking,
+	})
+	b.PushOrderUpdate(*order)
+
+	if r.ExecutionType != "TRADE" {
+		return
+	}
+
+	b.Streams.PublishPrivate(exchange.PrivateEvent{
+		Exchange: b.GetName(),
+		Type:     exchange.PrivateEventFill,
+		Fill: &exchange.Fill{
+			OrderID:      order.OrderID,
+			TradeID:      strconv.FormatInt(r.TradeID, 10),
+			CurrencyPair: order.CurrencyPair,
+			Side:         order.Side,
+			Price:        r.LastExecutedPrice,
+			Amount:       r.LastExecutedQuantity,
+			Fee:          r.Commission,
+			FeeCurrency:  r.CommissionAsset,
+		},
+		Timestamp: time.Unix(0, r.TransactionTime*int64(time.Millisecond)),
+	})
+}
+
+// WebsocketProcessAccountUpdate publishes our balances to private stream
+// subscribers
+func (b *Binance) WebsocketProcessAccountUpdate(u AccountUpdate) {
+	for _, x := range u.Balances {
+		b.PushBalance(exchange.AccountCurrencyInfo{
+			CurrencyName: x.Asset,
+			TotalValue:   x.Free + x.Locked,
+			Hold:         x.Locked,
+			Available:    x.Free,
+		})
+	}
+}
+
+// WebsocketOnMessage handles a message received from the user data stream
+func (b *Binance) WebsocketOnMessage(msgType int, resp []byte) {
+	if msgType != websocket.TextMessage {
+		return
+	}
+
+	event := UserDataEvent{}
+	err := common.JSONDecode(resp, &event)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	switch event.EventType {
+	case "executionReport":
+		report := ExecutionReport{}
+		err = common.JSONDecode(resp, &report)
+		if err != nil {
+			log.Println(err)
+			return
+		}
+		b.WebsocketProcessExecutionReport(report)
+	case "outboundAccountInfo":
+		update := AccountUpdate{}
+		err = common.JSONDecode(resp, &update)
+		if err != nil {
+			log.Println(err)
+			return
+		}
+		b.WebsocketProcessAccountUpdate(update)
+	default:
+		if b.Verbose {
+			log.Printf("%s unhandled user data event: %s\n", b.GetName(), strings.TrimSpace(string(resp)))
+		}
+	}
+}
+
+// WebsocketClient connects to the user data stream and keeps it open while
+// the exchange and its websocket are enabled
+func (b *Binance) WebsocketClient() {
+	supervisor := exchange.NewWebsocketSupervisor(&b.Base, binanceWebsocketURL)
+	supervisor.ResolveURL = func() (string, error) {
+		listenKey, err := b.WebsocketListenKey()
+		if err != nil {
+			return "", err
+		}
+		return binanceWebsocketURL + listenKey, nil
+	}
+	supervisor.OnMessage = b.WebsocketOnMessage
+
+	go b.WebsocketKeepAlive()
+	supervisor.Run()
+}
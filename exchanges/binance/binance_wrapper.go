@@ -104,6 +104,12 @@ func (b *Binance) Run() {
 	if err != nil {
 		log.Printf("%s failed to update available currencies\n", b.Name)
 	}
+
+	// The websocket is only used for the user data stream, market data is
+	// still polled
+	if b.IsPrivateStreamEnabled() {
+		go b.WebsocketClient()
+	}
 }
 
 // UpdateTicker updates and returns the ticker for a currency pair
@@ -153,6 +159,15 @@ func (b *Binance) UpdateOrderbook(p pair.CurrencyPair, assetType string) (orderb
 	return b.Orderbooks.GetOrderbook(b.Name, p, assetType)
 }
 
+// SubscribePrivate returns a channel of our order, fill and balance updates
+// pushed by the Binance user data stream
+func (b *Binance) SubscribePrivate() (<-chan exchange.PrivateEvent, error) {
+	if !b.IsPrivateStreamEnabled() {
+		return nil, exchange.ErrPrivateStreamNotEnabled
+	}
+	return b.Streams.SubscribePrivate(), nil
+}
+
 // GetExchangeAccountInfo retrieves balances for all enabled currencies on the
 // Binance exchange
 func (b *Binance) GetExchangeAccountInfo() (exchange.AccountInfo, error) {
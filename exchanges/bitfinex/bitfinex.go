@@ -87,6 +87,7 @@ var errRateLimit = errors.New("ERR_RATE_LIMIT")
 type Bitfinex struct {
 	exchange.Base
 	WebsocketConn         *websocket.Conn
+	WebsocketSupervisor   *exchange.WebsocketSupervisor
 	WebsocketSubdChannels map[int]WebsocketChanInfo
 	// Maps symbol (exchange specific market identifier) to currency pair info
 	currencyPairs map[pair.CurrencyItem]*exchange.CurrencyPairInfo
@@ -379,6 +379,8 @@ type WebsocketTicker struct {
 	DialyChangePerc float64
 	LastPrice       float64
 	Volume          float64
+	High            float64
+	Low             float64
 }
 
 // WebsocketPosition holds position information
@@ -421,6 +423,8 @@ type WebsocketTradeExecuted struct {
 	OrderID        int64
 	AmountExecuted float64
 	PriceExecuted  float64
+	Fee            float64
+	FeeCurrency    string
 }
 
 // ErrorCapture is a simple type for returned errors from Bitfinex
@@ -1,14 +1,20 @@
 package bitfinex
 
 import (
+	"hash/crc32"
 	"log"
-	"net/http"
+	"math"
 	"reflect"
+	"sort"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gorilla/websocket"
 	"github.com/mattkanwisher/cryptofiend/common"
+	"github.com/mattkanwisher/cryptofiend/exchanges"
+	"github.com/mattkanwisher/cryptofiend/exchanges/orderbook"
+	"github.com/mattkanwisher/cryptofiend/exchanges/ticker"
 )
 
 const (
@@ -25,7 +31,11 @@ const (
 	bitfinexWebsocketOrderUpdate        = "ou"
 	bitfinexWebsocketOrderCancel        = "oc"
 	bitfinexWebsocketTradeExecuted      = "te"
+	bitfinexWebsocketTradeUpdate        = "tu"
 	bitfinexWebsocketHeartbeat          = "hb"
+	bitfinexWebsocketChecksum           = "cs"
+	bitfinexWebsocketChecksumFlag       = 131072
+	bitfinexWebsocketChecksumDepth      = 25
 	bitfinexWebsocketAlertRestarting    = "20051"
 	bitfinexWebsocketAlertRefreshing    = "20060"
 	bitfinexWebsocketAlertResume        = "20061"
@@ -46,6 +56,10 @@ func (b *Bitfinex) WebsocketPingHandler() error {
 
 // WebsocketSend sends data to the websocket server
 func (b *Bitfinex) WebsocketSend(data interface{}) error {
+	if b.WebsocketSupervisor != nil {
+		return b.WebsocketSupervisor.Send(data)
+	}
+
 	json, err := common.JSONEncode(data)
 	if err != nil {
 		return err
@@ -54,7 +68,8 @@ func (b *Bitfinex) WebsocketSend(data interface{}) error {
 	return b.WebsocketConn.WriteMessage(websocket.TextMessage, json)
 }
 
-// WebsocketSubscribe subscribes to the websocket channel
+// WebsocketSubscribe subscribes to the websocket channel, the subscription is
+// sent again whenever the supervisor reconnects
 func (b *Bitfinex) WebsocketSubscribe(channel string, params map[string]string) error {
 	request := make(map[string]string)
 	request["event"] = "subscribe"
@@ -65,9 +80,48 @@ func (b *Bitfinex) WebsocketSubscribe(channel string, params map[string]string)
 			request[k] = v
 		}
 	}
+
+	if b.WebsocketSupervisor != nil {
+		return b.WebsocketSupervisor.AddSubscription(request)
+	}
 	return b.WebsocketSend(request)
 }
 
+// WebsocketEnableChecksums asks the server to send a checksum of each
+// orderbook after every update
+func (b *Bitfinex) WebsocketEnableChecksums() error {
+	request := make(map[string]interface{})
+	request["event"] = "conf"
+	request["flags"] = bitfinexWebsocketChecksumFlag
+
+	return b.WebsocketSend(request)
+}
+
+// WebsocketResubscribe unsubscribes from a channel and subscribes to it again,
+// for a book channel this causes a fresh snapshot to be sent
+func (b *Bitfinex) WebsocketResubscribe(chanID int) error {
+	chanInfo, ok := b.WebsocketSubdChannels[chanID]
+	if !ok {
+		return nil
+	}
+	delete(b.WebsocketSubdChannels, chanID)
+
+	request := make(map[string]interface{})
+	request["event"] = "unsubscribe"
+	request["chanId"] = chanID
+	err := b.WebsocketSend(request)
+	if err != nil {
+		return err
+	}
+
+	params := make(map[string]string)
+	if chanInfo.Channel == "book" {
+		params["prec"] = "P0"
+	}
+	params["pair"] = chanInfo.Pair
+	return b.WebsocketSubscribe(chanInfo.Channel, params)
+}
+
 // WebsocketSendAuth sends a autheticated event payload
 func (b *Bitfinex) WebsocketSendAuth() error {
 	request := make(map[string]interface{})
@@ -99,205 +153,417 @@ func (b *Bitfinex) WebsocketAddSubscriptionChannel(chanID int, channel, pair str
 	}
 }
 
-// WebsocketClient makes a connection with the websocket server
-func (b *Bitfinex) WebsocketClient() {
-	channels := []string{"book", "trades", "ticker"}
-	for b.Enabled && b.Websocket {
-		var Dialer websocket.Dialer
-		var err error
-		b.WebsocketConn, _, err = Dialer.Dial(bitfinexWebsocket, http.Header{})
+// WebsocketProcessTicker stores a ticker update and publishes it to stream
+// subscribers
+func (b *Bitfinex) WebsocketProcessTicker(symbol string, t WebsocketTicker) {
+	p, err := b.SymbolToCurrencyPair(symbol)
+	if err != nil {
+		log.Println(err)
+		return
+	}
 
-		if err != nil {
-			log.Printf("%s Unable to connect to Websocket. Error: %s\n", b.GetName(), err)
-			continue
+	b.PushTicker(ticker.Price{Pair: p, Last: t.LastPrice, High: t.High, Low: t.Low, Bid: t.Bid, Ask: t.Ask, Volume: t.Volume}, ticker.Spot)
+}
+
+// WebsocketProcessBookSnapshot replaces the stored orderbook with a snapshot
+// received from the book channel and publishes it to stream subscribers
+func (b *Bitfinex) WebsocketProcessBookSnapshot(symbol string, book []WebsocketBook) {
+	p, err := b.SymbolToCurrencyPair(symbol)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	ob := orderbook.Base{Pair: p}
+	for _, x := range book {
+		if x.Amount > 0 {
+			ob.Bids = append(ob.Bids, orderbook.Item{Price: x.Price, Amount: x.Amount})
+		} else {
+			ob.Asks = append(ob.Asks, orderbook.Item{Price: x.Price, Amount: -x.Amount})
 		}
+	}
+	sort.Slice(ob.Bids, func(i, j int) bool { return ob.Bids[i].Price > ob.Bids[j].Price })
+	sort.Slice(ob.Asks, func(i, j int) bool { return ob.Asks[i].Price < ob.Asks[j].Price })
+	b.PushOrderbook(ob, orderbook.Spot)
+}
 
-		msgType, resp, err := b.WebsocketConn.ReadMessage()
-		if err != nil {
-			log.Printf("%s Unable to read from Websocket. Error: %s\n", b.GetName(), err)
-			continue
+// WebsocketProcessBookUpdate applies a single price level update received from
+// the book channel, a count of zero removes the level
+func (b *Bitfinex) WebsocketProcessBookUpdate(symbol string, book WebsocketBook) {
+	p, err := b.SymbolToCurrencyPair(symbol)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	delta := orderbook.Delta{Side: orderbook.Bid, Price: book.Price, Amount: book.Amount}
+	if book.Amount < 0 {
+		delta.Side = orderbook.Ask
+		delta.Amount = -book.Amount
+	}
+	if book.Count == 0 {
+		delta.Amount = 0
+	}
+
+	err = b.PushOrderbookDeltas(p, orderbook.Spot, 0, []orderbook.Delta{delta})
+	if err != nil && b.Verbose {
+		log.Printf("%s %s orderbook update not applied. Error: %s\n", b.GetName(), symbol, err)
+	}
+}
+
+// WebsocketValidateChecksum compares the checksum sent by the server with one
+// calculated from the stored orderbook, resubscribing to the book channel to
+// get a new snapshot if they differ
+func (b *Bitfinex) WebsocketValidateChecksum(chanID int, symbol string, checksum int32) {
+	p, err := b.SymbolToCurrencyPair(symbol)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	ob, err := b.Orderbooks.GetOrderbook(b.GetName(), p, orderbook.Spot)
+	if err != nil {
+		return
+	}
+
+	if bitfinexChecksum(ob) == checksum {
+		return
+	}
+
+	log.Printf("%s %s orderbook checksum mismatch, resubscribing.\n", b.GetName(), symbol)
+	err = b.WebsocketResubscribe(chanID)
+	if err != nil {
+		log.Println(err)
+	}
+}
+
+// Returns the CRC32 checksum Bitfinex calculates over the top levels of each
+// side of a book, taken alternately with asks given negative amounts.
+func bitfinexChecksum(ob orderbook.Base) int32 {
+	var parts []string
+	for i := 0; i < bitfinexWebsocketChecksumDepth; i++ {
+		if i < len(ob.Bids) {
+			parts = append(parts, bitfinexChecksumFloat(ob.Bids[i].Price), bitfinexChecksumFloat(ob.Bids[i].Amount))
 		}
-		if msgType != websocket.TextMessage {
-			continue
+		if i < len(ob.Asks) {
+			parts = append(parts, bitfinexChecksumFloat(ob.Asks[i].Price), bitfinexChecksumFloat(-ob.Asks[i].Amount))
 		}
+	}
+	return int32(crc32.ChecksumIEEE([]byte(strings.Join(parts, ":"))))
+}
 
-		type WebsocketHandshake struct {
-			Event   string  `json:"event"`
-			Code    int64   `json:"code"`
-			Version float64 `json:"version"`
-		}
+// Formats a number the way the server does (javascript's Number.toString),
+// which switches to exponent notation for very small values.
+func bitfinexChecksumFloat(f float64) string {
+	if f == 0 || math.Abs(f) >= 1e-6 {
+		return strconv.FormatFloat(f, 'f', -1, 64)
+	}
+	s := strconv.FormatFloat(f, 'e', -1, 64)
+	s = strings.Replace(s, "e-0", "e-", 1)
+	return s
+}
 
-		hs := WebsocketHandshake{}
-		err = common.JSONDecode(resp, &hs)
-		if err != nil {
-			log.Println(err)
-			continue
-		}
+// WebsocketProcessTrade publishes a trade received from the trades channel to
+// stream subscribers
+func (b *Bitfinex) WebsocketProcessTrade(symbol string, t WebsocketTrade) {
+	p, err := b.SymbolToCurrencyPair(symbol)
+	if err != nil {
+		log.Println(err)
+		return
+	}
 
-		if hs.Event == "info" {
-			if b.Verbose {
-				log.Printf("%s Connected to Websocket.\n", b.GetName())
-			}
-		}
+	trade := exchange.Trade{
+		Exchange:  b.GetName(),
+		Pair:      p,
+		TradeID:   strconv.FormatInt(t.ID, 10),
+		Side:      exchange.OrderSideBuy,
+		Price:     t.Price,
+		Amount:    math.Abs(t.Amount),
+		Timestamp: time.Unix(t.Timestamp, 0),
+	}
+	if t.Amount < 0 {
+		trade.Side = exchange.OrderSideSell
+	}
+	b.Streams.PublishTrade(trade)
+}
 
-		for _, x := range channels {
-			for _, y := range b.EnabledPairs {
-				params := make(map[string]string)
-				if x == "book" {
-					params["prec"] = "P0"
-				}
-				params["pair"] = y
-				b.WebsocketSubscribe(x, params)
+// WebsocketProcessOrder publishes a change to one of our orders received
+// from the account channel to private stream subscribers
+func (b *Bitfinex) WebsocketProcessOrder(o WebsocketOrder) {
+	order := exchange.Order{
+		OrderID:         strconv.FormatInt(o.OrderID, 10),
+		Amount:          math.Abs(o.OrigAmount),
+		RemainingAmount: math.Abs(o.Amount),
+		Rate:            o.Price,
+	}
+	order.FilledAmount = order.Amount - order.RemainingAmount
+	order.CurrencyPair, _ = b.SymbolToCurrencyPair(o.Pair)
+
+	// The sign of the amount gives the side of the order
+	if o.OrigAmount < 0 {
+		order.Side = exchange.OrderSideSell
+	} else {
+		order.Side = exchange.OrderSideBuy
+	}
+
+	switch OrderType(strings.ToLower(o.OrderType)) {
+	case OrderTypeExchangeLimit:
+		order.Type = exchange.OrderTypeExchangeLimit
+	case OrderTypeMarginLimit:
+		order.Type = exchange.OrderTypeMarginLimit
+	}
+
+	// Statuses are followed by details of the fills, e.g. EXECUTED @ 1.2(0.5)
+	switch {
+	case strings.HasPrefix(o.Status, "ACTIVE"), strings.HasPrefix(o.Status, "PARTIALLY FILLED"):
+		order.Status = exchange.OrderStatusActive
+	case strings.HasPrefix(o.Status, "EXECUTED"):
+		order.Status = exchange.OrderStatusFilled
+	case strings.HasPrefix(o.Status, "CANCELED"):
+		order.Status = exchange.OrderStatusAborted
+	default:
+		order.Status = exchange.OrderStatusUnknown
+	}
+
+	b.PushOrderUpdate(order)
+}
+
+// WebsocketProcessFill publishes an execution of one of our orders received
+// from the account channel to private stream subscribers
+func (b *Bitfinex) WebsocketProcessFill(t WebsocketTradeExecuted) {
+	fill := exchange.Fill{
+		OrderID:     strconv.FormatInt(t.OrderID, 10),
+		TradeID:     strconv.FormatInt(t.TradeID, 10),
+		Price:       t.PriceExecuted,
+		Amount:      math.Abs(t.AmountExecuted),
+		Fee:         math.Abs(t.Fee),
+		FeeCurrency: t.FeeCurrency,
+	}
+	fill.CurrencyPair, _ = b.SymbolToCurrencyPair(t.Pair)
+	if t.AmountExecuted < 0 {
+		fill.Side = exchange.OrderSideSell
+	} else {
+		fill.Side = exchange.OrderSideBuy
+	}
+
+	b.Streams.PublishPrivate(exchange.PrivateEvent{
+		Exchange:  b.GetName(),
+		Type:      exchange.PrivateEventFill,
+		Fill:      &fill,
+		Timestamp: time.Unix(t.Timestamp, 0),
+	})
+}
+
+// WebsocketProcessWallet publishes an exchange wallet balance received from
+// the account channel to private stream subscribers, the account channel only
+// reports the total balance of each wallet
+func (b *Bitfinex) WebsocketProcessWallet(w WebsocketWallet) {
+	// Only the exchange wallet is used for regular orders
+	if w.Name != WalletTypeExchange {
+		return
+	}
+
+	b.PushBalance(exchange.AccountCurrencyInfo{
+		CurrencyName: common.StringToUpper(w.Currency),
+		TotalValue:   w.Balance,
+	})
+}
+
+// WebsocketOnConnect resets the state of the previous connection and enables
+// orderbook checksums, it is called by the supervisor after each connection
+func (b *Bitfinex) WebsocketOnConnect(conn *websocket.Conn) error {
+	b.WebsocketConn = conn
+	b.WebsocketSubdChannels = make(map[int]WebsocketChanInfo)
+	return b.WebsocketEnableChecksums()
+}
+
+// WebsocketAuth authenticates the connection if authenticated API support is
+// enabled, it is called by the supervisor after each connection
+func (b *Bitfinex) WebsocketAuth() error {
+	if !b.AuthenticatedAPISupport {
+		return nil
+	}
+	return b.WebsocketSendAuth()
+}
+
+// WebsocketClient makes a connection with the websocket server and keeps it
+// open while the exchange and its websocket are enabled
+func (b *Bitfinex) WebsocketClient() {
+	b.WebsocketSupervisor = exchange.NewWebsocketSupervisor(&b.Base, bitfinexWebsocket)
+	b.WebsocketSupervisor.OnConnect = b.WebsocketOnConnect
+	b.WebsocketSupervisor.Auth = b.WebsocketAuth
+	b.WebsocketSupervisor.Heartbeat = b.WebsocketPingHandler
+	b.WebsocketSupervisor.OnMessage = b.WebsocketOnMessage
+
+	channels := []string{"book", "trades", "ticker"}
+	for _, x := range channels {
+		for _, y := range b.EnabledPairs {
+			params := make(map[string]string)
+			if x == "book" {
+				params["prec"] = "P0"
 			}
+			params["pair"] = y
+			b.WebsocketSubscribe(x, params)
 		}
+	}
 
-		if b.AuthenticatedAPISupport {
-			err = b.WebsocketSendAuth()
-			if err != nil {
-				log.Println(err)
-			}
+	b.WebsocketSupervisor.Run()
+}
+
+// WebsocketOnMessage handles a message received from the websocket server
+func (b *Bitfinex) WebsocketOnMessage(msgType int, resp []byte) {
+	switch msgType {
+	case websocket.TextMessage:
+		var result interface{}
+		err := common.JSONDecode(resp, &result)
+		if err != nil {
+			log.Println(err)
+			return
 		}
 
-		for b.Enabled && b.Websocket {
-			msgType, resp, err := b.WebsocketConn.ReadMessage()
-			if err != nil {
-				log.Println(err)
-				break
-			}
+		switch reflect.TypeOf(result).String() {
+		case "map[string]interface {}":
+			eventData := result.(map[string]interface{})
+			event := eventData["event"]
+
+			switch event {
+			case "subscribed":
+				b.WebsocketAddSubscriptionChannel(int(eventData["chanId"].(float64)), eventData["channel"].(string), eventData["pair"].(string))
+			case "auth":
+				status := eventData["status"].(string)
 
-			switch msgType {
-			case websocket.TextMessage:
-				var result interface{}
-				err := common.JSONDecode(resp, &result)
-				if err != nil {
-					log.Println(err)
-					continue
+				if status == "OK" {
+					b.WebsocketAddSubscriptionChannel(0, "account", "N/A")
+				} else if status == "fail" {
+					log.Printf("%s Websocket unable to AUTH. Error code: %s\n", b.GetName(), eventData["code"].(string))
+					b.AuthenticatedAPISupport = false
 				}
+			}
+		case "[]interface {}":
+			chanData := result.([]interface{})
+			chanID := int(chanData[0].(float64))
+			chanInfo, ok := b.WebsocketSubdChannels[chanID]
 
-				switch reflect.TypeOf(result).String() {
-				case "map[string]interface {}":
-					eventData := result.(map[string]interface{})
-					event := eventData["event"]
-
-					switch event {
-					case "subscribed":
-						b.WebsocketAddSubscriptionChannel(int(eventData["chanId"].(float64)), eventData["channel"].(string), eventData["pair"].(string))
-					case "auth":
-						status := eventData["status"].(string)
-
-						if status == "OK" {
-							b.WebsocketAddSubscriptionChannel(0, "account", "N/A")
-						} else if status == "fail" {
-							log.Printf("%s Websocket unable to AUTH. Error code: %s\n", b.GetName(), eventData["code"].(string))
-							b.AuthenticatedAPISupport = false
+			if !ok {
+				log.Printf("Unable to locate chanID: %d\n", chanID)
+			} else {
+				if len(chanData) == 2 {
+					if reflect.TypeOf(chanData[1]).String() == "string" {
+						if chanData[1].(string) == bitfinexWebsocketHeartbeat {
+							return
 						}
 					}
-				case "[]interface {}":
-					chanData := result.([]interface{})
-					chanID := int(chanData[0].(float64))
-					chanInfo, ok := b.WebsocketSubdChannels[chanID]
-
-					if !ok {
-						log.Printf("Unable to locate chanID: %d\n", chanID)
-					} else {
-						if len(chanData) == 2 {
-							if reflect.TypeOf(chanData[1]).String() == "string" {
-								if chanData[1].(string) == bitfinexWebsocketHeartbeat {
-									continue
-								}
-							}
+				}
+				if len(chanData) == 3 && chanData[1] == bitfinexWebsocketChecksum {
+					b.WebsocketValidateChecksum(chanID, chanInfo.Pair, int32(chanData[2].(float64)))
+					return
+				}
+				switch chanInfo.Channel {
+				case "book":
+					orderbook := []WebsocketBook{}
+					switch len(chanData) {
+					case 2:
+						data := chanData[1].([]interface{})
+						for _, x := range data {
+							y := x.([]interface{})
+							orderbook = append(orderbook, WebsocketBook{Price: y[0].(float64), Count: int(y[1].(float64)), Amount: y[2].(float64)})
+						}
+						b.WebsocketProcessBookSnapshot(chanInfo.Pair, orderbook)
+					case 4:
+						b.WebsocketProcessBookUpdate(chanInfo.Pair, WebsocketBook{Price: chanData[1].(float64), Count: int(chanData[2].(float64)), Amount: chanData[3].(float64)})
+					}
+				case "ticker":
+					ticker := WebsocketTicker{Bid: chanData[1].(float64), BidSize: chanData[2].(float64), Ask: chanData[3].(float64), AskSize: chanData[4].(float64),
+						DailyChange: chanData[5].(float64), DialyChangePerc: chanData[6].(float64), LastPrice: chanData[7].(float64), Volume: chanData[8].(float64)}
+					if len(chanData) > 10 {
+						ticker.High = chanData[9].(float64)
+						ticker.Low = chanData[10].(float64)
+					}
+
+					if b.Verbose {
+						log.Printf("Bitfinex %s Websocket Last %f Volume %f\n", chanInfo.Pair, ticker.LastPrice, ticker.Volume)
+					}
+					b.WebsocketProcessTicker(chanInfo.Pair, ticker)
+				case "account":
+					switch chanData[1].(string) {
+					case bitfinexWebsocketPositionSnapshot:
+						positionSnapshot := []WebsocketPosition{}
+						data := chanData[2].([]interface{})
+						for _, x := range data {
+							y := x.([]interface{})
+							positionSnapshot = append(positionSnapshot, WebsocketPosition{Pair: y[0].(string), Status: y[1].(string), Amount: y[2].(float64), Price: y[3].(float64),
+								MarginFunding: y[4].(float64), MarginFundingType: int(y[5].(float64))})
 						}
-						switch chanInfo.Channel {
-						case "book":
-							orderbook := []WebsocketBook{}
-							switch len(chanData) {
-							case 2:
-								data := chanData[1].([]interface{})
-								for _, x := range data {
-									y := x.([]interface{})
-									orderbook = append(orderbook, WebsocketBook{Price: y[0].(float64), Count: int(y[1].(float64)), Amount: y[2].(float64)})
-								}
-							case 4:
-								orderbook = append(orderbook, WebsocketBook{Price: chanData[1].(float64), Count: int(chanData[2].(float64)), Amount: chanData[3].(float64)})
-							}
-							log.Println(orderbook)
-						case "ticker":
-							ticker := WebsocketTicker{Bid: chanData[1].(float64), BidSize: chanData[2].(float64), Ask: chanData[3].(float64), AskSize: chanData[4].(float64),
-								DailyChange: chanData[5].(float64), DialyChangePerc: chanData[6].(float64), LastPrice: chanData[7].(float64), Volume: chanData[8].(float64)}
-
-							log.Printf("Bitfinex %s Websocket Last %f Volume %f\n", chanInfo.Pair, ticker.LastPrice, ticker.Volume)
-						case "account":
-							switch chanData[1].(string) {
-							case bitfinexWebsocketPositionSnapshot:
-								positionSnapshot := []WebsocketPosition{}
-								data := chanData[2].([]interface{})
-								for _, x := range data {
-									y := x.([]interface{})
-									positionSnapshot = append(positionSnapshot, WebsocketPosition{Pair: y[0].(string), Status: y[1].(string), Amount: y[2].(float64), Price: y[3].(float64),
-										MarginFunding: y[4].(float64), MarginFundingType: int(y[5].(float64))})
-								}
-								log.Println(positionSnapshot)
-							case bitfinexWebsocketPositionNew, bitfinexWebsocketPositionUpdate, bitfinexWebsocketPositionClose:
-								data := chanData[2].([]interface{})
-								position := WebsocketPosition{Pair: data[0].(string), Status: data[1].(string), Amount: data[2].(float64), Price: data[3].(float64),
-									MarginFunding: data[4].(float64), MarginFundingType: int(data[5].(float64))}
-								log.Println(position)
-							case bitfinexWebsocketWalletSnapshot:
-								data := chanData[2].([]interface{})
-								walletSnapshot := []WebsocketWallet{}
-								for _, x := range data {
-									y := x.([]interface{})
-									walletSnapshot = append(walletSnapshot, WebsocketWallet{Name: y[0].(string), Currency: y[1].(string), Balance: y[2].(float64), UnsettledInterest: y[3].(float64)})
-								}
-								log.Println(walletSnapshot)
-							case bitfinexWebsocketWalletUpdate:
-								data := chanData[2].([]interface{})
-								wallet := WebsocketWallet{Name: data[0].(string), Currency: data[1].(string), Balance: data[2].(float64), UnsettledInterest: data[3].(float64)}
-								log.Println(wallet)
-							case bitfinexWebsocketOrderSnapshot:
-								orderSnapshot := []WebsocketOrder{}
-								data := chanData[2].([]interface{})
-								for _, x := range data {
-									y := x.([]interface{})
-									orderSnapshot = append(orderSnapshot, WebsocketOrder{OrderID: int64(y[0].(float64)), Pair: y[1].(string), Amount: y[2].(float64), OrigAmount: y[3].(float64),
-										OrderType: y[4].(string), Status: y[5].(string), Price: y[6].(float64), PriceAvg: y[7].(float64), Timestamp: y[8].(string)})
-								}
-								log.Println(orderSnapshot)
-							case bitfinexWebsocketOrderNew, bitfinexWebsocketOrderUpdate, bitfinexWebsocketOrderCancel:
-								data := chanData[2].([]interface{})
-								order := WebsocketOrder{OrderID: int64(data[0].(float64)), Pair: data[1].(string), Amount: data[2].(float64), OrigAmount: data[3].(float64),
-									OrderType: data[4].(string), Status: data[5].(string), Price: data[6].(float64), PriceAvg: data[7].(float64), Timestamp: data[8].(string), Notify: int(data[9].(float64))}
-								log.Println(order)
-							case bitfinexWebsocketTradeExecuted:
-								data := chanData[2].([]interface{})
-								trade := WebsocketTradeExecuted{TradeID: int64(data[0].(float64)), Pair: data[1].(string), Timestamp: int64(data[2].(float64)), OrderID: int64(data[3].(float64)),
-									AmountExecuted: data[4].(float64), PriceExecuted: data[5].(float64)}
-								log.Println(trade)
-							}
-						case "trades":
-							trades := []WebsocketTrade{}
-							switch len(chanData) {
-							case 2:
-								data := chanData[1].([]interface{})
-								for _, x := range data {
-									y := x.([]interface{})
-									trades = append(trades, WebsocketTrade{ID: int64(y[0].(float64)), Timestamp: int64(y[1].(float64)), Price: y[2].(float64), Amount: y[3].(float64)})
-								}
-							case 5:
-								trade := WebsocketTrade{ID: int64(chanData[1].(float64)), Timestamp: int64(chanData[2].(float64)), Price: chanData[3].(float64), Amount: chanData[4].(float64)}
-								trades = append(trades, trade)
-
-								if b.Verbose {
-									log.Printf("Bitfinex %s Websocket Trade ID %d Timestamp %d Price %f Amount %f\n", chanInfo.Pair, trade.ID, trade.Timestamp, trade.Price, trade.Amount)
-								}
-							}
-							log.Println(trades)
+						log.Println(positionSnapshot)
+					case bitfinexWebsocketPositionNew, bitfinexWebsocketPositionUpdate, bitfinexWebsocketPositionClose:
+						data := chanData[2].([]interface{})
+						position := WebsocketPosition{Pair: data[0].(string), Status: data[1].(string), Amount: data[2].(float64), Price: data[3].(float64),
+							MarginFunding: data[4].(float64), MarginFundingType: int(data[5].(float64))}
+						log.Println(position)
+					case bitfinexWebsocketWalletSnapshot:
+						data := chanData[2].([]interface{})
+						walletSnapshot := []WebsocketWallet{}
+						for _, x := range data {
+							y := x.([]interface{})
+							walletSnapshot = append(walletSnapshot, WebsocketWallet{Name: y[0].(string), Currency: y[1].(string), Balance: y[2].(float64), UnsettledInterest: y[3].(float64)})
+						}
+						for _, x := range walletSnapshot {
+							b.WebsocketProcessWallet(x)
+						}
+					case bitfinexWebsocketWalletUpdate:
+						data := chanData[2].([]interface{})
+						wallet := WebsocketWallet{Name: data[0].(string), Currency: data[1].(string), Balance: data[2].(float64), UnsettledInterest: data[3].(float64)}
+						b.WebsocketProcessWallet(wallet)
+					case bitfinexWebsocketOrderSnapshot:
+						orderSnapshot := []WebsocketOrder{}
+						data := chanData[2].([]interface{})
+						for _, x := range data {
+							y := x.([]interface{})
+							orderSnapshot = append(orderSnapshot, WebsocketOrder{OrderID: int64(y[0].(float64)), Pair: y[1].(string), Amount: y[2].(float64), OrigAmount: y[3].(float64),
+								OrderType: y[4].(string), Status: y[5].(string), Price: y[6].(float64), PriceAvg: y[7].(float64), Timestamp: y[8].(string)})
+						}
+						for _, x := range orderSnapshot {
+							b.WebsocketProcessOrder(x)
+						}
+					case bitfinexWebsocketOrderNew, bitfinexWebsocketOrderUpdate, bitfinexWebsocketOrderCancel:
+						data := chanData[2].([]interface{})
+						order := WebsocketOrder{OrderID: int64(data[0].(float64)), Pair: data[1].(string), Amount: data[2].(float64), OrigAmount: data[3].(float64),
+							OrderType: data[4].(string), Status: data[5].(string), Price: data[6].(float64), PriceAvg: data[7].(float64), Timestamp: data[8].(string), Notify: int(data[9].(float64))}
+						b.WebsocketProcessOrder(order)
+					case bitfinexWebsocketTradeExecuted:
+						data := chanData[2].([]interface{})
+						trade := WebsocketTradeExecuted{TradeID: int64(data[0].(float64)), Pair: data[1].(string), Timestamp: int64(data[2].(float64)), OrderID: int64(data[3].(float64)),
+							AmountExecuted: data[4].(float64), PriceExecuted: data[5].(float64)}
+						if b.Verbose {
+							log.Println(trade)
+						}
+					case bitfinexWebsocketTradeUpdate:
+						// Trade updates follow executions and include the fee
+						data := chanData[2].([]interface{})
+						trade := WebsocketTradeExecuted{TradeID: int64(data[0].(float64)), Pair: data[1].(string), Timestamp: int64(data[2].(float64)), OrderID: int64(data[3].(float64)),
+							AmountExecuted: data[4].(float64), PriceExecuted: data[5].(float64)}
+						if len(data) > 9 {
+							trade.Fee, _ = data[8].(float64)
+							trade.FeeCurrency, _ = data[9].(string)
+						}
+						b.WebsocketProcessFill(trade)
+					}
+				case "trades":
+					switch len(chanData) {
+					case 5:
+						trade := WebsocketTrade{ID: int64(chanData[1].(float64)), Timestamp: int64(chanData[2].(float64)), Price: chanData[3].(float64), Amount: chanData[4].(float64)}
+						b.WebsocketProcessTrade(chanInfo.Pair, trade)
+					case 7:
+						// Trade update messages carry the trade ID, execution
+						// messages ("te") are followed by one so are skipped.
+						if chanData[1] != "tu" {
+							return
 						}
+						trade := WebsocketTrade{ID: int64(chanData[3].(float64)), Timestamp: int64(chanData[4].(float64)), Price: chanData[5].(float64), Amount: chanData[6].(float64)}
+						b.WebsocketProcessTrade(chanInfo.Pair, trade)
 					}
 				}
 			}
 		}
-		b.WebsocketConn.Close()
-		log.Printf("%s Websocket client disconnected.\n", b.GetName())
 	}
 }
@@ -115,6 +115,21 @@ func (b *Bitfinex) UpdateOrderbook(p pair.CurrencyPair, assetType string) (order
 	return b.Orderbooks.GetOrderbook(b.Name, p, assetType)
 }
 
+// StreamsFullOrderbook returns true, the book channel updates the snapshot it
+// sends on subscribing
+func (b *Bitfinex) StreamsFullOrderbook() bool {
+	return true
+}
+
+// SubscribePrivate returns a channel of our order, fill and balance updates
+// pushed by the Bitfinex authenticated websocket feed
+func (b *Bitfinex) SubscribePrivate() (<-chan exchange.PrivateEvent, error) {
+	if !b.IsPrivateStreamEnabled() {
+		return nil, exchange.ErrPrivateStreamNotEnabled
+	}
+	return b.Streams.SubscribePrivate(), nil
+}
+
 // GetExchangeAccountInfo retrieves balances for all enabled currencies on the
 // Bitfinex exchange
 func (b *Bitfinex) GetExchangeAccountInfo() (exchange.AccountInfo, error) {
@@ -52,6 +52,8 @@ const (
 type Bitstamp struct {
 	exchange.Base
 	Balance Balances
+	// Price of the last trade received by the pusher client
+	pusherLastPrice float64
 }
 
 // SetDefaults sets default for Bitstamp
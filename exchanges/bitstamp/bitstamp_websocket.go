@@ -2,8 +2,14 @@ package bitstamp
 
 import (
 	"log"
+	"strconv"
+	"time"
 
 	"github.com/mattkanwisher/cryptofiend/common"
+	"github.com/mattkanwisher/cryptofiend/currency/pair"
+	"github.com/mattkanwisher/cryptofiend/exchanges"
+	"github.com/mattkanwisher/cryptofiend/exchanges/orderbook"
+	"github.com/mattkanwisher/cryptofiend/exchanges/ticker"
 	"github.com/toorop/go-pusher"
 )
 
@@ -15,9 +21,11 @@ type PusherOrderbook struct {
 
 // PusherTrade holds trade information to be pushed
 type PusherTrade struct {
-	Price  float64 `json:"price"`
-	Amount float64 `json:"amount"`
-	ID     int64   `json:"id"`
+	Price     float64 `json:"price"`
+	Amount    float64 `json:"amount"`
+	ID        int64   `json:"id"`
+	Type      int     `json:"type"`
+	Timestamp int64   `json:"timestamp,string"`
 }
 
 const (
@@ -25,6 +33,53 @@ const (
 	BitstampPusherKey = "de504dc5763aeef9ff52"
 )
 
+// The live_trades and order_book channels only carry the BTCUSD market
+var pusherCurrencyPair = pair.NewCurrencyPair("BTC", "USD")
+
+// PusherProcessOrderbook stores an order_book push and publishes it to stream
+// subscribers. Bitstamp doesn't push tickers, so ticker subscribers receive the
+// top of book along with the last trade price instead.
+func (b *Bitstamp) PusherProcessOrderbook(result PusherOrderbook) {
+	ob := orderbook.Base{Pair: pusherCurrencyPair}
+	for _, x := range result.Bids {
+		price, _ := strconv.ParseFloat(x[0], 64)
+		amount, _ := strconv.ParseFloat(x[1], 64)
+		ob.Bids = append(ob.Bids, orderbook.Item{Price: price, Amount: amount})
+	}
+	for _, x := range result.Asks {
+		price, _ := strconv.ParseFloat(x[0], 64)
+		amount, _ := strconv.ParseFloat(x[1], 64)
+		ob.Asks = append(ob.Asks, orderbook.Item{Price: price, Amount: amount})
+	}
+	b.PushOrderbook(ob, orderbook.Spot)
+
+	if len(ob.Bids) == 0 || len(ob.Asks) == 0 || b.pusherLastPrice == 0 {
+		return
+	}
+	tp := ticker.Price{Pair: pusherCurrencyPair, Bid: ob.Bids[0].Price, Ask: ob.Asks[0].Price, Last: b.pusherLastPrice}
+	b.Streams.PublishTicker(b.GetName(), ticker.Spot, tp)
+}
+
+// PusherProcessTrade publishes a live_trades push to stream subscribers
+func (b *Bitstamp) PusherProcessTrade(result PusherTrade) {
+	trade := exchange.Trade{
+		Exchange: b.GetName(),
+		Pair:     pusherCurrencyPair,
+		TradeID:  strconv.FormatInt(result.ID, 10),
+		Side:     exchange.OrderSideBuy,
+		Price:    result.Price,
+		Amount:   result.Amount,
+	}
+	if result.Type == 1 {
+		trade.Side = exchange.OrderSideSell
+	}
+	b.pusherLastPrice = result.Price
+	if result.Timestamp != 0 {
+		trade.Timestamp = time.Unix(result.Timestamp, 0)
+	}
+	b.Streams.PublishTrade(trade)
+}
+
 // PusherClient starts the push mechanism
 func (b *Bitstamp) PusherClient() {
 	for b.Enabled && b.Websocket {
@@ -64,14 +119,20 @@ func (b *Bitstamp) PusherClient() {
 				err := common.JSONDecode([]byte(data.Data), &result)
 				if err != nil {
 					log.Println(err)
+					continue
 				}
+				b.PusherProcessOrderbook(result)
 			case trade := <-tradeChannelTrade:
 				result := PusherTrade{}
 				err := common.JSONDecode([]byte(trade.Data), &result)
 				if err != nil {
 					log.Println(err)
+					continue
+				}
+				if b.Verbose {
+					log.Printf("%s Pusher trade: Price: %f Amount: %f\n", b.GetName(), result.Price, result.Amount)
 				}
-				log.Printf("%s Pusher trade: Price: %f Amount: %f\n", b.GetName(), result.Price, result.Amount)
+				b.PusherProcessTrade(result)
 			}
 		}
 	}
@@ -87,6 +87,12 @@ func (b *Bitstamp) UpdateOrderbook(p pair.CurrencyPair, assetType string) (order
 	return b.Orderbooks.GetOrderbook(b.Name, p, assetType)
 }
 
+// StreamsFullOrderbook returns false as the Pusher feed only carries the top
+// of the orderbook
+func (b *Bitstamp) StreamsFullOrderbook() bool {
+	return false
+}
+
 // GetExchangeAccountInfo retrieves balances for all enabled currencies for the
 // Bitstamp exchange
 func (b *Bitstamp) GetExchangeAccountInfo() (exchange.AccountInfo, error) {
@@ -99,26 +105,30 @@ func (b *Bitstamp) GetExchangeAccountInfo() (exchange.AccountInfo, error) {
 
 	response.Currencies = append(response.Currencies, exchange.AccountCurrencyInfo{
 		CurrencyName: "BTC",
-		TotalValue:   accountBalance.BTCAvailable,
+		TotalValue:   accountBalance.BTCAvailable + accountBalance.BTCReserved,
 		Hold:         accountBalance.BTCReserved,
+		Available:    accountBalance.BTCAvailable,
 	})
 
 	response.Currencies = append(response.Currencies, exchange.AccountCurrencyInfo{
 		CurrencyName: "XRP",
-		TotalValue:   accountBalance.XRPAvailable,
+		TotalValue:   accountBalance.XRPAvailable + accountBalance.XRPReserved,
 		Hold:         accountBalance.XRPReserved,
+		Available:    accountBalance.XRPAvailable,
 	})
 
 	response.Currencies = append(response.Currencies, exchange.AccountCurrencyInfo{
 		CurrencyName: "USD",
-		TotalValue:   accountBalance.USDAvailable,
+		TotalValue:   accountBalance.USDAvailable + accountBalance.USDReserved,
 		Hold:         accountBalance.USDReserved,
+		Available:    accountBalance.USDAvailable,
 	})
 
 	response.Currencies = append(response.Currencies, exchange.AccountCurrencyInfo{
 		CurrencyName: "EUR",
-		TotalValue:   accountBalance.EURAvailable,
+		TotalValue:   accountBalance.EURAvailable + accountBalance.EURReserved,
 		Hold:         accountBalance.EURReserved,
+		Available:    accountBalance.EURAvailable,
 	})
 	return response, nil
 }
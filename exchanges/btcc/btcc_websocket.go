@@ -3,8 +3,15 @@ package btcc
 import (
 	"fmt"
 	"log"
+	"sort"
+	"strconv"
+	"time"
 
 	"github.com/mattkanwisher/cryptofiend/common"
+	"github.com/mattkanwisher/cryptofiend/currency/pair"
+	"github.com/mattkanwisher/cryptofiend/exchanges"
+	"github.com/mattkanwisher/cryptofiend/exchanges/orderbook"
+	"github.com/mattkanwisher/cryptofiend/exchanges/ticker"
 	"github.com/thrasher-/socketio"
 )
 
@@ -65,6 +72,17 @@ func (b *BTCC) OnTicker(message []byte, output chan socketio.Message) {
 		log.Println(err)
 		return
 	}
+
+	t := resp.Ticker
+	var tp ticker.Price
+	tp.Pair = b.WebsocketMarketPair(t.Market)
+	tp.Ask = t.Sell
+	tp.Bid = t.Buy
+	tp.High = t.High
+	tp.Low = t.Low
+	tp.Last = t.Last
+	tp.Volume = t.Volume
+	b.PushTicker(tp, ticker.Spot)
 }
 
 func (b *BTCC) OnGroupOrder(message []byte, output chan socketio.Message) {
@@ -78,6 +96,17 @@ func (b *BTCC) OnGroupOrder(message []byte, output chan socketio.Message) {
 		log.Println(err)
 		return
 	}
+
+	ob := orderbook.Base{Pair: b.WebsocketMarketPair(resp.GroupOrder.Market)}
+	for _, x := range resp.GroupOrder.Bids {
+		ob.Bids = append(ob.Bids, orderbook.Item{Price: x.Price, Amount: x.TotalAmount})
+	}
+	for _, x := range resp.GroupOrder.Asks {
+		ob.Asks = append(ob.Asks, orderbook.Item{Price: x.Price, Amount: x.TotalAmount})
+	}
+	sort.Slice(ob.Bids, func(i, j int) bool { return ob.Bids[i].Price > ob.Bids[j].Price })
+	sort.Slice(ob.Asks, func(i, j int) bool { return ob.Asks[i].Price < ob.Asks[j].Price })
+	b.PushOrderbook(ob, orderbook.Spot)
 }
 
 func (b *BTCC) OnTrade(message []byte, output chan socketio.Message) {
@@ -88,6 +117,23 @@ func (b *BTCC) OnTrade(message []byte, output chan socketio.Message) {
 		log.Println(err)
 		return
 	}
+
+	newTrade := exchange.Trade{
+		Exchange:  b.GetName(),
+		Pair:      b.WebsocketMarketPair(trade.Market),
+		TradeID:   strconv.FormatFloat(trade.TradeID, 'f', -1, 64),
+		Side:      exchange.OrderSide(trade.Type),
+		Price:     trade.Price,
+		Amount:    trade.Amount,
+		Timestamp: time.Unix(int64(trade.Date), 0),
+	}
+	b.Streams.PublishTrade(newTrade)
+}
+
+// WebsocketMarketPair returns the currency pair of a market such as btccny
+func (b *BTCC) WebsocketMarketPair(market string) pair.CurrencyPair {
+	market = common.StringToUpper(market)
+	return pair.NewCurrencyPair(market[0:3], market[3:])
 }
 
 func (b *BTCC) WebsocketClient() {
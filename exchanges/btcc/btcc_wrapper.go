@@ -86,6 +86,12 @@ func (b *BTCC) UpdateOrderbook(p pair.CurrencyPair, assetType string) (orderbook
 	return b.Orderbooks.GetOrderbook(b.Name, p, assetType)
 }
 
+// StreamsFullOrderbook returns false as the grouped orders only cover the
+// top of the orderbook
+func (b *BTCC) StreamsFullOrderbook() bool {
+	return false
+}
+
 // GetExchangeAccountInfo : Retrieves balances for all enabled currencies for
 // the Kraken exchange - TODO
 func (b *BTCC) GetExchangeAccountInfo() (exchange.AccountInfo, error) {
@@ -59,8 +59,9 @@ func (c *COINUT) GetExchangeAccountInfo() (exchange.AccountInfo, error) {
 		for i := 0; i < len(accountBalance); i++ {
 			var exchangeCurrency exchange.AccountCurrencyInfo
 			exchangeCurrency.CurrencyName = accountBalance[i].Currency
-			exchangeCurrency.TotalValue = accountBalance[i].Available
+			exchangeCurrency.TotalValue = accountBalance[i].Available + accountBalance[i].Hold
 			exchangeCurrency.Hold = accountBalance[i].Hold
+			exchangeCurrency.Available = accountBalance[i].Available
 
 			response.Currencies = append(response.Currencies, exchangeCurrency)
 		}
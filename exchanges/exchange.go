@@ -116,6 +116,7 @@ type Base struct {
 	RequestCurrencyPairFormat   config.CurrencyPairFormatConfig
 	ConfigCurrencyPairFormat    config.CurrencyPairFormatConfig
 	Orderbooks                  orderbook.Orderbooks
+	Streams                     StreamHub
 }
 
 // IBotExchange enforces standard functions for all exchanges supported in
@@ -248,6 +249,12 @@ func (e *Base) GetName() string {
 	return e.Name
 }
 
+// GetTakerFee returns the percentage fee the exchange charges when taking
+// liquidity
+func (e *Base) GetTakerFee() float64 {
+	return e.TakerFee
+}
+
 // Common exchange setup method so we can stop duplicating so much code
 func (e *Base) CommonSetup(exch config.ExchangeConfig) {
 	e.BaseCurrencies = common.SplitStrings(exch.BaseCurrencies, ",")
@@ -0,0 +1,57 @@
+package exchange
+
+import (
+	"sync"
+	"time"
+)
+
+// Types of order activity reported by PublishOrderEvent
+const (
+	OrderEventPlaced    = "placed"
+	OrderEventFilled    = "filled"
+	OrderEventCancelled = "cancelled"
+)
+
+// OrderEvent reports an order placed or cancelled by the bot, or one of its
+// orders found to be filled or cancelled on the exchange
+type OrderEvent struct {
+	Exchange  string
+	Type      string
+	Order     Order
+	Timestamp time.Time
+}
+
+var orderEvents struct {
+	m           sync.Mutex
+	subscribers []chan OrderEvent
+}
+
+// SubscribeOrderEvents returns a channel which receives all subsequent order
+// activity
+func SubscribeOrderEvents() <-chan OrderEvent {
+	orderEvents.m.Lock()
+	defer orderEvents.m.Unlock()
+
+	ch := make(chan OrderEvent, StreamBufferSize)
+	orderEvents.subscribers = append(orderEvents.subscribers, ch)
+	return ch
+}
+
+// PublishOrderEvent delivers order activity to all subscribers
+func PublishOrderEvent(exchangeName, eventType string, order Order) {
+	orderEvents.m.Lock()
+	defer orderEvents.m.Unlock()
+
+	event := OrderEvent{
+		Exchange:  exchangeName,
+		Type:      eventType,
+		Order:     order,
+		Timestamp: time.Now(),
+	}
+	for _, ch := range orderEvents.subscribers {
+		select {
+		case ch <- event:
+		default:
+		}
+	}
+}
@@ -0,0 +1,20 @@
+package exchange
+
+import (
+	"testing"
+)
+
+func TestSubscribeOrderEvents(t *testing.T) {
+	events := SubscribeOrderEvents()
+	PublishOrderEvent("Test", OrderEventFilled, Order{OrderID: "1", Status: OrderStatusFilled})
+
+	select {
+	case event := <-events:
+		if event.Exchange != "Test" || event.Type != OrderEventFilled || event.Order.OrderID != "1" ||
+			event.Timestamp.IsZero() {
+			t.Errorf("Test failed. TestSubscribeOrderEvents: unexpected event %+v", event)
+		}
+	default:
+		t.Error("Test failed. TestSubscribeOrderEvents: event not delivered")
+	}
+}
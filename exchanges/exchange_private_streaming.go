@@ -0,0 +1,104 @@
+package exchange
+
+import (
+	"errors"
+	"time"
+
+	"github.com/mattkanwisher/cryptofiend/currency/pair"
+)
+
+// Types of event pushed by an authenticated exchange stream
+const (
+	PrivateEventOrder   = "order"
+	PrivateEventFill    = "fill"
+	PrivateEventBalance = "balance"
+)
+
+// ErrPrivateStreamNotEnabled indicates that a private stream was requested
+// from an exchange that has its websocket or authenticated API support
+// disabled
+var ErrPrivateStreamNotEnabled = errors.New("authenticated streaming requires websocket and authenticated API support to be enabled")
+
+// Fill is an execution of one of our orders
+type Fill struct {
+	OrderID      string
+	TradeID      string
+	CurrencyPair pair.CurrencyPair
+	Side         OrderSide
+	Price        float64
+	Amount       float64
+	Fee          float64
+	FeeCurrency  string
+}
+
+// PrivateEvent is a normalised update to our orders or balances pushed by an
+// authenticated exchange stream. Type says which one of Order, Fill or
+// Balance is set.
+type PrivateEvent struct {
+	Exchange  string
+	Type      string
+	Order     *Order
+	Fill      *Fill
+	Balance   *AccountCurrencyInfo
+	Timestamp time.Time
+}
+
+// IPrivateStreamingExchange is implemented by exchanges that can push our
+// order, fill and balance updates over an authenticated websocket connection,
+// so that they don't need to be polled
+type IPrivateStreamingExchange interface {
+	IBotExchange
+	SubscribePrivate() (<-chan PrivateEvent, error)
+}
+
+// SubscribePrivate registers a new subscriber for order, fill and balance
+// updates
+func (s *StreamHub) SubscribePrivate() <-chan PrivateEvent {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	ch := make(chan PrivateEvent, StreamBufferSize)
+	s.private = append(s.private, ch)
+	return ch
+}
+
+// PublishPrivate delivers an order, fill or balance update to all private
+// subscribers
+func (s *StreamHub) PublishPrivate(event PrivateEvent) {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	if event.Timestamp.IsZero() {
+		event.Timestamp = time.Now()
+	}
+	for _, ch := range s.private {
+		select {
+		case ch <- event:
+		default:
+		}
+	}
+}
+
+// PushOrderUpdate publishes a change to one of our orders received from an
+// authenticated exchange stream
+func (e *Base) PushOrderUpdate(order Order) {
+	e.Streams.PublishPrivate(PrivateEvent{Exchange: e.GetName(), Type: PrivateEventOrder, Order: &order})
+}
+
+// PushFill publishes an execution of one of our orders received from an
+// authenticated exchange stream
+func (e *Base) PushFill(fill Fill) {
+	e.Streams.PublishPrivate(PrivateEvent{Exchange: e.GetName(), Type: PrivateEventFill, Fill: &fill})
+}
+
+// PushBalance publishes a balance change received from an authenticated
+// exchange stream
+func (e *Base) PushBalance(balance AccountCurrencyInfo) {
+	e.Streams.PublishPrivate(PrivateEvent{Exchange: e.GetName(), Type: PrivateEventBalance, Balance: &balance})
+}
+
+// IsPrivateStreamEnabled returns whether the exchange can push authenticated
+// updates
+func (e *Base) IsPrivateStreamEnabled() bool {
+	return e.Websocket && e.AuthenticatedAPISupport
+}
@@ -0,0 +1,44 @@
+package exchange
+
+import (
+	"testing"
+)
+
+func TestPushPrivate(t *testing.T) {
+	b := Base{Name: "TESTNAME"}
+	events := b.Streams.SubscribePrivate()
+
+	b.PushOrderUpdate(Order{OrderID: "1", Status: OrderStatusActive})
+	b.PushFill(Fill{OrderID: "1", Amount: 0.5})
+	b.PushBalance(AccountCurrencyInfo{CurrencyName: "BTC", Available: 2})
+
+	expected := []string{PrivateEventOrder, PrivateEventFill, PrivateEventBalance}
+	for _, x := range expected {
+		select {
+		case event := <-events:
+			if event.Exchange != "TESTNAME" || event.Type != x || event.Timestamp.IsZero() {
+				t.Errorf("Test failed. TestPushPrivate expected %s event, got %+v", x, event)
+			}
+			switch x {
+			case PrivateEventOrder:
+				if event.Order == nil || event.Order.OrderID != "1" {
+					t.Error("Test failed. TestPushPrivate order not set")
+				}
+			case PrivateEventFill:
+				if event.Fill == nil || event.Fill.Amount != 0.5 {
+					t.Error("Test failed. TestPushPrivate fill not set")
+				}
+			case PrivateEventBalance:
+				if event.Balance == nil || event.Balance.Available != 2 {
+					t.Error("Test failed. TestPushPrivate balance not set")
+				}
+			}
+		default:
+			t.Fatalf("Test failed. TestPushPrivate %s event was not delivered", x)
+		}
+	}
+
+	if b.IsPrivateStreamEnabled() {
+		t.Error("Test failed. TestPushPrivate private stream enabled without websocket support")
+	}
+}
@@ -0,0 +1,305 @@
+package exchange
+
+import (
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/mattkanwisher/cryptofiend/currency/pair"
+	"github.com/mattkanwisher/cryptofiend/exchanges/orderbook"
+	"github.com/mattkanwisher/cryptofiend/exchanges/ticker"
+)
+
+// StreamBufferSize is the number of undelivered events held for each
+// subscriber before further events are dropped
+const StreamBufferSize = 256
+
+// ErrWebsocketNotEnabled indicates that a streaming subscription was requested
+// from an exchange that has its websocket disabled
+var ErrWebsocketNotEnabled = errors.New("websocket support is not enabled for this exchange")
+
+// TickerEvent is a normalised ticker update pushed by an exchange stream
+type TickerEvent struct {
+	Exchange  string
+	AssetType string
+	Price     ticker.Price
+	Timestamp time.Time
+}
+
+// OrderbookEvent is a normalised orderbook update pushed by an exchange
+// stream, Orderbook always holds the full book after the update was applied
+type OrderbookEvent struct {
+	Exchange  string
+	AssetType string
+	Orderbook orderbook.Base
+	Timestamp time.Time
+}
+
+// Trade is a normalised public trade pushed by an exchange stream
+type Trade struct {
+	Exchange  string
+	Pair      pair.CurrencyPair
+	TradeID   string
+	Side      OrderSide // taker side, empty if the exchange doesn't report it
+	Price     float64
+	Amount    float64
+	Timestamp time.Time
+}
+
+// IStreamingExchange is implemented by exchanges that can push market data
+// over a websocket connection. Each call returns a new channel which receives
+// every subsequent event for the currency pair until it is passed to
+// Unsubscribe, events are dropped rather than blocking the exchange connection
+// if a subscriber falls behind.
+// StreamsFullOrderbook reports whether the stored orderbooks are maintained
+// in full from a snapshot and deltas, rather than only the top of the book,
+// so that they needn't be polled over REST.
+type IStreamingExchange interface {
+	IBotExchange
+	IsWebsocketEnabled() bool
+	StreamsFullOrderbook() bool
+	SubscribeTicker(p pair.CurrencyPair) (<-chan TickerEvent, error)
+	SubscribeOrderbook(p pair.CurrencyPair) (<-chan OrderbookEvent, error)
+	SubscribeTrades(p pair.CurrencyPair) (<-chan Trade, error)
+	Unsubscribe(ch interface{})
+}
+
+// StreamHub fans out the events decoded by an exchange websocket client to
+// its subscribers. The zero value is ready to use.
+type StreamHub struct {
+	m          sync.Mutex
+	tickers    map[pair.CurrencyItem][]chan TickerEvent
+	orderbooks map[pair.CurrencyItem][]chan OrderbookEvent
+	trades     map[pair.CurrencyItem][]chan Trade
+	private    []chan PrivateEvent
+}
+
+// Returns the key used to index subscribers regardless of the pair format
+// used by the exchange.
+func streamKey(p pair.CurrencyPair) pair.CurrencyItem {
+	return p.Display("/", false)
+}
+
+// SubscribeTicker registers a new ticker subscriber for the currency pair
+func (s *StreamHub) SubscribeTicker(p pair.CurrencyPair) <-chan TickerEvent {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	if s.tickers == nil {
+		s.tickers = make(map[pair.CurrencyItem][]chan TickerEvent)
+	}
+	ch := make(chan TickerEvent, StreamBufferSize)
+	s.tickers[streamKey(p)] = append(s.tickers[streamKey(p)], ch)
+	return ch
+}
+
+// SubscribeOrderbook registers a new orderbook subscriber for the currency
+// pair
+func (s *StreamHub) SubscribeOrderbook(p pair.CurrencyPair) <-chan OrderbookEvent {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	if s.orderbooks == nil {
+		s.orderbooks = make(map[pair.CurrencyItem][]chan OrderbookEvent)
+	}
+	ch := make(chan OrderbookEvent, StreamBufferSize)
+	s.orderbooks[streamKey(p)] = append(s.orderbooks[streamKey(p)], ch)
+	return ch
+}
+
+// SubscribeTrades registers a new trade subscriber for the currency pair
+func (s *StreamHub) SubscribeTrades(p pair.CurrencyPair) <-chan Trade {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	if s.trades == nil {
+		s.trades = make(map[pair.CurrencyItem][]chan Trade)
+	}
+	ch := make(chan Trade, StreamBufferSize)
+	s.trades[streamKey(p)] = append(s.trades[streamKey(p)], ch)
+	return ch
+}
+
+// Unsubscribe stops events being sent to a channel returned by
+// SubscribeTicker, SubscribeOrderbook or SubscribeTrades and closes it
+func (s *StreamHub) Unsubscribe(ch interface{}) {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	switch c := ch.(type) {
+	case <-chan TickerEvent:
+		for key, channels := range s.tickers {
+			for i, x := range channels {
+				if x == c {
+					s.tickers[key] = append(channels[:i], channels[i+1:]...)
+					close(x)
+					return
+				}
+			}
+		}
+	case <-chan OrderbookEvent:
+		for key, channels := range s.orderbooks {
+			for i, x := range channels {
+				if x == c {
+					s.orderbooks[key] = append(channels[:i], channels[i+1:]...)
+					close(x)
+					return
+				}
+			}
+		}
+	case <-chan Trade:
+		for key, channels := range s.trades {
+			for i, x := range channels {
+				if x == c {
+					s.trades[key] = append(channels[:i], channels[i+1:]...)
+					close(x)
+					return
+				}
+			}
+		}
+	}
+}
+
+// PublishTicker delivers a ticker update to all subscribers of its pair
+func (s *StreamHub) PublishTicker(exchName, assetType string, price ticker.Price) {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	event := TickerEvent{
+		Exchange:  exchName,
+		AssetType: assetType,
+		Price:     price,
+		Timestamp: time.Now(),
+	}
+	for _, ch := range s.tickers[streamKey(price.Pair)] {
+		select {
+		case ch <- event:
+		default:
+		}
+	}
+}
+
+// PublishOrderbook delivers an orderbook update to all subscribers of its
+// pair
+func (s *StreamHub) PublishOrderbook(exchName, assetType string, ob orderbook.Base) {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	event := OrderbookEvent{
+		Exchange:  exchName,
+		AssetType: assetType,
+		Orderbook: ob,
+		Timestamp: time.Now(),
+	}
+	for _, ch := range s.orderbooks[streamKey(ob.Pair)] {
+		select {
+		case ch <- event:
+		default:
+		}
+	}
+}
+
+// PublishTrade delivers a trade to all subscribers of its pair
+func (s *StreamHub) PublishTrade(trade Trade) {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	if trade.Timestamp.IsZero() {
+		trade.Timestamp = time.Now()
+	}
+	for _, ch := range s.trades[streamKey(trade.Pair)] {
+		select {
+		case ch <- trade:
+		default:
+		}
+	}
+}
+
+// PushTicker stores a ticker update received from an exchange stream and
+// publishes it to the exchange's subscribers
+func (e *Base) PushTicker(price ticker.Price, assetType string) {
+	ticker.ProcessTicker(e.GetName(), price.Pair, price, assetType)
+	e.Streams.PublishTicker(e.GetName(), assetType, price)
+}
+
+// PushOrderbook stores an orderbook received from an exchange stream and
+// publishes it to the exchange's subscribers
+func (e *Base) PushOrderbook(ob orderbook.Base, assetType string) {
+	e.Orderbooks.ProcessOrderbook(e.GetName(), ob.Pair, ob, assetType)
+	stored, err := e.Orderbooks.GetOrderbook(e.GetName(), ob.Pair, assetType)
+	if err != nil {
+		return
+	}
+	stored.Pair = ob.Pair
+	e.Streams.PublishOrderbook(e.GetName(), assetType, stored)
+}
+
+// PushOrderbookDeltas applies price level changes received from an exchange
+// stream to the stored orderbook and publishes the result. Errors returned by
+// orderbook.ProcessDeltas are passed on so the caller can resync the book.
+func (e *Base) PushOrderbookDeltas(p pair.CurrencyPair, assetType string, sequence int64, deltas []orderbook.Delta) error {
+	ob, err := e.Orderbooks.ProcessDeltas(e.GetName(), p, assetType, sequence, deltas)
+	if err != nil || len(deltas) == 0 {
+		return err
+	}
+	ob.Pair = p
+	e.Streams.PublishOrderbook(e.GetName(), assetType, ob)
+	return nil
+}
+
+// ResyncOrderbook replaces an orderbook that has fallen out of step with its
+// stream using a REST snapshot, usually the exchange's UpdateOrderbook method,
+// and publishes the result
+func (e *Base) ResyncOrderbook(p pair.CurrencyPair, assetType string, snapshot func(pair.CurrencyPair, string) (orderbook.Base, error)) error {
+	if e.Verbose {
+		log.Printf("%s resynchronising %s orderbook.\n", e.GetName(), p.Pair())
+	}
+
+	ob, err := snapshot(p, assetType)
+	if err != nil {
+		log.Printf("%s failed to resynchronise %s orderbook. Error: %s\n", e.GetName(), p.Pair(), err)
+		return err
+	}
+	ob.Pair = p
+	e.Streams.PublishOrderbook(e.GetName(), assetType, ob)
+	return nil
+}
+
+// SubscribeTicker returns a channel of ticker updates pushed by the exchange
+// websocket feed
+func (e *Base) SubscribeTicker(p pair.CurrencyPair) (<-chan TickerEvent, error) {
+	if !e.Websocket {
+		return nil, ErrWebsocketNotEnabled
+	}
+	return e.Streams.SubscribeTicker(p), nil
+}
+
+// SubscribeOrderbook returns a channel of orderbook updates pushed by the
+// exchange websocket feed
+func (e *Base) SubscribeOrderbook(p pair.CurrencyPair) (<-chan OrderbookEvent, error) {
+	if !e.Websocket {
+		return nil, ErrWebsocketNotEnabled
+	}
+	return e.Streams.SubscribeOrderbook(p), nil
+}
+
+// SubscribeTrades returns a channel of trades pushed by the exchange websocket
+// feed
+func (e *Base) SubscribeTrades(p pair.CurrencyPair) (<-chan Trade, error) {
+	if !e.Websocket {
+		return nil, ErrWebsocketNotEnabled
+	}
+	return e.Streams.SubscribeTrades(p), nil
+}
+
+// Unsubscribe stops stream events being sent to a channel returned by one of
+// the exchange's Subscribe methods and closes it
+func (e *Base) Unsubscribe(ch interface{}) {
+	e.Streams.Unsubscribe(ch)
+}
+
+// IsWebsocketEnabled returns whether the exchange websocket client is enabled
+func (e *Base) IsWebsocketEnabled() bool {
+	return e.Websocket
+}
@@ -0,0 +1,119 @@
+package exchange
+
+import (
+	"testing"
+
+	"github.com/mattkanwisher/cryptofiend/currency/pair"
+	"github.com/mattkanwisher/cryptofiend/exchanges/orderbook"
+	"github.com/mattkanwisher/cryptofiend/exchanges/ticker"
+)
+
+func TestStreamHub(t *testing.T) {
+	var s StreamHub
+	p := pair.NewCurrencyPair("BTC", "USD")
+
+	tickers := s.SubscribeTicker(p)
+	orderbooks := s.SubscribeOrderbook(pair.NewCurrencyPairDelimiter("btc-usd", "-"))
+	trades := s.SubscribeTrades(p)
+	other := s.SubscribeTicker(pair.NewCurrencyPair("LTC", "USD"))
+
+	s.PublishTicker("TESTNAME", ticker.Spot, ticker.Price{Pair: p, Last: 1000})
+	s.PublishOrderbook("TESTNAME", orderbook.Spot, orderbook.Base{Pair: p, Bids: []orderbook.Item{{Price: 999, Amount: 1}}})
+	s.PublishTrade(Trade{Exchange: "TESTNAME", Pair: p, Price: 1000, Amount: 2})
+
+	select {
+	case event := <-tickers:
+		if event.Exchange != "TESTNAME" || event.Price.Last != 1000 {
+			t.Error("Test failed. TestStreamHub received unexpected ticker event")
+		}
+	default:
+		t.Error("Test failed. TestStreamHub ticker event was not delivered")
+	}
+
+	select {
+	case event := <-orderbooks:
+		if len(event.Orderbook.Bids) != 1 {
+			t.Error("Test failed. TestStreamHub received unexpected orderbook event")
+		}
+	default:
+		t.Error("Test failed. TestStreamHub orderbook event was not delivered to a differently formatted pair")
+	}
+
+	select {
+	case trade := <-trades:
+		if trade.Amount != 2 || trade.Timestamp.IsZero() {
+			t.Error("Test failed. TestStreamHub received unexpected trade")
+		}
+	default:
+		t.Error("Test failed. TestStreamHub trade was not delivered")
+	}
+
+	select {
+	case <-other:
+		t.Error("Test failed. TestStreamHub delivered an event to another pair's subscriber")
+	default:
+	}
+
+	for i := 0; i < StreamBufferSize+10; i++ {
+		s.PublishTicker("TESTNAME", ticker.Spot, ticker.Price{Pair: p})
+	}
+	if len(tickers) != StreamBufferSize {
+		t.Errorf("Test failed. TestStreamHub expected a full buffer of %d events, got %d", StreamBufferSize, len(tickers))
+	}
+}
+
+func TestStreamHubUnsubscribe(t *testing.T) {
+	var s StreamHub
+	p := pair.NewCurrencyPair("BTC", "USD")
+
+	tickers := s.SubscribeTicker(p)
+	orderbooks := s.SubscribeOrderbook(p)
+	trades := s.SubscribeTrades(p)
+	remaining := s.SubscribeTicker(p)
+
+	s.Unsubscribe(tickers)
+	s.Unsubscribe(orderbooks)
+	s.Unsubscribe(trades)
+	s.PublishTicker("TESTNAME", ticker.Spot, ticker.Price{Pair: p, Last: 1000})
+
+	if _, ok := <-tickers; ok {
+		t.Error("Test failed. TestStreamHubUnsubscribe ticker channel was not closed")
+	}
+	if _, ok := <-orderbooks; ok {
+		t.Error("Test failed. TestStreamHubUnsubscribe orderbook channel was not closed")
+	}
+	if _, ok := <-trades; ok {
+		t.Error("Test failed. TestStreamHubUnsubscribe trade channel was not closed")
+	}
+	if len(remaining) != 1 {
+		t.Error("Test failed. TestStreamHubUnsubscribe remaining subscriber was not delivered the ticker")
+	}
+
+	// Unsubscribing twice mustn't close the channel again
+	s.Unsubscribe(tickers)
+}
+
+func TestBaseSubscribe(t *testing.T) {
+	var b Base
+	p := pair.NewCurrencyPair("BTC", "USD")
+
+	if _, err := b.SubscribeTicker(p); err != ErrWebsocketNotEnabled {
+		t.Error("Test failed. TestBaseSubscribe subscribed to tickers with the websocket disabled")
+	}
+	if _, err := b.SubscribeOrderbook(p); err != ErrWebsocketNotEnabled {
+		t.Error("Test failed. TestBaseSubscribe subscribed to orderbooks with the websocket disabled")
+	}
+	if _, err := b.SubscribeTrades(p); err != ErrWebsocketNotEnabled {
+		t.Error("Test failed. TestBaseSubscribe subscribed to trades with the websocket disabled")
+	}
+
+	b.Websocket = true
+	trades, err := b.SubscribeTrades(p)
+	if err != nil {
+		t.Fatalf("Test failed. TestBaseSubscribe error: %s", err)
+	}
+	b.Streams.PublishTrade(Trade{Pair: p, Amount: 1})
+	if len(trades) != 1 {
+		t.Error("Test failed. TestBaseSubscribe trade was not delivered")
+	}
+}
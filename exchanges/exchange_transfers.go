@@ -0,0 +1,24 @@
+package exchange
+
+import (
+	"time"
+)
+
+// Transfer is a deposit to or a withdrawal from an exchange account
+type Transfer struct {
+	ID        string
+	Currency  string
+	Amount    float64
+	Fee       float64
+	Deposit   bool
+	Timestamp time.Time
+}
+
+// ITransferHistoryExchange is implemented by exchanges which can list the
+// deposits and withdrawals of the account
+type ITransferHistoryExchange interface {
+	IBotExchange
+	// GetTransferHistory returns the completed deposits and withdrawals made
+	// since a time.
+	GetTransferHistory(since time.Time) ([]Transfer, error)
+}
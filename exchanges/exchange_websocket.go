@@ -0,0 +1,437 @@
+package exchange
+
+import (
+	"bytes"
+	"errors"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/mattkanwisher/cryptofiend/common"
+)
+
+// Websocket connection states reported by a WebsocketSupervisor
+const (
+	WebsocketStateConnecting   = "connecting"
+	WebsocketStateConnected    = "connected"
+	WebsocketStateDisconnected = "disconnected"
+)
+
+// Default WebsocketSupervisor timings
+const (
+	DefaultWebsocketMinReconnectDelay = time.Second
+	DefaultWebsocketMaxReconnectDelay = time.Minute
+	DefaultWebsocketHeartbeatInterval = time.Second * 30
+	DefaultWebsocketReadTimeout       = time.Second * 90
+)
+
+// ErrWebsocketNotConnected indicates that a message couldn't be sent because
+// the websocket connection is down, stored subscriptions are sent once it is
+// reestablished.
+var ErrWebsocketNotConnected = errors.New("websocket is not connected")
+
+// WebsocketStateEvent reports a change in the state of an exchange websocket
+// connection, Attempt counts the connection attempts since the last successful
+// connection and Error holds the reason a connection failed or dropped.
+type WebsocketStateEvent struct {
+	Exchange  string
+	State     string
+	Attempt   int
+	Error     error
+	Timestamp time.Time
+}
+
+var websocketStates = struct {
+	m           sync.Mutex
+	latest      map[string]WebsocketStateEvent
+	subscribers []chan WebsocketStateEvent
+}{latest: make(map[string]WebsocketStateEvent)}
+
+// SubscribeWebsocketState returns a channel which receives every subsequent
+// websocket state change of every exchange
+func SubscribeWebsocketState() <-chan WebsocketStateEvent {
+	websocketStates.m.Lock()
+	defer websocketStates.m.Unlock()
+
+	ch := make(chan WebsocketStateEvent, StreamBufferSize)
+	websocketStates.subscribers = append(websocketStates.subscribers, ch)
+	return ch
+}
+
+// UnsubscribeWebsocketState stops websocket state changes being sent to a
+// channel returned by SubscribeWebsocketState and closes it
+func UnsubscribeWebsocketState(ch <-chan WebsocketStateEvent) {
+	websocketStates.m.Lock()
+	defer websocketStates.m.Unlock()
+
+	for i, x := range websocketStates.subscribers {
+		if x == ch {
+			websocketStates.subscribers = append(websocketStates.subscribers[:i], websocketStates.subscribers[i+1:]...)
+			close(x)
+			return
+		}
+	}
+}
+
+// GetWebsocketStates returns the latest websocket state of each exchange
+func GetWebsocketStates() map[string]WebsocketStateEvent {
+	websocketStates.m.Lock()
+	defer websocketStates.m.Unlock()
+
+	states := make(map[string]WebsocketStateEvent)
+	for k, v := range websocketStates.latest {
+		states[k] = v
+	}
+	return states
+}
+
+func publishWebsocketState(event WebsocketStateEvent) {
+	websocketStates.m.Lock()
+	defer websocketStates.m.Unlock()
+
+	websocketStates.latest[event.Exchange] = event
+	for _, ch := range websocketStates.subscribers {
+		select {
+		case ch <- event:
+		default:
+		}
+	}
+}
+
+// WebsocketSupervisor owns the websocket connection of an exchange. It
+// reconnects with exponential backoff when the connection drops, detects
+// stalled connections with a heartbeat and read timeout, and after each
+// connection runs the exchange's auth hook and replays its subscriptions.
+type WebsocketSupervisor struct {
+	Name    string
+	URL     string
+	Verbose bool
+
+	// Running reports whether the connection should be kept open
+	Running func() bool
+
+	MinReconnectDelay time.Duration
+	MaxReconnectDelay time.Duration
+	HeartbeatInterval time.Duration
+	ReadTimeout       time.Duration
+
+	// ResolveURL returns the URL to connect to for servers whose URL changes
+	// between connections, URL is used if it is nil
+	ResolveURL func() (string, error)
+	// OnConnect is called with each new connection before the auth hook, it
+	// is used to reset per connection state
+	OnConnect func(conn *websocket.Conn) error
+	// Auth is called after each connection before subscriptions are replayed
+	Auth func() error
+	// Heartbeat sends an exchange specific keepalive message, a websocket
+	// ping frame is sent if it is nil
+	Heartbeat func() error
+	// OnMessage is called for every message read from the connection
+	OnMessage func(msgType int, data []byte)
+
+	m             sync.Mutex
+	conn          *websocket.Conn
+	subscriptions [][]byte
+}
+
+// NewWebsocketSupervisor returns a supervisor for the websocket server at url
+// with the default timings, the connection is kept open while the exchange
+// and its websocket are enabled
+func NewWebsocketSupervisor(e *Base, url string) *WebsocketSupervisor {
+	return &WebsocketSupervisor{
+		Name:              e.GetName(),
+		URL:               url,
+		Verbose:           e.Verbose,
+		Running:           func() bool { return e.Enabled && e.Websocket },
+		MinReconnectDelay: DefaultWebsocketMinReconnectDelay,
+		MaxReconnectDelay: DefaultWebsocketMaxReconnectDelay,
+		HeartbeatInterval: DefaultWebsocketHeartbeatInterval,
+		ReadTimeout:       DefaultWebsocketReadTimeout,
+	}
+}
+
+// Run connects to the websocket server and keeps the connection open until
+// Running returns false
+func (w *WebsocketSupervisor) Run() {
+	attempt := 0
+	for w.Running() {
+		if attempt > 0 {
+			time.Sleep(w.ReconnectDelay(attempt))
+		}
+		attempt++
+
+		w.setState(WebsocketStateConnecting, attempt, nil)
+		err := w.connect()
+		if err != nil {
+			log.Printf("%s Unable to connect to Websocket. Error: %s\n", w.Name, err)
+			w.close()
+			w.setState(WebsocketStateDisconnected, attempt, err)
+			continue
+		}
+
+		log.Printf("%s Connected to Websocket.\n", w.Name)
+		w.setState(WebsocketStateConnected, attempt, nil)
+		connected := time.Now()
+
+		err = w.readLoop()
+		w.close()
+		log.Printf("%s Websocket client disconnected.\n", w.Name)
+		w.setState(WebsocketStateDisconnected, 0, err)
+
+		// Only back off from connections that drop straight away
+		if time.Since(connected) > w.MaxReconnectDelay {
+			attempt = 0
+		}
+	}
+}
+
+// ReconnectDelay returns the time to wait before a connection attempt,
+// doubling with each failed attempt up to MaxReconnectDelay
+func (w *WebsocketSupervisor) ReconnectDelay(attempt int) time.Duration {
+	delay := w.MinReconnectDelay
+	for i := 1; i < attempt && delay < w.MaxReconnectDelay; i++ {
+		delay *= 2
+	}
+	if delay > w.MaxReconnectDelay {
+		delay = w.MaxReconnectDelay
+	}
+	return delay
+}
+
+func (w *WebsocketSupervisor) setState(state string, attempt int, err error) {
+	publishWebsocketState(WebsocketStateEvent{
+		Exchange:  w.Name,
+		State:     state,
+		Attempt:   attempt,
+		Error:     err,
+		Timestamp: time.Now(),
+	})
+}
+
+func (w *WebsocketSupervisor) connect() error {
+	url := w.URL
+	if w.ResolveURL != nil {
+		var err error
+		url, err = w.ResolveURL()
+		if err != nil {
+			return err
+		}
+	}
+
+	var Dialer websocket.Dialer
+	conn, _, err := Dialer.Dial(url, http.Header{})
+	if err != nil {
+		return err
+	}
+
+	w.m.Lock()
+	w.conn = conn
+	w.m.Unlock()
+
+	w.extendReadDeadline()
+	conn.SetPongHandler(func(string) error {
+		w.extendReadDeadline()
+		return nil
+	})
+
+	if w.OnConnect != nil {
+		err = w.OnConnect(conn)
+		if err != nil {
+			return err
+		}
+	}
+
+	if w.Auth != nil {
+		err = w.Auth()
+		if err != nil {
+			return err
+		}
+	}
+
+	w.m.Lock()
+	defer w.m.Unlock()
+	for _, x := range w.subscriptions {
+		err = conn.WriteMessage(websocket.TextMessage, x)
+		if err != nil {
+			return err
+		}
+	}
+
+	if w.Verbose {
+		log.Printf("%s Websocket sent %d subscriptions.\n", w.Name, len(w.subscriptions))
+	}
+	return nil
+}
+
+func (w *WebsocketSupervisor) readLoop() error {
+	done := make(chan struct{})
+	defer close(done)
+	go w.heartbeat(done)
+
+	for w.Running() {
+		conn := w.Conn()
+		if conn == nil {
+			return ErrWebsocketNotConnected
+		}
+
+		msgType, resp, err := conn.ReadMessage()
+		if err != nil {
+			log.Printf("%s Websocket read error: %s\n", w.Name, err)
+			return err
+		}
+		w.extendReadDeadline()
+
+		if w.OnMessage != nil {
+			w.OnMessage(msgType, resp)
+		}
+	}
+	return nil
+}
+
+func (w *WebsocketSupervisor) heartbeat(done chan struct{}) {
+	if w.HeartbeatInterval <= 0 {
+		return
+	}
+
+	t := time.NewTicker(w.HeartbeatInterval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-t.C:
+			var err error
+			if w.Heartbeat != nil {
+				err = w.Heartbeat()
+			} else {
+				err = w.WriteControl(websocket.PingMessage, nil)
+			}
+			if err != nil {
+				log.Printf("%s Websocket heartbeat failed. Error: %s\n", w.Name, err)
+				// Closing the connection makes the pending read fail
+				w.close()
+				return
+			}
+		}
+	}
+}
+
+func (w *WebsocketSupervisor) extendReadDeadline() {
+	conn := w.Conn()
+	if conn == nil || w.ReadTimeout <= 0 {
+		return
+	}
+	conn.SetReadDeadline(time.Now().Add(w.ReadTimeout))
+}
+
+func (w *WebsocketSupervisor) close() {
+	w.m.Lock()
+	defer w.m.Unlock()
+
+	if w.conn != nil {
+		w.conn.Close()
+		w.conn = nil
+	}
+}
+
+// Conn returns the current connection, or nil while disconnected
+func (w *WebsocketSupervisor) Conn() *websocket.Conn {
+	w.m.Lock()
+	defer w.m.Unlock()
+	return w.conn
+}
+
+// IsConnected returns whether the websocket is currently connected
+func (w *WebsocketSupervisor) IsConnected() bool {
+	return w.Conn() != nil
+}
+
+// WriteMessage writes a message to the current connection
+func (w *WebsocketSupervisor) WriteMessage(msgType int, data []byte) error {
+	w.m.Lock()
+	defer w.m.Unlock()
+
+	if w.conn == nil {
+		return ErrWebsocketNotConnected
+	}
+	return w.conn.WriteMessage(msgType, data)
+}
+
+// WriteControl writes a control frame such as a ping to the current
+// connection
+func (w *WebsocketSupervisor) WriteControl(msgType int, data []byte) error {
+	conn := w.Conn()
+	if conn == nil {
+		return ErrWebsocketNotConnected
+	}
+	return conn.WriteControl(msgType, data, time.Now().Add(time.Second*10))
+}
+
+// Send JSON encodes data and writes it to the current connection
+func (w *WebsocketSupervisor) Send(data interface{}) error {
+	json, err := common.JSONEncode(data)
+	if err != nil {
+		return err
+	}
+	return w.WriteMessage(websocket.TextMessage, json)
+}
+
+// AddSubscription stores a subscription request so it is sent again after
+// every reconnect, and sends it now if connected. Requests already stored are
+// only sent.
+func (w *WebsocketSupervisor) AddSubscription(data interface{}) error {
+	json, err := common.JSONEncode(data)
+	if err != nil {
+		return err
+	}
+
+	w.m.Lock()
+	defer w.m.Unlock()
+
+	found := false
+	for _, x := range w.subscriptions {
+		if bytes.Equal(x, json) {
+			found = true
+			break
+		}
+	}
+	if !found {
+		w.subscriptions = append(w.subscriptions, json)
+	}
+
+	if w.conn == nil {
+		return nil
+	}
+	return w.conn.WriteMessage(websocket.TextMessage, json)
+}
+
+// RemoveSubscription forgets a subscription request stored by
+// AddSubscription, the exchange specific unsubscribe request must be sent
+// separately
+func (w *WebsocketSupervisor) RemoveSubscription(data interface{}) error {
+	json, err := common.JSONEncode(data)
+	if err != nil {
+		return err
+	}
+
+	w.m.Lock()
+	defer w.m.Unlock()
+
+	for i, x := range w.subscriptions {
+		if bytes.Equal(x, json) {
+			w.subscriptions = append(w.subscriptions[:i], w.subscriptions[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
+
+// SubscriptionCount returns the number of stored subscription requests
+func (w *WebsocketSupervisor) SubscriptionCount() int {
+	w.m.Lock()
+	defer w.m.Unlock()
+	return len(w.subscriptions)
+}
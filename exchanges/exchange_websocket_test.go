@@ -0,0 +1,71 @@
+package exchange
+
+import (
+	"testing"
+	"time"
+)
+
+func TestWebsocketSupervisorReconnectDelay(t *testing.T) {
+	t.Parallel()
+	w := WebsocketSupervisor{MinReconnectDelay: time.Second, MaxReconnectDelay: time.Second * 10}
+
+	expected := []time.Duration{time.Second, time.Second * 2, time.Second * 4, time.Second * 8, time.Second * 10, time.Second * 10}
+	for i, x := range expected {
+		delay := w.ReconnectDelay(i + 1)
+		if delay != x {
+			t.Errorf("Test failed. TestWebsocketSupervisorReconnectDelay attempt %d expected %s, got %s", i+1, x, delay)
+		}
+	}
+}
+
+func TestWebsocketSupervisorSubscriptions(t *testing.T) {
+	t.Parallel()
+	w := WebsocketSupervisor{}
+
+	sub := map[string]string{"event": "subscribe", "channel": "ticker"}
+	for i := 0; i < 2; i++ {
+		err := w.AddSubscription(sub)
+		if err != nil {
+			t.Fatalf("Test failed. TestWebsocketSupervisorSubscriptions error: %s", err)
+		}
+	}
+	w.AddSubscription(map[string]string{"event": "subscribe", "channel": "book"})
+
+	if w.SubscriptionCount() != 2 {
+		t.Errorf("Test failed. TestWebsocketSupervisorSubscriptions expected 2 subscriptions, got %d", w.SubscriptionCount())
+	}
+
+	w.RemoveSubscription(sub)
+	if w.SubscriptionCount() != 1 {
+		t.Errorf("Test failed. TestWebsocketSupervisorSubscriptions expected 1 subscription, got %d", w.SubscriptionCount())
+	}
+
+	if w.Send(sub) != ErrWebsocketNotConnected {
+		t.Error("Test failed. TestWebsocketSupervisorSubscriptions sent a message while disconnected")
+	}
+}
+
+func TestWebsocketState(t *testing.T) {
+	ch := SubscribeWebsocketState()
+	w := WebsocketSupervisor{Name: "TestWebsocketState"}
+	w.setState(WebsocketStateConnected, 1, nil)
+
+	select {
+	case event := <-ch:
+		if event.Exchange != "TestWebsocketState" || event.State != WebsocketStateConnected {
+			t.Errorf("Test failed. TestWebsocketState unexpected event %+v", event)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Test failed. TestWebsocketState event not received")
+	}
+
+	if GetWebsocketStates()["TestWebsocketState"].State != WebsocketStateConnected {
+		t.Error("Test failed. TestWebsocketState latest state not stored")
+	}
+
+	UnsubscribeWebsocketState(ch)
+	w.setState(WebsocketStateDisconnected, 0, nil)
+	if _, ok := <-ch; ok {
+		t.Error("Test failed. TestWebsocketState channel not closed on unsubscribe")
+	}
+}
@@ -7,10 +7,12 @@ import (
 	"log"
 	"net/url"
 	"strconv"
+	"sync"
 
 	"github.com/mattkanwisher/cryptofiend/common"
 	"github.com/mattkanwisher/cryptofiend/config"
 	"github.com/mattkanwisher/cryptofiend/exchanges"
+	"github.com/mattkanwisher/cryptofiend/exchanges/orderbook"
 	"github.com/mattkanwisher/cryptofiend/exchanges/ticker"
 )
 
@@ -53,6 +55,14 @@ var sometin []string
 // GDAX is the overarching type across the GDAX package
 type GDAX struct {
 	exchange.Base
+	WebsocketSupervisor *exchange.WebsocketSupervisor
+
+	// Level 3 orderbooks built from the full channel, keyed by product ID.
+	// A product has an entry in l3Pending for as long as its book is being
+	// resynchronised, holding the messages received meanwhile.
+	l3Books   map[string]*orderbook.L3Book
+	l3Pending map[string][]l3Message
+	l3Mutex   sync.Mutex
 }
 
 // SetDefaults sets default values for the exchange
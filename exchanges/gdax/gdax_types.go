@@ -341,19 +341,65 @@ type FillResponse struct {
 
 // WebsocketSubscribe takes in subscription information
 type WebsocketSubscribe struct {
-	Type      string `json:"type"`
-	ProductID string `json:"product_id"`
+	Type       string   `json:"type"`
+	ProductIDs []string `json:"product_ids"`
+	Channels   []string `json:"channels"`
+}
+
+// WebsocketAuthSubscribe is a subscription request signed with the API key,
+// required for the user channel
+type WebsocketAuthSubscribe struct {
+	Type       string   `json:"type"`
+	ProductIDs []string `json:"product_ids"`
+	Channels   []string `json:"channels"`
+	Signature  string   `json:"signature"`
+	Key        string   `json:"key"`
+	Passphrase string   `json:"passphrase"`
+	Timestamp  string   `json:"timestamp"`
+}
+
+// WebsocketTicker holds ticker channel information
+type WebsocketTicker struct {
+	Type      string  `json:"type"`
+	Sequence  int     `json:"sequence"`
+	ProductID string  `json:"product_id"`
+	Price     float64 `json:"price,string"`
+	Open24H   float64 `json:"open_24h,string"`
+	Volume24H float64 `json:"volume_24h,string"`
+	Low24H    float64 `json:"low_24h,string"`
+	High24H   float64 `json:"high_24h,string"`
+	BestBid   float64 `json:"best_bid,string"`
+	BestAsk   float64 `json:"best_ask,string"`
+}
+
+// WebsocketL2Snapshot holds the initial level2 channel orderbook, each level is
+// sent as [price, size]
+type WebsocketL2Snapshot struct {
+	Type      string     `json:"type"`
+	ProductID string     `json:"product_id"`
+	Bids      [][]string `json:"bids"`
+	Asks      [][]string `json:"asks"`
+}
+
+// WebsocketL2Update holds level2 channel changes, each change is sent as
+// [side, price, size] with a size of zero removing the level
+type WebsocketL2Update struct {
+	Type      string     `json:"type"`
+	ProductID string     `json:"product_id"`
+	Changes   [][]string `json:"changes"`
 }
 
 // WebsocketReceived holds websocket received values
 type WebsocketReceived struct {
-	Type     string  `json:"type"`
-	Time     string  `json:"time"`
-	Sequence int     `json:"sequence"`
-	OrderID  string  `json:"order_id"`
-	Size     float64 `json:"size,string"`
-	Price    float64 `json:"price,string"`
-	Side     string  `json:"side"`
+	Type      string  `json:"type"`
+	Time      string  `json:"time"`
+	Sequence  int     `json:"sequence"`
+	ProductID string  `json:"product_id"`
+	UserID    string  `json:"user_id"`
+	OrderID   string  `json:"order_id"`
+	Size      float64 `json:"size,string"`
+	Price     float64 `json:"price,string"`
+	Side      string  `json:"side"`
 }
 
 // WebsocketOpen collates open orders
@@ -361,6 +407,8 @@ type WebsocketOpen struct {
 	Type          string  `json:"type"`
 	Time          string  `json:"time"`
 	Sequence      int     `json:"sequence"`
+	ProductID     string  `json:"product_id"`
+	UserID        string  `json:"user_id"`
 	OrderID       string  `json:"order_id"`
 	Price         float64 `json:"price,string"`
 	RemainingSize float64 `json:"remaining_size,string"`
@@ -372,6 +420,8 @@ type WebsocketDone struct {
 	Type          string  `json:"type"`
 	Time          string  `json:"time"`
 	Sequence      int     `json:"sequence"`
+	ProductID     string  `json:"product_id"`
+	UserID        string  `json:"user_id"`
 	Price         float64 `json:"price,string"`
 	OrderID       string  `json:"order_id"`
 	Reason        string  `json:"reason"`
@@ -384,8 +434,11 @@ type WebsocketMatch struct {
 	Type         string  `json:"type"`
 	TradeID      int     `json:"trade_id"`
 	Sequence     int     `json:"sequence"`
+	ProductID    string  `json:"product_id"`
 	MakerOrderID string  `json:"maker_order_id"`
 	TakerOrderID string  `json:"taker_order_id"`
+	MakerUserID  string  `json:"maker_user_id"`
+	TakerUserID  string  `json:"taker_user_id"`
 	Time         string  `json:"time"`
 	Size         float64 `json:"size,string"`
 	Price        float64 `json:"price,string"`
@@ -394,12 +447,14 @@ type WebsocketMatch struct {
 
 // WebsocketChange holds change information
 type WebsocketChange struct {
-	Type     string  `json:"type"`
-	Time     string  `json:"time"`
-	Sequence int     `json:"sequence"`
-	OrderID  string  `json:"order_id"`
-	NewSize  float64 `json:"new_size,string"`
-	OldSize  float64 `json:"old_size,string"`
-	Price    float64 `json:"price,string"`
-	Side     string  `json:"side"`
+	Type      string  `json:"type"`
+	Time      string  `json:"time"`
+	Sequence  int     `json:"sequence"`
+	ProductID string  `json:"product_id"`
+	UserID    string  `json:"user_id"`
+	OrderID   string  `json:"order_id"`
+	NewSize   float64 `json:"new_size,string"`
+	OldSize   float64 `json:"old_size,string"`
+	Price     float64 `json:"price,string"`
+	Side      string  `json:"side"`
 }
@@ -1,125 +1,470 @@
 package gdax
 
 import (
+	"errors"
 	"log"
-	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/gorilla/websocket"
 	"github.com/mattkanwisher/cryptofiend/common"
+	"github.com/mattkanwisher/cryptofiend/currency/pair"
+	"github.com/mattkanwisher/cryptofiend/exchanges"
+	"github.com/mattkanwisher/cryptofiend/exchanges/orderbook"
+	"github.com/mattkanwisher/cryptofiend/exchanges/ticker"
 )
 
 const (
 	GDAX_WEBSOCKET_URL = "wss://ws-feed.gdax.com"
 )
 
-func (g *GDAX) WebsocketSubscribe(product string, conn *websocket.Conn) error {
-	subscribe := WebsocketSubscribe{"subscribe", product}
-	json, err := common.JSONEncode(subscribe)
+// Channels subscribed to for every enabled product, matches are delivered on
+// the full channel so the matches channel isn't needed
+var gdaxWebsocketChannels = []string{"ticker", "level2", "full"}
+
+// WebsocketSubscribe subscribes to the market data channels of a product, the
+// subscription is sent again whenever the supervisor reconnects
+func (g *GDAX) WebsocketSubscribe(product string) error {
+	subscribe := WebsocketSubscribe{"subscribe", []string{product}, gdaxWebsocketChannels}
+	return g.WebsocketSupervisor.AddSubscription(subscribe)
+}
+
+// WebsocketProcessTicker stores a ticker update and publishes it to stream
+// subscribers
+func (g *GDAX) WebsocketProcessTicker(t WebsocketTicker) {
+	p := pair.NewCurrencyPairDelimiter(t.ProductID, "-")
+	g.PushTicker(ticker.Price{Pair: p, Last: t.Price, High: t.High24H, Low: t.Low24H, Bid: t.BestBid, Ask: t.BestAsk, Volume: t.Volume24H}, ticker.Spot)
+}
+
+// WebsocketProcessL2Snapshot replaces the stored orderbook with the level2
+// channel snapshot and publishes it to stream subscribers
+func (g *GDAX) WebsocketProcessL2Snapshot(snapshot WebsocketL2Snapshot) {
+	ob := orderbook.Base{Pair: pair.NewCurrencyPairDelimiter(snapshot.ProductID, "-")}
+	for _, x := range snapshot.Bids {
+		price, _ := strconv.ParseFloat(x[0], 64)
+		amount, _ := strconv.ParseFloat(x[1], 64)
+		ob.Bids = append(ob.Bids, orderbook.Item{Price: price, Amount: amount})
+	}
+	for _, x := range snapshot.Asks {
+		price, _ := strconv.ParseFloat(x[0], 64)
+		amount, _ := strconv.ParseFloat(x[1], 64)
+		ob.Asks = append(ob.Asks, orderbook.Item{Price: price, Amount: amount})
+	}
+	g.PushOrderbook(ob, orderbook.Spot)
+}
+
+// WebsocketProcessL2Update applies level2 channel changes to the stored
+// orderbook and publishes the result to stream subscribers
+func (g *GDAX) WebsocketProcessL2Update(update WebsocketL2Update) {
+	p := pair.NewCurrencyPairDelimiter(update.ProductID, "-")
+	deltas := []orderbook.Delta{}
+	for _, x := range update.Changes {
+		delta := orderbook.Delta{Side: orderbook.Bid}
+		if x[0] == "sell" {
+			delta.Side = orderbook.Ask
+		}
+		delta.Price, _ = strconv.ParseFloat(x[1], 64)
+		delta.Amount, _ = strconv.ParseFloat(x[2], 64)
+		deltas = append(deltas, delta)
+	}
+
+	err := g.PushOrderbookDeltas(p, orderbook.Spot, 0, deltas)
+	if err == orderbook.ErrSnapshotMissing {
+		g.ResyncOrderbook(p, orderbook.Spot, g.UpdateOrderbook)
+	}
+}
+
+// WebsocketProcessMatch publishes a match received from the full channel to
+// stream subscribers
+func (g *GDAX) WebsocketProcessMatch(m WebsocketMatch) {
+	trade := exchange.Trade{
+		Exchange: g.GetName(),
+		Pair:     pair.NewCurrencyPairDelimiter(m.ProductID, "-"),
+		TradeID:  strconv.Itoa(m.TradeID),
+		Price:    m.Price,
+		Amount:   m.Size,
+	}
+	// The side reported is that of the maker order, so the taker is opposite
+	if m.Side == "buy" {
+		trade.Side = exchange.OrderSideSell
+	} else {
+		trade.Side = exchange.OrderSideBuy
+	}
+	if t, err := time.Parse(time.RFC3339Nano, m.Time); err == nil {
+		trade.Timestamp = t
+	}
+	g.Streams.PublishTrade(trade)
+}
+
+// Returns the orderbook side of a GDAX order side
+func gdaxBookSide(side string) string {
+	if side == "sell" {
+		return orderbook.Ask
+	}
+	return orderbook.Bid
+}
+
+// Level 3 orderbook resynchronisation limits. Failed snapshot requests are
+// retried with a delay doubling from l3ResyncMinDelay up to l3ResyncMaxDelay,
+// and at most l3MaxPending messages are buffered while a resync is running.
+const (
+	l3ResyncMinDelay = time.Second
+	l3ResyncMaxDelay = time.Minute
+	l3MaxPending     = 100000
+)
+
+var errL3SnapshotStale = errors.New("snapshot is older than the buffered messages")
+
+// l3Message is a full channel message to apply to a level 3 orderbook
+type l3Message struct {
+	sequence int64
+	apply    func(*orderbook.L3Book)
+}
+
+// applyL3 applies a message to a level 3 orderbook unless the book already
+// covers it
+func applyL3(book *orderbook.L3Book, msg l3Message) error {
+	ok, err := book.AdvanceSequence(msg.sequence)
+	if ok && msg.apply != nil {
+		msg.apply(book)
+	}
+	return err
+}
+
+// WebsocketProcessL3 applies a full channel message to the level 3 orderbook
+// of a product. Messages the book already covers are ignored. Before the first
+// message or after any are missed the book is reloaded from a REST snapshot by
+// WebsocketResyncL3, which runs apart from the read loop, and messages are
+// buffered until it completes. apply may be nil for messages that don't change
+// resting orders but still advance the sequence.
+func (g *GDAX) WebsocketProcessL3(product string, sequence int64, apply func(*orderbook.L3Book)) {
+	if product == "" {
+		return
+	}
+
+	g.l3Mutex.Lock()
+	defer g.l3Mutex.Unlock()
+
+	if g.l3Books == nil {
+		g.l3Books = make(map[string]*orderbook.L3Book)
+		g.l3Pending = make(map[string][]l3Message)
+	}
+	book, ok := g.l3Books[product]
+	if !ok {
+		book = orderbook.NewL3Book(pair.NewCurrencyPairDelimiter(product, "-"))
+		g.l3Books[product] = book
+	}
+
+	msg := l3Message{sequence, apply}
+	if pending, ok := g.l3Pending[product]; ok {
+		// Once the buffer is dropped the first message after the snapshot
+		// leaves a gap, which starts another resync
+		if len(pending) >= l3MaxPending {
+			log.Printf("%s %s level 3 orderbook resync buffer full, dropping messages.\n", g.GetName(), product)
+			pending = nil
+		}
+		g.l3Pending[product] = append(pending, msg)
+		return
+	}
+
+	if book.Sequence() != 0 {
+		err := applyL3(book, msg)
+		if err != orderbook.ErrSequenceGap {
+			return
+		}
+		log.Printf("%s %s level 3 orderbook missed updates, resynchronising.\n", g.GetName(), product)
+	}
+	g.l3Pending[product] = []l3Message{msg}
+	go g.WebsocketResyncL3(product, book)
+}
+
+// WebsocketResyncL3 reloads a level 3 orderbook from a REST snapshot and
+// applies the messages buffered since the resync started, retrying with
+// backoff until it succeeds or the websocket is disabled
+func (g *GDAX) WebsocketResyncL3(product string, book *orderbook.L3Book) {
+	delay := l3ResyncMinDelay
+	for {
+		if !g.Enabled || !g.Websocket {
+			g.l3Mutex.Lock()
+			delete(g.l3Pending, product)
+			g.l3Mutex.Unlock()
+			return
+		}
+
+		sequence, orders, err := g.getL3Snapshot(product)
+		if err == nil {
+			g.l3Mutex.Lock()
+			book.LoadSnapshot(sequence, orders)
+			pending := g.l3Pending[product]
+			delete(g.l3Pending, product)
+			for i, msg := range pending {
+				if applyL3(book, msg) == orderbook.ErrSequenceGap {
+					g.l3Pending[product] = pending[i:]
+					err = errL3SnapshotStale
+					break
+				}
+			}
+			g.l3Mutex.Unlock()
+			if err == nil {
+				return
+			}
+		}
+
+		log.Printf("%s failed to resynchronise %s level 3 orderbook, retrying in %s. Error: %s\n",
+			g.GetName(), product, delay, err)
+		time.Sleep(delay)
+		if delay *= 2; delay > l3ResyncMaxDelay {
+			delay = l3ResyncMaxDelay
+		}
+	}
+}
+
+// getL3Snapshot fetches the level 3 orderbook of a product over REST,
+// returning its sequence number and resting orders
+func (g *GDAX) getL3Snapshot(product string) (int64, []orderbook.L3Order, error) {
+	result, err := g.GetOrderbook(product, 3)
 	if err != nil {
-		return err
+		return 0, nil, err
 	}
 
-	err = conn.WriteMessage(websocket.TextMessage, json)
+	snapshot := result.(OrderbookL3)
+	orders := []orderbook.L3Order{}
+	for _, x := range snapshot.Bids {
+		orders = append(orders, orderbook.L3Order{ID: x.OrderID, Side: orderbook.Bid, Price: x.Price, Size: x.Amount})
+	}
+	for _, x := range snapshot.Asks {
+		orders = append(orders, orderbook.L3Order{ID: x.OrderID, Side: orderbook.Ask, Price: x.Price, Size: x.Amount})
+	}
+	return int64(snapshot.Sequence), orders, nil
+}
 
+// GetL3Orderbook returns the level 3 orderbook built from the full channel for
+// a currency pair
+func (g *GDAX) GetL3Orderbook(p pair.CurrencyPair) (*orderbook.L3Book, error) {
+	g.l3Mutex.Lock()
+	defer g.l3Mutex.Unlock()
+
+	book, ok := g.l3Books[exchange.FormatExchangeCurrency(g.Name, p).String()]
+	if !ok {
+		return nil, errors.New(orderbook.ErrOrderbookForExchangeNotFound)
+	}
+	return book, nil
+}
+
+// QueuePosition estimates the position of one of our resting orders in the
+// queue at its price level
+func (g *GDAX) QueuePosition(p pair.CurrencyPair, orderID string) (orderbook.QueuePosition, error) {
+	book, err := g.GetL3Orderbook(p)
 	if err != nil {
-		return err
+		return orderbook.QueuePosition{}, err
 	}
-	return nil
+	return book.QueuePosition(orderID)
 }
 
-func (g *GDAX) WebsocketClient() {
-	for g.Enabled && g.Websocket {
-		var Dialer websocket.Dialer
-		conn, _, err := Dialer.Dial(GDAX_WEBSOCKET_URL, http.Header{})
+// WebsocketAuth subscribes to the user channel for every enabled product if
+// authenticated API support is enabled, it is called by the supervisor after
+// each connection as the request signature expires
+func (g *GDAX) WebsocketAuth() error {
+	if !g.AuthenticatedAPISupport {
+		return nil
+	}
 
-		if err != nil {
-			log.Printf("%s Unable to connect to Websocket. Error: %s\n", g.GetName(), err)
-			continue
+	products := []string{}
+	for _, x := range g.EnabledPairs {
+		products = append(products, x[0:3]+"-"+x[3:])
+	}
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	hmac := common.GetHMAC(common.HashSHA256, []byte(timestamp+"GET/users/self/verify"), []byte(g.APISecret))
+	subscribe := WebsocketAuthSubscribe{
+		Type:       "subscribe",
+		ProductIDs: products,
+		Channels:   []string{"user"},
+		Signature:  common.Base64Encode(hmac),
+		Key:        g.APIKey,
+		Passphrase: g.ClientID,
+		Timestamp:  timestamp,
+	}
+	return g.WebsocketSupervisor.Send(subscribe)
+}
+
+// WebsocketProcessUserOrder publishes a change to one of our orders received
+// from the user channel to private stream subscribers. The user channel
+// doesn't report balances.
+func (g *GDAX) WebsocketProcessUserOrder(product, orderID, side string, status exchange.OrderStatus, price, remaining float64) {
+	g.PushOrderUpdate(exchange.Order{
+		CurrencyPair:    pair.NewCurrencyPairDelimiter(product, "-"),
+		Type:            exchange.OrderTypeExchangeLimit,
+		Side:            exchange.OrderSide(side),
+		RemainingAmount: remaining,
+		Rate:            price,
+		Status:          status,
+		OrderID:         orderID,
+	})
+}
+
+// WebsocketProcessUserMatch publishes a fill of one of our orders received
+// from the user channel to private stream subscribers
+func (g *GDAX) WebsocketProcessUserMatch(m WebsocketMatch) {
+	fill := exchange.Fill{
+		TradeID:      strconv.Itoa(m.TradeID),
+		CurrencyPair: pair.NewCurrencyPairDelimiter(m.ProductID, "-"),
+		Price:        m.Price,
+		Amount:       m.Size,
+	}
+	// The side reported is that of the maker order
+	if m.MakerUserID != "" {
+		fill.OrderID = m.MakerOrderID
+		fill.Side = exchange.OrderSide(m.Side)
+	} else {
+		fill.OrderID = m.TakerOrderID
+		if m.Side == "buy" {
+			fill.Side = exchange.OrderSideSell
+		} else {
+			fill.Side = exchange.OrderSideBuy
 		}
+	}
+
+	event := exchange.PrivateEvent{Exchange: g.GetName(), Type: exchange.PrivateEventFill, Fill: &fill}
+	if t, err := time.Parse(time.RFC3339Nano, m.Time); err == nil {
+		event.Timestamp = t
+	}
+	g.Streams.PublishPrivate(event)
+}
 
-		log.Printf("%s Connected to Websocket.\n", g.GetName())
+// WebsocketClient makes a connection with the websocket server and keeps it
+// open while the exchange and its websocket are enabled
+func (g *GDAX) WebsocketClient() {
+	g.WebsocketSupervisor = exchange.NewWebsocketSupervisor(&g.Base, GDAX_WEBSOCKET_URL)
+	g.WebsocketSupervisor.Auth = g.WebsocketAuth
+	g.WebsocketSupervisor.OnMessage = g.WebsocketOnMessage
 
-		currencies := []string{}
-		for _, x := range g.EnabledPairs {
-			currency := x[0:3] + "-" + x[3:]
-			currencies = append(currencies, currency)
+	for _, x := range g.EnabledPairs {
+		err := g.WebsocketSubscribe(x[0:3] + "-" + x[3:])
+		if err != nil {
+			log.Printf("%s Websocket subscription error: %s\n", g.GetName(), err)
 		}
+	}
 
-		for _, x := range currencies {
-			err = g.WebsocketSubscribe(x, conn)
-			if err != nil {
-				log.Printf("%s Websocket subscription error: %s\n", g.GetName(), err)
-				continue
-			}
+	g.WebsocketSupervisor.Run()
+}
+
+// WebsocketOnMessage handles a message received from the websocket server
+func (g *GDAX) WebsocketOnMessage(msgType int, resp []byte) {
+	switch msgType {
+	case websocket.TextMessage:
+		type MsgType struct {
+			Type string `json:"type"`
 		}
 
-		if g.Verbose {
-			log.Printf("%s Subscribed to product messages.", g.GetName())
+		msgType := MsgType{}
+		err := common.JSONDecode(resp, &msgType)
+		if err != nil {
+			log.Println(err)
+			return
 		}
 
-		for g.Enabled && g.Websocket {
-			msgType, resp, err := conn.ReadMessage()
+		switch msgType.Type {
+		case "error":
+			log.Println(string(resp))
+			break
+		case "received":
+			received := WebsocketReceived{}
+			err := common.JSONDecode(resp, &received)
 			if err != nil {
 				log.Println(err)
-				break
+				return
 			}
-
-			switch msgType {
-			case websocket.TextMessage:
-				type MsgType struct {
-					Type string `json:"type"`
-				}
-
-				msgType := MsgType{}
-				err := common.JSONDecode(resp, &msgType)
-				if err != nil {
-					log.Println(err)
-					continue
-				}
-
-				switch msgType.Type {
-				case "error":
-					log.Println(string(resp))
-					break
-				case "received":
-					received := WebsocketReceived{}
-					err := common.JSONDecode(resp, &received)
-					if err != nil {
-						log.Println(err)
-						continue
-					}
-				case "open":
-					open := WebsocketOpen{}
-					err := common.JSONDecode(resp, &open)
-					if err != nil {
-						log.Println(err)
-						continue
-					}
-				case "done":
-					done := WebsocketDone{}
-					err := common.JSONDecode(resp, &done)
-					if err != nil {
-						log.Println(err)
-						continue
-					}
-				case "match":
-					match := WebsocketMatch{}
-					err := common.JSONDecode(resp, &match)
-					if err != nil {
-						log.Println(err)
-						continue
-					}
-				case "change":
-					change := WebsocketChange{}
-					err := common.JSONDecode(resp, &change)
-					if err != nil {
-						log.Println(err)
-						continue
-					}
+			g.WebsocketProcessL3(received.ProductID, int64(received.Sequence), nil)
+			if received.UserID != "" {
+				g.WebsocketProcessUserOrder(received.ProductID, received.OrderID, received.Side, exchange.OrderStatusActive, received.Price, received.Size)
+			}
+		case "open":
+			open := WebsocketOpen{}
+			err := common.JSONDecode(resp, &open)
+			if err != nil {
+				log.Println(err)
+				return
+			}
+			g.WebsocketProcessL3(open.ProductID, int64(open.Sequence), func(b *orderbook.L3Book) {
+				b.Open(orderbook.L3Order{ID: open.OrderID, Side: gdaxBookSide(open.Side), Price: open.Price, Size: open.RemainingSize})
+			})
+			if open.UserID != "" {
+				g.WebsocketProcessUserOrder(open.ProductID, open.OrderID, open.Side, exchange.OrderStatusActive, open.Price, open.RemainingSize)
+			}
+		case "done":
+			done := WebsocketDone{}
+			err := common.JSONDecode(resp, &done)
+			if err != nil {
+				log.Println(err)
+				return
+			}
+			g.WebsocketProcessL3(done.ProductID, int64(done.Sequence), func(b *orderbook.L3Book) {
+				b.Done(done.OrderID)
+			})
+			if done.UserID != "" {
+				status := exchange.OrderStatusFilled
+				if done.Reason == "canceled" {
+					status = exchange.OrderStatusAborted
 				}
+				g.WebsocketProcessUserOrder(done.ProductID, done.OrderID, done.Side, status, done.Price, done.RemainingSize)
+			}
+		case "match", "last_match":
+			match := WebsocketMatch{}
+			err := common.JSONDecode(resp, &match)
+			if err != nil {
+				log.Println(err)
+				return
+			}
+			// Our own matches are sent on the user channel as well as the full
+			// channel, only the full channel's copy is a public trade
+			if match.MakerUserID != "" || match.TakerUserID != "" {
+				g.WebsocketProcessUserMatch(match)
+				return
+			}
+			g.WebsocketProcessMatch(match)
+			if match.Type == "match" {
+				g.WebsocketProcessL3(match.ProductID, int64(match.Sequence), func(b *orderbook.L3Book) {
+					b.Match(match.MakerOrderID, match.Size)
+				})
+			}
+		case "snapshot":
+			snapshot := WebsocketL2Snapshot{}
+			err := common.JSONDecode(resp, &snapshot)
+			if err != nil {
+				log.Println(err)
+				return
+			}
+			g.WebsocketProcessL2Snapshot(snapshot)
+		case "l2update":
+			update := WebsocketL2Update{}
+			err := common.JSONDecode(resp, &update)
+			if err != nil {
+				log.Println(err)
+				return
+			}
+			g.WebsocketProcessL2Update(update)
+		case "ticker":
+			ticker := WebsocketTicker{}
+			err := common.JSONDecode(resp, &ticker)
+			if err != nil {
+				log.Println(err)
+				return
+			}
+			g.WebsocketProcessTicker(ticker)
+		case "change":
+			change := WebsocketChange{}
+			err := common.JSONDecode(resp, &change)
+			if err != nil {
+				log.Println(err)
+				return
+			}
+			g.WebsocketProcessL3(change.ProductID, int64(change.Sequence), func(b *orderbook.L3Book) {
+				b.Change(change.OrderID, change.NewSize)
+			})
+			if change.UserID != "" {
+				g.WebsocketProcessUserOrder(change.ProductID, change.OrderID, change.Side, exchange.OrderStatusActive, change.Price, change.NewSize)
 			}
 		}
-		conn.Close()
-		log.Printf("%s Websocket client disconnected.", g.GetName())
 	}
 }
@@ -44,6 +44,21 @@ func (g *GDAX) Run() {
 	}
 }
 
+// StreamsFullOrderbook returns true as the level2 channel sends a snapshot
+// followed by changes to it
+func (g *GDAX) StreamsFullOrderbook() bool {
+	return true
+}
+
+// SubscribePrivate returns a channel of our order, fill and balance updates
+// pushed by the GDAX websocket user channel
+func (g *GDAX) SubscribePrivate() (<-chan exchange.PrivateEvent, error) {
+	if !g.IsPrivateStreamEnabled() {
+		return nil, exchange.ErrPrivateStreamNotEnabled
+	}
+	return g.Streams.SubscribePrivate(), nil
+}
+
 // GetExchangeAccountInfo retrieves balances for all enabled currencies for the
 // GDAX exchange
 func (g *GDAX) GetExchangeAccountInfo() (exchange.AccountInfo, error) {
@@ -56,8 +71,9 @@ func (g *GDAX) GetExchangeAccountInfo() (exchange.AccountInfo, error) {
 	for i := 0; i < len(accountBalance); i++ {
 		var exchangeCurrency exchange.AccountCurrencyInfo
 		exchangeCurrency.CurrencyName = accountBalance[i].Currency
-		exchangeCurrency.TotalValue = accountBalance[i].Available
+		exchangeCurrency.TotalValue = accountBalance[i].Balance
 		exchangeCurrency.Hold = accountBalance[i].Hold
+		exchangeCurrency.Available = accountBalance[i].Available
 
 		response.Currencies = append(response.Currencies, exchangeCurrency)
 	}
@@ -120,8 +136,9 @@ func (g *GDAX) UpdateOrderbook(p pair.CurrencyPair, assetType string) (orderbook
 	}
 
 	for x := range obNew.Asks {
-		orderBook.Asks = append(orderBook.Asks, orderbook.Item{Amount: obNew.Bids[x].Amount, Price: obNew.Bids[x].Price})
+		orderBook.Asks = append(orderBook.Asks, orderbook.Item{Amount: obNew.Asks[x].Amount, Price: obNew.Asks[x].Price})
 	}
+	orderBook.Sequence = obNew.Sequence
 
 	g.Orderbooks.ProcessOrderbook(g.GetName(), p, orderBook, assetType)
 	return g.Orderbooks.GetOrderbook(g.Name, p, assetType)
@@ -1,9 +1,16 @@
 package huobi
 
 import (
+	"fmt"
 	"log"
+	"strconv"
+	"time"
 
 	"github.com/mattkanwisher/cryptofiend/common"
+	"github.com/mattkanwisher/cryptofiend/currency/pair"
+	"github.com/mattkanwisher/cryptofiend/exchanges"
+	"github.com/mattkanwisher/cryptofiend/exchanges/orderbook"
+	"github.com/mattkanwisher/cryptofiend/exchanges/ticker"
 	"github.com/thrasher-/socketio"
 )
 
@@ -165,14 +172,17 @@ func (h *HUOBI) OnConnect(output chan socketio.Message) {
 		log.Printf("%s Connected to Websocket.", h.GetName())
 	}
 
+	channels := []string{HUOBI_SOCKET_MARKET_OVERVIEW, HUOBI_SOCKET_MARKET_DEPTH_TOP, HUOBI_SOCKET_TRADE_DETAIL}
 	for _, x := range h.EnabledPairs {
 		currency := common.StringToLower(x)
-		msg := h.BuildHuobiWebsocketRequestExtra(HUOBI_SOCKET_REQ_SUBSCRIBE, 100, h.BuildHuobiWebsocketParamsList(HUOBI_SOCKET_MARKET_OVERVIEW, currency, "pushLong", "", "", "", "", ""))
-		result, err := common.JSONEncode(msg)
-		if err != nil {
-			log.Println(err)
+		for _, y := range channels {
+			msg := h.BuildHuobiWebsocketRequestExtra(HUOBI_SOCKET_REQ_SUBSCRIBE, 100, h.BuildHuobiWebsocketParamsList(y, currency, "pushLong", "", "", "", "", ""))
+			result, err := common.JSONEncode(msg)
+			if err != nil {
+				log.Println(err)
+			}
+			output <- socketio.CreateMessageEvent("request", string(result), nil, HuobiSocket.Version)
 		}
-		output <- socketio.CreateMessageEvent("request", string(result), nil, HuobiSocket.Version)
 	}
 }
 
@@ -186,7 +196,118 @@ func (h *HUOBI) OnError() {
 	h.WebsocketClient()
 }
 
+// OnMessage handles pushed market data
 func (h *HUOBI) OnMessage(message []byte, output chan socketio.Message) {
+	response := HuobiResponse{}
+	err := common.JSONDecode(message, &response)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	payload, err := common.JSONEncode(response.Payload)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	switch response.MsgType {
+	case HUOBI_SOCKET_MARKET_OVERVIEW:
+		overview := HuobiWebsocketMarketOverview{}
+		err = common.JSONDecode(payload, &overview)
+		if err != nil {
+			log.Println(err)
+			return
+		}
+		h.WebsocketProcessTicker(overview)
+	case HUOBI_SOCKET_MARKET_DEPTH_TOP:
+		depth := HuobiDepth{}
+		err = common.JSONDecode(payload, &depth)
+		if err != nil {
+			log.Println(err)
+			return
+		}
+		h.WebsocketProcessDepth(depth)
+	case HUOBI_SOCKET_TRADE_DETAIL:
+		trades := HuobiWebsocketTradeDetail{}
+		err = common.JSONDecode(payload, &trades)
+		if err != nil {
+			log.Println(err)
+			return
+		}
+		h.WebsocketProcessTrades(trades)
+	}
+}
+
+// WebsocketSymbolPair returns the currency pair of a symbol ID such as btccny
+func (h *HUOBI) WebsocketSymbolPair(symbol string) (pair.CurrencyPair, error) {
+	if len(symbol) <= 3 {
+		return pair.CurrencyPair{}, fmt.Errorf("%s websocket invalid symbol %q", h.GetName(), symbol)
+	}
+	symbol = common.StringToUpper(symbol)
+	return pair.NewCurrencyPair(symbol[0:3], symbol[3:]), nil
+}
+
+// WebsocketProcessTicker stores a market overview push as a ticker update and
+// publishes it to stream subscribers
+func (h *HUOBI) WebsocketProcessTicker(overview HuobiWebsocketMarketOverview) {
+	p, err := h.WebsocketSymbolPair(overview.SymbolID)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	var tp ticker.Price
+	tp.Pair = p
+	tp.Ask = overview.Ask
+	tp.Bid = overview.Bid
+	tp.High = overview.High
+	tp.Low = overview.Low
+	tp.Last = overview.Last
+	tp.Volume = overview.Volume
+	h.PushTicker(tp, ticker.Spot)
+}
+
+// WebsocketProcessDepth stores a top of book push and publishes it to stream
+// subscribers
+func (h *HUOBI) WebsocketProcessDepth(depth HuobiDepth) {
+	p, err := h.WebsocketSymbolPair(depth.SymbolID)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	ob := orderbook.Base{Pair: p}
+	for i := 0; i < len(depth.BidPrice) && i < len(depth.BidAmount); i++ {
+		ob.Bids = append(ob.Bids, orderbook.Item{Price: depth.BidPrice[i], Amount: depth.BidAmount[i]})
+	}
+	for i := 0; i < len(depth.AskPrice) && i < len(depth.AskAmount); i++ {
+		ob.Asks = append(ob.Asks, orderbook.Item{Price: depth.AskPrice[i], Amount: depth.AskAmount[i]})
+	}
+	h.PushOrderbook(ob, orderbook.Spot)
+}
+
+// WebsocketProcessTrades publishes a trade detail push to stream subscribers
+func (h *HUOBI) WebsocketProcessTrades(trades HuobiWebsocketTradeDetail) {
+	p, err := h.WebsocketSymbolPair(trades.SymbolID)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	for i := 0; i < len(trades.TradeID) && i < len(trades.Price) && i < len(trades.Amount); i++ {
+		trade := exchange.Trade{
+			Exchange: h.GetName(),
+			Pair:     p,
+			TradeID:  strconv.FormatInt(trades.TradeID[i], 10),
+			Price:    trades.Price[i],
+			Amount:   trades.Amount[i],
+		}
+		if i < len(trades.Time) {
+			trade.Timestamp = time.Unix(trades.Time[i], 0)
+		}
+		h.Streams.PublishTrade(trade)
+	}
 }
 
 func (h *HUOBI) OnRequest(message []byte, output chan socketio.Message) {
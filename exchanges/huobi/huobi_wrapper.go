@@ -86,6 +86,12 @@ func (h *HUOBI) UpdateOrderbook(p pair.CurrencyPair, assetType string) (orderboo
 	return h.Orderbooks.GetOrderbook(h.Name, p, assetType)
 }
 
+// StreamsFullOrderbook returns false as the depth feed only carries the top
+// levels of the orderbook
+func (h *HUOBI) StreamsFullOrderbook() bool {
+	return false
+}
+
 //GetExchangeAccountInfo retrieves balances for all enabled currencies for the
 // HUOBI exchange - to-do
 func (h *HUOBI) GetExchangeAccountInfo() (exchange.AccountInfo, error) {
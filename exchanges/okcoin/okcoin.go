@@ -74,10 +74,13 @@ var (
 
 type OKCoin struct {
 	exchange.Base
-	RESTErrors      map[string]string
-	WebsocketErrors map[string]string
-	FuturesValues   []string
-	WebsocketConn   *websocket.Conn
+	RESTErrors          map[string]string
+	WebsocketErrors     map[string]string
+	FuturesValues       []string
+	WebsocketConn       *websocket.Conn
+	WebsocketSupervisor *exchange.WebsocketSupervisor
+	// Incremental depth channels which have sent their initial full orderbook
+	depthSnapshots map[string]bool
 }
 
 func (o *OKCoin) setCurrencyPairFormats() {
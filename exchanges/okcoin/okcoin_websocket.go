@@ -3,7 +3,6 @@ package okcoin
 import (
 	"fmt"
 	"log"
-	"net/http"
 	"net/url"
 	"reflect"
 	"strconv"
@@ -12,6 +11,10 @@ import (
 
 	"github.com/gorilla/websocket"
 	"github.com/mattkanwisher/cryptofiend/common"
+	"github.com/mattkanwisher/cryptofiend/currency/pair"
+	"github.com/mattkanwisher/cryptofiend/exchanges"
+	"github.com/mattkanwisher/cryptofiend/exchanges/orderbook"
+	"github.com/mattkanwisher/cryptofiend/exchanges/ticker"
 )
 
 const (
@@ -42,14 +45,29 @@ func (o *OKCoin) PingHandler(message string) error {
 	return nil
 }
 
+// WebsocketWrite writes a message to the websocket connection, through the
+// supervisor while the client is running
+func (o *OKCoin) WebsocketWrite(data []byte) error {
+	if o.WebsocketSupervisor != nil {
+		return o.WebsocketSupervisor.WriteMessage(websocket.TextMessage, data)
+	}
+	return o.WebsocketConn.WriteMessage(websocket.TextMessage, data)
+}
+
 func (o *OKCoin) AddChannel(channel string) {
 	event := OKCoinWebsocketEvent{"addChannel", channel}
-	json, err := common.JSONEncode(event)
-	if err != nil {
-		log.Println(err)
-		return
+	var err error
+	if o.WebsocketSupervisor != nil {
+		err = o.WebsocketSupervisor.AddSubscription(event)
+	} else {
+		var json []byte
+		json, err = common.JSONEncode(event)
+		if err != nil {
+			log.Println(err)
+			return
+		}
+		err = o.WebsocketWrite(json)
 	}
-	err = o.WebsocketConn.WriteMessage(websocket.TextMessage, json)
 
 	if err != nil {
 		log.Println(err)
@@ -62,13 +80,17 @@ func (o *OKCoin) AddChannel(channel string) {
 }
 
 func (o *OKCoin) RemoveChannel(channel string) {
+	if o.WebsocketSupervisor != nil {
+		o.WebsocketSupervisor.RemoveSubscription(OKCoinWebsocketEvent{"addChannel", channel})
+	}
+
 	event := OKCoinWebsocketEvent{"removeChannel", channel}
 	json, err := common.JSONEncode(event)
 	if err != nil {
 		log.Println(err)
 		return
 	}
-	err = o.WebsocketConn.WriteMessage(websocket.TextMessage, json)
+	err = o.WebsocketWrite(json)
 
 	if err != nil {
 		log.Println(err)
@@ -180,7 +202,7 @@ func (o *OKCoin) AddChannelAuthenticated(channel string, values map[string]strin
 		log.Println(err)
 		return
 	}
-	err = o.WebsocketConn.WriteMessage(websocket.TextMessage, json)
+	err = o.WebsocketWrite(json)
 
 	if err != nil {
 		log.Println(err)
@@ -200,7 +222,7 @@ func (o *OKCoin) RemoveChannelAuthenticated(conn *websocket.Conn, channel string
 		log.Println(err)
 		return
 	}
-	err = o.WebsocketConn.WriteMessage(websocket.TextMessage, json)
+	err = o.WebsocketWrite(json)
 
 	if err != nil {
 		log.Println(err)
@@ -212,10 +234,172 @@ func (o *OKCoin) RemoveChannelAuthenticated(conn *websocket.Conn, channel string
 	}
 }
 
-func (o *OKCoin) WebsocketClient() {
-	klineValues := []string{"1min", "3min", "5min", "15min", "30min", "1hour", "2hour", "4hour", "6hour", "12hour", "day", "3day", "week"}
-	currencyChan, userinfoChan := "", ""
+// WebsocketChannelPair returns the currency pair of a market data channel such
+// as ok_btcusd_ticker
+func (o *OKCoin) WebsocketChannelPair(channel string) pair.CurrencyPair {
+	currency := common.StringToUpper(common.SplitStrings(channel, "_")[1])
+	return pair.NewCurrencyPair(currency[0:3], currency[3:])
+}
+
+// WebsocketProcessTicker stores a spot ticker update and publishes it to
+// stream subscribers
+func (o *OKCoin) WebsocketProcessTicker(channel string, t OKCoinWebsocketTicker) {
+	var tp ticker.Price
+	tp.Pair = o.WebsocketChannelPair(channel)
+	tp.Ask = t.Sell
+	tp.Bid = t.Buy
+	tp.Low = t.Low
+	tp.Last = t.Last
+	tp.High = t.High
+	tp.Volume, _ = strconv.ParseFloat(common.ReplaceString(t.Vol, ",", "", -1), 64)
+	o.PushTicker(tp, ticker.Spot)
+}
+
+// WebsocketProcessOrderbook handles spot depth channel data and publishes the
+// orderbook to stream subscribers. The first message on the incremental depth
+// channel holds the full orderbook, later ones only the changed levels with an
+// amount of zero for removed levels.
+func (o *OKCoin) WebsocketProcessOrderbook(channel string, ob OKCoinWebsocketOrderbook) {
+	p := o.WebsocketChannelPair(channel)
+
+	if o.depthSnapshots[channel] {
+		deltas := []orderbook.Delta{}
+		for _, x := range ob.Bids {
+			deltas = append(deltas, orderbook.Delta{Side: orderbook.Bid, Price: x[0], Amount: x[1]})
+		}
+		for _, x := range ob.Asks {
+			deltas = append(deltas, orderbook.Delta{Side: orderbook.Ask, Price: x[0], Amount: x[1]})
+		}
+		err := o.PushOrderbookDeltas(p, orderbook.Spot, 0, deltas)
+		if err != nil {
+			// Subscribing again makes the server send the full orderbook
+			log.Printf("%s %s orderbook update not applied, resubscribing. Error: %s\n", o.GetName(), channel, err)
+			o.depthSnapshots[channel] = false
+			o.RemoveChannel(channel)
+			o.AddChannel(channel)
+		}
+		return
+	}
+
+	orderBook := orderbook.Base{Pair: p}
+	for _, x := range ob.Bids {
+		orderBook.Bids = append(orderBook.Bids, orderbook.Item{Price: x[0], Amount: x[1]})
+	}
+	// Asks are sent highest first
+	for i := len(ob.Asks) - 1; i >= 0; i-- {
+		orderBook.Asks = append(orderBook.Asks, orderbook.Item{Price: ob.Asks[i][0], Amount: ob.Asks[i][1]})
+	}
+	o.PushOrderbook(orderBook, orderbook.Spot)
+	o.depthSnapshots[channel] = true
+}
+
+// WebsocketProcessTrades publishes spot trades to stream subscribers, each
+// trade is sent as [id, price, amount, time, type]
+func (o *OKCoin) WebsocketProcessTrades(channel string, trades [][]string) {
+	p := o.WebsocketChannelPair(channel)
+	for _, x := range trades {
+		if len(x) < 5 {
+			continue
+		}
+		trade := exchange.Trade{
+			Exchange: o.GetName(),
+			Pair:     p,
+			TradeID:  x[0],
+			Side:     exchange.OrderSideBuy,
+		}
+		trade.Price, _ = strconv.ParseFloat(x[1], 64)
+		trade.Amount, _ = strconv.ParseFloat(x[2], 64)
+		if x[4] == "ask" {
+			trade.Side = exchange.OrderSideSell
+		}
+		o.Streams.PublishTrade(trade)
+	}
+}
+
+// WebsocketProcessRealtrades publishes a change to one of our spot orders, and
+// the fill that caused it if any, to private stream subscribers
+func (o *OKCoin) WebsocketProcessRealtrades(t OKCoinWebsocketRealtrades) {
+	p := pair.NewCurrencyPairDelimiter(common.StringToUpper(t.Symbol), "_")
+	orderID := strconv.FormatFloat(t.OrderID, 'f', -1, 64)
+	side := exchange.OrderSideBuy
+	if common.StringContains(t.TradeType, "sell") {
+		side = exchange.OrderSideSell
+	}
+
+	order := exchange.Order{
+		CurrencyPair:    p,
+		Type:            exchange.OrderTypeExchangeLimit,
+		Side:            side,
+		Amount:          t.TradeAmount,
+		FilledAmount:    t.CompletedTradeAmount,
+		RemainingAmount: t.UnTrade,
+		Rate:            t.TradeUnitPrice,
+		CreatedAt:       int64(t.DateCreated) / 1000,
+		OrderID:         orderID,
+	}
+	// -1 cancelled, 0 unfilled, 1 partially filled, 2 filled, 4 cancelling
+	switch t.Status {
+	case -1:
+		order.Status = exchange.OrderStatusAborted
+	case 0, 1, 4:
+		order.Status = exchange.OrderStatusActive
+	case 2:
+		order.Status = exchange.OrderStatusFilled
+	default:
+		order.Status = exchange.OrderStatusUnknown
+	}
+	o.PushOrderUpdate(order)
+
+	if t.SigTradeAmount > 0 {
+		o.PushFill(exchange.Fill{
+			OrderID:      orderID,
+			TradeID:      strconv.FormatFloat(t.ID, 'f', -1, 64),
+			CurrencyPair: p,
+			Side:         side,
+			Price:        t.SigTradePrice,
+			Amount:       t.SigTradeAmount,
+		})
+	}
+}
+
+// WebsocketProcessUserinfo publishes our spot balances to private stream
+// subscribers
+func (o *OKCoin) WebsocketProcessUserinfo(u OKCoinWebsocketUserinfo) {
+	free := u.Info.Funds.Free
+	frozen := u.Info.Funds.Frozen
+	balances := []exchange.AccountCurrencyInfo{
+		{CurrencyName: "BTC", Available: free.BTC, Hold: frozen.BTC},
+		{CurrencyName: "LTC", Available: free.LTC, Hold: frozen.LTC},
+	}
+	if o.WebsocketURL == OKCOIN_WEBSOCKET_URL_CHINA {
+		balances = append(balances, exchange.AccountCurrencyInfo{CurrencyName: "CNY", Available: free.CNY, Hold: frozen.CNY})
+	} else {
+		balances = append(balances, exchange.AccountCurrencyInfo{CurrencyName: "USD", Available: free.USD, Hold: frozen.USD})
+	}
+
+	for _, x := range balances {
+		x.TotalValue = x.Available + x.Hold
+		o.PushBalance(x)
+	}
+}
+
+// WebsocketOnConnect resets the state of the previous connection, it is called
+// by the supervisor after each connection
+func (o *OKCoin) WebsocketOnConnect(conn *websocket.Conn) error {
+	o.WebsocketConn = conn
+	o.WebsocketConn.SetPingHandler(o.PingHandler)
+	o.depthSnapshots = make(map[string]bool)
+	return nil
+}
+
+// WebsocketAuth subscribes to the authenticated channels if authenticated API
+// support is enabled, it is called by the supervisor after each connection
+func (o *OKCoin) WebsocketAuth() error {
+	if !o.AuthenticatedAPISupport {
+		return nil
+	}
 
+	currencyChan, userinfoChan := "", ""
 	if o.WebsocketURL == OKCOIN_WEBSOCKET_URL_CHINA {
 		currencyChan = OKCOIN_WEBSOCKET_CNY_REALTRADES
 		userinfoChan = OKCOIN_WEBSOCKET_SPOTCNY_USERINFO
@@ -224,291 +408,294 @@ func (o *OKCoin) WebsocketClient() {
 		userinfoChan = OKCOIN_WEBSOCKET_SPOTUSD_USERINFO
 	}
 
-	for o.Enabled && o.Websocket {
-		var Dialer websocket.Dialer
-		var err error
-		o.WebsocketConn, _, err = Dialer.Dial(o.WebsocketURL, http.Header{})
-
-		if err != nil {
-			log.Printf("%s Unable to connect to Websocket. Error: %s\n", o.GetName(), err)
-			continue
+	if o.WebsocketURL == OKCOIN_WEBSOCKET_URL {
+		o.AddChannelAuthenticated(OKCOIN_WEBSOCKET_FUTURES_REALTRADES, map[string]string{})
+		o.AddChannelAuthenticated(OKCOIN_WEBSOCKET_FUTURES_USERINFO, map[string]string{})
+	}
+	o.AddChannelAuthenticated(currencyChan, map[string]string{})
+	o.AddChannelAuthenticated(userinfoChan, map[string]string{})
+
+	for _, x := range o.EnabledPairs {
+		currency := common.StringToLower(x)
+		currencyUL := currency[0:3] + "_" + currency[3:]
+		o.WebsocketSpotOrderInfo(currencyUL, -1)
+		if o.WebsocketURL == OKCOIN_WEBSOCKET_URL {
+			for _, y := range o.FuturesValues {
+				o.WebsocketFuturesOrderInfo(currencyUL, y, -1, 1, 1, 50)
+			}
 		}
+	}
+	return nil
+}
 
-		if o.Verbose {
-			log.Printf("%s Connected to Websocket.\n", o.GetName())
-		}
+// WebsocketClient makes a connection with the websocket server and keeps it
+// open while the exchange and its websocket are enabled
+func (o *OKCoin) WebsocketClient() {
+	klineValues := []string{"1min", "3min", "5min", "15min", "30min", "1hour", "2hour", "4hour", "6hour", "12hour", "day", "3day", "week"}
 
-		o.WebsocketConn.SetPingHandler(o.PingHandler)
+	o.WebsocketSupervisor = exchange.NewWebsocketSupervisor(&o.Base, o.WebsocketURL)
+	o.WebsocketSupervisor.OnConnect = o.WebsocketOnConnect
+	o.WebsocketSupervisor.Auth = o.WebsocketAuth
+	o.WebsocketSupervisor.OnMessage = o.WebsocketOnMessage
+
+	for _, x := range o.EnabledPairs {
+		currency := common.StringToLower(x)
+		if o.WebsocketURL == OKCOIN_WEBSOCKET_URL {
+			o.AddChannel(fmt.Sprintf("ok_%s_future_index", currency))
+			for _, y := range o.FuturesValues {
+				o.AddChannel(fmt.Sprintf("ok_%s_future_ticker_%s", currency, y))
+				o.AddChannel(fmt.Sprintf("ok_%s_future_depth_%s_60", currency, y))
+				o.AddChannel(fmt.Sprintf("ok_%s_future_trade_v1_%s", currency, y))
+				for _, z := range klineValues {
+					o.AddChannel(fmt.Sprintf("ok_future_%s_kline_%s_%s", currency, y, z))
+				}
+			}
+		} else {
+			o.AddChannel(fmt.Sprintf("ok_%s_ticker", currency))
+			o.AddChannel(fmt.Sprintf("ok_%s_depth", currency))
+			o.AddChannel(fmt.Sprintf("ok_%s_trades_v1", currency))
 
-		if o.AuthenticatedAPISupport {
-			if o.WebsocketURL == OKCOIN_WEBSOCKET_URL {
-				o.AddChannelAuthenticated(OKCOIN_WEBSOCKET_FUTURES_REALTRADES, map[string]string{})
-				o.AddChannelAuthenticated(OKCOIN_WEBSOCKET_FUTURES_USERINFO, map[string]string{})
+			for _, y := range klineValues {
+				o.AddChannel(fmt.Sprintf("ok_%s_kline_%s", currency, y))
 			}
-			o.AddChannelAuthenticated(currencyChan, map[string]string{})
-			o.AddChannelAuthenticated(userinfoChan, map[string]string{})
+		}
+	}
+
+	o.WebsocketSupervisor.Run()
+}
+
+// WebsocketOnMessage handles a message received from the websocket server
+func (o *OKCoin) WebsocketOnMessage(msgType int, resp []byte) {
+	switch msgType {
+	case websocket.TextMessage:
+		response := []interface{}{}
+		err := common.JSONDecode(resp, &response)
+
+		if err != nil {
+			log.Println(err)
+			return
 		}
 
-		for _, x := range o.EnabledPairs {
-			currency := common.StringToLower(x)
-			currencyUL := currency[0:3] + "_" + currency[3:]
-			if o.AuthenticatedAPISupport {
-				o.WebsocketSpotOrderInfo(currencyUL, -1)
+		for _, y := range response {
+			z := y.(map[string]interface{})
+			channel := z["channel"]
+			data := z["data"]
+			success := z["success"]
+			errorcode := z["errorcode"]
+			channelStr, ok := channel.(string)
+
+			if !ok {
+				log.Println("Unable to convert channel to string")
+				continue
 			}
-			if o.WebsocketURL == OKCOIN_WEBSOCKET_URL {
-				o.AddChannel(fmt.Sprintf("ok_%s_future_index", currency))
-				for _, y := range o.FuturesValues {
-					if o.AuthenticatedAPISupport {
-						o.WebsocketFuturesOrderInfo(currencyUL, y, -1, 1, 1, 50)
-					}
-					o.AddChannel(fmt.Sprintf("ok_%s_future_ticker_%s", currency, y))
-					o.AddChannel(fmt.Sprintf("ok_%s_future_depth_%s_60", currency, y))
-					o.AddChannel(fmt.Sprintf("ok_%s_future_trade_v1_%s", currency, y))
-					for _, z := range klineValues {
-						o.AddChannel(fmt.Sprintf("ok_future_%s_kline_%s_%s", currency, y, z))
-					}
+
+			if success != "true" && success != nil {
+				errorCodeStr, ok := errorcode.(string)
+				if !ok {
+					log.Printf("%s Websocket: Unable to convert errorcode to string.\n", o.GetName())
+					log.Printf("%s Websocket: channel %s error code: %s.\n", o.GetName(), channelStr, errorcode)
+				} else {
+					log.Printf("%s Websocket: channel %s error: %s.\n", o.GetName(), channelStr, o.WebsocketErrors[errorCodeStr])
 				}
-			} else {
-				o.AddChannel(fmt.Sprintf("ok_%s_ticker", currency))
-				o.AddChannel(fmt.Sprintf("ok_%s_depth60", currency))
-				o.AddChannel(fmt.Sprintf("ok_%s_trades_v1", currency))
+				continue
+			}
 
-				for _, y := range klineValues {
-					o.AddChannel(fmt.Sprintf("ok_%s_kline_%s", currency, y))
+			if success == "true" {
+				if data == nil {
+					continue
 				}
 			}
-		}
 
-		for o.Enabled && o.Websocket {
-			msgType, resp, err := o.WebsocketConn.ReadMessage()
+			dataJSON, err := common.JSONEncode(data)
+
 			if err != nil {
 				log.Println(err)
-				break
+				continue
 			}
-			switch msgType {
-			case websocket.TextMessage:
-				response := []interface{}{}
-				err = common.JSONDecode(resp, &response)
 
-				if err != nil {
-					log.Println(err)
-					continue
-				}
-
-				for _, y := range response {
-					z := y.(map[string]interface{})
-					channel := z["channel"]
-					data := z["data"]
-					success := z["success"]
-					errorcode := z["errorcode"]
-					channelStr, ok := channel.(string)
-
-					if !ok {
-						log.Println("Unable to convert channel to string")
-						continue
-					}
+			switch true {
+			case common.StringContains(channelStr, "ticker") && !common.StringContains(channelStr, "future"):
+				tickerValues := []string{"buy", "high", "last", "low", "sell", "timestamp"}
+				tickerMap := data.(map[string]interface{})
+				ticker := OKCoinWebsocketTicker{}
+				ticker.Vol = tickerMap["vol"].(string)
+
+				for _, z := range tickerValues {
+					result := reflect.TypeOf(tickerMap[z]).String()
+					if result == "string" {
+						value, err := strconv.ParseFloat(tickerMap[z].(string), 64)
+						if err != nil {
+							log.Println(err)
+							continue
+						}
 
-					if success != "true" && success != nil {
-						errorCodeStr, ok := errorcode.(string)
-						if !ok {
-							log.Printf("%s Websocket: Unable to convert errorcode to string.\n", o.GetName())
-							log.Printf("%s Websocket: channel %s error code: %s.\n", o.GetName(), channelStr, errorcode)
-						} else {
-							log.Printf("%s Websocket: channel %s error: %s.\n", o.GetName(), channelStr, o.WebsocketErrors[errorCodeStr])
+						switch z {
+						case "buy":
+							ticker.Buy = value
+						case "high":
+							ticker.High = value
+						case "last":
+							ticker.Last = value
+						case "low":
+							ticker.Low = value
+						case "sell":
+							ticker.Sell = value
+						case "timestamp":
+							ticker.Timestamp = value
 						}
-						continue
-					}
 
-					if success == "true" {
-						if data == nil {
-							continue
+					} else if result == "float64" {
+						switch z {
+						case "buy":
+							ticker.Buy = tickerMap[z].(float64)
+						case "high":
+							ticker.High = tickerMap[z].(float64)
+						case "last":
+							ticker.Last = tickerMap[z].(float64)
+						case "low":
+							ticker.Low = tickerMap[z].(float64)
+						case "sell":
+							ticker.Sell = tickerMap[z].(float64)
+						case "timestamp":
+							ticker.Timestamp = tickerMap[z].(float64)
 						}
 					}
+				}
+				o.WebsocketProcessTicker(channelStr, ticker)
+			case common.StringContains(channelStr, "ticker") && common.StringContains(channelStr, "future"):
+				ticker := OKCoinWebsocketFuturesTicker{}
+				err = common.JSONDecode(dataJSON, &ticker)
 
-					dataJSON, err := common.JSONEncode(data)
-
-					if err != nil {
-						log.Println(err)
-						continue
-					}
+				if err != nil {
+					log.Println(err)
+					continue
+				}
+			case common.StringContains(channelStr, "depth"):
+				orderbook := OKCoinWebsocketOrderbook{}
+				err = common.JSONDecode(dataJSON, &orderbook)
 
-					switch true {
-					case common.StringContains(channelStr, "ticker") && !common.StringContains(channelStr, "future"):
-						tickerValues := []string{"buy", "high", "last", "low", "sell", "timestamp"}
-						tickerMap := data.(map[string]interface{})
-						ticker := OKCoinWebsocketTicker{}
-						ticker.Vol = tickerMap["vol"].(string)
-
-						for _, z := range tickerValues {
-							result := reflect.TypeOf(tickerMap[z]).String()
-							if result == "string" {
-								value, err := strconv.ParseFloat(tickerMap[z].(string), 64)
-								if err != nil {
-									log.Println(err)
-									continue
-								}
-
-								switch z {
-								case "buy":
-									ticker.Buy = value
-								case "high":
-									ticker.High = value
-								case "last":
-									ticker.Last = value
-								case "low":
-									ticker.Low = value
-								case "sell":
-									ticker.Sell = value
-								case "timestamp":
-									ticker.Timestamp = value
-								}
-
-							} else if result == "float64" {
-								switch z {
-								case "buy":
-									ticker.Buy = tickerMap[z].(float64)
-								case "high":
-									ticker.High = tickerMap[z].(float64)
-								case "last":
-									ticker.Last = tickerMap[z].(float64)
-								case "low":
-									ticker.Low = tickerMap[z].(float64)
-								case "sell":
-									ticker.Sell = tickerMap[z].(float64)
-								case "timestamp":
-									ticker.Timestamp = tickerMap[z].(float64)
-								}
-							}
-						}
-					case common.StringContains(channelStr, "ticker") && common.StringContains(channelStr, "future"):
-						ticker := OKCoinWebsocketFuturesTicker{}
-						err = common.JSONDecode(dataJSON, &ticker)
+				if err != nil {
+					log.Println(err)
+					continue
+				}
 
-						if err != nil {
-							log.Println(err)
-							continue
-						}
-					case common.StringContains(channelStr, "depth"):
-						orderbook := OKCoinWebsocketOrderbook{}
-						err = common.JSONDecode(dataJSON, &orderbook)
+				if !common.StringContains(channelStr, "future") {
+					o.WebsocketProcessOrderbook(channelStr, orderbook)
+				}
+			case common.StringContains(channelStr, "trades_v1") || common.StringContains(channelStr, "trade_v1"):
+				type TradeResponse struct {
+					Data [][]string
+				}
 
-						if err != nil {
-							log.Println(err)
-							continue
-						}
-					case common.StringContains(channelStr, "trades_v1") || common.StringContains(channelStr, "trade_v1"):
-						type TradeResponse struct {
-							Data [][]string
-						}
+				trades := TradeResponse{}
+				err = common.JSONDecode(dataJSON, &trades.Data)
 
-						trades := TradeResponse{}
-						err = common.JSONDecode(dataJSON, &trades.Data)
+				if err != nil {
+					log.Println(err)
+					continue
+				}
 
-						if err != nil {
-							log.Println(err)
-							continue
-						}
-						// to-do: convert from string array to trade struct
-					case common.StringContains(channelStr, "kline"):
-						klines := []interface{}{}
-						err := common.JSONDecode(dataJSON, &klines)
+				if !common.StringContains(channelStr, "future") {
+					o.WebsocketProcessTrades(channelStr, trades.Data)
+				}
+			case common.StringContains(channelStr, "kline"):
+				klines := []interface{}{}
+				err := common.JSONDecode(dataJSON, &klines)
 
-						if err != nil {
-							log.Println(err)
-							continue
-						}
-					case common.StringContains(channelStr, "spot") && common.StringContains(channelStr, "realtrades"):
-						if string(dataJSON) == "null" {
-							continue
-						}
-						realtrades := OKCoinWebsocketRealtrades{}
-						err := common.JSONDecode(dataJSON, &realtrades)
+				if err != nil {
+					log.Println(err)
+					continue
+				}
+			case channelStr == OKCOIN_WEBSOCKET_USD_REALTRADES || channelStr == OKCOIN_WEBSOCKET_CNY_REALTRADES:
+				if string(dataJSON) == "null" {
+					continue
+				}
+				realtrades := OKCoinWebsocketRealtrades{}
+				err := common.JSONDecode(dataJSON, &realtrades)
 
-						if err != nil {
-							log.Println(err)
-							continue
-						}
-					case common.StringContains(channelStr, "future") && common.StringContains(channelStr, "realtrades"):
-						if string(dataJSON) == "null" {
-							continue
-						}
-						realtrades := OKCoinWebsocketFuturesRealtrades{}
-						err := common.JSONDecode(dataJSON, &realtrades)
+				if err != nil {
+					log.Println(err)
+					continue
+				}
+				o.WebsocketProcessRealtrades(realtrades)
+			case common.StringContains(channelStr, "future") && common.StringContains(channelStr, "realtrades"):
+				if string(dataJSON) == "null" {
+					continue
+				}
+				realtrades := OKCoinWebsocketFuturesRealtrades{}
+				err := common.JSONDecode(dataJSON, &realtrades)
 
-						if err != nil {
-							log.Println(err)
-							continue
-						}
-					case common.StringContains(channelStr, "spot") && common.StringContains(channelStr, "trade") || common.StringContains(channelStr, "futures") && common.StringContains(channelStr, "trade"):
-						tradeOrder := OKCoinWebsocketTradeOrderResponse{}
-						err := common.JSONDecode(dataJSON, &tradeOrder)
+				if err != nil {
+					log.Println(err)
+					continue
+				}
+			case common.StringContains(channelStr, "spot") && common.StringContains(channelStr, "trade") || common.StringContains(channelStr, "futures") && common.StringContains(channelStr, "trade"):
+				tradeOrder := OKCoinWebsocketTradeOrderResponse{}
+				err := common.JSONDecode(dataJSON, &tradeOrder)
 
-						if err != nil {
-							log.Println(err)
-							continue
-						}
-					case common.StringContains(channelStr, "cancel_order"):
-						cancelOrder := OKCoinWebsocketTradeOrderResponse{}
-						err := common.JSONDecode(dataJSON, &cancelOrder)
+				if err != nil {
+					log.Println(err)
+					continue
+				}
+			case common.StringContains(channelStr, "cancel_order"):
+				cancelOrder := OKCoinWebsocketTradeOrderResponse{}
+				err := common.JSONDecode(dataJSON, &cancelOrder)
 
-						if err != nil {
-							log.Println(err)
-							continue
-						}
-					case common.StringContains(channelStr, "spot") && common.StringContains(channelStr, "userinfo"):
-						userinfo := OKCoinWebsocketUserinfo{}
-						err = common.JSONDecode(dataJSON, &userinfo)
+				if err != nil {
+					log.Println(err)
+					continue
+				}
+			case common.StringContains(channelStr, "spot") && common.StringContains(channelStr, "userinfo"):
+				userinfo := OKCoinWebsocketUserinfo{}
+				err = common.JSONDecode(dataJSON, &userinfo)
 
-						if err != nil {
-							log.Println(err)
-							continue
-						}
-					case common.StringContains(channelStr, "futureusd_userinfo"):
-						userinfo := OKCoinWebsocketFuturesUserInfo{}
-						err = common.JSONDecode(dataJSON, &userinfo)
+				if err != nil {
+					log.Println(err)
+					continue
+				}
+				o.WebsocketProcessUserinfo(userinfo)
+			case common.StringContains(channelStr, "futureusd_userinfo"):
+				userinfo := OKCoinWebsocketFuturesUserInfo{}
+				err = common.JSONDecode(dataJSON, &userinfo)
 
-						if err != nil {
-							log.Println(err)
-							continue
-						}
-					case common.StringContains(channelStr, "spot") && common.StringContains(channelStr, "order_info"):
-						type OrderInfoResponse struct {
-							Result bool                   `json:"result"`
-							Orders []OKCoinWebsocketOrder `json:"orders"`
-						}
-						var orders OrderInfoResponse
-						err := common.JSONDecode(dataJSON, &orders)
+				if err != nil {
+					log.Println(err)
+					continue
+				}
+			case common.StringContains(channelStr, "spot") && common.StringContains(channelStr, "order_info"):
+				type OrderInfoResponse struct {
+					Result bool                   `json:"result"`
+					Orders []OKCoinWebsocketOrder `json:"orders"`
+				}
+				var orders OrderInfoResponse
+				err := common.JSONDecode(dataJSON, &orders)
 
-						if err != nil {
-							log.Println(err)
-							continue
-						}
-					case common.StringContains(channelStr, "futureusd_order_info"):
-						type OrderInfoResponse struct {
-							Result bool                          `json:"result"`
-							Orders []OKCoinWebsocketFuturesOrder `json:"orders"`
-						}
-						var orders OrderInfoResponse
-						err := common.JSONDecode(dataJSON, &orders)
+				if err != nil {
+					log.Println(err)
+					continue
+				}
+			case common.StringContains(channelStr, "futureusd_order_info"):
+				type OrderInfoResponse struct {
+					Result bool                          `json:"result"`
+					Orders []OKCoinWebsocketFuturesOrder `json:"orders"`
+				}
+				var orders OrderInfoResponse
+				err := common.JSONDecode(dataJSON, &orders)
 
-						if err != nil {
-							log.Println(err)
-							continue
-						}
-					case common.StringContains(channelStr, "future_index"):
-						index := OKCoinWebsocketFutureIndex{}
-						err = common.JSONDecode(dataJSON, &index)
+				if err != nil {
+					log.Println(err)
+					continue
+				}
+			case common.StringContains(channelStr, "future_index"):
+				index := OKCoinWebsocketFutureIndex{}
+				err = common.JSONDecode(dataJSON, &index)
 
-						if err != nil {
-							log.Println(err)
-							continue
-						}
-					}
+				if err != nil {
+					log.Println(err)
+					continue
 				}
 			}
 		}
-		o.WebsocketConn.Close()
-		log.Printf("%s Websocket client disconnected.", o.GetName())
 	}
 }
 
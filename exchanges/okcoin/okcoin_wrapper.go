@@ -104,6 +104,21 @@ func (o *OKCoin) UpdateOrderbook(currency pair.CurrencyPair, assetType string) (
 	return o.Orderbooks.GetOrderbook(o.Name, currency, assetType)
 }
 
+// StreamsFullOrderbook returns true as incremental depth updates are applied
+// to the full orderbook sent first
+func (o *OKCoin) StreamsFullOrderbook() bool {
+	return true
+}
+
+// SubscribePrivate returns a channel of our order, fill and balance updates
+// pushed by the OKCoin authenticated websocket feed
+func (o *OKCoin) SubscribePrivate() (<-chan exchange.PrivateEvent, error) {
+	if !o.IsPrivateStreamEnabled() {
+		return nil, exchange.ErrPrivateStreamNotEnabled
+	}
+	return o.Streams.SubscribePrivate(), nil
+}
+
 // GetExchangeAccountInfo retrieves balances for all enabled currencies for the
 // OKCoin exchange
 func (o *OKCoin) GetExchangeAccountInfo() (exchange.AccountInfo, error) {
@@ -116,26 +131,30 @@ func (o *OKCoin) GetExchangeAccountInfo() (exchange.AccountInfo, error) {
 
 	response.Currencies = append(response.Currencies, exchange.AccountCurrencyInfo{
 		CurrencyName: "BTC",
-		TotalValue:   assets.Info.Funds.Free.BTC,
+		TotalValue:   assets.Info.Funds.Free.BTC + assets.Info.Funds.Freezed.BTC,
 		Hold:         assets.Info.Funds.Freezed.BTC,
+		Available:    assets.Info.Funds.Free.BTC,
 	})
 
 	response.Currencies = append(response.Currencies, exchange.AccountCurrencyInfo{
 		CurrencyName: "LTC",
-		TotalValue:   assets.Info.Funds.Free.LTC,
+		TotalValue:   assets.Info.Funds.Free.LTC + assets.Info.Funds.Freezed.LTC,
 		Hold:         assets.Info.Funds.Freezed.LTC,
+		Available:    assets.Info.Funds.Free.LTC,
 	})
 
 	response.Currencies = append(response.Currencies, exchange.AccountCurrencyInfo{
 		CurrencyName: "USD",
-		TotalValue:   assets.Info.Funds.Free.USD,
+		TotalValue:   assets.Info.Funds.Free.USD + assets.Info.Funds.Freezed.USD,
 		Hold:         assets.Info.Funds.Freezed.USD,
+		Available:    assets.Info.Funds.Free.USD,
 	})
 
 	response.Currencies = append(response.Currencies, exchange.AccountCurrencyInfo{
 		CurrencyName: "CNY",
-		TotalValue:   assets.Info.Funds.Free.CNY,
+		TotalValue:   assets.Info.Funds.Free.CNY + assets.Info.Funds.Freezed.CNY,
 		Hold:         assets.Info.Funds.Freezed.CNY,
+		Available:    assets.Info.Funds.Free.CNY,
 	})
 
 	return response, nil
@@ -18,6 +18,24 @@ const (
 	Spot = "SPOT"
 )
 
+// SubscriberBufferSize is the number of undelivered orderbook events held for
+// each subscriber before further events are dropped
+const SubscriberBufferSize = 256
+
+// subscribers holds the channels orderbook updates of every exchange are sent
+// to
+var subscribers = struct {
+	m        sync.Mutex
+	channels []chan Event
+}{}
+
+// Event is sent to subscribers when an exchange updates an orderbook
+type Event struct {
+	Exchange  string
+	Pair      pair.CurrencyPair
+	AssetType string
+}
+
 // CalculateTotalBids returns the total amount of bids and the total orderbook
 // bids value
 func (o *Base) CalculateTotalBids() (float64, float64) {
@@ -68,6 +86,9 @@ type Base struct {
 	Bids         []Item            `json:"bids"`
 	Asks         []Item            `json:"asks"`
 	LastUpdated  time.Time         `json:"last_updated"`
+	// Exchange sequence number of the last update applied, zero if the
+	// exchange doesn't provide one
+	Sequence int64 `json:"sequence"`
 }
 
 // GetOrderbook checks and returns the orderbook given an exchange name and
@@ -113,8 +134,10 @@ func (o *Orderbooks) SecondCurrencyExists(p pair.CurrencyPair) bool {
 }
 
 // ProcessOrderbook processes incoming orderbooks, creating or updating the
-// Orderbook list
-func (o *Orderbooks) ProcessOrderbook(_ string, p pair.CurrencyPair, orderbookNew Base, orderbookType string) {
+// Orderbook list and notifying subscribers
+func (o *Orderbooks) ProcessOrderbook(exchangeName string, p pair.CurrencyPair, orderbookNew Base, orderbookType string) {
+	defer notify(Event{Exchange: exchangeName, Pair: p, AssetType: orderbookType})
+
 	o.m.Lock()
 	defer o.m.Unlock()
 
@@ -124,6 +147,10 @@ func (o *Orderbooks) ProcessOrderbook(_ string, p pair.CurrencyPair, orderbookNe
 	orderbookNew.CurrencyPair = fp.Pair().String()
 	orderbookNew.LastUpdated = time.Now()
 
+	if o.orderbooks == nil {
+		o.orderbooks = make(map[pair.CurrencyItem]map[pair.CurrencyItem]map[string]Base)
+	}
+
 	if o.FirstCurrencyExists(fp.GetFirstCurrency()) {
 		if !o.SecondCurrencyExists(fp) {
 			b := make(map[string]Base)
@@ -143,6 +170,45 @@ func (o *Orderbooks) ProcessOrderbook(_ string, p pair.CurrencyPair, orderbookNe
 	o.orderbooks[fp.FirstCurrency] = a
 }
 
+func notify(event Event) {
+	subscribers.m.Lock()
+	defer subscribers.m.Unlock()
+
+	for _, ch := range subscribers.channels {
+		select {
+		case ch <- event:
+		default:
+		}
+	}
+}
+
+// Subscribe returns a channel which receives every subsequent orderbook update,
+// events are dropped rather than blocking exchanges if the subscriber falls
+// behind
+func Subscribe() <-chan Event {
+	subscribers.m.Lock()
+	defer subscribers.m.Unlock()
+
+	ch := make(chan Event, SubscriberBufferSize)
+	subscribers.channels = append(subscribers.channels, ch)
+	return ch
+}
+
+// Unsubscribe stops orderbook updates being sent to a channel returned by
+// Subscribe and closes it
+func Unsubscribe(ch <-chan Event) {
+	subscribers.m.Lock()
+	defer subscribers.m.Unlock()
+
+	for i, x := range subscribers.channels {
+		if x == ch {
+			subscribers.channels = append(subscribers.channels[:i], subscribers.channels[i+1:]...)
+			close(x)
+			return
+		}
+	}
+}
+
 // Returns a new currency pair based on the given one that's formatted using the internal format.
 func (o *Orderbooks) formatCurrencyPair(p pair.CurrencyPair) pair.CurrencyPair {
 	return p.FormatPair("/", false)
@@ -154,8 +220,7 @@ func (o *Orderbooks) formatCurrency(currency pair.CurrencyItem) pair.CurrencyIte
 
 // Init creates a new set of Orderbooks
 func Init() Orderbooks {
-	obs := Orderbooks{}
-	obs.m = sync.Mutex{}
-	obs.orderbooks = make(map[pair.CurrencyItem]map[pair.CurrencyItem]map[string]Base)
-	return obs
+	return Orderbooks{
+		orderbooks: make(map[pair.CurrencyItem]map[pair.CurrencyItem]map[string]Base),
+	}
 }
@@ -0,0 +1,238 @@
+package orderbook
+
+import (
+	"errors"
+)
+
+// Order sides used when estimating fills, these match the values of
+// exchange.OrderSide
+const (
+	Buy  = "buy"
+	Sell = "sell"
+)
+
+// Errors returned by the orderbook analytics
+var (
+	// ErrEmptyOrderbook indicates that the side of the book needed for a
+	// calculation has no levels.
+	ErrEmptyOrderbook = errors.New("orderbook has no bids or asks")
+	// ErrInsufficientDepth indicates that the book doesn't have enough
+	// volume to fill the requested amount, the estimate returned with it
+	// covers the whole side of the book.
+	ErrInsufficientDepth = errors.New("orderbook doesn't have enough depth to fill the amount")
+	// ErrInvalidSide indicates that a fill was estimated for a side other
+	// than Buy or Sell.
+	ErrInvalidSide = errors.New("order side must be buy or sell")
+)
+
+// FillEstimate describes how a market order would be filled by walking the
+// book. Slippage is how far the average price is from the best price, in
+// basis points and always positive.
+type FillEstimate struct {
+	BaseAmount   float64 `json:"base_amount"`
+	QuoteAmount  float64 `json:"quote_amount"`
+	AveragePrice float64 `json:"average_price"`
+	BestPrice    float64 `json:"best_price"`
+	WorstPrice   float64 `json:"worst_price"`
+	SlippageBps  float64 `json:"slippage_bps"`
+	Levels       int     `json:"levels"`
+}
+
+// Depth holds the volume resting within a distance of the mid price
+type Depth struct {
+	BidAmount float64 `json:"bid_amount"`
+	BidValue  float64 `json:"bid_value"`
+	AskAmount float64 `json:"ask_amount"`
+	AskValue  float64 `json:"ask_value"`
+}
+
+// The calculations below expect bids to be sorted highest first and asks
+// lowest first, as exchanges return them.
+
+// BestBid returns the highest bid
+func (o *Base) BestBid() (Item, error) {
+	if len(o.Bids) == 0 {
+		return Item{}, ErrEmptyOrderbook
+	}
+	return o.Bids[0], nil
+}
+
+// BestAsk returns the lowest ask
+func (o *Base) BestAsk() (Item, error) {
+	if len(o.Asks) == 0 {
+		return Item{}, ErrEmptyOrderbook
+	}
+	return o.Asks[0], nil
+}
+
+// MidPrice returns the price halfway between the best bid and ask
+func (o *Base) MidPrice() (float64, error) {
+	bid, err := o.BestBid()
+	if err != nil {
+		return 0, err
+	}
+	ask, err := o.BestAsk()
+	if err != nil {
+		return 0, err
+	}
+	return (bid.Price + ask.Price) / 2, nil
+}
+
+// Spread returns the difference between the best ask and bid
+func (o *Base) Spread() (float64, error) {
+	bid, err := o.BestBid()
+	if err != nil {
+		return 0, err
+	}
+	ask, err := o.BestAsk()
+	if err != nil {
+		return 0, err
+	}
+	return ask.Price - bid.Price, nil
+}
+
+// SpreadBps returns the spread in basis points of the mid price
+func (o *Base) SpreadBps() (float64, error) {
+	spread, err := o.Spread()
+	if err != nil {
+		return 0, err
+	}
+	mid, _ := o.MidPrice()
+	if mid == 0 {
+		return 0, ErrEmptyOrderbook
+	}
+	return spread / mid * 10000, nil
+}
+
+// Returns the levels a market order on the given side is filled against
+func (o *Base) levelsFor(side string) ([]Item, error) {
+	switch side {
+	case Buy:
+		return o.Asks, nil
+	case Sell:
+		return o.Bids, nil
+	}
+	return nil, ErrInvalidSide
+}
+
+// FillForBase estimates the fill of a market order for an amount of the base
+// currency
+func (o *Base) FillForBase(side string, amount float64) (FillEstimate, error) {
+	return o.estimateFill(side, amount, func(x Item) float64 { return x.Amount })
+}
+
+// FillForQuote estimates the fill of a market order spending, or receiving
+// when selling, an amount of the quote currency
+func (o *Base) FillForQuote(side string, amount float64) (FillEstimate, error) {
+	return o.estimateFill(side, amount, func(x Item) float64 { return x.Amount * x.Price })
+}
+
+// Walks the levels of a side until size, measured in the units returned by
+// levelSize, has been filled
+func (o *Base) estimateFill(side string, size float64, levelSize func(Item) float64) (FillEstimate, error) {
+	levels, err := o.levelsFor(side)
+	if err != nil {
+		return FillEstimate{}, err
+	}
+	if len(levels) == 0 {
+		return FillEstimate{}, ErrEmptyOrderbook
+	}
+
+	estimate := FillEstimate{BestPrice: levels[0].Price}
+	remaining := size
+	for _, x := range levels {
+		if remaining <= 0 {
+			break
+		}
+		available := levelSize(x)
+		fraction := 1.0
+		if available > remaining {
+			fraction = remaining / available
+		}
+		estimate.BaseAmount += x.Amount * fraction
+		estimate.QuoteAmount += x.Amount * x.Price * fraction
+		estimate.WorstPrice = x.Price
+		estimate.Levels++
+		remaining -= available * fraction
+	}
+
+	if estimate.BaseAmount > 0 {
+		estimate.AveragePrice = estimate.QuoteAmount / estimate.BaseAmount
+		estimate.SlippageBps = (estimate.AveragePrice - estimate.BestPrice) / estimate.BestPrice * 10000
+		if side == Sell {
+			estimate.SlippageBps = -estimate.SlippageBps
+		}
+	}
+
+	// Allow for rounding when the fill uses up the last level exactly
+	if remaining > size*1e-9 {
+		return estimate, ErrInsufficientDepth
+	}
+	return estimate, nil
+}
+
+// AverageFillPrice returns the average price a market order for an amount of
+// the base currency would be filled at
+func (o *Base) AverageFillPrice(side string, amount float64) (float64, error) {
+	estimate, err := o.FillForBase(side, amount)
+	return estimate.AveragePrice, err
+}
+
+// Slippage returns how far in basis points the average fill price of a market
+// order for an amount of the base currency is from the best price
+func (o *Base) Slippage(side string, amount float64) (float64, error) {
+	estimate, err := o.FillForBase(side, amount)
+	return estimate.SlippageBps, err
+}
+
+// DepthWithin returns the volume resting within a percentage of the mid price
+// on each side of the book
+func (o *Base) DepthWithin(percent float64) (Depth, error) {
+	mid, err := o.MidPrice()
+	if err != nil {
+		return Depth{}, err
+	}
+
+	depth := Depth{}
+	minBid := mid * (1 - percent/100)
+	for _, x := range o.Bids {
+		if x.Price < minBid {
+			break
+		}
+		depth.BidAmount += x.Amount
+		depth.BidValue += x.Amount * x.Price
+	}
+
+	maxAsk := mid * (1 + percent/100)
+	for _, x := range o.Asks {
+		if x.Price > maxAsk {
+			break
+		}
+		depth.AskAmount += x.Amount
+		depth.AskValue += x.Amount * x.Price
+	}
+	return depth, nil
+}
+
+// Imbalance returns (bid volume - ask volume) / (bid volume + ask volume) over
+// the best levels of each side, from -1 when there are only asks to 1 when
+// there are only bids. A levels value of zero or less uses the whole book.
+func (o *Base) Imbalance(levels int) (float64, error) {
+	bidAmount := sumAmounts(o.Bids, levels)
+	askAmount := sumAmounts(o.Asks, levels)
+	if bidAmount+askAmount == 0 {
+		return 0, ErrEmptyOrderbook
+	}
+	return (bidAmount - askAmount) / (bidAmount + askAmount), nil
+}
+
+func sumAmounts(items []Item, levels int) float64 {
+	if levels > 0 && levels < len(items) {
+		items = items[:levels]
+	}
+	total := float64(0)
+	for _, x := range items {
+		total += x.Amount
+	}
+	return total
+}
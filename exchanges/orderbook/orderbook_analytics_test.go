@@ -0,0 +1,115 @@
+package orderbook
+
+import (
+	"math"
+	"testing"
+)
+
+func testAnalyticsBook() Base {
+	return Base{
+		Bids: []Item{{Price: 99, Amount: 1}, {Price: 98, Amount: 2}, {Price: 90, Amount: 5}},
+		Asks: []Item{{Price: 101, Amount: 1}, {Price: 102, Amount: 2}, {Price: 110, Amount: 5}},
+	}
+}
+
+func floatEquals(a, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
+
+func TestSpread(t *testing.T) {
+	t.Parallel()
+	ob := testAnalyticsBook()
+
+	mid, err := ob.MidPrice()
+	if err != nil || mid != 100 {
+		t.Errorf("Test failed. TestSpread expected mid 100, got %f %v", mid, err)
+	}
+	spread, _ := ob.Spread()
+	if spread != 2 {
+		t.Errorf("Test failed. TestSpread expected spread 2, got %f", spread)
+	}
+	bps, _ := ob.SpreadBps()
+	if !floatEquals(bps, 200) {
+		t.Errorf("Test failed. TestSpread expected 200 bps, got %f", bps)
+	}
+
+	empty := Base{}
+	if _, err = empty.MidPrice(); err != ErrEmptyOrderbook {
+		t.Error("Test failed. TestSpread expected an empty orderbook error")
+	}
+}
+
+func TestFillForBase(t *testing.T) {
+	t.Parallel()
+	ob := testAnalyticsBook()
+
+	estimate, err := ob.FillForBase(Buy, 2)
+	if err != nil {
+		t.Fatalf("Test failed. TestFillForBase error: %s", err)
+	}
+	if !floatEquals(estimate.AveragePrice, 101.5) || estimate.Levels != 2 || estimate.WorstPrice != 102 {
+		t.Errorf("Test failed. TestFillForBase unexpected buy estimate %+v", estimate)
+	}
+	if !floatEquals(estimate.SlippageBps, 0.5/101*10000) {
+		t.Errorf("Test failed. TestFillForBase unexpected buy slippage %f", estimate.SlippageBps)
+	}
+
+	price, _ := ob.AverageFillPrice(Sell, 3)
+	if !floatEquals(price, (99+98*2)/3.0) {
+		t.Errorf("Test failed. TestFillForBase unexpected sell price %f", price)
+	}
+	slippage, _ := ob.Slippage(Sell, 3)
+	if slippage <= 0 {
+		t.Errorf("Test failed. TestFillForBase sell slippage should be positive, got %f", slippage)
+	}
+
+	estimate, err = ob.FillForBase(Buy, 10)
+	if err != ErrInsufficientDepth || estimate.BaseAmount != 8 {
+		t.Errorf("Test failed. TestFillForBase expected insufficient depth, got %+v %v", estimate, err)
+	}
+
+	if _, err = ob.FillForBase("hold", 1); err == nil {
+		t.Error("Test failed. TestFillForBase accepted an invalid side")
+	}
+}
+
+func TestFillForQuote(t *testing.T) {
+	t.Parallel()
+	ob := testAnalyticsBook()
+
+	estimate, err := ob.FillForQuote(Buy, 203)
+	if err != nil {
+		t.Fatalf("Test failed. TestFillForQuote error: %s", err)
+	}
+	if !floatEquals(estimate.BaseAmount, 2) || !floatEquals(estimate.QuoteAmount, 203) {
+		t.Errorf("Test failed. TestFillForQuote unexpected estimate %+v", estimate)
+	}
+}
+
+func TestDepthWithin(t *testing.T) {
+	t.Parallel()
+	ob := testAnalyticsBook()
+
+	depth, err := ob.DepthWithin(2)
+	if err != nil {
+		t.Fatalf("Test failed. TestDepthWithin error: %s", err)
+	}
+	if depth.BidAmount != 3 || depth.AskAmount != 3 || depth.BidValue != 99+196 {
+		t.Errorf("Test failed. TestDepthWithin unexpected depth %+v", depth)
+	}
+}
+
+func TestImbalance(t *testing.T) {
+	t.Parallel()
+	ob := testAnalyticsBook()
+	ob.Bids[0].Amount = 3
+
+	imbalance, err := ob.Imbalance(1)
+	if err != nil || !floatEquals(imbalance, 0.5) {
+		t.Errorf("Test failed. TestImbalance expected 0.5, got %f %v", imbalance, err)
+	}
+	imbalance, _ = ob.Imbalance(0)
+	if !floatEquals(imbalance, (10.0-8)/18) {
+		t.Errorf("Test failed. TestImbalance unexpected whole book imbalance %f", imbalance)
+	}
+}
@@ -0,0 +1,123 @@
+package orderbook
+
+import (
+	"sort"
+	"time"
+
+	"github.com/mattkanwisher/cryptofiend/currency/pair"
+)
+
+// ConsolidatedItem is a price level of a consolidated orderbook, annotated with
+// the exchange it rests on
+type ConsolidatedItem struct {
+	Exchange string  `json:"exchange"`
+	Amount   float64 `json:"amount"`
+	Price    float64 `json:"price"`
+	// Price quoted by the exchange, before conversion and fee adjustment
+	ExchangePrice float64 `json:"exchange_price"`
+}
+
+// ConsolidatedBase holds the merged orderbooks of several exchanges trading the
+// same currency pair. Prices are in the quote currency of Pair, bids are sorted
+// highest first and asks lowest first.
+type ConsolidatedBase struct {
+	Pair        pair.CurrencyPair  `json:"pair"`
+	Bids        []ConsolidatedItem `json:"bids"`
+	Asks        []ConsolidatedItem `json:"asks"`
+	Exchanges   []string           `json:"exchanges"`
+	FeeAdjusted bool               `json:"fee_adjusted"`
+	LastUpdated time.Time          `json:"last_updated"`
+}
+
+// ConsolidationSource is an exchange orderbook to merge into a consolidated
+// orderbook
+type ConsolidationSource struct {
+	Exchange  string
+	Orderbook Base
+	// Rate converts prices of the orderbook into the quote currency of the
+	// consolidated orderbook, a zero rate is treated as 1
+	Rate float64
+	// TakerFee is the percentage fee charged by the exchange when taking
+	// liquidity
+	TakerFee float64
+}
+
+// Consolidate merges the orderbooks of several exchanges into one. When
+// feeAdjusted is set, bid prices are reduced and ask prices increased by the
+// taker fee of their exchange, so that levels reflect what a market order would
+// actually receive or pay.
+func Consolidate(p pair.CurrencyPair, sources []ConsolidationSource, feeAdjusted bool) ConsolidatedBase {
+	result := ConsolidatedBase{
+		Pair:        p,
+		Bids:        []ConsolidatedItem{},
+		Asks:        []ConsolidatedItem{},
+		Exchanges:   []string{},
+		FeeAdjusted: feeAdjusted,
+	}
+
+	for _, s := range sources {
+		rate := s.Rate
+		if rate == 0 {
+			rate = 1
+		}
+		fee := float64(0)
+		if feeAdjusted {
+			fee = s.TakerFee / 100
+		}
+
+		for _, x := range s.Orderbook.Bids {
+			result.Bids = append(result.Bids, ConsolidatedItem{
+				Exchange:      s.Exchange,
+				Amount:        x.Amount,
+				Price:         x.Price * rate * (1 - fee),
+				ExchangePrice: x.Price,
+			})
+		}
+		for _, x := range s.Orderbook.Asks {
+			result.Asks = append(result.Asks, ConsolidatedItem{
+				Exchange:      s.Exchange,
+				Amount:        x.Amount,
+				Price:         x.Price * rate * (1 + fee),
+				ExchangePrice: x.Price,
+			})
+		}
+
+		result.Exchanges = append(result.Exchanges, s.Exchange)
+		if s.Orderbook.LastUpdated.After(result.LastUpdated) {
+			result.LastUpdated = s.Orderbook.LastUpdated
+		}
+	}
+
+	sort.SliceStable(result.Bids, func(i, j int) bool {
+		if result.Bids[i].Price == result.Bids[j].Price {
+			return result.Bids[i].Exchange < result.Bids[j].Exchange
+		}
+		return result.Bids[i].Price > result.Bids[j].Price
+	})
+	sort.SliceStable(result.Asks, func(i, j int) bool {
+		if result.Asks[i].Price == result.Asks[j].Price {
+			return result.Asks[i].Exchange < result.Asks[j].Exchange
+		}
+		return result.Asks[i].Price < result.Asks[j].Price
+	})
+	return result
+}
+
+// Base returns the consolidated orderbook without the exchange annotations, so
+// that the orderbook analytics can be used on the total liquidity
+func (c *ConsolidatedBase) Base() Base {
+	ob := Base{
+		Pair:         c.Pair,
+		CurrencyPair: c.Pair.Pair().String(),
+		Bids:         make([]Item, 0, len(c.Bids)),
+		Asks:         make([]Item, 0, len(c.Asks)),
+		LastUpdated:  c.LastUpdated,
+	}
+	for _, x := range c.Bids {
+		ob.Bids = append(ob.Bids, Item{Amount: x.Amount, Price: x.Price})
+	}
+	for _, x := range c.Asks {
+		ob.Asks = append(ob.Asks, Item{Amount: x.Amount, Price: x.Price})
+	}
+	return ob
+}
@@ -0,0 +1,53 @@
+package orderbook
+
+import (
+	"testing"
+
+	"github.com/mattkanwisher/cryptofiend/currency/pair"
+)
+
+func TestConsolidate(t *testing.T) {
+	t.Parallel()
+	p := pair.NewCurrencyPair("BTC", "USD")
+	sources := []ConsolidationSource{
+		{
+			Exchange: "Bitfinex",
+			Orderbook: Base{
+				Bids: []Item{{Price: 100, Amount: 1}, {Price: 98, Amount: 2}},
+				Asks: []Item{{Price: 102, Amount: 1}},
+			},
+			TakerFee: 0.2,
+		},
+		{
+			Exchange: "GDAX",
+			Orderbook: Base{
+				Bids: []Item{{Price: 99, Amount: 3}},
+				Asks: []Item{{Price: 101, Amount: 4}, {Price: 103, Amount: 1}},
+			},
+			Rate:     2,
+			TakerFee: 0.25,
+		},
+	}
+
+	c := Consolidate(p, sources, false)
+	if len(c.Bids) != 3 || len(c.Asks) != 3 || len(c.Exchanges) != 2 {
+		t.Fatalf("Test failed. TestConsolidate unexpected book %+v", c)
+	}
+	if c.Bids[0].Exchange != "GDAX" || c.Bids[0].Price != 198 || c.Bids[0].ExchangePrice != 99 {
+		t.Errorf("Test failed. TestConsolidate unexpected best bid %+v", c.Bids[0])
+	}
+	if c.Asks[0].Exchange != "Bitfinex" || c.Asks[0].Price != 102 {
+		t.Errorf("Test failed. TestConsolidate unexpected best ask %+v", c.Asks[0])
+	}
+
+	c = Consolidate(p, sources, true)
+	if !floatEquals(c.Asks[0].Price, 102*1.002) || !floatEquals(c.Bids[1].Price, 100*0.998) {
+		t.Errorf("Test failed. TestConsolidate unexpected fee adjusted prices %+v %+v", c.Asks[0], c.Bids[1])
+	}
+
+	ob := c.Base()
+	amount, _ := ob.CalculateTotalAsks()
+	if amount != 6 {
+		t.Errorf("Test failed. TestConsolidate expected 6 asks, got %f", amount)
+	}
+}
@@ -0,0 +1,126 @@
+package orderbook
+
+import (
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/mattkanwisher/cryptofiend/currency/pair"
+)
+
+// Sides of the orderbook a Delta can be applied to
+const (
+	Bid = "bid"
+	Ask = "ask"
+)
+
+// Errors returned when price level changes can't be applied to an orderbook
+var (
+	// ErrSequenceGap indicates that updates were missed between the stored
+	// orderbook and the one being applied, the orderbook must be replaced
+	// with a fresh snapshot.
+	ErrSequenceGap = errors.New("orderbook update is out of sequence")
+	// ErrSnapshotMissing indicates that updates were received for an
+	// orderbook before a snapshot of it was stored.
+	ErrSnapshotMissing = errors.New("orderbook snapshot has not been received")
+)
+
+// Delta holds a change to a single price level, an amount of zero removes the
+// level from the book
+type Delta struct {
+	Side   string
+	Price  float64
+	Amount float64
+}
+
+// ApplyDelta inserts, updates or removes a price level keeping bids sorted
+// highest first and asks lowest first
+func (o *Base) ApplyDelta(d Delta) {
+	if d.Side == Bid {
+		o.Bids = applyDelta(o.Bids, d, func(a, b float64) bool { return a > b })
+	} else {
+		o.Asks = applyDelta(o.Asks, d, func(a, b float64) bool { return a < b })
+	}
+}
+
+// ApplyDeltas applies a batch of price level changes
+func (o *Base) ApplyDeltas(deltas []Delta) {
+	for _, d := range deltas {
+		o.ApplyDelta(d)
+	}
+	o.LastUpdated = time.Now()
+}
+
+// Applies a change to one side of the book, before reports whether price a is
+// ordered ahead of price b on that side.
+func applyDelta(items []Item, d Delta, before func(a, b float64) bool) []Item {
+	i := sort.Search(len(items), func(i int) bool { return !before(items[i].Price, d.Price) })
+	found := i < len(items) && items[i].Price == d.Price
+
+	switch {
+	case d.Amount == 0 && found:
+		return append(items[:i], items[i+1:]...)
+	case d.Amount == 0:
+		return items
+	case found:
+		items[i].Amount = d.Amount
+		return items
+	}
+
+	items = append(items, Item{})
+	copy(items[i+1:], items[i:])
+	items[i] = Item{Price: d.Price, Amount: d.Amount}
+	return items
+}
+
+// ProcessDeltas applies price level changes to a stored orderbook, notifies
+// subscribers and returns the updated book. If sequence is non zero it is
+// checked against the sequence of the stored book, updates the book already
+// covers are ignored and ErrSequenceGap is returned if any were missed, in
+// which case the book is left untouched and should be replaced with a fresh
+// snapshot.
+func (o *Orderbooks) ProcessDeltas(exchangeName string, p pair.CurrencyPair, orderbookType string, sequence int64, deltas []Delta) (Base, error) {
+	ob, applied, err := o.processDeltas(p, orderbookType, sequence, deltas)
+	if applied {
+		notify(Event{Exchange: exchangeName, Pair: p, AssetType: orderbookType})
+	}
+	return ob, err
+}
+
+// processDeltas applies price level changes to a stored orderbook and reports
+// whether the book was changed
+func (o *Orderbooks) processDeltas(p pair.CurrencyPair, orderbookType string, sequence int64, deltas []Delta) (Base, bool, error) {
+	o.m.Lock()
+	defer o.m.Unlock()
+
+	fp := o.formatCurrencyPair(p)
+
+	if !o.SecondCurrencyExists(fp) {
+		return Base{}, false, ErrSnapshotMissing
+	}
+
+	ob, ok := o.orderbooks[fp.FirstCurrency][fp.SecondCurrency][orderbookType]
+	if !ok {
+		return Base{}, false, ErrSnapshotMissing
+	}
+
+	if sequence != 0 && ob.Sequence != 0 {
+		if sequence <= ob.Sequence {
+			return ob, false, nil
+		}
+		if sequence != ob.Sequence+1 {
+			return ob, false, ErrSequenceGap
+		}
+	}
+
+	// Copy the levels so books previously handed out aren't modified
+	ob.Bids = append([]Item(nil), ob.Bids...)
+	ob.Asks = append([]Item(nil), ob.Asks...)
+	ob.ApplyDeltas(deltas)
+	if sequence != 0 {
+		ob.Sequence = sequence
+	}
+
+	o.orderbooks[fp.FirstCurrency][fp.SecondCurrency][orderbookType] = ob
+	return ob, len(deltas) > 0, nil
+}
@@ -0,0 +1,127 @@
+package orderbook
+
+import (
+	"testing"
+
+	"github.com/mattkanwisher/cryptofiend/currency/pair"
+)
+
+func TestApplyDelta(t *testing.T) {
+	t.Parallel()
+	base := Base{
+		Bids: []Item{{Price: 200, Amount: 1}, {Price: 100, Amount: 1}},
+		Asks: []Item{{Price: 300, Amount: 1}, {Price: 400, Amount: 1}},
+	}
+
+	base.ApplyDeltas([]Delta{
+		{Side: Bid, Price: 150, Amount: 2},
+		{Side: Bid, Price: 250, Amount: 3},
+		{Side: Bid, Price: 100, Amount: 0},
+		{Side: Ask, Price: 300, Amount: 5},
+		{Side: Ask, Price: 350, Amount: 4},
+		{Side: Ask, Price: 500, Amount: 0},
+	})
+
+	expectedBids := []Item{{Price: 250, Amount: 3}, {Price: 200, Amount: 1}, {Price: 150, Amount: 2}}
+	if len(base.Bids) != len(expectedBids) {
+		t.Fatalf("Test failed. TestApplyDelta expected %d bids, got %d", len(expectedBids), len(base.Bids))
+	}
+	for i := range expectedBids {
+		if base.Bids[i] != expectedBids[i] {
+			t.Errorf("Test failed. TestApplyDelta bid %d expected %v, got %v", i, expectedBids[i], base.Bids[i])
+		}
+	}
+
+	expectedAsks := []Item{{Price: 300, Amount: 5}, {Price: 350, Amount: 4}, {Price: 400, Amount: 1}}
+	if len(base.Asks) != len(expectedAsks) {
+		t.Fatalf("Test failed. TestApplyDelta expected %d asks, got %d", len(expectedAsks), len(base.Asks))
+	}
+	for i := range expectedAsks {
+		if base.Asks[i] != expectedAsks[i] {
+			t.Errorf("Test failed. TestApplyDelta ask %d expected %v, got %v", i, expectedAsks[i], base.Asks[i])
+		}
+	}
+
+	if base.LastUpdated.IsZero() {
+		t.Error("Test failed. TestApplyDelta expected LastUpdated to be set")
+	}
+}
+
+func TestProcessDeltas(t *testing.T) {
+	t.Parallel()
+	currency := pair.NewCurrencyPair("BTC", "USD")
+	o := Init()
+
+	_, err := o.ProcessDeltas("Exchange", currency, Spot, 1, []Delta{{Side: Bid, Price: 100, Amount: 1}})
+	if err != ErrSnapshotMissing {
+		t.Fatalf("Test failed. TestProcessDeltas expected missing snapshot error, got %v", err)
+	}
+
+	snapshot := Base{
+		Pair:     currency,
+		Bids:     []Item{{Price: 100, Amount: 1}},
+		Asks:     []Item{{Price: 101, Amount: 1}},
+		Sequence: 10,
+	}
+	o.ProcessOrderbook("Exchange", currency, snapshot, Spot)
+	held, _ := o.GetOrderbook("Exchange", currency, Spot)
+
+	// Already covered by the snapshot
+	result, err := o.ProcessDeltas("Exchange", currency, Spot, 9, []Delta{{Side: Bid, Price: 100, Amount: 0}})
+	if err != nil || len(result.Bids) != 1 {
+		t.Fatal("Test failed. TestProcessDeltas applied an update older than the snapshot")
+	}
+
+	result, err = o.ProcessDeltas("Exchange", currency, Spot, 11, []Delta{{Side: Bid, Price: 100, Amount: 0}})
+	if err != nil {
+		t.Fatalf("Test failed. TestProcessDeltas error: %s", err)
+	}
+	if len(result.Bids) != 0 || result.Sequence != 11 {
+		t.Fatal("Test failed. TestProcessDeltas failed to apply in sequence update")
+	}
+	if len(held.Bids) != 1 {
+		t.Fatal("Test failed. TestProcessDeltas modified a previously returned orderbook")
+	}
+
+	_, err = o.ProcessDeltas("Exchange", currency, Spot, 13, []Delta{{Side: Ask, Price: 101, Amount: 0}})
+	if err != ErrSequenceGap {
+		t.Fatalf("Test failed. TestProcessDeltas expected sequence gap error, got %v", err)
+	}
+
+	result, _ = o.GetOrderbook("Exchange", currency, Spot)
+	if len(result.Asks) != 1 || result.Sequence != 11 {
+		t.Fatal("Test failed. TestProcessDeltas modified the orderbook after a sequence gap")
+	}
+
+	// Exchanges without sequence numbers skip the check
+	result, err = o.ProcessDeltas("Exchange", currency, Spot, 0, []Delta{{Side: Ask, Price: 102, Amount: 2}})
+	if err != nil || len(result.Asks) != 2 {
+		t.Fatal("Test failed. TestProcessDeltas failed to apply unsequenced update")
+	}
+}
+
+func TestProcessDeltasNotify(t *testing.T) {
+	currency := pair.NewCurrencyPair("LTC", "USD")
+	o := Init()
+	o.ProcessOrderbook("DeltaExchange", currency, Base{Pair: currency, Sequence: 1}, Spot)
+
+	ch := Subscribe()
+	defer Unsubscribe(ch)
+
+	_, err := o.ProcessDeltas("DeltaExchange", currency, Spot, 2, []Delta{{Side: Bid, Price: 100, Amount: 1}})
+	if err != nil {
+		t.Fatalf("Test failed. TestProcessDeltasNotify error: %s", err)
+	}
+	o.ProcessDeltas("DeltaExchange", currency, Spot, 4, []Delta{{Side: Bid, Price: 100, Amount: 2}})
+
+	received := 0
+	for len(ch) > 0 {
+		event := <-ch
+		if event.Exchange == "DeltaExchange" && event.AssetType == Spot && event.Pair == currency {
+			received++
+		}
+	}
+	if received != 1 {
+		t.Errorf("Test failed. TestProcessDeltasNotify expected 1 event, got %d", received)
+	}
+}
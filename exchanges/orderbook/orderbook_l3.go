@@ -0,0 +1,245 @@
+package orderbook
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/mattkanwisher/cryptofiend/currency/pair"
+)
+
+// Const values for the level 3 orderbook
+const (
+	ErrL3OrderNotFound = "Order not found in level 3 orderbook."
+)
+
+// L3Order is a single resting order in an order-by-order book
+type L3Order struct {
+	ID    string  `json:"id"`
+	Side  string  `json:"side"`
+	Price float64 `json:"price"`
+	Size  float64 `json:"size"`
+}
+
+// QueuePosition estimates how much of a price level has to trade before an
+// order is filled, assuming orders at a level are filled in arrival order
+type QueuePosition struct {
+	OrderID     string  `json:"order_id"`
+	Price       float64 `json:"price"`
+	AheadSize   float64 `json:"ahead_size"`
+	AheadOrders int     `json:"ahead_orders"`
+	LevelSize   float64 `json:"level_size"`
+}
+
+// L3Book holds the individual resting orders of a market, as sent by exchanges
+// with an order-by-order feed. Orders at each price level are kept in arrival
+// order so queue positions can be estimated.
+type L3Book struct {
+	m           sync.RWMutex
+	pair        pair.CurrencyPair
+	sequence    int64
+	orders      map[string]*L3Order
+	levels      map[string]map[float64][]*L3Order
+	lastUpdated time.Time
+}
+
+// NewL3Book returns an empty level 3 orderbook for a currency pair
+func NewL3Book(p pair.CurrencyPair) *L3Book {
+	b := &L3Book{pair: p}
+	b.reset()
+	return b
+}
+
+func (b *L3Book) reset() {
+	b.orders = make(map[string]*L3Order)
+	b.levels = map[string]map[float64][]*L3Order{
+		Bid: make(map[float64][]*L3Order),
+		Ask: make(map[float64][]*L3Order),
+	}
+}
+
+// LoadSnapshot replaces the book with a snapshot taken at the given sequence,
+// orders at the same price must be given in queue order
+func (b *L3Book) LoadSnapshot(sequence int64, orders []L3Order) {
+	b.m.Lock()
+	defer b.m.Unlock()
+
+	b.reset()
+	for _, o := range orders {
+		b.open(o)
+	}
+	b.sequence = sequence
+	b.lastUpdated = time.Now()
+}
+
+// Sequence returns the sequence number of the last message applied
+func (b *L3Book) Sequence() int64 {
+	b.m.RLock()
+	defer b.m.RUnlock()
+	return b.sequence
+}
+
+// AdvanceSequence checks the sequence number of a message against the book
+// before the message is applied. It returns false for messages already covered
+// by the book, which should be ignored, and ErrSequenceGap if messages were
+// missed, in which case the book must be reloaded from a snapshot.
+func (b *L3Book) AdvanceSequence(sequence int64) (bool, error) {
+	b.m.Lock()
+	defer b.m.Unlock()
+
+	if sequence <= b.sequence {
+		return false, nil
+	}
+	if b.sequence != 0 && sequence != b.sequence+1 {
+		return false, ErrSequenceGap
+	}
+	b.sequence = sequence
+	return true, nil
+}
+
+// Open adds an order to the back of the queue at its price level
+func (b *L3Book) Open(o L3Order) {
+	b.m.Lock()
+	defer b.m.Unlock()
+
+	b.open(o)
+	b.lastUpdated = time.Now()
+}
+
+func (b *L3Book) open(o L3Order) {
+	if _, ok := b.orders[o.ID]; ok {
+		b.remove(o.ID)
+	}
+	order := &o
+	b.orders[o.ID] = order
+	b.levels[o.Side][o.Price] = append(b.levels[o.Side][o.Price], order)
+}
+
+// Done removes an order from the book, whether it was filled or cancelled
+func (b *L3Book) Done(id string) {
+	b.m.Lock()
+	defer b.m.Unlock()
+
+	b.remove(id)
+	b.lastUpdated = time.Now()
+}
+
+func (b *L3Book) remove(id string) {
+	order, ok := b.orders[id]
+	if !ok {
+		return
+	}
+	delete(b.orders, id)
+
+	level := b.levels[order.Side][order.Price]
+	for i := range level {
+		if level[i] == order {
+			level = append(level[:i], level[i+1:]...)
+			break
+		}
+	}
+	if len(level) == 0 {
+		delete(b.levels[order.Side], order.Price)
+	} else {
+		b.levels[order.Side][order.Price] = level
+	}
+}
+
+// Match reduces the size of a resting order that traded, the order is removed
+// once nothing remains
+func (b *L3Book) Match(makerID string, size float64) {
+	b.m.Lock()
+	defer b.m.Unlock()
+
+	order, ok := b.orders[makerID]
+	if !ok {
+		return
+	}
+	order.Size -= size
+	if order.Size <= 0 {
+		b.remove(makerID)
+	}
+	b.lastUpdated = time.Now()
+}
+
+// Change updates the size of a resting order, the order keeps its place in the
+// queue
+func (b *L3Book) Change(id string, newSize float64) {
+	b.m.Lock()
+	defer b.m.Unlock()
+
+	order, ok := b.orders[id]
+	if !ok {
+		return
+	}
+	order.Size = newSize
+	b.lastUpdated = time.Now()
+}
+
+// GetOrder returns a resting order by ID
+func (b *L3Book) GetOrder(id string) (L3Order, error) {
+	b.m.RLock()
+	defer b.m.RUnlock()
+
+	order, ok := b.orders[id]
+	if !ok {
+		return L3Order{}, errors.New(ErrL3OrderNotFound)
+	}
+	return *order, nil
+}
+
+// QueuePosition returns the position of a resting order in the queue at its
+// price level
+func (b *L3Book) QueuePosition(id string) (QueuePosition, error) {
+	b.m.RLock()
+	defer b.m.RUnlock()
+
+	order, ok := b.orders[id]
+	if !ok {
+		return QueuePosition{}, errors.New(ErrL3OrderNotFound)
+	}
+
+	pos := QueuePosition{OrderID: id, Price: order.Price}
+	ahead := true
+	for _, x := range b.levels[order.Side][order.Price] {
+		if x == order {
+			ahead = false
+		} else if ahead {
+			pos.AheadSize += x.Size
+			pos.AheadOrders++
+		}
+		pos.LevelSize += x.Size
+	}
+	return pos, nil
+}
+
+// L2 returns the aggregated price level view of the book
+func (b *L3Book) L2() Base {
+	b.m.RLock()
+	defer b.m.RUnlock()
+
+	ob := Base{
+		Pair:         b.pair,
+		CurrencyPair: b.pair.Pair().String(),
+		Bids:         aggregateLevels(b.levels[Bid]),
+		Asks:         aggregateLevels(b.levels[Ask]),
+		LastUpdated:  b.lastUpdated,
+		Sequence:     b.sequence,
+	}
+	sort.Slice(ob.Bids, func(i, j int) bool { return ob.Bids[i].Price > ob.Bids[j].Price })
+	sort.Slice(ob.Asks, func(i, j int) bool { return ob.Asks[i].Price < ob.Asks[j].Price })
+	return ob
+}
+
+func aggregateLevels(levels map[float64][]*L3Order) []Item {
+	items := make([]Item, 0, len(levels))
+	for price, orders := range levels {
+		item := Item{Price: price}
+		for _, x := range orders {
+			item.Amount += x.Size
+		}
+		items = append(items, item)
+	}
+	return items
+}
@@ -0,0 +1,79 @@
+package orderbook
+
+import (
+	"testing"
+
+	"github.com/mattkanwisher/cryptofiend/currency/pair"
+)
+
+func TestL3Book(t *testing.T) {
+	t.Parallel()
+	b := NewL3Book(pair.NewCurrencyPair("BTC", "USD"))
+	b.LoadSnapshot(100, []L3Order{
+		{ID: "a", Side: Bid, Price: 100, Size: 1},
+		{ID: "b", Side: Bid, Price: 100, Size: 2},
+		{ID: "c", Side: Bid, Price: 99, Size: 5},
+		{ID: "d", Side: Ask, Price: 101, Size: 3},
+	})
+
+	b.Open(L3Order{ID: "mine", Side: Bid, Price: 100, Size: 1.5})
+	pos, err := b.QueuePosition("mine")
+	if err != nil {
+		t.Fatalf("Test failed. TestL3Book QueuePosition error: %s", err)
+	}
+	if pos.AheadSize != 3 || pos.AheadOrders != 2 || pos.LevelSize != 4.5 {
+		t.Errorf("Test failed. TestL3Book unexpected queue position %+v", pos)
+	}
+
+	b.Match("a", 1)
+	b.Change("b", 0.5)
+	pos, _ = b.QueuePosition("mine")
+	if pos.AheadSize != 0.5 || pos.AheadOrders != 1 {
+		t.Errorf("Test failed. TestL3Book unexpected queue position after match %+v", pos)
+	}
+
+	b.Done("d")
+	b.Open(L3Order{ID: "e", Side: Ask, Price: 102, Size: 1})
+	b.Open(L3Order{ID: "f", Side: Ask, Price: 101.5, Size: 2})
+
+	l2 := b.L2()
+	expectedBids := []Item{{Price: 100, Amount: 2}, {Price: 99, Amount: 5}}
+	expectedAsks := []Item{{Price: 101.5, Amount: 2}, {Price: 102, Amount: 1}}
+	if len(l2.Bids) != len(expectedBids) || len(l2.Asks) != len(expectedAsks) {
+		t.Fatalf("Test failed. TestL3Book unexpected L2 view %+v", l2)
+	}
+	for i := range expectedBids {
+		if l2.Bids[i] != expectedBids[i] {
+			t.Errorf("Test failed. TestL3Book bid %d expected %v, got %v", i, expectedBids[i], l2.Bids[i])
+		}
+	}
+	for i := range expectedAsks {
+		if l2.Asks[i] != expectedAsks[i] {
+			t.Errorf("Test failed. TestL3Book ask %d expected %v, got %v", i, expectedAsks[i], l2.Asks[i])
+		}
+	}
+
+	_, err = b.QueuePosition("d")
+	if err == nil {
+		t.Error("Test failed. TestL3Book returned a queue position for a removed order")
+	}
+}
+
+func TestL3BookAdvanceSequence(t *testing.T) {
+	t.Parallel()
+	b := NewL3Book(pair.NewCurrencyPair("BTC", "USD"))
+	b.LoadSnapshot(100, nil)
+
+	if ok, err := b.AdvanceSequence(100); ok || err != nil {
+		t.Error("Test failed. TestL3BookAdvanceSequence accepted a message covered by the snapshot")
+	}
+	if ok, err := b.AdvanceSequence(101); !ok || err != nil {
+		t.Error("Test failed. TestL3BookAdvanceSequence rejected the next message")
+	}
+	if _, err := b.AdvanceSequence(103); err != ErrSequenceGap {
+		t.Error("Test failed. TestL3BookAdvanceSequence expected a sequence gap")
+	}
+	if b.Sequence() != 101 {
+		t.Errorf("Test failed. TestL3BookAdvanceSequence expected sequence 101, got %d", b.Sequence())
+	}
+}
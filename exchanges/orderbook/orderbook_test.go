@@ -81,7 +81,7 @@ func TestGetOrderbook(t *testing.T) {
 	}
 
 	o := Init()
-	o.CreateNewOrderbook("Exchange", currency, base, Spot)
+	o.ProcessOrderbook("Exchange", currency, base, Spot)
 
 	result, err := o.GetOrderbook("Exchange", currency, Spot)
 	if err != nil {
@@ -93,7 +93,8 @@ func TestGetOrderbook(t *testing.T) {
 		t.Fatal("Test failed. TestGetOrderbook failed. Mismatched pairs")
 	}
 
-	_, err = o.GetOrderbook("nonexistent", currency, Spot)
+	empty := Init()
+	_, err = empty.GetOrderbook("nonexistent", currency, Spot)
 	if err == nil {
 		t.Fatal("Test failed. TestGetOrderbook retrieved non-existent orderbook")
 	}
@@ -120,16 +121,18 @@ func TestGetOrderbookByExchange(t *testing.T) {
 		Bids:         []Item{Item{Price: 200, Amount: 10}},
 	}
 
+	// Each exchange holds its own set of orderbooks
 	o := Init()
-	o.CreateNewOrderbook("Exchange", currency, base, Spot)
+	o.ProcessOrderbook("Exchange", currency, base, Spot)
 
-	_, err := o.GetOrderbookByExchange("Exchange")
+	_, err := o.GetOrderbook("Exchange", currency, Spot)
 	if err != nil {
 		t.Fatalf("Test failed. TestGetOrderbookByExchange failed to get orderbook. Error %s",
 			err)
 	}
 
-	_, err = o.GetOrderbookByExchange("nonexistant")
+	other := Init()
+	_, err = other.GetOrderbook("nonexistant", currency, Spot)
 	if err == nil {
 		t.Fatal("Test failed. TestGetOrderbookByExchange retrieved non-existant orderbook")
 	}
@@ -145,14 +148,14 @@ func TestFirstCurrencyExists(t *testing.T) {
 	}
 
 	o := Init()
-	o.CreateNewOrderbook("Exchange", currency, base, Spot)
+	o.ProcessOrderbook("Exchange", currency, base, Spot)
 
-	if !o.FirstCurrencyExists("Exchange", currency.FirstCurrency) {
+	if !o.FirstCurrencyExists(currency.FirstCurrency) {
 		t.Fatal("Test failed. TestFirstCurrencyExists expected first currency doesn't exist")
 	}
 
 	var item pair.CurrencyItem = "blah"
-	if o.FirstCurrencyExists("Exchange", item) {
+	if o.FirstCurrencyExists(item) {
 		t.Fatal("Test failed. TestFirstCurrencyExists unexpected first currency exists")
 	}
 }
@@ -167,14 +170,14 @@ func TestSecondCurrencyExists(t *testing.T) {
 	}
 
 	o := Init()
-	o.CreateNewOrderbook("Exchange", currency, base, Spot)
+	o.ProcessOrderbook("Exchange", currency, base, Spot)
 
-	if !o.SecondCurrencyExists("Exchange", currency) {
+	if !o.SecondCurrencyExists(currency) {
 		t.Fatal("Test failed. TestSecondCurrencyExists expected first currency doesn't exist")
 	}
 
 	currency.SecondCurrency = "blah"
-	if o.SecondCurrencyExists("Exchange", currency) {
+	if o.SecondCurrencyExists(currency) {
 		t.Fatal("Test failed. TestSecondCurrencyExists unexpected first currency exists")
 	}
 }
@@ -189,7 +192,7 @@ func TestCreateNewOrderbook(t *testing.T) {
 	}
 
 	o := Init()
-	o.CreateNewOrderbook("Exchange", currency, base, Spot)
+	o.ProcessOrderbook("Exchange", currency, base, Spot)
 
 	result, err := o.GetOrderbook("Exchange", currency, Spot)
 	if err != nil {
@@ -270,3 +273,22 @@ func TestProcessOrderbook(t *testing.T) {
 		t.Fatal("Test failed. TestProcessOrderbook CalculateTotalsBids incorrect values")
 	}
 }
+
+func TestSubscribe(t *testing.T) {
+	o := Init()
+	currency := pair.NewCurrencyPair("LTC", "USD")
+
+	ch := Subscribe()
+	o.ProcessOrderbook("Kraken", currency, Base{Pair: currency}, Spot)
+
+	event := <-ch
+	if event.Exchange != "Kraken" || event.AssetType != Spot || !event.Pair.Equal(currency) {
+		t.Errorf("Test failed. TestSubscribe unexpected event %+v", event)
+	}
+
+	Unsubscribe(ch)
+	o.ProcessOrderbook("Kraken", currency, Base{Pair: currency}, Spot)
+	if _, ok := <-ch; ok {
+		t.Error("Test failed. TestSubscribe received event after unsubscribing")
+	}
+}
@@ -171,7 +171,7 @@ func (p *Poloniex) GetOrderbook(currencyPair string, depth int) (PoloniexOrderbo
 		return PoloniexOrderbook{}, err
 	}
 
-	ob := PoloniexOrderbook{}
+	ob := PoloniexOrderbook{Seq: resp.Seq}
 	for x := range resp.Asks {
 		data := resp.Asks[x]
 		price, err := strconv.ParseFloat(data[0].(string), 64)
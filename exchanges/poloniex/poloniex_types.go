@@ -16,6 +16,7 @@ type PoloniexOrderbookResponse struct {
 	Asks     [][]interface{} `json:"asks"`
 	Bids     [][]interface{} `json:"bids"`
 	IsFrozen string          `json:"isFrozen"`
+	Seq      int64           `json:"seq"`
 }
 
 type PoloniexOrderbookItem struct {
@@ -26,6 +27,7 @@ type PoloniexOrderbookItem struct {
 type PoloniexOrderbook struct {
 	Asks []PoloniexOrderbookItem `json:"asks"`
 	Bids []PoloniexOrderbookItem `json:"bids"`
+	Seq  int64                   `json:"seq"`
 }
 
 type PoloniexTradeHistory struct {
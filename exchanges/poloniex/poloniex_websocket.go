@@ -3,8 +3,13 @@ package poloniex
 import (
 	"log"
 	"strconv"
+	"time"
 
 	"github.com/beatgammit/turnpike"
+	"github.com/mattkanwisher/cryptofiend/common"
+	"github.com/mattkanwisher/cryptofiend/exchanges"
+	"github.com/mattkanwisher/cryptofiend/exchanges/orderbook"
+	"github.com/mattkanwisher/cryptofiend/exchanges/ticker"
 )
 
 const (
@@ -27,7 +32,9 @@ type PoloniexWebsocketTicker struct {
 	Low           float64
 }
 
-func PoloniexOnTicker(args []interface{}, kwargs map[string]interface{}) {
+// PoloniexOnTicker handles ticker channel messages, which carry updates for
+// every market so anything other than the enabled pairs is ignored
+func (p *Poloniex) PoloniexOnTicker(args []interface{}, kwargs map[string]interface{}) {
 	ticker := PoloniexWebsocketTicker{}
 	ticker.CurrencyPair = args[0].(string)
 	ticker.Last, _ = strconv.ParseFloat(args[1].(string), 64)
@@ -45,6 +52,25 @@ func PoloniexOnTicker(args []interface{}, kwargs map[string]interface{}) {
 
 	ticker.High, _ = strconv.ParseFloat(args[8].(string), 64)
 	ticker.Low, _ = strconv.ParseFloat(args[9].(string), 64)
+
+	if !common.DataContains(p.EnabledPairs, ticker.CurrencyPair) {
+		return
+	}
+	p.WebsocketProcessTicker(ticker)
+}
+
+// WebsocketProcessTicker stores a ticker update and publishes it to stream
+// subscribers
+func (p *Poloniex) WebsocketProcessTicker(t PoloniexWebsocketTicker) {
+	var tp ticker.Price
+	tp.Pair = p.SymbolToCurrencyPair(t.CurrencyPair)
+	tp.Last = t.Last
+	tp.Ask = t.LowestAsk
+	tp.Bid = t.HighestBid
+	tp.High = t.High
+	tp.Low = t.Low
+	tp.Volume = t.BaseVolume
+	p.PushTicker(tp, ticker.Spot)
 }
 
 type PoloniexWebsocketTrollboxMessage struct {
@@ -54,7 +80,7 @@ type PoloniexWebsocketTrollboxMessage struct {
 	Reputation    float64
 }
 
-func PoloniexOnTrollbox(args []interface{}, kwargs map[string]interface{}) {
+func (p *Poloniex) PoloniexOnTrollbox(args []interface{}, kwargs map[string]interface{}) {
 	message := PoloniexWebsocketTrollboxMessage{}
 	message.MessageNumber, _ = args[1].(float64)
 	message.Username = args[2].(string)
@@ -64,7 +90,10 @@ func PoloniexOnTrollbox(args []interface{}, kwargs map[string]interface{}) {
 	}
 }
 
-func PoloniexOnDepthOrTrade(args []interface{}, kwargs map[string]interface{}) {
+// PoloniexOnDepthOrTrade handles the orderbook and trade messages of a
+// market channel
+func (p *Poloniex) PoloniexOnDepthOrTrade(symbol string, args []interface{}, kwargs map[string]interface{}) {
+	deltas := []orderbook.Delta{}
 	for x := range args {
 		data := args[x].(map[string]interface{})
 		msgData := data["data"].(map[string]interface{})
@@ -87,6 +116,8 @@ func PoloniexOnDepthOrTrade(args []interface{}, kwargs map[string]interface{}) {
 
 				amountStr := msgData["amount"].(string)
 				orderModify.Amount, _ = strconv.ParseFloat(amountStr, 64)
+
+				deltas = append(deltas, orderbook.Delta{Side: orderModify.Type, Price: orderModify.Rate, Amount: orderModify.Amount})
 			}
 		case "orderBookRemove":
 			{
@@ -100,6 +131,8 @@ func PoloniexOnDepthOrTrade(args []interface{}, kwargs map[string]interface{}) {
 
 				rateStr := msgData["rate"].(string)
 				orderRemoval.Rate, _ = strconv.ParseFloat(rateStr, 64)
+
+				deltas = append(deltas, orderbook.Delta{Side: orderRemoval.Type, Price: orderRemoval.Rate})
 			}
 		case "newTrade":
 			{
@@ -125,12 +158,45 @@ func PoloniexOnDepthOrTrade(args []interface{}, kwargs map[string]interface{}) {
 				trade.Amount, _ = strconv.ParseFloat(amountStr, 64)
 
 				totalStr := msgData["total"].(string)
-				trade.Rate, _ = strconv.ParseFloat(totalStr, 64)
+				trade.Total, _ = strconv.ParseFloat(totalStr, 64)
 
 				trade.Date = msgData["date"].(string)
+
+				newTrade := exchange.Trade{
+					Exchange: p.GetName(),
+					Pair:     p.SymbolToCurrencyPair(symbol),
+					TradeID:  tradeIDstr,
+					Side:     exchange.OrderSide(trade.Type),
+					Price:    trade.Rate,
+					Amount:   trade.Amount,
+				}
+				if t, err := time.Parse("2006-01-02 15:04:05", trade.Date); err == nil {
+					newTrade.Timestamp = t
+				}
+				p.Streams.PublishTrade(newTrade)
 			}
 		}
 	}
+
+	// Every message on a market channel carries the next sequence number,
+	// even those holding only trades
+	seq, _ := kwargs["seq"].(float64)
+	p.WebsocketProcessDeltas(symbol, int64(seq), deltas)
+}
+
+// WebsocketProcessDeltas applies orderbook changes from a market channel,
+// resynchronising the orderbook from the REST API if any were missed
+func (p *Poloniex) WebsocketProcessDeltas(symbol string, seq int64, deltas []orderbook.Delta) {
+	currencyPair := p.SymbolToCurrencyPair(symbol)
+	err := p.PushOrderbookDeltas(currencyPair, orderbook.Spot, seq, deltas)
+	if err == nil {
+		return
+	}
+
+	if p.Verbose {
+		log.Printf("%s %s orderbook update not applied. Error: %s\n", p.GetName(), symbol, err)
+	}
+	p.ResyncOrderbook(currencyPair, orderbook.Spot, p.UpdateOrderbook)
 }
 
 func (p *Poloniex) WebsocketClient() {
@@ -157,17 +223,20 @@ func (p *Poloniex) WebsocketClient() {
 
 		c.ReceiveDone = make(chan bool)
 
-		if err := c.Subscribe(POLONIEX_WEBSOCKET_TICKER, PoloniexOnTicker); err != nil {
+		if err := c.Subscribe(POLONIEX_WEBSOCKET_TICKER, p.PoloniexOnTicker); err != nil {
 			log.Printf("%s Error subscribing to ticker channel: %s\n", p.GetName(), err)
 		}
 
-		if err := c.Subscribe(POLONIEX_WEBSOCKET_TROLLBOX, PoloniexOnTrollbox); err != nil {
+		if err := c.Subscribe(POLONIEX_WEBSOCKET_TROLLBOX, p.PoloniexOnTrollbox); err != nil {
 			log.Printf("%s Error subscribing to trollbox channel: %s\n", p.GetName(), err)
 		}
 
 		for x := range p.EnabledPairs {
 			currency := p.EnabledPairs[x]
-			if err := c.Subscribe(currency, PoloniexOnDepthOrTrade); err != nil {
+			handler := func(args []interface{}, kwargs map[string]interface{}) {
+				p.PoloniexOnDepthOrTrade(currency, args, kwargs)
+			}
+			if err := c.Subscribe(currency, handler); err != nil {
 				log.Printf("%s Error subscribing to %s channel: %s\n", p.GetName(), currency, err)
 			}
 		}
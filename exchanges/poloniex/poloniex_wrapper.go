@@ -100,11 +100,18 @@ func (p *Poloniex) UpdateOrderbook(currencyPair pair.CurrencyPair, assetType str
 		data := orderbookNew.Asks[x]
 		orderBook.Asks = append(orderBook.Asks, orderbook.Item{Amount: data.Amount, Price: data.Price})
 	}
+	orderBook.Sequence = orderbookNew.Seq
 
 	p.Orderbooks.ProcessOrderbook(p.GetName(), currencyPair, orderBook, assetType)
 	return p.Orderbooks.GetOrderbook(p.Name, currencyPair, assetType)
 }
 
+// StreamsFullOrderbook returns true as the market channel sends the full
+// orderbook followed by sequenced changes
+func (p *Poloniex) StreamsFullOrderbook() bool {
+	return true
+}
+
 // GetExchangeAccountInfo retrieves balances for all enabled currencies for the
 // Poloniex exchange
 func (p *Poloniex) GetExchangeAccountInfo() (exchange.AccountInfo, error) {
@@ -2,6 +2,8 @@ package stats
 
 import (
 	"sort"
+	"sync"
+	"time"
 
 	"github.com/mattkanwisher/cryptofiend/currency/pair"
 )
@@ -18,6 +20,9 @@ type Item struct {
 // Items var array
 var Items []Item
 
+// m guards Items and the samples kept for the windowed stats
+var m sync.Mutex
+
 // ByPrice allows sorting by price
 type ByPrice []Item
 
@@ -48,23 +53,37 @@ func (b ByVolume) Swap(i, j int) {
 	b[i], b[j] = b[j], b[i]
 }
 
-// Add adds or updates the item stats
+// quoteEquivalents maps quote currencies to a currency they track closely
+// enough for prices to be compared
+var quoteEquivalents = map[pair.CurrencyItem]pair.CurrencyItem{
+	"USDT": "USD",
+}
+
+// normalisePair returns the pair stats are stored under, so that the same
+// market on different exchanges is compared regardless of the currency codes
+// used by each exchange
+func normalisePair(p pair.CurrencyPair) pair.CurrencyPair {
+	p = p.Normalise()
+	if quote, ok := quoteEquivalents[p.SecondCurrency]; ok {
+		p.SecondCurrency = quote
+	}
+	return p
+}
+
+// Add adds or updates the item stats, and records the price and volume for
+// the windowed stats
 func Add(exchange string, p pair.CurrencyPair, assetType string, price, volume float64) {
 	if exchange == "" || assetType == "" || price == 0 || volume == 0 || p.FirstCurrency == "" || p.SecondCurrency == "" {
 		return
 	}
 
-	if p.FirstCurrency == "XBT" {
-		newPair := pair.NewCurrencyPair("BTC", p.SecondCurrency.String())
-		Append(exchange, newPair, assetType, price, volume)
-	}
+	p = normalisePair(p)
 
-	if p.SecondCurrency == "USDT" {
-		newPair := pair.NewCurrencyPair(p.FirstCurrency.String(), "USD")
-		Append(exchange, newPair, assetType, price, volume)
-	}
+	m.Lock()
+	defer m.Unlock()
 
 	Append(exchange, p, assetType, price, volume)
+	addSample(exchange, p, assetType, price, volume, time.Now())
 }
 
 // Append adds or updates the item stats for a specific
@@ -101,6 +120,11 @@ func AlreadyExists(exchange string, p pair.CurrencyPair, assetType string, price
 // currency pair and asset type. Reverse will reverse the order from lowest to
 // highest
 func SortExchangesByVolume(p pair.CurrencyPair, assetType string, reverse bool) []Item {
+	p = normalisePair(p)
+
+	m.Lock()
+	defer m.Unlock()
+
 	var result []Item
 	for x := range Items {
 		if Items[x].Pair.Equal(p) && Items[x].AssetType == assetType {
@@ -120,6 +144,11 @@ func SortExchangesByVolume(p pair.CurrencyPair, assetType string, reverse bool)
 // currency pair and asset type. Reverse will reverse the order from lowest to
 // highest
 func SortExchangesByPrice(p pair.CurrencyPair, assetType string, reverse bool) []Item {
+	p = normalisePair(p)
+
+	m.Lock()
+	defer m.Unlock()
+
 	var result []Item
 	for x := range Items {
 		if Items[x].Pair.Equal(p) && Items[x].AssetType == assetType {
@@ -118,14 +118,14 @@ func TestAdd(t *testing.T) {
 	p.FirstCurrency = "XBT"
 	Add("ANX", p, "SPOT", 1201, 43)
 
-	if Items[1].Pair.Pair() != "XBTUSD" {
-		t.Fatal("Test failed. stats Add did not add exchange info.")
+	if len(Items) != 1 || Items[0].Price != 1201 {
+		t.Fatal("Test failed. stats Add did not normalise XBT to BTC.")
 	}
 
 	p = pair.NewCurrencyPair("ETH", "USDT")
 	Add("ANX", p, "SPOT", 300, 1000)
 
-	if Items[2].Pair.Pair() != "ETHUSD" {
+	if Items[1].Pair.Pair() != "ETHUSD" {
 		t.Fatal("Test failed. stats Add did not add exchange info.")
 	}
 }
@@ -137,8 +137,9 @@ func TestAppend(t *testing.T) {
 		t.Error("Test Failed - stats Append did not add exchange values.")
 	}
 
+	count := len(Items)
 	Append("sillyexchange", p, "SPOT", 1234, 45)
-	if len(Items) == 3 {
+	if len(Items) != count {
 		t.Error("Test Failed - stats Append added exchange values")
 	}
 }
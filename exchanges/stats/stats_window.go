@@ -0,0 +1,154 @@
+package stats
+
+import (
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/mattkanwisher/cryptofiend/currency/pair"
+)
+
+// Const values for the windowed stats
+const (
+	ErrNoStatsInWindow = "No stats for currency pair and asset type in window."
+
+	// DefaultMaxWindow is how long samples are kept for by default
+	DefaultMaxWindow = time.Hour * 24
+)
+
+// MaxWindow is the longest window stats can be calculated over, older samples
+// are discarded
+var MaxWindow = DefaultMaxWindow
+
+// ExchangeStats holds the stats of one exchange over a window. Price is the
+// volume weighted average of the prices seen, Volume the latest volume
+// reported by the exchange and Premium the percentage its price is above the
+// cross exchange average.
+type ExchangeStats struct {
+	Exchange    string  `json:"exchange"`
+	Price       float64 `json:"price"`
+	Volume      float64 `json:"volume"`
+	VolumeShare float64 `json:"volume_share"`
+	Premium     float64 `json:"premium"`
+	Samples     int     `json:"samples"`
+}
+
+// WindowStats holds the stats of a currency pair across exchanges over a
+// window. Exchanges are sorted cheapest first, Spread is the difference
+// between the most expensive and cheapest exchange.
+type WindowStats struct {
+	Pair          pair.CurrencyPair `json:"pair"`
+	AssetType     string            `json:"asset_type"`
+	Window        time.Duration     `json:"window"`
+	VWAP          float64           `json:"vwap"`
+	Volume        float64           `json:"volume"`
+	Exchanges     []ExchangeStats   `json:"exchanges"`
+	Cheapest      string            `json:"cheapest"`
+	MostExpensive string            `json:"most_expensive"`
+	Spread        float64           `json:"spread"`
+	SpreadPercent float64           `json:"spread_percent"`
+}
+
+type sample struct {
+	price     float64
+	volume    float64
+	timestamp time.Time
+}
+
+type sampleKey struct {
+	exchange  string
+	pair      pair.CurrencyItem
+	assetType string
+}
+
+var samples = make(map[sampleKey][]sample)
+
+// Records a price and volume and discards samples older than MaxWindow, the
+// caller must hold m
+func addSample(exchange string, p pair.CurrencyPair, assetType string, price, volume float64, t time.Time) {
+	key := sampleKey{exchange, p.Pair(), assetType}
+	s := append(samples[key], sample{price, volume, t})
+
+	cutoff := t.Add(-MaxWindow)
+	i := 0
+	for i < len(s) && s[i].timestamp.Before(cutoff) {
+		i++
+	}
+	samples[key] = s[i:]
+}
+
+// GetWindowStats returns the stats of a currency pair across every exchange
+// that reported it within the window
+func GetWindowStats(p pair.CurrencyPair, assetType string, window time.Duration) (WindowStats, error) {
+	p = normalisePair(p)
+
+	m.Lock()
+	defer m.Unlock()
+
+	return calculateWindowStats(p, assetType, window, time.Now())
+}
+
+func calculateWindowStats(p pair.CurrencyPair, assetType string, window time.Duration, now time.Time) (WindowStats, error) {
+	result := WindowStats{Pair: p, AssetType: assetType, Window: window}
+	start := now.Add(-window)
+
+	for key, s := range samples {
+		if key.pair != p.Pair() || key.assetType != assetType {
+			continue
+		}
+
+		e := ExchangeStats{Exchange: key.exchange}
+		value, volume, sum := float64(0), float64(0), float64(0)
+		for _, x := range s {
+			if x.timestamp.Before(start) {
+				continue
+			}
+			value += x.price * x.volume
+			volume += x.volume
+			sum += x.price
+			e.Volume = x.volume
+			e.Samples++
+		}
+		if e.Samples == 0 {
+			continue
+		}
+
+		e.Price = sum / float64(e.Samples)
+		if volume > 0 {
+			e.Price = value / volume
+		}
+		result.Exchanges = append(result.Exchanges, e)
+	}
+
+	if len(result.Exchanges) == 0 {
+		return WindowStats{}, errors.New(ErrNoStatsInWindow)
+	}
+
+	value := float64(0)
+	for _, e := range result.Exchanges {
+		value += e.Price * e.Volume
+		result.Volume += e.Volume
+	}
+	result.VWAP = value / result.Volume
+
+	for i := range result.Exchanges {
+		e := &result.Exchanges[i]
+		e.VolumeShare = e.Volume / result.Volume
+		e.Premium = (e.Price - result.VWAP) / result.VWAP * 100
+	}
+
+	sort.Slice(result.Exchanges, func(i, j int) bool {
+		if result.Exchanges[i].Price == result.Exchanges[j].Price {
+			return result.Exchanges[i].Exchange < result.Exchanges[j].Exchange
+		}
+		return result.Exchanges[i].Price < result.Exchanges[j].Price
+	})
+
+	cheapest := result.Exchanges[0]
+	mostExpensive := result.Exchanges[len(result.Exchanges)-1]
+	result.Cheapest = cheapest.Exchange
+	result.MostExpensive = mostExpensive.Exchange
+	result.Spread = mostExpensive.Price - cheapest.Price
+	result.SpreadPercent = result.Spread / cheapest.Price * 100
+	return result, nil
+}
@@ -0,0 +1,55 @@
+package stats
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/mattkanwisher/cryptofiend/currency/pair"
+)
+
+func TestGetWindowStats(t *testing.T) {
+	m.Lock()
+	samples = make(map[sampleKey][]sample)
+	m.Unlock()
+
+	now := time.Now()
+	p := pair.NewCurrencyPair("LTC", "USD")
+	m.Lock()
+	addSample("Kraken", p, "SPOT", 90, 100, now.Add(-time.Hour*2))
+	addSample("Kraken", p, "SPOT", 100, 100, now.Add(-time.Minute))
+	addSample("GDAX", p, "SPOT", 102, 200, now.Add(-time.Minute))
+	addSample("GDAX", p, "SPOT", 104, 200, now)
+	addSample("Bitfinex", p, "SPOT", 95, 50, now.Add(-time.Hour*27))
+	addSample("Bitfinex", p, "SPOT", 96, 50, now.Add(-time.Hour*2))
+	result, err := calculateWindowStats(p, "SPOT", time.Hour, now)
+	m.Unlock()
+
+	if err != nil {
+		t.Fatalf("Test failed. TestGetWindowStats error: %s", err)
+	}
+	if len(result.Exchanges) != 2 || result.Cheapest != "Kraken" || result.MostExpensive != "GDAX" {
+		t.Fatalf("Test failed. TestGetWindowStats unexpected exchanges %+v", result)
+	}
+	if result.VWAP != (100*100+103*200)/300.0 || result.Spread != 3 {
+		t.Errorf("Test failed. TestGetWindowStats unexpected VWAP %f or spread %f", result.VWAP, result.Spread)
+	}
+	if math.Abs(result.Exchanges[1].VolumeShare-2.0/3) > 1e-9 || result.Exchanges[1].Premium <= 0 {
+		t.Errorf("Test failed. TestGetWindowStats unexpected exchange stats %+v", result.Exchanges[1])
+	}
+
+	// Samples older than MaxWindow are discarded
+	if len(samples[sampleKey{"Bitfinex", p.Pair(), "SPOT"}]) != 1 {
+		t.Error("Test failed. TestGetWindowStats old samples weren't discarded")
+	}
+
+	Add("Kraken", pair.NewCurrencyPair("xbt", "usdt"), "SPOT", 10000, 5)
+	_, err = GetWindowStats(pair.NewCurrencyPair("BTC", "USD"), "SPOT", time.Minute)
+	if err != nil {
+		t.Errorf("Test failed. TestGetWindowStats normalised pair not found: %s", err)
+	}
+	_, err = GetWindowStats(pair.NewCurrencyPair("BTC", "EUR"), "SPOT", time.Minute)
+	if err == nil {
+		t.Error("Test failed. TestGetWindowStats expected no stats error")
+	}
+}
@@ -2,7 +2,10 @@ package ticker
 
 import (
 	"errors"
+	"math"
 	"strconv"
+	"sync"
+	"time"
 
 	"github.com/mattkanwisher/cryptofiend/common"
 	"github.com/mattkanwisher/cryptofiend/currency/pair"
@@ -17,10 +20,22 @@ const (
 	Spot = "SPOT"
 )
 
-// Vars for the ticker package
-var (
-	Tickers []Ticker
-)
+// SubscriberBufferSize is the number of undelivered ticker events held for
+// each subscriber before further events are dropped
+const SubscriberBufferSize = 256
+
+// tickers is the store of the latest ticker of each exchange, pair and asset
+// type, keyed by exchange name. Currencies are stored upper case so lookups
+// don't depend on the pair format used by the exchange.
+var tickers = struct {
+	m           sync.RWMutex
+	exchanges   map[string]*Ticker
+	history     map[historyKey]*history
+	subscribers []chan Event
+}{
+	exchanges: make(map[string]*Ticker),
+	history:   make(map[historyKey]*history),
+}
 
 // Price struct stores the currency pair and pricing information
 type Price struct {
@@ -33,6 +48,15 @@ type Price struct {
 	Ask          float64           `json:"Ask"`
 	Volume       float64           `json:"Volume"`
 	PriceATH     float64           `json:"PriceATH"`
+	LastUpdated  time.Time         `json:"LastUpdated"`
+}
+
+// Event is sent to subscribers when a stored ticker changes
+type Event struct {
+	Exchange  string
+	Pair      pair.CurrencyPair
+	AssetType string
+	Price     Price
 }
 
 // Ticker struct holds the ticker information for a currency pair and type
@@ -44,6 +68,7 @@ type Ticker struct {
 // PriceToString returns the string version of a stored price field
 func (t *Ticker) PriceToString(p pair.CurrencyPair, priceType, tickerType string) string {
 	priceType = common.StringToLower(priceType)
+	p = formatPair(p)
 
 	switch priceType {
 	case "last":
@@ -65,42 +90,87 @@ func (t *Ticker) PriceToString(p pair.CurrencyPair, priceType, tickerType string
 	}
 }
 
+// Returns the pair used to index the store regardless of the pair format used
+// by the exchange
+func formatPair(p pair.CurrencyPair) pair.CurrencyPair {
+	return pair.NewCurrencyPair(p.FirstCurrency.Upper().String(), p.SecondCurrency.Upper().String())
+}
+
 // GetTicker checks and returns a requested ticker if it exists
 func GetTicker(exchange string, p pair.CurrencyPair, tickerType string) (Price, error) {
-	ticker, err := GetTickerByExchange(exchange)
-	if err != nil {
-		return Price{}, err
+	tickers.m.RLock()
+	defer tickers.m.RUnlock()
+
+	ticker, ok := tickers.exchanges[exchange]
+	if !ok {
+		return Price{}, errors.New(ErrTickerForExchangeNotFound)
 	}
 
-	if !FirstCurrencyExists(exchange, p.FirstCurrency) {
+	fp := formatPair(p)
+	a, ok := ticker.Price[fp.FirstCurrency]
+	if !ok {
 		return Price{}, errors.New(ErrPrimaryCurrencyNotFound)
 	}
 
-	if !SecondCurrencyExists(exchange, p) {
+	b, ok := a[fp.SecondCurrency]
+	if !ok {
 		return Price{}, errors.New(ErrSecondaryCurrencyNotFound)
 	}
 
-	return ticker.Price[p.FirstCurrency][p.SecondCurrency][tickerType], nil
+	return b[tickerType], nil
 }
 
-// GetTickerByExchange returns an exchange Ticker
+// GetTickerByExchange returns a copy of the tickers of an exchange
 func GetTickerByExchange(exchange string) (*Ticker, error) {
-	for _, y := range Tickers {
-		if y.ExchangeName == exchange {
-			return &y, nil
+	tickers.m.RLock()
+	defer tickers.m.RUnlock()
+
+	ticker, ok := tickers.exchanges[exchange]
+	if !ok {
+		return nil, errors.New(ErrTickerForExchangeNotFound)
+	}
+	result := ticker.copy()
+	return &result, nil
+}
+
+// GetTickers returns a copy of the tickers of every exchange
+func GetTickers() []Ticker {
+	tickers.m.RLock()
+	defer tickers.m.RUnlock()
+
+	var result []Ticker
+	for _, x := range tickers.exchanges {
+		result = append(result, x.copy())
+	}
+	return result
+}
+
+func (t *Ticker) copy() Ticker {
+	result := Ticker{
+		ExchangeName: t.ExchangeName,
+		Price:        make(map[pair.CurrencyItem]map[pair.CurrencyItem]map[string]Price),
+	}
+	for first, a := range t.Price {
+		result.Price[first] = make(map[pair.CurrencyItem]map[string]Price)
+		for second, b := range a {
+			result.Price[first][second] = make(map[string]Price)
+			for tickerType, price := range b {
+				result.Price[first][second][tickerType] = price
+			}
 		}
 	}
-	return nil, errors.New(ErrTickerForExchangeNotFound)
+	return result
 }
 
 // FirstCurrencyExists checks to see if the first currency of the Price map
 // exists
 func FirstCurrencyExists(exchange string, currency pair.CurrencyItem) bool {
-	for _, y := range Tickers {
-		if y.ExchangeName == exchange {
-			if _, ok := y.Price[currency]; ok {
-				return true
-			}
+	tickers.m.RLock()
+	defer tickers.m.RUnlock()
+
+	if ticker, ok := tickers.exchanges[exchange]; ok {
+		if _, ok := ticker.Price[currency.Upper()]; ok {
+			return true
 		}
 	}
 	return false
@@ -109,61 +179,103 @@ func FirstCurrencyExists(exchange string, currency pair.CurrencyItem) bool {
 // SecondCurrencyExists checks to see if the second currency of the Price map
 // exists
 func SecondCurrencyExists(exchange string, p pair.CurrencyPair) bool {
-	for _, y := range Tickers {
-		if y.ExchangeName == exchange {
-			if _, ok := y.Price[p.GetFirstCurrency()]; ok {
-				if _, ok := y.Price[p.GetFirstCurrency()][p.GetSecondCurrency()]; ok {
-					return true
-				}
+	tickers.m.RLock()
+	defer tickers.m.RUnlock()
+
+	fp := formatPair(p)
+	if ticker, ok := tickers.exchanges[exchange]; ok {
+		if _, ok := ticker.Price[fp.FirstCurrency]; ok {
+			if _, ok := ticker.Price[fp.FirstCurrency][fp.SecondCurrency]; ok {
+				return true
 			}
 		}
 	}
 	return false
 }
 
-// CreateNewTicker creates a new Ticker
+// CreateNewTicker stores a ticker for an exchange and returns the stored
+// tickers of the exchange
 func CreateNewTicker(exchangeName string, p pair.CurrencyPair, tickerNew Price, tickerType string) Ticker {
-	ticker := Ticker{}
-	ticker.ExchangeName = exchangeName
-	ticker.Price = make(map[pair.CurrencyItem]map[pair.CurrencyItem]map[string]Price)
-	a := make(map[pair.CurrencyItem]map[string]Price)
-	b := make(map[string]Price)
-	b[tickerType] = tickerNew
-	a[p.SecondCurrency] = b
-	ticker.Price[p.FirstCurrency] = a
-	Tickers = append(Tickers, ticker)
-	return ticker
+	ProcessTicker(exchangeName, p, tickerNew, tickerType)
+	ticker, _ := GetTickerByExchange(exchangeName)
+	return *ticker
 }
 
-// ProcessTicker processes incoming tickers, creating or updating the Tickers
-// list
+// ProcessTicker processes incoming tickers, storing them in the store and the
+// ticker history and notifying subscribers if the ticker changed
 func ProcessTicker(exchangeName string, p pair.CurrencyPair, tickerNew Price, tickerType string) {
 	tickerNew.CurrencyPair = p.Pair().String()
-	if len(Tickers) == 0 {
-		CreateNewTicker(exchangeName, p, tickerNew, tickerType)
-		return
+	tickerNew.LastUpdated = time.Now()
+	fp := formatPair(p)
+
+	tickers.m.Lock()
+	defer tickers.m.Unlock()
+
+	ticker, ok := tickers.exchanges[exchangeName]
+	if !ok {
+		ticker = &Ticker{
+			ExchangeName: exchangeName,
+			Price:        make(map[pair.CurrencyItem]map[pair.CurrencyItem]map[string]Price),
+		}
+		tickers.exchanges[exchangeName] = ticker
 	}
 
-	ticker, err := GetTickerByExchange(exchangeName)
-	if err != nil {
-		CreateNewTicker(exchangeName, p, tickerNew, tickerType)
+	a, ok := ticker.Price[fp.FirstCurrency]
+	if !ok {
+		a = make(map[pair.CurrencyItem]map[string]Price)
+		ticker.Price[fp.FirstCurrency] = a
+	}
+
+	b, ok := a[fp.SecondCurrency]
+	if !ok {
+		b = make(map[string]Price)
+		a[fp.SecondCurrency] = b
+	}
+
+	old, ok := b[tickerType]
+	// Keep the highest price seen as the all time high
+	tickerNew.PriceATH = math.Max(tickerNew.PriceATH, math.Max(old.PriceATH,
+		math.Max(tickerNew.Last, tickerNew.High)))
+	b[tickerType] = tickerNew
+	addHistory(exchangeName, fp, tickerType, tickerNew)
+
+	old.LastUpdated = tickerNew.LastUpdated
+	if ok && old == tickerNew {
 		return
 	}
 
-	if FirstCurrencyExists(exchangeName, p.FirstCurrency) {
-		if !SecondCurrencyExists(exchangeName, p) {
-			a := ticker.Price[p.FirstCurrency]
-			b := make(map[string]Price)
-			b[tickerType] = tickerNew
-			a[p.SecondCurrency] = b
-			ticker.Price[p.FirstCurrency] = a
-			return
+	event := Event{Exchange: exchangeName, Pair: p, AssetType: tickerType, Price: tickerNew}
+	for _, ch := range tickers.subscribers {
+		select {
+		case ch <- event:
+		default:
 		}
 	}
+}
 
-	a := make(map[pair.CurrencyItem]map[string]Price)
-	b := make(map[string]Price)
-	b[tickerType] = tickerNew
-	a[p.SecondCurrency] = b
-	ticker.Price[p.FirstCurrency] = a
+// Subscribe returns a channel which receives every subsequent ticker change,
+// events are dropped rather than blocking exchanges if the subscriber falls
+// behind
+func Subscribe() <-chan Event {
+	tickers.m.Lock()
+	defer tickers.m.Unlock()
+
+	ch := make(chan Event, SubscriberBufferSize)
+	tickers.subscribers = append(tickers.subscribers, ch)
+	return ch
+}
+
+// Unsubscribe stops ticker changes being sent to a channel returned by
+// Subscribe and closes it
+func Unsubscribe(ch <-chan Event) {
+	tickers.m.Lock()
+	defer tickers.m.Unlock()
+
+	for i, x := range tickers.subscribers {
+		if x == ch {
+			tickers.subscribers = append(tickers.subscribers[:i], tickers.subscribers[i+1:]...)
+			close(x)
+			return
+		}
+	}
 }
@@ -0,0 +1,202 @@
+package ticker
+
+import (
+	"errors"
+	"math"
+	"time"
+
+	"github.com/mattkanwisher/cryptofiend/currency/pair"
+)
+
+// Const values for the ticker history
+const (
+	ErrTickerHistoryNotFound     = "Ticker history for exchange does not exist."
+	ErrInsufficientTickerHistory = "Not enough ticker history for the requested window."
+
+	// DefaultHistorySize holds 24 hours of snapshots at the default resolution
+	DefaultHistorySize = 8640
+	// DefaultHistoryResolution is the minimum time between stored snapshots,
+	// later updates within it replace the latest snapshot
+	DefaultHistoryResolution = time.Second * 10
+)
+
+// Vars for the ticker history
+var (
+	HistorySize       = DefaultHistorySize
+	HistoryResolution = DefaultHistoryResolution
+)
+
+// Snapshot is a point in the history of a ticker
+type Snapshot struct {
+	Last      float64   `json:"last"`
+	Bid       float64   `json:"bid"`
+	Ask       float64   `json:"ask"`
+	Volume    float64   `json:"volume"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// Change holds statistics of the last price of a ticker over a window of its
+// history. Volatility is the standard deviation of the log returns between
+// snapshots, it isn't annualised.
+type Change struct {
+	Window     time.Duration `json:"window"`
+	From       float64       `json:"from"`
+	To         float64       `json:"to"`
+	Percent    float64       `json:"percent"`
+	High       float64       `json:"high"`
+	Low        float64       `json:"low"`
+	Volatility float64       `json:"volatility"`
+	Samples    int           `json:"samples"`
+	Start      time.Time     `json:"start"`
+	End        time.Time     `json:"end"`
+}
+
+type historyKey struct {
+	exchange   string
+	first      pair.CurrencyItem
+	second     pair.CurrencyItem
+	tickerType string
+}
+
+// history is a ring buffer of the snapshots of a ticker, oldest first. It
+// grows as snapshots are added until it holds size of them, then wraps
+// around, so tickers that are rarely updated don't reserve the whole buffer.
+type history struct {
+	items []Snapshot
+	size  int
+	start int
+}
+
+func newHistory(size int) *history {
+	if size < 1 {
+		size = 1
+	}
+	return &history{size: size}
+}
+
+func (h *history) len() int {
+	return len(h.items)
+}
+
+func (h *history) at(i int) *Snapshot {
+	return &h.items[(h.start+i)%len(h.items)]
+}
+
+func (h *history) add(s Snapshot) {
+	if h.len() > 0 {
+		latest := h.at(h.len() - 1)
+		if s.Timestamp.Sub(latest.Timestamp) < HistoryResolution {
+			*latest = s
+			return
+		}
+	}
+
+	if h.len() < h.size {
+		h.items = append(h.items, s)
+		return
+	}
+	h.items[h.start] = s
+	h.start = (h.start + 1) % len(h.items)
+}
+
+// since returns the snapshots taken at or after t, oldest first
+func (h *history) since(t time.Time) []Snapshot {
+	var result []Snapshot
+	for i := 0; i < h.len(); i++ {
+		s := h.at(i)
+		if !s.Timestamp.Before(t) {
+			result = append(result, *s)
+		}
+	}
+	return result
+}
+
+// Records a ticker in its history, the caller must hold the store lock
+func addHistory(exchange string, p pair.CurrencyPair, tickerType string, price Price) {
+	key := historyKey{exchange, p.FirstCurrency, p.SecondCurrency, tickerType}
+	h, ok := tickers.history[key]
+	if !ok {
+		h = newHistory(HistorySize)
+		tickers.history[key] = h
+	}
+	h.add(Snapshot{
+		Last:      price.Last,
+		Bid:       price.Bid,
+		Ask:       price.Ask,
+		Volume:    price.Volume,
+		Timestamp: price.LastUpdated,
+	})
+}
+
+// GetHistory returns the snapshots of a ticker taken within the window,
+// oldest first
+func GetHistory(exchange string, p pair.CurrencyPair, tickerType string, window time.Duration) ([]Snapshot, error) {
+	tickers.m.RLock()
+	defer tickers.m.RUnlock()
+
+	fp := formatPair(p)
+	h, ok := tickers.history[historyKey{exchange, fp.FirstCurrency, fp.SecondCurrency, tickerType}]
+	if !ok {
+		return nil, errors.New(ErrTickerHistoryNotFound)
+	}
+	return h.since(time.Now().Add(-window)), nil
+}
+
+// GetChange returns the change, range and volatility of the last price of a
+// ticker over the window
+func GetChange(exchange string, p pair.CurrencyPair, tickerType string, window time.Duration) (Change, error) {
+	snapshots, err := GetHistory(exchange, p, tickerType, window)
+	if err != nil {
+		return Change{}, err
+	}
+	return calculateChange(snapshots, window)
+}
+
+func calculateChange(snapshots []Snapshot, window time.Duration) (Change, error) {
+	// Prices of zero are sent by exchanges that haven't traded yet
+	var prices []Snapshot
+	for _, x := range snapshots {
+		if x.Last > 0 {
+			prices = append(prices, x)
+		}
+	}
+	if len(prices) < 2 {
+		return Change{}, errors.New(ErrInsufficientTickerHistory)
+	}
+
+	first := prices[0]
+	last := prices[len(prices)-1]
+	change := Change{
+		Window:  window,
+		From:    first.Last,
+		To:      last.Last,
+		Percent: (last.Last - first.Last) / first.Last * 100,
+		High:    first.Last,
+		Low:     first.Last,
+		Samples: len(prices),
+		Start:   first.Timestamp,
+		End:     last.Timestamp,
+	}
+
+	var returns []float64
+	for i, x := range prices {
+		change.High = math.Max(change.High, x.Last)
+		change.Low = math.Min(change.Low, x.Last)
+		if i > 0 {
+			returns = append(returns, math.Log(x.Last/prices[i-1].Last))
+		}
+	}
+
+	mean := float64(0)
+	for _, x := range returns {
+		mean += x
+	}
+	mean /= float64(len(returns))
+
+	variance := float64(0)
+	for _, x := range returns {
+		variance += (x - mean) * (x - mean)
+	}
+	change.Volatility = math.Sqrt(variance / float64(len(returns)))
+	return change, nil
+}
@@ -0,0 +1,87 @@
+package ticker
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/mattkanwisher/cryptofiend/currency/pair"
+)
+
+func TestHistoryRingBuffer(t *testing.T) {
+	t.Parallel()
+	h := newHistory(3)
+	if cap(h.items) != 0 {
+		t.Errorf("Test failed. TestHistoryRingBuffer allocated %d snapshots up front", cap(h.items))
+	}
+	now := time.Now()
+	h.add(Snapshot{Last: 0, Timestamp: now})
+	if h.len() != 1 || h.at(0).Last != 0 {
+		t.Errorf("Test failed. TestHistoryRingBuffer unexpected buffer %+v", h)
+	}
+	for i := 1; i < 5; i++ {
+		h.add(Snapshot{Last: float64(i), Timestamp: now.Add(time.Duration(i) * time.Minute)})
+	}
+	if h.len() != 3 || h.at(0).Last != 2 || h.at(2).Last != 4 {
+		t.Errorf("Test failed. TestHistoryRingBuffer unexpected buffer %+v", h)
+	}
+
+	// Updates within the resolution replace the latest snapshot
+	h.add(Snapshot{Last: 5, Timestamp: now.Add(time.Minute*4 + time.Second)})
+	if h.len() != 3 || h.at(2).Last != 5 {
+		t.Errorf("Test failed. TestHistoryRingBuffer snapshot wasn't replaced %+v", h)
+	}
+
+	result := h.since(now.Add(time.Minute * 3))
+	if len(result) != 2 || result[0].Last != 3 {
+		t.Errorf("Test failed. TestHistoryRingBuffer unexpected window %+v", result)
+	}
+}
+
+func TestCalculateChange(t *testing.T) {
+	t.Parallel()
+	now := time.Now()
+	snapshots := []Snapshot{
+		{Last: 100, Timestamp: now},
+		{Last: 0, Timestamp: now.Add(time.Minute)},
+		{Last: 110, Timestamp: now.Add(time.Minute * 2)},
+		{Last: 90, Timestamp: now.Add(time.Minute * 3)},
+		{Last: 105, Timestamp: now.Add(time.Minute * 4)},
+	}
+
+	change, err := calculateChange(snapshots, time.Hour)
+	if err != nil {
+		t.Fatalf("Test failed. TestCalculateChange error: %s", err)
+	}
+	if math.Abs(change.Percent-5) > 1e-9 || change.High != 110 || change.Low != 90 || change.Samples != 4 {
+		t.Errorf("Test failed. TestCalculateChange unexpected change %+v", change)
+	}
+	if change.Volatility <= 0 {
+		t.Error("Test failed. TestCalculateChange expected volatility")
+	}
+
+	_, err = calculateChange(snapshots[:2], time.Hour)
+	if err == nil {
+		t.Error("Test failed. TestCalculateChange expected insufficient history error")
+	}
+}
+
+func TestGetChange(t *testing.T) {
+	newPair := pair.NewCurrencyPair("XRP", "USD")
+	ProcessTicker("history", newPair, Price{Pair: newPair, Last: 1, High: 2}, Spot)
+
+	_, err := GetChange("history", newPair, Spot, time.Hour)
+	if err == nil {
+		t.Error("Test failed. TestGetChange expected insufficient history error")
+	}
+
+	result, err := GetTicker("history", newPair, Spot)
+	if err != nil || result.PriceATH != 2 {
+		t.Errorf("Test failed. TestGetChange expected ATH of 2, got %f", result.PriceATH)
+	}
+
+	_, err = GetHistory("history", pair.NewCurrencyPair("XRP", "EUR"), Spot, time.Hour)
+	if err == nil {
+		t.Error("Test failed. TestGetChange expected history not found error")
+	}
+}
@@ -115,8 +115,7 @@ func TestGetTickerByExchange(t *testing.T) {
 		PriceATH:     1337,
 	}
 
-	anxTicker := CreateNewTicker("ANX", newPair, priceStruct, Spot)
-	Tickers = append(Tickers, anxTicker)
+	CreateNewTicker("ANX", newPair, priceStruct, Spot)
 
 	tickerPtr, err := GetTickerByExchange("ANX")
 	if err != nil {
@@ -141,8 +140,7 @@ func TestFirstCurrencyExists(t *testing.T) {
 		PriceATH:     1337,
 	}
 
-	alphaTicker := CreateNewTicker("alphapoint", newPair, priceStruct, Spot)
-	Tickers = append(Tickers, alphaTicker)
+	CreateNewTicker("alphapoint", newPair, priceStruct, Spot)
 
 	if !FirstCurrencyExists("alphapoint", "BTC") {
 		t.Error("Test Failed - FirstCurrencyExists1 value return is incorrect")
@@ -168,8 +166,7 @@ func TestSecondCurrencyExists(t *testing.T) {
 		PriceATH:     1337,
 	}
 
-	bitstampTicker := CreateNewTicker("bitstamp", newPair, priceStruct, "SPOT")
-	Tickers = append(Tickers, bitstampTicker)
+	CreateNewTicker("bitstamp", newPair, priceStruct, "SPOT")
 
 	if !SecondCurrencyExists("bitstamp", newPair) {
 		t.Error("Test Failed - SecondCurrencyExists1 value return is incorrect")
@@ -237,7 +234,6 @@ func TestCreateNewTicker(t *testing.T) {
 }
 
 func TestProcessTicker(t *testing.T) { //non-appending function to tickers
-	Tickers = []Ticker{}
 	newPair := pair.NewCurrencyPair("BTC", "USD")
 	priceStruct := Price{
 		Pair:         newPair,
@@ -276,3 +272,39 @@ func TestProcessTicker(t *testing.T) { //non-appending function to tickers
 		t.Fatal("Test failed. TestProcessTicker failed to return an existing ticker")
 	}
 }
+
+func TestSubscribe(t *testing.T) {
+	newPair := pair.NewCurrencyPair("ltc", "usd")
+	priceStruct := Price{Pair: newPair, Last: 50}
+
+	ch := Subscribe()
+	ProcessTicker("kraken", newPair, priceStruct, Spot)
+
+	event := <-ch
+	if event.Exchange != "kraken" || event.Price.Last != 50 || event.AssetType != Spot {
+		t.Errorf("Test failed. TestSubscribe unexpected event %+v", event)
+	}
+	if event.Price.LastUpdated.IsZero() {
+		t.Error("Test failed. TestSubscribe LastUpdated not set")
+	}
+
+	// Unchanged tickers are stored without notifying subscribers
+	ProcessTicker("kraken", newPair, priceStruct, Spot)
+	select {
+	case event = <-ch:
+		t.Errorf("Test failed. TestSubscribe unexpected event for unchanged ticker %+v", event)
+	default:
+	}
+
+	result, err := GetTicker("kraken", pair.NewCurrencyPair("LTC", "USD"), Spot)
+	if err != nil || result.Last != 50 {
+		t.Errorf("Test failed. TestSubscribe ticker lookup isn't case insensitive %v", err)
+	}
+
+	Unsubscribe(ch)
+	priceStruct.Last = 51
+	ProcessTicker("kraken", newPair, priceStruct, Spot)
+	if _, ok := <-ch; ok {
+		t.Error("Test failed. TestSubscribe received event after unsubscribing")
+	}
+}
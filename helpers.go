@@ -3,12 +3,20 @@ package main
 import (
 	"errors"
 	"fmt"
+	"log"
+	"sort"
+	"time"
 
+	"github.com/mattkanwisher/cryptofiend/currency"
 	"github.com/mattkanwisher/cryptofiend/currency/pair"
+	"github.com/mattkanwisher/cryptofiend/events"
 	exchange "github.com/mattkanwisher/cryptofiend/exchanges"
+	"github.com/mattkanwisher/cryptofiend/exchanges/arbitrage"
 	"github.com/mattkanwisher/cryptofiend/exchanges/orderbook"
 	"github.com/mattkanwisher/cryptofiend/exchanges/stats"
 	"github.com/mattkanwisher/cryptofiend/exchanges/ticker"
+	"github.com/mattkanwisher/cryptofiend/portfolio"
+	"github.com/mattkanwisher/cryptofiend/portfolio/rebalance"
 )
 
 // GetSpecificOrderbook returns a specific orderbook given the currency,
@@ -30,6 +38,140 @@ func GetSpecificOrderbook(currency, exchangeName, assetType string) (orderbook.B
 	return specificOrderbook, err
 }
 
+// GetCachedOrderbook returns the orderbook of an enabled exchange as last
+// updated by the orderbook updater, without fetching it
+func GetCachedOrderbook(exchangeName string, p pair.CurrencyPair, assetType string) (orderbook.Base, error) {
+	for _, exch := range bot.exchanges {
+		if exch != nil && exch.IsEnabled() && exch.GetName() == exchangeName {
+			return exch.GetOrderbookSimple(p, assetType)
+		}
+	}
+	return orderbook.Base{}, errors.New(exchange.ErrExchangeNotFound)
+}
+
+// GetTradingExchange returns an enabled exchange which supports placing orders
+// with authenticated API support
+func GetTradingExchange(exchangeName string) (exchange.IBotExchangeEx, error) {
+	for _, exch := range bot.exchanges {
+		if exch == nil || !exch.IsEnabled() || exch.GetName() != exchangeName {
+			continue
+		}
+		exchEx, ok := exch.(exchange.IBotExchangeEx)
+		if !ok || !exch.GetAuthenticatedAPISupport() {
+			return nil, fmt.Errorf("%s does not support trading", exchangeName)
+		}
+		return exchEx, nil
+	}
+	return nil, errors.New(exchange.ErrExchangeNotFound)
+}
+
+// GetRebalancePlan works out the trades which bring the portfolio back to the
+// target allocations in the config. Holdings include offline addresses, but
+// trades are limited to the balances available on enabled exchanges which
+// support trading.
+func GetRebalancePlan() (rebalance.Plan, error) {
+	cfg := bot.config.Rebalance
+	var targets []rebalance.Target
+	for coin, weight := range cfg.Targets {
+		tolerance, ok := cfg.Tolerances[coin]
+		if !ok {
+			tolerance = cfg.Tolerance
+		}
+		targets = append(targets, rebalance.Target{Coin: coin, Weight: weight, Tolerance: tolerance})
+	}
+	sort.Slice(targets, func(i, j int) bool {
+		return targets[i].Coin < targets[j].Coin
+	})
+
+	input := rebalance.Input{
+		Currency: bot.config.FiatDisplayCurrency,
+		Holdings: make(map[string]float64),
+		Prices:   make(map[string]float64),
+		Balances: make(map[string]map[string]float64),
+	}
+	for _, x := range bot.portfolio.GetPortfolioSummary().Totals {
+		input.Holdings[pair.CurrencyItem(x.Coin).Normalise().String()] += x.Balance
+	}
+	for _, x := range targets {
+		price, err := portfolio.GetFiatPrice(x.Coin, input.Currency)
+		if err == nil {
+			input.Prices[x.Coin] = price
+		}
+	}
+
+	for _, exch := range bot.exchanges {
+		if exch == nil || !exch.IsEnabled() || !exch.GetAuthenticatedAPISupport() {
+			continue
+		}
+		exchEx, ok := exch.(exchange.IBotExchangeEx)
+		if !ok {
+			continue
+		}
+
+		info, err := exch.GetExchangeAccountInfo()
+		if err != nil {
+			log.Printf("Rebalance: unable to get %s balances. Error: %s\n", exch.GetName(), err)
+			continue
+		}
+		balances := make(map[string]float64)
+		for _, x := range info.Currencies {
+			balances[pair.CurrencyItem(x.CurrencyName).Normalise().String()] += x.Available
+		}
+		input.Balances[exch.GetName()] = balances
+
+		limits := exchEx.GetLimits()
+		if limits == nil {
+			limits = &exchange.DefaultExchangeLimits{}
+		}
+		for _, x := range exch.GetEnabledCurrencies() {
+			ob, err := exch.GetOrderbookSimple(x, orderbook.Spot)
+			if err != nil || len(ob.Bids) == 0 || len(ob.Asks) == 0 {
+				continue
+			}
+			input.Markets = append(input.Markets, rebalance.Market{
+				Exchange:       exch.GetName(),
+				Pair:           x,
+				Bid:            ob.Bids[0].Price,
+				Ask:            ob.Asks[0].Price,
+				MinAmount:      limits.GetMinAmount(x),
+				MinTotal:       limits.GetMinTotal(x),
+				AmountDecimals: limits.GetAmountDecimalPlaces(x),
+				TakerFee:       getTakerFee(exch),
+			})
+		}
+	}
+	return rebalance.NewPlan(targets, input)
+}
+
+// ExecuteRebalancePlan places the orders of a rebalancing plan in turn and
+// returns their IDs. Remaining trades aren't placed if an order fails.
+func ExecuteRebalancePlan(plan rebalance.Plan) ([]string, error) {
+	var orderIDs []string
+	for _, t := range plan.Trades {
+		exch, err := GetTradingExchange(t.Exchange)
+		if err != nil {
+			return orderIDs, err
+		}
+		id, err := rebalance.Execute(exch, t)
+		if err != nil {
+			return orderIDs, err
+		}
+		orderIDs = append(orderIDs, id)
+	}
+	return orderIDs, nil
+}
+
+var errLedgerDisabled = errors.New("ledger is not enabled")
+
+var errRebalanceExecuteDisabled = errors.New("rebalance plan execution is not enabled")
+
+// updateEventsConfig copies the events into the config and saves it, so that
+// changes to the events survive a restart
+func updateEventsConfig() error {
+	bot.config.Events.Events = events.GetEventConfigs()
+	return bot.config.SaveConfig(bot.configFile)
+}
+
 // GetSpecificTicker returns a specific ticker given the currency,
 // exchangeName and assetType
 func GetSpecificTicker(currency, exchangeName, assetType string) (ticker.Price, error) {
@@ -105,3 +247,173 @@ func GetExchangeLowestPriceByCurrencyPair(p pair.CurrencyPair, assetType string)
 
 	return result[0].Exchange, nil
 }
+
+// getTakerFee returns the taker fee percentage of an exchange, or zero if it
+// doesn't have one
+func getTakerFee(exch exchange.IBotExchange) float64 {
+	if fees, ok := exch.(interface {
+		GetTakerFee() float64
+	}); ok {
+		return fees.GetTakerFee()
+	}
+	return 0
+}
+
+// GetConsolidatedOrderbook merges the orderbooks of every enabled exchange
+// trading the base currency of the pair. Books quoted in a different fiat
+// currency are converted to the quote currency of the pair, and if
+// feeAdjusted is set prices include each exchange's taker fee.
+func GetConsolidatedOrderbook(p pair.CurrencyPair, assetType string, feeAdjusted bool) (orderbook.ConsolidatedBase, error) {
+	p = p.Normalise()
+
+	var sources []orderbook.ConsolidationSource
+	for _, exch := range bot.exchanges {
+		if exch == nil || !exch.IsEnabled() {
+			continue
+		}
+
+		for _, x := range exch.GetEnabledCurrencies() {
+			if x.FirstCurrency.Normalise() != p.FirstCurrency {
+				continue
+			}
+
+			rate := float64(1)
+			exchQuote := x.SecondCurrency.Normalise().String()
+			quote := p.SecondCurrency.String()
+			if exchQuote != quote {
+				if !currency.IsFiatCurrency(exchQuote) || !currency.IsFiatCurrency(quote) {
+					continue
+				}
+				var err error
+				rate, err = currency.ConvertCurrency(1, exchQuote, quote)
+				if err != nil && !currency.IsStale(err) {
+					log.Printf("Consolidated orderbook: unable to convert %s to %s. Error: %s\n",
+						exchQuote, quote, err)
+					continue
+				}
+			}
+
+			ob, err := exch.GetOrderbookSimple(x, assetType)
+			if err != nil {
+				continue
+			}
+
+			sources = append(sources, orderbook.ConsolidationSource{
+				Exchange:  exch.GetName(),
+				Orderbook: ob,
+				Rate:      rate,
+				TakerFee:  getTakerFee(exch),
+			})
+		}
+	}
+
+	if len(sources) == 0 {
+		return orderbook.ConsolidatedBase{}, errors.New(orderbook.ErrOrderbookForExchangeNotFound)
+	}
+	return orderbook.Consolidate(p, sources, feeAdjusted), nil
+}
+
+// ScanArbitrage scans the cached orderbooks of every currency pair enabled on
+// more than one exchange for arbitrage opportunities and stores the result
+func ScanArbitrage() {
+	pairs := make(map[pair.CurrencyItem]pair.CurrencyPair)
+	venues := make(map[pair.CurrencyItem][]arbitrage.Venue)
+
+	for _, exch := range bot.exchanges {
+		if exch == nil || !exch.IsEnabled() {
+			continue
+		}
+
+		for _, x := range exch.GetEnabledCurrencies() {
+			ob, err := exch.GetOrderbookSimple(x, orderbook.Spot)
+			if err != nil {
+				continue
+			}
+
+			p := x.Normalise()
+			key := p.Pair()
+			pairs[key] = p
+			venues[key] = append(venues[key], arbitrage.Venue{
+				Exchange:      exch.GetName(),
+				Orderbook:     ob,
+				TakerFee:      getTakerFee(exch),
+				WithdrawalFee: bot.config.Arbitrage.WithdrawalFees[p.FirstCurrency.String()],
+			})
+		}
+	}
+
+	for key, x := range venues {
+		if len(x) < 2 {
+			continue
+		}
+		result := arbitrage.Scan(pairs[key], x, bot.config.Arbitrage.MinProfitPercent)
+		arbitrage.SetOpportunities(pairs[key], result)
+	}
+}
+
+// triangularFillTimeout is how long each leg of a triangular arbitrage cycle
+// is given to fill before it is cancelled
+const triangularFillTimeout = time.Second * 30
+
+// ScanTriangularArbitrage searches the recently updated orderbooks of each
+// exchange for triangular arbitrage cycles and stores the result. The most
+// profitable cycle is traded in the background if enabled in the config,
+// unless a cycle is already being traded on the exchange or the same cycle
+// was traded within the cooldown.
+func ScanTriangularArbitrage() {
+	maxAge := bot.config.Arbitrage.TriangularMaxBookAge * time.Second
+	for _, exch := range bot.exchanges {
+		if exch == nil || !exch.IsEnabled() {
+			continue
+		}
+
+		var limits exchange.ILimits
+		exchEx, ok := exch.(exchange.IBotExchangeEx)
+		if ok {
+			limits = exchEx.GetLimits()
+		}
+
+		var markets []arbitrage.Market
+		for _, x := range exch.GetEnabledCurrencies() {
+			ob, err := exch.GetOrderbookSimple(x, orderbook.Spot)
+			if err != nil || time.Since(ob.LastUpdated) > maxAge {
+				continue
+			}
+
+			market := arbitrage.Market{Pair: x, Orderbook: ob}
+			if limits != nil {
+				market.MinAmount = limits.GetMinAmount(x)
+				market.MinTotal = limits.GetMinTotal(x)
+			}
+			markets = append(markets, market)
+		}
+		if len(markets) < 3 {
+			continue
+		}
+
+		result := arbitrage.FindCycles(exch.GetName(), markets, getTakerFee(exch),
+			bot.config.Arbitrage.MinProfitPercent)
+		arbitrage.SetCycles(exch.GetName(), result)
+
+		if len(result) == 0 || !ok || !bot.config.Arbitrage.ExecuteTriangular ||
+			!exch.GetAuthenticatedAPISupport() {
+			continue
+		}
+
+		cycle := result[0]
+		if !arbitrage.StartExecution(cycle, bot.config.Arbitrage.TriangularCooldown*time.Second) {
+			continue
+		}
+		go func() {
+			defer arbitrage.FinishExecution(cycle)
+			orderIDs, err := arbitrage.ExecuteCycle(exchEx, cycle, triangularFillTimeout)
+			if err != nil {
+				log.Printf("%s failed to execute triangular arbitrage cycle, orders: %v. Error: %s\n",
+					cycle.Exchange, orderIDs, err)
+				return
+			}
+			log.Printf("%s executed triangular arbitrage cycle from %s, orders: %v\n",
+				cycle.Exchange, cycle.Currency, orderIDs)
+		}()
+	}
+}
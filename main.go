@@ -9,10 +9,12 @@ import (
 	"runtime"
 	"strconv"
 	"syscall"
+	"time"
 
 	"github.com/mattkanwisher/cryptofiend/common"
 	"github.com/mattkanwisher/cryptofiend/config"
 	"github.com/mattkanwisher/cryptofiend/currency"
+	"github.com/mattkanwisher/cryptofiend/events"
 	"github.com/mattkanwisher/cryptofiend/exchanges"
 	"github.com/mattkanwisher/cryptofiend/exchanges/bitfinex"
 	"github.com/mattkanwisher/cryptofiend/exchanges/bitstamp"
@@ -32,8 +34,10 @@ import (
 	"github.com/mattkanwisher/cryptofiend/exchanges/poloniex"
 	"github.com/mattkanwisher/cryptofiend/exchanges/ticker"
 	"github.com/mattkanwisher/cryptofiend/exchanges/wex"
+	"github.com/mattkanwisher/cryptofiend/notifications"
 	"github.com/mattkanwisher/cryptofiend/portfolio"
 	"github.com/mattkanwisher/cryptofiend/smsglobal"
+	"github.com/mattkanwisher/cryptofiend/webhooks"
 )
 
 // ExchangeMain contains all the necessary exchange packages
@@ -63,7 +67,8 @@ type ExchangeMain struct {
 type Bot struct {
 	config     *config.Config
 	smsglobal  *smsglobal.Base
-	portfolio  *portfolio.Base
+	portfolio  *portfolio.Service
+	ledger     *portfolio.Ledger
 	exchange   ExchangeMain
 	exchanges  []exchange.IBotExchange
 	tickers    []ticker.Ticker
@@ -129,6 +134,9 @@ func main() {
 		log.Println("SMS support disabled.")
 	}
 
+	notifications.Setup(bot.config.Notifications, bot.smsglobal)
+	webhooks.Setup(bot.config.Webhooks)
+
 	log.Printf(
 		"Available Exchanges: %d. Enabled Exchanges: %d.\n",
 		len(bot.config.Exchanges), bot.config.GetConfigEnabledExchanges(),
@@ -168,38 +176,92 @@ func main() {
 
 	setupBotExchanges()
 
-	if bot.config.CurrencyExchangeProvider == "yahoo" {
-		currency.SetProvider(true)
-	} else {
-		currency.SetProvider(false)
+	err = currency.SetupProviders(bot.config.CurrencyRates.Providers,
+		time.Second*bot.config.CurrencyRates.RefreshInterval,
+		time.Second*bot.config.CurrencyRates.StaleAfter)
+	if err != nil {
+		log.Fatalf("Fatal error setting up currency rate providers. Error: %s", err)
 	}
 
-	log.Printf("Using %s as currency exchange provider.", bot.config.CurrencyExchangeProvider)
-
 	bot.config.RetrieveConfigCurrencyPairs()
 	err = currency.SeedCurrencyData(currency.BaseCurrencies)
 	if err != nil {
-		currency.SwapProvider()
-		log.Printf("'%s' currency exchange provider failed, swapping to %s and testing..",
-			bot.config.CurrencyExchangeProvider, currency.GetProvider())
-		err = currency.SeedCurrencyData(currency.BaseCurrencies)
-		if err != nil {
-			log.Fatalf("Fatal error retrieving config currencies. Error: %s", err)
-		}
+		log.Fatalf("Fatal error retrieving config currencies. Error: %s", err)
 	}
 
-	log.Println("Successfully retrieved config currencies.")
+	log.Printf("Successfully retrieved config currencies from %s.", currency.GetProvider())
+	go currency.StartRatesWatcher()
+
+	events.GetOrderbook = GetCachedOrderbook
+	events.GetExchange = GetTradingExchange
+
+	portfolio.GapLimit = bot.config.BalanceProviders.GapLimit
+	portfolio.SetupTokens(bot.config.BalanceProviders.EthereumRPC, bot.config.BalanceProviders.Tokens)
+	err = portfolio.SetupProviders(bot.config.BalanceProviders.Coins,
+		time.Second*bot.config.BalanceProviders.CacheDuration)
+	if err != nil {
+		log.Printf("Failed to set up balance providers. Error: %s\n", err)
+	}
 
 	bot.portfolio = &portfolio.Portfolio
 	bot.portfolio.SeedPortfolio(bot.config.Portfolio)
 	SeedExchangeAccountInfo(GetAllEnabledExchangeAccountInfo().Data)
 	go portfolio.StartPortfolioWatcher()
 
+	if bot.config.PortfolioHistory.Enabled {
+		err = portfolio.LoadSnapshots(bot.config.PortfolioHistory.File,
+			time.Hour*24*time.Duration(bot.config.PortfolioHistory.MaxAge))
+		if err != nil {
+			log.Printf("Failed to load portfolio history. Error: %s\n", err)
+		}
+		go portfolio.StartSnapshotWatcher(bot.config.FiatDisplayCurrency,
+			time.Second*bot.config.PortfolioHistory.Interval)
+	}
+
+	if bot.config.Ledger.Enabled {
+		fiat := bot.config.Ledger.Currency
+		if fiat == "" {
+			fiat = bot.config.FiatDisplayCurrency
+		}
+		bot.ledger, err = portfolio.NewLedger(bot.config.Ledger.Method, fiat)
+		if err != nil {
+			log.Fatalf("Failed to create ledger. Error: %s", err)
+		}
+		err = bot.ledger.Load(bot.config.Ledger.File)
+		if err != nil {
+			log.Printf("Failed to load ledger. Error: %s\n", err)
+		}
+	}
+
+	events.LoadEvents(bot.config.Events.Events)
+	log.Printf("Loaded %d events.\n", len(bot.config.Events.Events))
+	go events.CheckEvents()
+
 	log.Println("Starting websocket handler")
 	go WebsocketHandler()
 
+	go TickerRelayRoutine()
 	go TickerUpdaterRoutine()
 	go OrderbookUpdaterRoutine()
+	go WebsocketStateRoutine()
+	go OrderTrackerRoutine()
+	go PortfolioSyncRoutine()
+
+	if webhooks.IsEnabled() {
+		go WebhookRoutine()
+	}
+
+	if bot.ledger != nil {
+		go LedgerRoutine()
+	}
+
+	if bot.config.Arbitrage.Enabled {
+		go ArbitrageRoutine()
+	}
+
+	if bot.config.Rebalance.Enabled {
+		go RebalanceRoutine()
+	}
 
 	if bot.config.Webserver.Enabled {
 		listenAddr := bot.config.Webserver.ListenAddress
@@ -254,7 +316,8 @@ func HandleInterrupt() {
 // Shutdown correctly shuts down bot saving configuration files
 func Shutdown() {
 	log.Println("Bot shutting down..")
-	bot.config.Portfolio = portfolio.Portfolio
+	bot.config.Portfolio = portfolio.Portfolio.Base()
+	bot.config.Events.Events = events.GetEventConfigs()
 	err := bot.config.SaveConfig(bot.configFile)
 
 	if err != nil {
@@ -267,50 +330,18 @@ func Shutdown() {
 	os.Exit(1)
 }
 
-// SeedExchangeAccountInfo seeds account info
+// SeedExchangeAccountInfo reconciles the exchange balances of the portfolio
+// with the account info of each exchange
 func SeedExchangeAccountInfo(data []exchange.AccountInfo) {
-	if len(data) == 0 {
-		return
-	}
-
-	port := portfolio.GetPortfolio()
-
 	for i := 0; i < len(data); i++ {
-		exchangeName := data[i].ExchangeName
+		info := portfolio.ExchangeAccountInfo{ExchangeName: data[i].ExchangeName}
 		for j := 0; j < len(data[i].Currencies); j++ {
-			currencyName := data[i].Currencies[j].CurrencyName
-			onHold := data[i].Currencies[j].Hold
-			avail := data[i].Currencies[j].TotalValue
-			total := onHold + avail
-
-			if !port.ExchangeAddressExists(exchangeName, currencyName) {
-				if total <= 0 {
-					continue
-				}
-				log.Printf("Portfolio: Adding new exchange address: %s, %s, %f, %s\n",
-					exchangeName, currencyName, total, portfolio.PortfolioAddressExchange)
-				port.Addresses = append(
-					port.Addresses,
-					portfolio.Address{Address: exchangeName, CoinType: currencyName,
-						Balance: total, Description: portfolio.PortfolioAddressExchange},
-				)
-			} else {
-				if total <= 0 {
-					log.Printf("Portfolio: Removing %s %s entry.\n", exchangeName,
-						currencyName)
-					port.RemoveExchangeAddress(exchangeName, currencyName)
-				} else {
-					balance, ok := port.GetAddressBalance(exchangeName, currencyName, portfolio.PortfolioAddressExchange)
-					if !ok {
-						continue
-					}
-					if balance != total {
-						log.Printf("Portfolio: Updating %s %s entry with balance %f.\n",
-							exchangeName, currencyName, total)
-						port.UpdateExchangeAddressBalance(exchangeName, currencyName, total)
-					}
-				}
-			}
+			info.Currencies = append(info.Currencies, portfolio.ExchangeAccountCurrencyInfo{
+				CurrencyName: data[i].Currencies[j].CurrencyName,
+				TotalValue:   data[i].Currencies[j].TotalValue,
+				Hold:         data[i].Currencies[j].Hold,
+			})
 		}
+		portfolio.GetPortfolio().SyncExchangeBalances(info)
 	}
 }
@@ -0,0 +1,278 @@
+package notifications
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/mattkanwisher/cryptofiend/common"
+	"github.com/mattkanwisher/cryptofiend/config"
+	"github.com/mattkanwisher/cryptofiend/smsglobal"
+)
+
+// Channel types which can be configured
+const (
+	TypeSMS      = "SMS"
+	TypeSMTP     = "SMTP"
+	TypeWebhook  = "WEBHOOK"
+	TypeSlack    = "SLACK"
+	TypeTelegram = "TELEGRAM"
+)
+
+// Alert types notifications are routed by
+const (
+	AlertEvent = "EVENT"
+)
+
+// SMSChannel is the name of the channel registered for SMSGlobal when the
+// config doesn't define an SMS channel of its own
+const SMSChannel = "SMS"
+
+// QueueSize is the number of messages held for each channel before further
+// messages are dropped
+const QueueSize = 256
+
+var (
+	errChannelNotFound = errors.New("notification channel not found")
+	errQueueFull       = errors.New("notification queue is full")
+	errInvalidType     = errors.New("invalid notification channel type")
+	errSMSDisabled     = errors.New("SMS support is disabled")
+)
+
+// RetryDelay is how long channels registered afterwards wait before resending
+// a message which failed, doubling after each attempt
+var RetryDelay = time.Second * 5
+
+// Message is a notification sent over one or more channels. To optionally
+// overrides the recipients configured for the channel.
+type Message struct {
+	AlertType string
+	Subject   string
+	Body      string
+	To        string
+	Timestamp time.Time
+}
+
+// String returns the message as a single line of text for channels which
+// have no subject
+func (m Message) String() string {
+	if m.Subject == "" {
+		return m.Body
+	}
+	return fmt.Sprintf("%s: %s", m.Subject, m.Body)
+}
+
+// Notifier is implemented by anything messages can be delivered through
+type Notifier interface {
+	Send(m Message) error
+}
+
+// channel delivers the messages queued for a notifier, waiting rateLimit
+// between messages
+type channel struct {
+	name       string
+	notifier   Notifier
+	rateLimit  time.Duration
+	maxRetries int
+	retryDelay time.Duration
+	queue      chan Message
+}
+
+var channels struct {
+	m      sync.RWMutex
+	byName map[string]*channel
+	alerts map[string][]string
+}
+
+// Register adds a notifier under name, replacing any channel registered with
+// the same name. Messages are sent no more often than once every rateLimit
+// and are retried up to maxRetries times if they fail.
+func Register(name string, n Notifier, rateLimit time.Duration, maxRetries int) {
+	c := &channel{
+		name:       name,
+		notifier:   n,
+		rateLimit:  rateLimit,
+		maxRetries: maxRetries,
+		retryDelay: RetryDelay,
+		queue:      make(chan Message, QueueSize),
+	}
+
+	channels.m.Lock()
+	if channels.byName == nil {
+		channels.byName = make(map[string]*channel)
+	}
+	if old, ok := channels.byName[name]; ok {
+		close(old.queue)
+	}
+	channels.byName[name] = c
+	channels.m.Unlock()
+
+	go c.run()
+}
+
+// Unregister removes a channel, messages already queued for it are still
+// delivered
+func Unregister(name string) {
+	channels.m.Lock()
+	defer channels.m.Unlock()
+
+	if c, ok := channels.byName[name]; ok {
+		close(c.queue)
+		delete(channels.byName, name)
+	}
+}
+
+// GetChannels returns the names of the registered channels
+func GetChannels() []string {
+	channels.m.RLock()
+	defer channels.m.RUnlock()
+
+	var result []string
+	for name := range channels.byName {
+		result = append(result, name)
+	}
+	sort.Strings(result)
+	return result
+}
+
+// SetAlertRoutes sets the channels each alert type is sent to by Notify.
+// Alert types without a route are sent to every channel.
+func SetAlertRoutes(routes map[string][]string) {
+	channels.m.Lock()
+	defer channels.m.Unlock()
+
+	channels.alerts = make(map[string][]string)
+	for alertType, names := range routes {
+		channels.alerts[common.StringToUpper(alertType)] = names
+	}
+}
+
+// Notify queues a message of an alert type on the channels it is routed to
+func Notify(alertType, subject, body string) {
+	m := Message{
+		AlertType: alertType,
+		Subject:   subject,
+		Body:      body,
+		Timestamp: time.Now(),
+	}
+
+	channels.m.RLock()
+	names, ok := channels.alerts[common.StringToUpper(alertType)]
+	if !ok {
+		for name := range channels.byName {
+			names = append(names, name)
+		}
+	}
+	channels.m.RUnlock()
+
+	for _, name := range names {
+		err := Send(name, m)
+		if err != nil {
+			log.Printf("Failed to queue %s notification on %s. Error: %s\n",
+				alertType, name, err)
+		}
+	}
+}
+
+// Send queues a message on a single channel
+func Send(name string, m Message) error {
+	if m.Timestamp.IsZero() {
+		m.Timestamp = time.Now()
+	}
+
+	channels.m.RLock()
+	defer channels.m.RUnlock()
+
+	c, ok := channels.byName[name]
+	if !ok {
+		return errChannelNotFound
+	}
+
+	select {
+	case c.queue <- m:
+		return nil
+	default:
+		return errQueueFull
+	}
+}
+
+// run delivers queued messages until the channel is unregistered
+func (c *channel) run() {
+	var last time.Time
+	for m := range c.queue {
+		if wait := c.rateLimit - time.Since(last); wait > 0 {
+			time.Sleep(wait)
+		}
+
+		err := c.notifier.Send(m)
+		delay := c.retryDelay
+		for i := 0; err != nil && i < c.maxRetries; i++ {
+			log.Printf("Failed to send notification on %s, retrying in %s. Error: %s\n",
+				c.name, delay, err)
+			time.Sleep(delay)
+			delay *= 2
+			err = c.notifier.Send(m)
+		}
+		if err != nil {
+			log.Printf("Failed to send notification on %s. Error: %s\n", c.name, err)
+		}
+		last = time.Now()
+	}
+}
+
+// New returns the notifier for a channel config
+func New(cfg config.NotifierConfig, sms *smsglobal.Base) (Notifier, error) {
+	switch common.StringToUpper(cfg.Type) {
+	case TypeSMS:
+		if sms == nil {
+			return nil, errSMSDisabled
+		}
+		return &SMS{SMSGlobal: sms, Contacts: cfg.To}, nil
+	case TypeSMTP:
+		return &SMTP{
+			Host:     cfg.Host,
+			Port:     cfg.Port,
+			Username: cfg.Username,
+			Password: cfg.Password,
+			From:     cfg.From,
+			To:       cfg.To,
+		}, nil
+	case TypeWebhook, TypeSlack, TypeTelegram:
+		w := &Webhook{URL: cfg.URL, Format: common.StringToUpper(cfg.Type)}
+		if len(cfg.To) > 0 {
+			w.ChatID = cfg.To[0]
+		}
+		return w, nil
+	}
+	return nil, errInvalidType
+}
+
+// Setup registers the enabled channels in the config along with their alert
+// routes. If SMSGlobal is enabled and no channel is named SMSChannel, one is
+// registered which sends to every enabled contact.
+func Setup(cfg config.NotificationsConfig, sms *smsglobal.Base) {
+	for _, x := range cfg.Channels {
+		if !x.Enabled {
+			continue
+		}
+
+		n, err := New(x, sms)
+		if err != nil {
+			log.Printf("Notification channel %s disabled. Error: %s\n", x.Name, err)
+			continue
+		}
+		Register(x.Name, n, time.Second*x.RateLimit, x.MaxRetries)
+		log.Printf("Notification channel %s (%s) enabled.\n", x.Name, x.Type)
+	}
+
+	channels.m.RLock()
+	_, ok := channels.byName[SMSChannel]
+	channels.m.RUnlock()
+	if sms != nil && !ok {
+		Register(SMSChannel, &SMS{SMSGlobal: sms}, 0, 0)
+	}
+	SetAlertRoutes(cfg.Alerts)
+}
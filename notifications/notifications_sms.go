@@ -0,0 +1,37 @@
+package notifications
+
+import (
+	"github.com/mattkanwisher/cryptofiend/smsglobal"
+)
+
+// SMS sends messages as text messages through SMSGlobal. Messages go to the
+// named Contacts, or every enabled contact if there are none.
+type SMS struct {
+	SMSGlobal *smsglobal.Base
+	Contacts  []string
+}
+
+// Send sends a message to the contact named by the message, or the contacts
+// of the channel
+func (s *SMS) Send(m Message) error {
+	contacts := s.Contacts
+	if m.To != "" {
+		contacts = []string{m.To}
+	}
+
+	if len(contacts) == 0 || (len(contacts) == 1 && contacts[0] == "ALL") {
+		return s.SMSGlobal.SendMessageToAll(m.String())
+	}
+
+	for _, name := range contacts {
+		contact, err := s.SMSGlobal.GetContactByName(name)
+		if err != nil {
+			return err
+		}
+		err = s.SMSGlobal.SendMessage(contact.Number, m.String())
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
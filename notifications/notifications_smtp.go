@@ -0,0 +1,54 @@
+package notifications
+
+import (
+	"fmt"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+// SMTP sends messages as emails through an SMTP server, authenticating with
+// Username and Password if they are set
+type SMTP struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	From     string
+	To       []string
+}
+
+// Send emails a message to the address in the message, or the addresses of the
+// channel
+func (s *SMTP) Send(m Message) error {
+	to := s.To
+	if m.To != "" {
+		to = []string{m.To}
+	}
+
+	var auth smtp.Auth
+	if s.Username != "" {
+		auth = smtp.PlainAuth("", s.Username, s.Password, s.Host)
+	}
+
+	addr := fmt.Sprintf("%s:%d", s.Host, s.Port)
+	return smtp.SendMail(addr, auth, s.From, to, buildEmail(s.From, to, m))
+}
+
+// buildEmail formats a message as a plain text email
+func buildEmail(from string, to []string, m Message) []byte {
+	subject := m.Subject
+	if subject == "" {
+		subject = m.AlertType
+	}
+
+	headers := []string{
+		"From: " + from,
+		"To: " + strings.Join(to, ", "),
+		"Subject: " + subject,
+		"Date: " + m.Timestamp.Format(time.RFC1123Z),
+		"MIME-Version: 1.0",
+		"Content-Type: text/plain; charset=UTF-8",
+	}
+	return []byte(strings.Join(headers, "\r\n") + "\r\n\r\n" + m.Body + "\r\n")
+}
@@ -0,0 +1,190 @@
+package notifications
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mattkanwisher/cryptofiend/config"
+	"github.com/mattkanwisher/cryptofiend/smsglobal"
+)
+
+type testNotifier struct {
+	m        sync.Mutex
+	failures int
+	sent     []Message
+	times    []time.Time
+	done     chan struct{}
+}
+
+func newTestNotifier(failures int) *testNotifier {
+	return &testNotifier{failures: failures, done: make(chan struct{}, QueueSize)}
+}
+
+func (n *testNotifier) Send(m Message) error {
+	n.m.Lock()
+	defer n.m.Unlock()
+
+	n.times = append(n.times, time.Now())
+	if n.failures > 0 {
+		n.failures--
+		return errors.New("failed")
+	}
+	n.sent = append(n.sent, m)
+	n.done <- struct{}{}
+	return nil
+}
+
+func (n *testNotifier) wait(t *testing.T, count int) {
+	for i := 0; i < count; i++ {
+		select {
+		case <-n.done:
+		case <-time.After(time.Second * 5):
+			t.Fatal("Test failed. notification not sent")
+		}
+	}
+}
+
+func TestMessageString(t *testing.T) {
+	m := Message{Subject: "Event triggered", Body: "BTC-USD > 100"}
+	if m.String() != "Event triggered: BTC-USD > 100" {
+		t.Errorf("Test failed. TestMessageString: unexpected %s", m.String())
+	}
+
+	m.Subject = ""
+	if m.String() != "BTC-USD > 100" {
+		t.Errorf("Test failed. TestMessageString: unexpected %s", m.String())
+	}
+}
+
+func TestNotify(t *testing.T) {
+	a := newTestNotifier(0)
+	b := newTestNotifier(0)
+	Register("TestNotifyA", a, 0, 0)
+	Register("TestNotifyB", b, 0, 0)
+	defer Unregister("TestNotifyA")
+	defer Unregister("TestNotifyB")
+
+	SetAlertRoutes(map[string][]string{"test": {"TestNotifyB"}})
+	defer SetAlertRoutes(nil)
+
+	Notify("TEST", "subject", "body")
+	b.wait(t, 1)
+	if len(a.sent) != 0 || b.sent[0].AlertType != "TEST" || b.sent[0].Body != "body" {
+		t.Error("Test failed. TestNotify: alert not routed to its channel")
+	}
+
+	err := Send("TestNotifyA", Message{Body: "direct"})
+	if err != nil {
+		t.Fatalf("Test failed. TestNotify: Error, %s", err)
+	}
+	a.wait(t, 1)
+	if a.sent[0].Timestamp.IsZero() {
+		t.Error("Test failed. TestNotify: message not timestamped")
+	}
+
+	err = Send("TestNotifyMissing", Message{})
+	if err != errChannelNotFound {
+		t.Error("Test failed. TestNotify: sent to a missing channel")
+	}
+}
+
+func TestRetries(t *testing.T) {
+	delay := RetryDelay
+	RetryDelay = time.Millisecond
+	defer func() { RetryDelay = delay }()
+
+	n := newTestNotifier(2)
+	Register("TestRetries", n, 0, 2)
+	defer Unregister("TestRetries")
+
+	err := Send("TestRetries", Message{Body: "retry"})
+	if err != nil {
+		t.Fatalf("Test failed. TestRetries: Error, %s", err)
+	}
+	n.wait(t, 1)
+	if len(n.times) != 3 || len(n.sent) != 1 {
+		t.Errorf("Test failed. TestRetries: sent %d times", len(n.times))
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	n := newTestNotifier(0)
+	Register("TestRateLimit", n, time.Millisecond*50, 0)
+	defer Unregister("TestRateLimit")
+
+	for i := 0; i < 3; i++ {
+		err := Send("TestRateLimit", Message{Body: "limited"})
+		if err != nil {
+			t.Fatalf("Test failed. TestRateLimit: Error, %s", err)
+		}
+	}
+	n.wait(t, 3)
+
+	for i := 1; i < len(n.times); i++ {
+		if n.times[i].Sub(n.times[i-1]) < time.Millisecond*50 {
+			t.Error("Test failed. TestRateLimit: messages sent too quickly")
+		}
+	}
+}
+
+func TestSetup(t *testing.T) {
+	sms := smsglobal.New("bob", "pw", "Skynet", []smsglobal.Contact{
+		{Name: "bob", Number: "1234", Enabled: true},
+	})
+	cfg := config.NotificationsConfig{
+		Channels: []config.NotifierConfig{
+			{Name: "TestSetupHook", Type: "webhook", Enabled: true, URL: "http://localhost"},
+			{Name: "TestSetupDisabled", Type: "SLACK"},
+			{Name: "TestSetupInvalid", Type: "FAX", Enabled: true},
+		},
+	}
+	Setup(cfg, sms)
+	defer Unregister("TestSetupHook")
+	defer Unregister(SMSChannel)
+
+	channels := strings.Join(GetChannels(), ",")
+	if !strings.Contains(channels, "TestSetupHook") || !strings.Contains(channels, SMSChannel) ||
+		strings.Contains(channels, "TestSetupDisabled") || strings.Contains(channels, "TestSetupInvalid") {
+		t.Errorf("Test failed. TestSetup: unexpected channels %s", channels)
+	}
+}
+
+func TestSMS(t *testing.T) {
+	s := &SMS{SMSGlobal: smsglobal.New("bob", "pw", "Skynet", []smsglobal.Contact{
+		{Name: "bob", Number: "1234", Enabled: true},
+	})}
+
+	err := s.Send(Message{Body: "all"})
+	if err != nil {
+		t.Errorf("Test failed. TestSMS: Error, %s", err)
+	}
+
+	err = s.Send(Message{Body: "bob", To: "Bob"})
+	if err != nil {
+		t.Errorf("Test failed. TestSMS: Error, %s", err)
+	}
+
+	err = s.Send(Message{Body: "alice", To: "alice"})
+	if err == nil {
+		t.Error("Test failed. TestSMS: sent to a missing contact")
+	}
+}
+
+func TestBuildEmail(t *testing.T) {
+	m := Message{
+		AlertType: AlertEvent,
+		Subject:   "Event triggered",
+		Body:      "BTC-USD > 100",
+		Timestamp: time.Unix(0, 0).UTC(),
+	}
+	email := string(buildEmail("bot@example.com", []string{"a@example.com", "b@example.com"}, m))
+
+	expected := "From: bot@example.com\r\nTo: a@example.com, b@example.com\r\n" +
+		"Subject: Event triggered\r\nDate: Thu, 01 Jan 1970 00:00:00 +0000\r\n"
+	if !strings.HasPrefix(email, expected) || !strings.HasSuffix(email, "\r\n\r\nBTC-USD > 100\r\n") {
+		t.Errorf("Test failed. TestBuildEmail: unexpected email %q", email)
+	}
+}
@@ -0,0 +1,70 @@
+package notifications
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/mattkanwisher/cryptofiend/common"
+)
+
+// webhookMessage is the payload of generic webhooks
+type webhookMessage struct {
+	AlertType string    `json:"alert_type"`
+	Subject   string    `json:"subject"`
+	Body      string    `json:"body"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// Webhook posts messages as JSON to a URL. Format is TypeWebhook for a
+// webhookMessage, TypeSlack for a Slack style incoming webhook or TypeTelegram
+// for the Telegram sendMessage method, which sends to ChatID.
+type Webhook struct {
+	URL    string
+	Format string
+	ChatID string
+}
+
+// payload returns the JSON body posted for a message
+func (w *Webhook) payload(m Message) ([]byte, error) {
+	switch w.Format {
+	case TypeSlack:
+		return common.JSONEncode(map[string]string{"text": m.String()})
+	case TypeTelegram:
+		chatID := w.ChatID
+		if m.To != "" {
+			chatID = m.To
+		}
+		return common.JSONEncode(map[string]string{"chat_id": chatID, "text": m.String()})
+	}
+	return common.JSONEncode(webhookMessage{
+		AlertType: m.AlertType,
+		Subject:   m.Subject,
+		Body:      m.Body,
+		Timestamp: m.Timestamp,
+	})
+}
+
+// Send posts a message to the webhook URL
+func (w *Webhook) Send(m Message) error {
+	body, err := w.payload(m)
+	if err != nil {
+		return err
+	}
+
+	headers := http.Header{}
+	headers.Set("Content-Type", "application/json")
+	resp, status, err := common.SendHTTPRequest2("POST", w.URL, headers, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	if status < 200 || status > 299 {
+		return &common.HTTPRequestError{
+			StatusCode: status,
+			Message:    fmt.Sprintf("webhook returned status %d: %s", status, resp),
+		}
+	}
+	return nil
+}
@@ -0,0 +1,54 @@
+package notifications
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/mattkanwisher/cryptofiend/common"
+)
+
+func TestWebhookSend(t *testing.T) {
+	var body []byte
+	status := http.StatusOK
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ = ioutil.ReadAll(r.Body)
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	m := Message{
+		AlertType: AlertEvent,
+		Subject:   "Event triggered",
+		Body:      "price reached 100",
+		Timestamp: time.Unix(0, 0).UTC(),
+	}
+	tests := []struct {
+		webhook  Webhook
+		expected string
+	}{
+		{Webhook{Format: TypeWebhook}, `{"alert_type":"EVENT","subject":"Event triggered","body":"price reached 100","timestamp":"1970-01-01T00:00:00Z"}`},
+		{Webhook{Format: TypeSlack}, `{"text":"Event triggered: price reached 100"}`},
+		{Webhook{Format: TypeTelegram, ChatID: "42"}, `{"chat_id":"42","text":"Event triggered: price reached 100"}`},
+	}
+
+	for _, test := range tests {
+		test.webhook.URL = server.URL
+		err := test.webhook.Send(m)
+		if err != nil {
+			t.Errorf("Test failed. TestWebhookSend %s: Error, %s", test.webhook.Format, err)
+		}
+		if string(body) != test.expected {
+			t.Errorf("Test failed. TestWebhookSend %s: unexpected body %s", test.webhook.Format, body)
+		}
+	}
+
+	status = http.StatusInternalServerError
+	w := Webhook{URL: server.URL, Format: TypeSlack}
+	err := w.Send(m)
+	if e, ok := err.(*common.HTTPRequestError); !ok || e.StatusCode != status {
+		t.Errorf("Test failed. TestWebhookSend: unexpected error %v", err)
+	}
+}
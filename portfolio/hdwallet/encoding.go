@@ -0,0 +1,167 @@
+package hdwallet
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"math/big"
+	"strings"
+)
+
+const (
+	base58Alphabet = "123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz"
+	bech32Alphabet = "qpzry9x8gf2tvdw0s3jn54khce6mua7l"
+)
+
+var (
+	errInvalidBase58   = errors.New("invalid base58 string")
+	errInvalidChecksum = errors.New("invalid base58 checksum")
+)
+
+// doubleSHA256 returns the SHA-256 digest of the SHA-256 digest of data
+func doubleSHA256(data []byte) []byte {
+	first := sha256.Sum256(data)
+	second := sha256.Sum256(first[:])
+	return second[:]
+}
+
+// Hash160 returns the RIPEMD-160 digest of the SHA-256 digest of data, which
+// addresses are made from
+func Hash160(data []byte) []byte {
+	digest := sha256.Sum256(data)
+	return RIPEMD160(digest[:])
+}
+
+// Base58Encode encodes data in base58, keeping leading zero bytes as ones
+func Base58Encode(data []byte) string {
+	n := new(big.Int).SetBytes(data)
+	radix := big.NewInt(58)
+	mod := new(big.Int)
+
+	var result []byte
+	for n.Sign() > 0 {
+		n.DivMod(n, radix, mod)
+		result = append(result, base58Alphabet[mod.Int64()])
+	}
+	for _, x := range data {
+		if x != 0 {
+			break
+		}
+		result = append(result, base58Alphabet[0])
+	}
+
+	for i, j := 0, len(result)-1; i < j; i, j = i+1, j-1 {
+		result[i], result[j] = result[j], result[i]
+	}
+	return string(result)
+}
+
+// Base58Decode decodes a base58 string
+func Base58Decode(s string) ([]byte, error) {
+	n := new(big.Int)
+	radix := big.NewInt(58)
+	for _, c := range s {
+		i := strings.IndexRune(base58Alphabet, c)
+		if i < 0 {
+			return nil, errInvalidBase58
+		}
+		n.Mul(n, radix)
+		n.Add(n, big.NewInt(int64(i)))
+	}
+
+	var zeros int
+	for zeros < len(s) && s[zeros] == base58Alphabet[0] {
+		zeros++
+	}
+	return append(make([]byte, zeros), n.Bytes()...), nil
+}
+
+// Base58CheckEncode encodes data in base58 with a four byte checksum
+func Base58CheckEncode(data []byte) string {
+	return Base58Encode(append(append([]byte(nil), data...), doubleSHA256(data)[:4]...))
+}
+
+// Base58CheckDecode decodes a base58 string and verifies its checksum
+func Base58CheckDecode(s string) ([]byte, error) {
+	data, err := Base58Decode(s)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < 4 {
+		return nil, errInvalidChecksum
+	}
+
+	payload := data[:len(data)-4]
+	if !bytes.Equal(doubleSHA256(payload)[:4], data[len(data)-4:]) {
+		return nil, errInvalidChecksum
+	}
+	return payload, nil
+}
+
+// bech32Polymod returns the BCH checksum of values
+func bech32Polymod(values []byte) uint32 {
+	generator := [5]uint32{0x3b6a57b2, 0x26508e6d, 0x1ea119fa, 0x3d4233dd, 0x2a1462b3}
+	chk := uint32(1)
+	for _, v := range values {
+		top := chk >> 25
+		chk = (chk&0x1ffffff)<<5 ^ uint32(v)
+		for i := uint(0); i < 5; i++ {
+			if (top>>i)&1 == 1 {
+				chk ^= generator[i]
+			}
+		}
+	}
+	return chk
+}
+
+// bech32HRPExpand returns the human readable part as checksummed
+func bech32HRPExpand(hrp string) []byte {
+	var result []byte
+	for i := 0; i < len(hrp); i++ {
+		result = append(result, hrp[i]>>5)
+	}
+	result = append(result, 0)
+	for i := 0; i < len(hrp); i++ {
+		result = append(result, hrp[i]&31)
+	}
+	return result
+}
+
+// convertBits regroups bytes of fromBits bits into groups of toBits bits,
+// padding the last group with zeros
+func convertBits(data []byte, fromBits, toBits uint) []byte {
+	var result []byte
+	var acc uint32
+	var bits uint
+	maxValue := uint32(1)<<toBits - 1
+	for _, x := range data {
+		acc = acc<<fromBits | uint32(x)
+		bits += fromBits
+		for bits >= toBits {
+			bits -= toBits
+			result = append(result, byte(acc>>bits&maxValue))
+		}
+	}
+	if bits > 0 {
+		result = append(result, byte(acc<<(toBits-bits)&maxValue))
+	}
+	return result
+}
+
+// SegwitAddress returns the bech32 address of a witness program
+func SegwitAddress(hrp string, version byte, program []byte) string {
+	data := append([]byte{version}, convertBits(program, 8, 5)...)
+
+	values := append(bech32HRPExpand(hrp), data...)
+	values = append(values, 0, 0, 0, 0, 0, 0)
+	checksum := bech32Polymod(values) ^ 1
+	for i := uint(0); i < 6; i++ {
+		data = append(data, byte(checksum>>(5*(5-i))&31))
+	}
+
+	result := []byte(hrp + "1")
+	for _, x := range data {
+		result = append(result, bech32Alphabet[x])
+	}
+	return string(result)
+}
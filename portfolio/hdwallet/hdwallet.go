@@ -0,0 +1,280 @@
+package hdwallet
+
+import (
+	"crypto/hmac"
+	"crypto/sha512"
+	"encoding/binary"
+	"errors"
+	"math/big"
+
+	"github.com/mattkanwisher/cryptofiend/common"
+)
+
+// Types of address derived from an account, set by the version of its
+// extended key
+const (
+	AddressP2PKH      = "p2pkh"       // BIP44 xpub, tpub, Ltub
+	AddressP2SHP2WPKH = "p2sh-p2wpkh" // BIP49 ypub, upub, Mtub
+	AddressP2WPKH     = "p2wpkh"      // BIP84 zpub, vpub
+
+	// HardenedIndex is the first hardened child index, which can't be derived
+	// from a public key
+	HardenedIndex = 0x80000000
+	// DefaultGapLimit is the number of unused addresses in a row after which
+	// the addresses of an account are assumed to be unused
+	DefaultGapLimit = 20
+)
+
+var (
+	errInvalidKey      = errors.New("invalid extended public key")
+	errPrivateKey      = errors.New("extended private keys aren't accepted, use the public key")
+	errHardenedChild   = errors.New("hardened children can't be derived from a public key")
+	errInvalidChild    = errors.New("invalid child key, use the next index")
+	errUnsupportedCoin = errors.New("coin not supported for HD wallet addresses")
+)
+
+// keyVersion is what an extended key version says about the account
+type keyVersion struct {
+	addressType string
+	testnet     bool
+}
+
+var versions = map[uint32]keyVersion{
+	0x0488B21E: {AddressP2PKH, false},      // xpub
+	0x049D7CB2: {AddressP2SHP2WPKH, false}, // ypub
+	0x04B24746: {AddressP2WPKH, false},     // zpub
+	0x043587CF: {AddressP2PKH, true},       // tpub
+	0x044A5262: {AddressP2SHP2WPKH, true},  // upub
+	0x045F1CF6: {AddressP2WPKH, true},      // vpub
+	0x019DA462: {AddressP2PKH, false},      // Ltub
+	0x01B26EF6: {AddressP2SHP2WPKH, false}, // Mtub
+}
+
+var privateVersions = map[uint32]bool{
+	0x0488ADE4: true, // xprv
+	0x049D7878: true, // yprv
+	0x04B2430C: true, // zprv
+	0x04358394: true, // tprv
+}
+
+// network holds the address prefixes of a coin
+type network struct {
+	pubKeyHash byte
+	scriptHash byte
+	hrp        string
+}
+
+var (
+	networks = map[string]network{
+		"BTC": {0x00, 0x05, "bc"},
+		"LTC": {0x30, 0x32, "ltc"},
+	}
+	testnet = network{0x6f, 0xc4, "tb"}
+)
+
+// ExtendedKey is a BIP32 extended public key, usually of an account from
+// which receive and change addresses are derived
+type ExtendedKey struct {
+	Version           uint32
+	Depth             byte
+	ParentFingerprint uint32
+	ChildNumber       uint32
+	ChainCode         []byte
+	Key               []byte
+	AddressType       string
+	Testnet           bool
+
+	pub point
+}
+
+// ParseExtendedKey parses a base58 encoded extended public key
+func ParseExtendedKey(s string) (*ExtendedKey, error) {
+	data, err := Base58CheckDecode(s)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) != 78 {
+		return nil, errInvalidKey
+	}
+
+	k := &ExtendedKey{
+		Version:           binary.BigEndian.Uint32(data[0:4]),
+		Depth:             data[4],
+		ParentFingerprint: binary.BigEndian.Uint32(data[5:9]),
+		ChildNumber:       binary.BigEndian.Uint32(data[9:13]),
+		ChainCode:         data[13:45],
+		Key:               data[45:78],
+	}
+	if privateVersions[k.Version] {
+		return nil, errPrivateKey
+	}
+	version, ok := versions[k.Version]
+	if !ok {
+		return nil, errInvalidKey
+	}
+	k.AddressType = version.addressType
+	k.Testnet = version.testnet
+
+	k.pub, err = decompress(k.Key)
+	if err != nil {
+		return nil, err
+	}
+	return k, nil
+}
+
+// IsExtendedKey returns whether s is an extended public key
+func IsExtendedKey(s string) bool {
+	_, err := ParseExtendedKey(s)
+	return err == nil
+}
+
+// String returns the base58 encoding of the key
+func (k *ExtendedKey) String() string {
+	data := make([]byte, 13, 78)
+	binary.BigEndian.PutUint32(data[0:4], k.Version)
+	data[4] = k.Depth
+	binary.BigEndian.PutUint32(data[5:9], k.ParentFingerprint)
+	binary.BigEndian.PutUint32(data[9:13], k.ChildNumber)
+	data = append(data, k.ChainCode...)
+	data = append(data, k.Key...)
+	return Base58CheckEncode(data)
+}
+
+// Child derives the non-hardened child key at an index
+func (k *ExtendedKey) Child(index uint32) (*ExtendedKey, error) {
+	if index >= HardenedIndex {
+		return nil, errHardenedChild
+	}
+
+	data := make([]byte, 37)
+	copy(data, k.Key)
+	binary.BigEndian.PutUint32(data[33:], index)
+	mac := hmac.New(sha512.New, k.ChainCode)
+	mac.Write(data)
+	sum := mac.Sum(nil)
+
+	tweak := new(big.Int).SetBytes(sum[:32])
+	if tweak.Cmp(curveN) >= 0 {
+		return nil, errInvalidChild
+	}
+	pub := scalarBaseMult(tweak).add(k.pub)
+	if pub.infinity() {
+		return nil, errInvalidChild
+	}
+
+	return &ExtendedKey{
+		Version:           k.Version,
+		Depth:             k.Depth + 1,
+		ParentFingerprint: binary.BigEndian.Uint32(Hash160(k.Key)[:4]),
+		ChildNumber:       index,
+		ChainCode:         sum[32:],
+		Key:               pub.compress(),
+		AddressType:       k.AddressType,
+		Testnet:           k.Testnet,
+		pub:               pub,
+	}, nil
+}
+
+// Address returns the address of the key on a coin's network
+func (k *ExtendedKey) Address(coin string) (string, error) {
+	net, ok := networks[common.StringToUpper(coin)]
+	if !ok {
+		return "", errUnsupportedCoin
+	}
+	if k.Testnet {
+		net = testnet
+	}
+
+	hash := Hash160(k.Key)
+	switch k.AddressType {
+	case AddressP2SHP2WPKH:
+		script := append([]byte{0x00, 0x14}, hash...)
+		return Base58CheckEncode(append([]byte{net.scriptHash}, Hash160(script)...)), nil
+	case AddressP2WPKH:
+		return SegwitAddress(net.hrp, 0, hash), nil
+	}
+	return Base58CheckEncode(append([]byte{net.pubKeyHash}, hash...)), nil
+}
+
+// DeriveAddress returns the receive or change address at an index of an
+// account, m/change/index from the account key
+func (k *ExtendedKey) DeriveAddress(coin string, change bool, index uint32) (string, error) {
+	chain := uint32(0)
+	if change {
+		chain = 1
+	}
+
+	key, err := k.Child(chain)
+	if err != nil {
+		return "", err
+	}
+	key, err = key.Child(index)
+	if err != nil {
+		return "", err
+	}
+	return key.Address(coin)
+}
+
+// Scan derives the receive and change addresses of an account in batches
+// until gapLimit addresses in a row on each chain have no balance, and
+// returns the balances of those which have one. Addresses are treated as
+// unused when they have no balance, so an account which emptied more than
+// gapLimit addresses in a row may be cut short.
+func (k *ExtendedKey) Scan(coin string, gapLimit int, getBalances func(addresses []string) (map[string]float64, error)) (map[string]float64, error) {
+	if gapLimit <= 0 {
+		gapLimit = DefaultGapLimit
+	}
+
+	result := make(map[string]float64)
+	for _, change := range []bool{false, true} {
+		chain := uint32(0)
+		if change {
+			chain = 1
+		}
+		chainKey, err := k.Child(chain)
+		if err != nil {
+			return nil, err
+		}
+
+		lastUsed := -1
+		for next := 0; next-lastUsed-1 < gapLimit; {
+			var addresses []string
+			for i := 0; i < gapLimit; i++ {
+				// Indexes without a valid key are skipped as BIP32 requires
+				key, err := chainKey.Child(uint32(next + i))
+				if err != nil {
+					addresses = append(addresses, "")
+					continue
+				}
+				address, err := key.Address(coin)
+				if err != nil {
+					return nil, err
+				}
+				addresses = append(addresses, address)
+			}
+
+			balances, err := getBalances(nonEmpty(addresses))
+			if err != nil {
+				return nil, err
+			}
+			for i, x := range addresses {
+				if x != "" && balances[x] > 0 {
+					result[x] = balances[x]
+					lastUsed = next + i
+				}
+			}
+			next += gapLimit
+		}
+	}
+	return result, nil
+}
+
+func nonEmpty(values []string) []string {
+	var result []string
+	for _, x := range values {
+		if x != "" {
+			result = append(result, x)
+		}
+	}
+	return result
+}
@@ -0,0 +1,149 @@
+package hdwallet
+
+import (
+	"encoding/hex"
+	"math/big"
+	"testing"
+)
+
+const (
+	bip32Account = "xpub68Gmy5EdvgibQVfPdqkBBCHxA5htiqg55crXYuXoQRKfDBFA1WEjWgP6LHhwBZeNK1VTsfTFUHCdrfp1bgwQ9xv5ski8PX9rL2dZXvgGDnw"
+	bip32Child   = "xpub6ASuArnXKPbfEwhqN6e3mwBcDTgzisQN1wXN9BJcM47sSikHjJf3UFHKkNAWbWMiGj7Wf5uMash7SyYq527Hqck2AxYysAA7xmALppuCkwQ"
+	bip84Account = "zpub6rFR7y4Q2AijBEqTUquhVz398htDFrtymD9xYYfG1m4wAcvPhXNfE3EfH1r1ADqtfSdVCToUG868RvUUkgDKf31mGDtKsAYz2oz2AGutZYs"
+)
+
+func TestRIPEMD160(t *testing.T) {
+	tests := map[string]string{
+		"":               "9c1185a5c5e9fc54612808977ee8f548b2258d31",
+		"abc":            "8eb208f7e05d987a9b044a8e98c6b087f15a0bfc",
+		"message digest": "5d0689ef49d2fae572b881b123a85ffa21595f36",
+		"12345678901234567890123456789012345678901234567890123456789012345678901234567890": "9b752e45573d4b39f4dbd3323cab82bf63326bfb",
+	}
+	for input, expected := range tests {
+		if result := hex.EncodeToString(RIPEMD160([]byte(input))); result != expected {
+			t.Errorf("Test failed. TestRIPEMD160: %q hashed to %s", input, result)
+		}
+	}
+}
+
+func TestAddresses(t *testing.T) {
+	// The public key of private key 1 is the generator
+	k := &ExtendedKey{Key: scalarBaseMult(big.NewInt(1)).compress()}
+
+	tests := []struct {
+		addressType string
+		coin        string
+		expected    string
+	}{
+		{AddressP2PKH, "BTC", "1BgGZ9tcN4rm9KBzDn7KprQz87SZ26SAMH"},
+		{AddressP2WPKH, "btc", "bc1qw508d6qejxtdg4y5r3zarvary0c5xw7kv8f3t4"},
+	}
+	for _, test := range tests {
+		k.AddressType = test.addressType
+		address, err := k.Address(test.coin)
+		if err != nil || address != test.expected {
+			t.Errorf("Test failed. TestAddresses: %s address %s, %v", test.addressType, address, err)
+		}
+	}
+
+	_, err := k.Address("ETH")
+	if err == nil {
+		t.Error("Test failed. TestAddresses: derived an ETH address")
+	}
+}
+
+func TestParseExtendedKey(t *testing.T) {
+	k, err := ParseExtendedKey(bip32Account)
+	if err != nil {
+		t.Fatalf("Test failed. TestParseExtendedKey: Error, %s", err)
+	}
+	if k.Depth != 1 || k.ChildNumber != HardenedIndex || k.AddressType != AddressP2PKH || k.Testnet {
+		t.Errorf("Test failed. TestParseExtendedKey: unexpected key %+v", k)
+	}
+	if k.String() != bip32Account {
+		t.Errorf("Test failed. TestParseExtendedKey: key encoded as %s", k.String())
+	}
+
+	invalid := []string{
+		"",
+		"xpub68Gmy5EdvgibQVfPdqkBBCHxA5htiqg55crXYuXoQRKfDBFA1WEjWgP6LHhwBZeNK1VTsfTFUHCdrfp1bgwQ9xv5ski8PX9rL2dZXvgGDnx",
+		"xprv9s21ZrQH143K3QTDL4LXw2F7HEK3wJUD2nW2nRk4stbPy6cq3jPPqjiChkVvvNKmPGJxWUtg6LnF5kejMRNNU3TGtRBeJgk33yuGBxrMPHi",
+		"1BgGZ9tcN4rm9KBzDn7KprQz87SZ26SAMH",
+	}
+	for _, x := range invalid {
+		if IsExtendedKey(x) {
+			t.Errorf("Test failed. TestParseExtendedKey: parsed %q", x)
+		}
+	}
+}
+
+func TestChild(t *testing.T) {
+	k, _ := ParseExtendedKey(bip32Account)
+	child, err := k.Child(1)
+	if err != nil {
+		t.Fatalf("Test failed. TestChild: Error, %s", err)
+	}
+	if child.String() != bip32Child {
+		t.Errorf("Test failed. TestChild: derived %s", child.String())
+	}
+
+	_, err = k.Child(HardenedIndex)
+	if err == nil {
+		t.Error("Test failed. TestChild: derived a hardened child")
+	}
+}
+
+func TestDeriveAddress(t *testing.T) {
+	k, err := ParseExtendedKey(bip84Account)
+	if err != nil {
+		t.Fatalf("Test failed. TestDeriveAddress: Error, %s", err)
+	}
+
+	tests := []struct {
+		change   bool
+		index    uint32
+		expected string
+	}{
+		{false, 0, "bc1qcr8te4kr609gcawutmrza0j4xv80jy8z306fyu"},
+		{false, 1, "bc1qnjg0jd8228aq7egyzacy8cys3knf9xvrerkf9g"},
+		{true, 0, "bc1q8c6fshw2dlwun7ekn9qwf37cu2rn755upcp6el"},
+	}
+	for _, test := range tests {
+		address, err := k.DeriveAddress("BTC", test.change, test.index)
+		if err != nil || address != test.expected {
+			t.Errorf("Test failed. TestDeriveAddress: %v/%d derived %s, %v", test.change,
+				test.index, address, err)
+		}
+	}
+}
+
+func TestScan(t *testing.T) {
+	k, _ := ParseExtendedKey(bip84Account)
+	used := make(map[string]float64)
+	for _, x := range []uint32{0, 4} {
+		address, _ := k.DeriveAddress("BTC", false, x)
+		used[address] = float64(x + 1)
+	}
+	change, _ := k.DeriveAddress("BTC", true, 0)
+	used[change] = 0.5
+
+	var requested int
+	result, err := k.Scan("BTC", 3, func(addresses []string) (map[string]float64, error) {
+		requested += len(addresses)
+		balances := make(map[string]float64)
+		for _, x := range addresses {
+			balances[x] = used[x]
+		}
+		return balances, nil
+	})
+	if err != nil {
+		t.Fatalf("Test failed. TestScan: Error, %s", err)
+	}
+	if len(result) != 3 {
+		t.Errorf("Test failed. TestScan: unexpected balances %v", result)
+	}
+	// Receive addresses 0-8 and change addresses 0-5 are checked
+	if requested != 15 {
+		t.Errorf("Test failed. TestScan: %d addresses requested", requested)
+	}
+}
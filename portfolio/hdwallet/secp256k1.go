@@ -0,0 +1,117 @@
+package hdwallet
+
+import (
+	"errors"
+	"math/big"
+)
+
+// secp256k1 curve parameters, the curve is y² = x³ + 7 over the field of
+// order p
+var (
+	curveP, _  = new(big.Int).SetString("FFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFEFFFFFC2F", 16)
+	curveN, _  = new(big.Int).SetString("FFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFEBAAEDCE6AF48A03BBFD25E8CD0364141", 16)
+	curveGx, _ = new(big.Int).SetString("79BE667EF9DCBBAC55A06295CE870B07029BFCDB2DCE28D959F2815B16F81798", 16)
+	curveGy, _ = new(big.Int).SetString("483ADA7726A3C4655DA4FBFC0E1108A8FD17B448A68554199C47D08FFB10D4B8", 16)
+	curveB     = big.NewInt(7)
+
+	errInvalidPoint = errors.New("invalid secp256k1 public key")
+)
+
+// point is a point on the curve, the point at infinity has nil coordinates
+type point struct {
+	x, y *big.Int
+}
+
+func (a point) infinity() bool {
+	return a.x == nil
+}
+
+// add returns the sum of two points
+func (a point) add(b point) point {
+	if a.infinity() {
+		return b
+	}
+	if b.infinity() {
+		return a
+	}
+
+	var slope *big.Int
+	if a.x.Cmp(b.x) == 0 {
+		if a.y.Cmp(b.y) != 0 || a.y.Sign() == 0 {
+			return point{}
+		}
+		// (3x²) / (2y)
+		num := new(big.Int).Mul(a.x, a.x)
+		num.Mul(num, big.NewInt(3))
+		den := new(big.Int).Lsh(a.y, 1)
+		slope = num.Mul(num, den.ModInverse(den, curveP))
+	} else {
+		// (y2 - y1) / (x2 - x1)
+		num := new(big.Int).Sub(b.y, a.y)
+		den := new(big.Int).Sub(b.x, a.x)
+		den.Mod(den, curveP)
+		slope = num.Mul(num, den.ModInverse(den, curveP))
+	}
+	slope.Mod(slope, curveP)
+
+	x := new(big.Int).Mul(slope, slope)
+	x.Sub(x, a.x)
+	x.Sub(x, b.x)
+	x.Mod(x, curveP)
+
+	y := new(big.Int).Sub(a.x, x)
+	y.Mul(y, slope)
+	y.Sub(y, a.y)
+	y.Mod(y, curveP)
+	return point{x, y}
+}
+
+// scalarBaseMult returns k times the generator
+func scalarBaseMult(k *big.Int) point {
+	result := point{}
+	addend := point{curveGx, curveGy}
+	for i := 0; i < k.BitLen(); i++ {
+		if k.Bit(i) == 1 {
+			result = result.add(addend)
+		}
+		addend = addend.add(addend)
+	}
+	return result
+}
+
+// compress returns the 33 byte SEC1 encoding of a point
+func (a point) compress() []byte {
+	result := make([]byte, 33)
+	result[0] = 0x02 + byte(a.y.Bit(0))
+	x := a.x.Bytes()
+	copy(result[33-len(x):], x)
+	return result
+}
+
+// decompress parses a 33 byte SEC1 encoded point
+func decompress(data []byte) (point, error) {
+	if len(data) != 33 || (data[0] != 0x02 && data[0] != 0x03) {
+		return point{}, errInvalidPoint
+	}
+
+	x := new(big.Int).SetBytes(data[1:])
+	if x.Cmp(curveP) >= 0 {
+		return point{}, errInvalidPoint
+	}
+
+	// y = (x³ + 7)^((p+1)/4), as p = 3 mod 4
+	y2 := new(big.Int).Exp(x, big.NewInt(3), curveP)
+	y2.Add(y2, curveB)
+	y2.Mod(y2, curveP)
+	exp := new(big.Int).Add(curveP, big.NewInt(1))
+	exp.Rsh(exp, 2)
+	y := new(big.Int).Exp(y2, exp, curveP)
+	if new(big.Int).Exp(y, big.NewInt(2), curveP).Cmp(y2) != 0 {
+		return point{}, errInvalidPoint
+	}
+
+	if y.Bit(0) != uint(data[0]&1) {
+		y.Sub(curveP, y)
+	}
+	return point{x, y}, nil
+}
@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"github.com/mattkanwisher/cryptofiend/common"
+	"github.com/mattkanwisher/cryptofiend/portfolio/hdwallet"
 )
 
 const (
@@ -18,10 +19,13 @@ const (
 	PortfolioAddressExchange = "Exchange"
 	// PortfolioAddressPersonal is a label for a personal/offline address
 	PortfolioAddressPersonal = "Personal"
+	// PortfolioAddressToken is a label for the balance of an ERC-20 token held
+	// by an Ethereum address
+	PortfolioAddressToken = "Token"
 )
 
-// Portfolio is variable store holding an array of portfolioAddress
-var Portfolio Base
+// Portfolio is the portfolio of the bot, shared by its routines
+var Portfolio Service
 
 // Base holds the portfolio base addresses
 type Base struct {
@@ -34,6 +38,8 @@ type Address struct {
 	CoinType    string
 	Balance     float64
 	Description string
+	// Hold is the part of an exchange balance held for open orders
+	Hold float64 `json:",omitempty"`
 }
 
 // EtherchainBalanceResponse holds JSON incoming and outgoing data for
@@ -164,10 +170,11 @@ func (p *Base) AddExchangeAddress(exchangeName, coinType string, balance float64
 	}
 }
 
-// UpdateAddressBalance updates the portfolio base balance
+// UpdateAddressBalance updates the portfolio base balance, token balances held
+// by the address are left to SetTokenBalance
 func (p *Base) UpdateAddressBalance(address string, amount float64) {
 	for x := range p.Addresses {
-		if p.Addresses[x].Address == address {
+		if p.Addresses[x].Address == address && p.Addresses[x].Description != PortfolioAddressToken {
 			p.Addresses[x].Balance = amount
 		}
 	}
@@ -224,29 +231,39 @@ func (p *Base) RemoveAddress(address, coinType, description string) {
 	}
 }
 
-// UpdatePortfolio adds to the portfolio addresses by coin type
+// UpdatePortfolio adds to the portfolio addresses by coin type, looking up
+// their balances with the balance providers of the coin. Extended public keys
+// are given the total balance of the addresses derived from them.
 func (p *Base) UpdatePortfolio(addresses []string, coinType string) bool {
 	if common.StringContains(common.JoinStrings(addresses, ","), PortfolioAddressExchange) || common.StringContains(common.JoinStrings(addresses, ","), PortfolioAddressPersonal) {
 		return true
 	}
 
-	if coinType == "ETH" {
-		result, err := GetEthereumBalance(addresses)
-		if err != nil {
-			return false
+	var plain []string
+	for _, x := range addresses {
+		if !hdwallet.IsExtendedKey(x) {
+			plain = append(plain, x)
+			continue
 		}
 
-		for _, x := range result.Data {
-			p.AddAddress(x.Address, coinType, PortfolioAddressPersonal, x.Balance)
-		}
-		return true
-	}
-	for x := range addresses {
-		result, err := GetCryptoIDAddress(addresses[x], coinType)
+		balance, err := GetExtendedKeyBalance(x, coinType)
 		if err != nil {
 			return false
 		}
-		p.AddAddress(addresses[x], coinType, PortfolioAddressPersonal, result)
+		// Extended keys are kept even when their balance is zero
+		p.UpdateAddressBalance(x, balance)
+	}
+	if len(plain) == 0 {
+		return true
+	}
+
+	result, err := GetBalances(coinType, plain)
+	if err != nil {
+		return false
+	}
+
+	for address, balance := range result {
+		p.AddAddress(address, coinType, PortfolioAddressPersonal, balance)
 	}
 	return true
 }
@@ -451,7 +468,8 @@ func (p *Base) GetPortfolioSummary() Summary {
 func (p *Base) GetPortfolioGroupedCoin() map[string][]string {
 	result := make(map[string][]string)
 	for _, x := range p.Addresses {
-		if common.StringContains(x.Description, PortfolioAddressExchange) {
+		if common.StringContains(x.Description, PortfolioAddressExchange) ||
+			x.Description == PortfolioAddressToken {
 			continue
 		}
 		result[x.CoinType] = append(result[x.CoinType], x.Address)
@@ -467,7 +485,7 @@ func (p *Base) SeedPortfolio(port Base) {
 
 // StartPortfolioWatcher observes the portfolio object
 func StartPortfolioWatcher() {
-	addrCount := len(Portfolio.Addresses)
+	addrCount := len(Portfolio.Base().Addresses)
 	log.Printf(
 		"PortfolioWatcher started: Have %d entries in portfolio.\n", addrCount,
 	)
@@ -482,11 +500,14 @@ func StartPortfolioWatcher() {
 				)
 			}
 		}
+		if len(GetTokens()) > 0 && Portfolio.UpdateTokenBalances() {
+			log.Println("PortfolioWatcher: Successfully updated token balances")
+		}
 		time.Sleep(time.Minute * 10)
 	}
 }
 
-// GetPortfolio returns a pointer to the portfolio base
-func GetPortfolio() *Base {
+// GetPortfolio returns a pointer to the portfolio of the bot
+func GetPortfolio() *Service {
 	return &Portfolio
 }
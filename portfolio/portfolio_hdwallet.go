@@ -0,0 +1,32 @@
+package portfolio
+
+import (
+	"github.com/mattkanwisher/cryptofiend/portfolio/hdwallet"
+)
+
+// GapLimit is the number of unused addresses in a row after which the
+// addresses of an HD wallet are no longer derived
+var GapLimit = hdwallet.DefaultGapLimit
+
+// GetExtendedKeyBalance returns the total balance of the addresses derived
+// from an extended public key, looked up with the balance providers of the
+// coin
+func GetExtendedKeyBalance(key, coinType string) (float64, error) {
+	k, err := hdwallet.ParseExtendedKey(key)
+	if err != nil {
+		return 0, err
+	}
+
+	balances, err := k.Scan(coinType, GapLimit, func(addresses []string) (map[string]float64, error) {
+		return GetBalances(coinType, addresses)
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	var total float64
+	for _, x := range balances {
+		total += x
+	}
+	return total, nil
+}
@@ -0,0 +1,47 @@
+package portfolio
+
+import (
+	"testing"
+	"time"
+)
+
+const testZpub = "zpub6rFR7y4Q2AijBEqTUquhVz398htDFrtymD9xYYfG1m4wAcvPhXNfE3EfH1r1ADqtfSdVCToUG868RvUUkgDKf31mGDtKsAYz2oz2AGutZYs"
+
+type testHDProvider struct {
+	balances map[string]float64
+}
+
+func (p *testHDProvider) GetName() string {
+	return "hd"
+}
+
+func (p *testHDProvider) GetBalances(coin string, addresses []string) (map[string]float64, error) {
+	result := make(map[string]float64)
+	for _, x := range addresses {
+		result[x] = p.balances[x]
+	}
+	return result, nil
+}
+
+func TestUpdatePortfolioExtendedKey(t *testing.T) {
+	SetupProviders(nil, time.Minute)
+	defer SetupProviders(nil, 0)
+	RegisterProvider("BTC", &testHDProvider{map[string]float64{
+		"bc1qcr8te4kr609gcawutmrza0j4xv80jy8z306fyu": 1.5,
+		"bc1q8c6fshw2dlwun7ekn9qwf37cu2rn755upcp6el": 0.25,
+	}})
+
+	balance, err := GetExtendedKeyBalance(testZpub, "BTC")
+	if err != nil || balance != 1.75 {
+		t.Errorf("Test failed. TestUpdatePortfolioExtendedKey: unexpected balance %f, %v", balance, err)
+	}
+
+	p := Base{}
+	p.AddAddress(testZpub, "BTC", PortfolioAddressPersonal, 0)
+	if !p.UpdatePortfolio([]string{testZpub}, "BTC") {
+		t.Fatal("Test failed. TestUpdatePortfolioExtendedKey: update failed")
+	}
+	if result, _ := p.GetAddressBalance(testZpub, "BTC", PortfolioAddressPersonal); result != 1.75 {
+		t.Errorf("Test failed. TestUpdatePortfolioExtendedKey: unexpected portfolio balance %f", result)
+	}
+}
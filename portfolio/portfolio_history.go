@@ -0,0 +1,350 @@
+package portfolio
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"log"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/mattkanwisher/cryptofiend/common"
+	"github.com/mattkanwisher/cryptofiend/currency"
+	"github.com/mattkanwisher/cryptofiend/exchanges/ticker"
+)
+
+var (
+	errNoPrice     = errors.New("no ticker price for coin")
+	errNoSnapshots = errors.New("no portfolio snapshots")
+)
+
+// Snapshot is the value of the portfolio in a fiat currency at a point in
+// time. Coins holds the offline and exchange balances of each coin along with
+// its price, Exchanges the value held on each exchange and Unpriced the coins
+// left out of the values because they have no price.
+type Snapshot struct {
+	Timestamp time.Time               `json:"timestamp"`
+	Currency  string                  `json:"currency"`
+	Total     float64                 `json:"total"`
+	Offline   float64                 `json:"offline"`
+	Online    float64                 `json:"online"`
+	Coins     map[string]SnapshotCoin `json:"coins"`
+	Exchanges map[string]float64      `json:"exchanges"`
+	Unpriced  []string                `json:"unpriced,omitempty"`
+}
+
+// SnapshotCoin is the balance of a coin held offline and on exchanges, and its
+// price and total value in the snapshot currency
+type SnapshotCoin struct {
+	Offline float64 `json:"offline"`
+	Online  float64 `json:"online"`
+	Price   float64 `json:"price"`
+	Value   float64 `json:"value"`
+}
+
+// ProfitLoss is the change in value of the portfolio between two snapshots
+type ProfitLoss struct {
+	Start         time.Time `json:"start"`
+	End           time.Time `json:"end"`
+	Currency      string    `json:"currency"`
+	StartValue    float64   `json:"start_value"`
+	EndValue      float64   `json:"end_value"`
+	Change        float64   `json:"change"`
+	ChangePercent float64   `json:"change_percent"`
+}
+
+// snapshots is the store of the portfolio history, ordered by time, which is
+// appended to file
+var snapshots struct {
+	m       sync.RWMutex
+	history []Snapshot
+	file    string
+	maxAge  time.Duration
+}
+
+// GetFiatPrice returns the price of a coin in a fiat currency. It averages the
+// last spot price of the coin on every exchange trading it against the fiat
+// currency, falling back to pairs against other fiat currencies converted with
+// the currency rates, then to pairs against BTC. Stale currency rates are
+// used rather than leaving the coin unpriced.
+func GetFiatPrice(coin, fiat string) (float64, error) {
+	coin = common.StringToUpper(coin)
+	fiat = common.StringToUpper(fiat)
+	if coin == fiat || currency.IsFiatCurrency(coin) {
+		price, err := currency.ConvertCurrency(1, coin, fiat)
+		if currency.IsStale(err) {
+			return price, nil
+		}
+		return price, err
+	}
+
+	var direct, converted, btc []float64
+	for _, t := range ticker.GetTickers() {
+		for first, seconds := range t.Price {
+			if first.Upper().String() != coin {
+				continue
+			}
+			for second, prices := range seconds {
+				last := prices[ticker.Spot].Last
+				if last <= 0 {
+					continue
+				}
+
+				quote := second.Upper().String()
+				switch {
+				case quote == fiat:
+					direct = append(direct, last)
+				case quote == "BTC":
+					btc = append(btc, last)
+				case currency.IsFiatCurrency(quote):
+					price, err := currency.ConvertCurrency(last, quote, fiat)
+					if err == nil || currency.IsStale(err) {
+						converted = append(converted, price)
+					}
+				}
+			}
+		}
+	}
+
+	switch {
+	case len(direct) > 0:
+		return average(direct), nil
+	case len(converted) > 0:
+		return average(converted), nil
+	case len(btc) > 0 && coin != "BTC":
+		price, err := GetFiatPrice("BTC", fiat)
+		if err != nil {
+			return 0, err
+		}
+		return average(btc) * price, nil
+	}
+	return 0, errNoPrice
+}
+
+func average(values []float64) float64 {
+	var total float64
+	for _, x := range values {
+		total += x
+	}
+	return total / float64(len(values))
+}
+
+// TakeSnapshot values the portfolio in a fiat currency
+func (p *Base) TakeSnapshot(fiat string) Snapshot {
+	summary := p.GetPortfolioSummary()
+	s := Snapshot{
+		Timestamp: time.Now(),
+		Currency:  common.StringToUpper(fiat),
+		Coins:     make(map[string]SnapshotCoin),
+		Exchanges: make(map[string]float64),
+	}
+
+	for _, x := range summary.Offline {
+		c := s.Coins[x.Coin]
+		c.Offline += x.Balance
+		s.Coins[x.Coin] = c
+	}
+	for _, x := range summary.Online {
+		c := s.Coins[x.Coin]
+		c.Online += x.Balance
+		s.Coins[x.Coin] = c
+	}
+
+	for coin, c := range s.Coins {
+		price, err := GetFiatPrice(coin, s.Currency)
+		if err != nil {
+			s.Unpriced = append(s.Unpriced, coin)
+			continue
+		}
+		c.Price = price
+		c.Value = (c.Offline + c.Online) * price
+		s.Coins[coin] = c
+		s.Offline += c.Offline * price
+		s.Online += c.Online * price
+	}
+	s.Total = s.Offline + s.Online
+	sort.Strings(s.Unpriced)
+
+	for exchangeName, coins := range summary.OnlineSummary {
+		for coin, x := range coins {
+			s.Exchanges[exchangeName] += x.Balance * s.Coins[coin].Price
+		}
+	}
+	return s
+}
+
+// LoadSnapshots loads the portfolio history persisted in file, which further
+// snapshots are appended to. If maxAge is set, older snapshots are dropped
+// from the history and the file.
+func LoadSnapshots(file string, maxAge time.Duration) error {
+	snapshots.m.Lock()
+	defer snapshots.m.Unlock()
+
+	snapshots.file = file
+	snapshots.maxAge = maxAge
+	snapshots.history = nil
+
+	data, err := common.ReadFile(file)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(nil, len(data)+1)
+	for scanner.Scan() {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		var s Snapshot
+		err = common.JSONDecode(scanner.Bytes(), &s)
+		if err != nil {
+			return err
+		}
+		snapshots.history = append(snapshots.history, s)
+	}
+
+	sort.SliceStable(snapshots.history, func(i, j int) bool {
+		return snapshots.history[i].Timestamp.Before(snapshots.history[j].Timestamp)
+	})
+	if pruneSnapshots(time.Now()) {
+		return writeSnapshots()
+	}
+	return nil
+}
+
+// pruneSnapshots drops snapshots older than the maximum age and reports
+// whether any were dropped, snapshots.m must be held
+func pruneSnapshots(now time.Time) bool {
+	if snapshots.maxAge <= 0 {
+		return false
+	}
+
+	cutoff := now.Add(-snapshots.maxAge)
+	i := sort.Search(len(snapshots.history), func(i int) bool {
+		return !snapshots.history[i].Timestamp.Before(cutoff)
+	})
+	snapshots.history = snapshots.history[i:]
+	return i > 0
+}
+
+// writeSnapshots rewrites the history file, snapshots.m must be held
+func writeSnapshots() error {
+	var data []byte
+	for _, s := range snapshots.history {
+		line, err := common.JSONEncode(s)
+		if err != nil {
+			return err
+		}
+		data = append(append(data, line...), '\n')
+	}
+	return common.WriteFile(snapshots.file, data)
+}
+
+// AddSnapshot adds a snapshot to the history and appends it to the history
+// file
+func AddSnapshot(s Snapshot) error {
+	snapshots.m.Lock()
+	defer snapshots.m.Unlock()
+
+	snapshots.history = append(snapshots.history, s)
+	pruneSnapshots(s.Timestamp)
+
+	if snapshots.file == "" {
+		return nil
+	}
+
+	line, err := common.JSONEncode(s)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(snapshots.file, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = f.Write(append(line, '\n'))
+	return err
+}
+
+// GetSnapshots returns the snapshots taken between start and end inclusive, a
+// zero end is treated as now
+func GetSnapshots(start, end time.Time) []Snapshot {
+	snapshots.m.RLock()
+	defer snapshots.m.RUnlock()
+
+	result := []Snapshot{}
+	for _, s := range snapshots.history {
+		if s.Timestamp.Before(start) || (!end.IsZero() && s.Timestamp.After(end)) {
+			continue
+		}
+		result = append(result, s)
+	}
+	return result
+}
+
+// GetProfitLoss returns the change in value of the portfolio over a period
+// ending at the latest snapshot. It starts from the last snapshot taken at or
+// before the start of the period in the same currency, or the earliest one if
+// the history doesn't go back that far.
+func GetProfitLoss(period time.Duration) (ProfitLoss, error) {
+	snapshots.m.RLock()
+	defer snapshots.m.RUnlock()
+
+	if len(snapshots.history) == 0 {
+		return ProfitLoss{}, errNoSnapshots
+	}
+
+	end := snapshots.history[len(snapshots.history)-1]
+	cutoff := end.Timestamp.Add(-period)
+	var start *Snapshot
+	for i := range snapshots.history {
+		s := &snapshots.history[i]
+		if s.Currency != end.Currency {
+			continue
+		}
+		if start == nil || !s.Timestamp.After(cutoff) {
+			start = s
+		}
+		if s.Timestamp.After(cutoff) {
+			break
+		}
+	}
+
+	result := ProfitLoss{
+		Start:      start.Timestamp,
+		End:        end.Timestamp,
+		Currency:   end.Currency,
+		StartValue: start.Total,
+		EndValue:   end.Total,
+		Change:     end.Total - start.Total,
+	}
+	if start.Total != 0 {
+		result.ChangePercent = result.Change / start.Total * 100
+	}
+	return result, nil
+}
+
+// StartSnapshotWatcher adds a snapshot of the portfolio valued in a fiat
+// currency to the history every interval
+func StartSnapshotWatcher(fiat string, interval time.Duration) {
+	log.Printf("Portfolio snapshot watcher started: Taking snapshots every %s.\n", interval)
+	for {
+		time.Sleep(interval)
+		s := Portfolio.TakeSnapshot(fiat)
+		err := AddSnapshot(s)
+		if err != nil {
+			log.Printf("Failed to save portfolio snapshot. Error: %s\n", err)
+			continue
+		}
+		if len(s.Unpriced) > 0 {
+			log.Printf("Portfolio snapshot: %s have no price and were left out.\n", s.Unpriced)
+		}
+	}
+}
@@ -0,0 +1,139 @@
+package portfolio
+
+import (
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/mattkanwisher/cryptofiend/currency"
+	"github.com/mattkanwisher/cryptofiend/currency/pair"
+	"github.com/mattkanwisher/cryptofiend/exchanges/ticker"
+)
+
+func setupPrices() {
+	currency.BaseCurrencies = "USD,EUR"
+	ticker.ProcessTicker("HistoryA", pair.NewCurrencyPair("BTC", "USD"), ticker.Price{Last: 100}, ticker.Spot)
+	ticker.ProcessTicker("HistoryB", pair.NewCurrencyPair("BTC", "USD"), ticker.Price{Last: 110}, ticker.Spot)
+	ticker.ProcessTicker("HistoryA", pair.NewCurrencyPair("XMR", "BTC"), ticker.Price{Last: 0.1}, ticker.Spot)
+}
+
+func TestGetFiatPrice(t *testing.T) {
+	setupPrices()
+
+	price, err := GetFiatPrice("btc", "USD")
+	if err != nil || price != 105 {
+		t.Errorf("Test failed. TestGetFiatPrice: unexpected BTC price %f, %v", price, err)
+	}
+
+	price, err = GetFiatPrice("XMR", "USD")
+	if err != nil || math.Abs(price-10.5) > 1e-9 {
+		t.Errorf("Test failed. TestGetFiatPrice: unexpected XMR price %f, %v", price, err)
+	}
+
+	price, err = GetFiatPrice("USD", "USD")
+	if err != nil || price != 1 {
+		t.Errorf("Test failed. TestGetFiatPrice: unexpected USD price %f, %v", price, err)
+	}
+
+	_, err = GetFiatPrice("NOPE", "USD")
+	if err == nil {
+		t.Error("Test failed. TestGetFiatPrice: priced a coin without tickers")
+	}
+}
+
+func TestTakeSnapshot(t *testing.T) {
+	setupPrices()
+
+	p := Base{}
+	p.AddAddress("1JCe8z4jJVNXSjohjM4i9Hh813dLCNx2Sy", "BTC", PortfolioAddressPersonal, 2)
+	p.AddExchangeAddress("Bitfinex", "BTC", 1)
+	p.AddExchangeAddress("Bitfinex", "XMR", 10)
+	p.AddExchangeAddress("Kraken", "NOPE", 5)
+
+	s := p.TakeSnapshot("usd")
+	if s.Currency != "USD" || s.Offline != 210 || s.Online != 210 || s.Total != 420 {
+		t.Errorf("Test failed. TestTakeSnapshot: unexpected values %+v", s)
+	}
+	btc := s.Coins["BTC"]
+	if btc.Offline != 2 || btc.Online != 1 || btc.Price != 105 || btc.Value != 315 {
+		t.Errorf("Test failed. TestTakeSnapshot: unexpected BTC %+v", btc)
+	}
+	if s.Exchanges["Bitfinex"] != 210 || s.Exchanges["Kraken"] != 0 {
+		t.Errorf("Test failed. TestTakeSnapshot: unexpected exchanges %v", s.Exchanges)
+	}
+	if len(s.Unpriced) != 1 || s.Unpriced[0] != "NOPE" {
+		t.Errorf("Test failed. TestTakeSnapshot: unexpected unpriced coins %v", s.Unpriced)
+	}
+}
+
+func TestSnapshots(t *testing.T) {
+	dir, err := ioutil.TempDir("", "portfolio")
+	if err != nil {
+		t.Fatalf("Test failed. TestSnapshots: Error, %s", err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "history.dat")
+
+	err = LoadSnapshots(file, time.Hour*24*30)
+	if err != nil {
+		t.Fatalf("Test failed. TestSnapshots: Error, %s", err)
+	}
+	defer LoadSnapshots("", 0)
+
+	_, err = GetProfitLoss(time.Hour * 24)
+	if err == nil {
+		t.Error("Test failed. TestSnapshots: profit and loss without snapshots")
+	}
+
+	now := time.Now().Truncate(time.Second)
+	values := []struct {
+		age   time.Duration
+		total float64
+	}{
+		{time.Hour * 24 * 60, 50},
+		{time.Hour * 24 * 8, 100},
+		{time.Hour * 24 * 2, 150},
+		{time.Hour * 12, 200},
+		{0, 220},
+	}
+	for _, x := range values {
+		err = AddSnapshot(Snapshot{Timestamp: now.Add(-x.age), Currency: "USD", Total: x.total})
+		if err != nil {
+			t.Fatalf("Test failed. TestSnapshots: Error, %s", err)
+		}
+	}
+
+	// Reloading drops the snapshot older than the maximum age
+	err = LoadSnapshots(file, time.Hour*24*30)
+	if err != nil {
+		t.Fatalf("Test failed. TestSnapshots: Error, %s", err)
+	}
+	if len(GetSnapshots(time.Time{}, time.Time{})) != 4 {
+		t.Fatal("Test failed. TestSnapshots: snapshots not persisted")
+	}
+
+	result := GetSnapshots(now.Add(-time.Hour*24*3), now.Add(-time.Hour))
+	if len(result) != 2 || result[0].Total != 150 || result[1].Total != 200 {
+		t.Errorf("Test failed. TestSnapshots: unexpected range %+v", result)
+	}
+
+	daily, err := GetProfitLoss(time.Hour * 24)
+	if err != nil {
+		t.Fatalf("Test failed. TestSnapshots: Error, %s", err)
+	}
+	if daily.StartValue != 150 || daily.EndValue != 220 || daily.Change != 70 ||
+		math.Abs(daily.ChangePercent-46.666666667) > 1e-6 {
+		t.Errorf("Test failed. TestSnapshots: unexpected daily profit and loss %+v", daily)
+	}
+
+	weekly, err := GetProfitLoss(time.Hour * 24 * 7)
+	if err != nil {
+		t.Fatalf("Test failed. TestSnapshots: Error, %s", err)
+	}
+	if weekly.StartValue != 100 || weekly.Change != 120 || !weekly.Start.Equal(now.Add(-time.Hour*24*8)) {
+		t.Errorf("Test failed. TestSnapshots: unexpected weekly profit and loss %+v", weekly)
+	}
+}
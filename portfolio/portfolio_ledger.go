@@ -0,0 +1,434 @@
+package portfolio
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/mattkanwisher/cryptofiend/common"
+	"github.com/mattkanwisher/cryptofiend/currency"
+)
+
+// Cost basis methods used by a ledger to match disposals to acquisitions
+const (
+	MethodFIFO    = "FIFO"
+	MethodLIFO    = "LIFO"
+	MethodAverage = "AVERAGE"
+)
+
+// Types of ledger entry
+const (
+	EntryBuy        = "BUY"
+	EntrySell       = "SELL"
+	EntryDeposit    = "DEPOSIT"
+	EntryWithdrawal = "WITHDRAWAL"
+)
+
+var (
+	errInvalidMethod = errors.New("invalid cost basis method")
+	errInvalidEntry  = errors.New("invalid ledger entry")
+)
+
+// LedgerEntry is a trade or a transfer recorded in a ledger. Trades buy or
+// sell Amount of Asset at Price in Quote, QuoteRate is the value of one unit
+// of Quote and Fee the value of the fees paid, both in the ledger currency.
+// For deposits Price is the value of one unit of Asset in the ledger
+// currency. Entries are identified by their exchange and ID.
+type LedgerEntry struct {
+	ID        string    `json:"id"`
+	Exchange  string    `json:"exchange"`
+	Timestamp time.Time `json:"timestamp"`
+	Type      string    `json:"type"`
+	Asset     string    `json:"asset"`
+	Quote     string    `json:"quote,omitempty"`
+	Amount    float64   `json:"amount"`
+	Price     float64   `json:"price"`
+	QuoteRate float64   `json:"quote_rate,omitempty"`
+	Fee       float64   `json:"fee,omitempty"`
+}
+
+// Lot is an amount of an asset acquired at a cost
+type Lot struct {
+	Amount   float64   `json:"amount"`
+	Cost     float64   `json:"cost"`
+	Acquired time.Time `json:"acquired"`
+}
+
+// Disposal is an amount of an asset sold or traded away, along with the cost
+// of the lot it came from. Acquired is zero when the average cost method is
+// used.
+type Disposal struct {
+	Asset    string    `json:"asset"`
+	Exchange string    `json:"exchange"`
+	Acquired time.Time `json:"acquired"`
+	Disposed time.Time `json:"disposed"`
+	Amount   float64   `json:"amount"`
+	Proceeds float64   `json:"proceeds"`
+	Cost     float64   `json:"cost"`
+	Gain     float64   `json:"gain"`
+}
+
+// AssetProfitLoss is the realized and unrealized profit and loss of an asset.
+// Amount and Cost are the amount held and its cost basis, Value is what it is
+// worth at the current price.
+type AssetProfitLoss struct {
+	Asset      string  `json:"asset"`
+	Amount     float64 `json:"amount"`
+	Cost       float64 `json:"cost"`
+	Value      float64 `json:"value"`
+	Realized   float64 `json:"realized"`
+	Unrealized float64 `json:"unrealized"`
+	Priced     bool    `json:"priced"`
+}
+
+// Ledger tracks the lots of each asset acquired through trades and deposits
+// to work out the cost basis and gain of disposals. Lots are pooled across
+// exchanges, so transfers between addresses of the portfolio shouldn't be
+// recorded.
+type Ledger struct {
+	m        sync.RWMutex
+	method   string
+	currency string
+	file     string
+	entries  []LedgerEntry
+	ids      map[string]bool
+}
+
+// ledgerState is the result of replaying the entries of a ledger
+type ledgerState struct {
+	lots      map[string][]Lot
+	realized  map[string]float64
+	disposals []Disposal
+}
+
+// NewLedger returns a ledger using a cost basis method which values assets in
+// a fiat currency
+func NewLedger(method, fiat string) (*Ledger, error) {
+	method = common.StringToUpper(method)
+	if method != MethodFIFO && method != MethodLIFO && method != MethodAverage {
+		return nil, errInvalidMethod
+	}
+	return &Ledger{
+		method:   method,
+		currency: common.StringToUpper(fiat),
+		ids:      make(map[string]bool),
+	}, nil
+}
+
+// Load reads the entries persisted in file, which further entries are
+// appended to
+func (l *Ledger) Load(file string) error {
+	l.m.Lock()
+	defer l.m.Unlock()
+
+	l.file = file
+	data, err := common.ReadFile(file)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(nil, len(data)+1)
+	for scanner.Scan() {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		var e LedgerEntry
+		err = common.JSONDecode(scanner.Bytes(), &e)
+		if err != nil {
+			return err
+		}
+		l.add(e)
+	}
+	return nil
+}
+
+// add appends an entry unless one with the same ID was recorded, l.m must be
+// held
+func (l *Ledger) add(e LedgerEntry) bool {
+	key := e.Exchange + ":" + e.ID
+	if e.ID != "" && l.ids[key] {
+		return false
+	}
+	l.ids[key] = true
+	l.entries = append(l.entries, e)
+	return true
+}
+
+// Record validates and adds an entry, returning false if it was already
+// recorded. Quote rates, fees and deposit prices which aren't set are valued
+// at the current price.
+func (l *Ledger) Record(e LedgerEntry) (bool, error) {
+	e.Type = common.StringToUpper(e.Type)
+	e.Asset = common.StringToUpper(e.Asset)
+	e.Quote = common.StringToUpper(e.Quote)
+	if e.Asset == "" || e.Amount <= 0 || e.Price < 0 || e.Fee < 0 {
+		return false, errInvalidEntry
+	}
+	if e.Timestamp.IsZero() {
+		e.Timestamp = time.Now()
+	}
+
+	switch e.Type {
+	case EntryBuy, EntrySell:
+		if e.Quote == "" || e.Price == 0 {
+			return false, errInvalidEntry
+		}
+		if e.QuoteRate == 0 {
+			rate, err := GetFiatPrice(e.Quote, l.currency)
+			if err != nil {
+				return false, fmt.Errorf("unable to value %s: %s", e.Quote, err)
+			}
+			e.QuoteRate = rate
+		}
+	case EntryDeposit:
+		if e.Price == 0 {
+			price, err := GetFiatPrice(e.Asset, l.currency)
+			if err != nil {
+				return false, fmt.Errorf("unable to value %s: %s", e.Asset, err)
+			}
+			e.Price = price
+		}
+	case EntryWithdrawal:
+	default:
+		return false, errInvalidEntry
+	}
+
+	l.m.Lock()
+	defer l.m.Unlock()
+
+	if !l.add(e) {
+		return false, nil
+	}
+	if l.file == "" {
+		return true, nil
+	}
+
+	line, err := common.JSONEncode(e)
+	if err != nil {
+		return true, err
+	}
+
+	f, err := os.OpenFile(l.file, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return true, err
+	}
+	defer f.Close()
+
+	_, err = f.Write(append(line, '\n'))
+	return true, err
+}
+
+// GetEntries returns the recorded entries in time order
+func (l *Ledger) GetEntries() []LedgerEntry {
+	l.m.RLock()
+	defer l.m.RUnlock()
+
+	return l.sortedEntries()
+}
+
+// sortedEntries returns a copy of the entries in time order, l.m must be held
+func (l *Ledger) sortedEntries() []LedgerEntry {
+	entries := append([]LedgerEntry(nil), l.entries...)
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].Timestamp.Before(entries[j].Timestamp)
+	})
+	return entries
+}
+
+// replay works out the lots held and the disposals made by the entries
+func (l *Ledger) replay() ledgerState {
+	l.m.RLock()
+	entries := l.sortedEntries()
+	l.m.RUnlock()
+
+	s := ledgerState{
+		lots:     make(map[string][]Lot),
+		realized: make(map[string]float64),
+	}
+	for _, e := range entries {
+		switch e.Type {
+		case EntryBuy:
+			value := e.Amount * e.Price * e.QuoteRate
+			s.acquire(l.method, e.Asset, Lot{e.Amount, value + e.Fee, e.Timestamp})
+			if !currency.IsFiatCurrency(e.Quote) {
+				s.dispose(l.method, e, e.Quote, e.Amount*e.Price, value)
+			}
+		case EntrySell:
+			value := e.Amount * e.Price * e.QuoteRate
+			s.dispose(l.method, e, e.Asset, e.Amount, value-e.Fee)
+			if !currency.IsFiatCurrency(e.Quote) {
+				s.acquire(l.method, e.Quote, Lot{e.Amount * e.Price, value, e.Timestamp})
+			}
+		case EntryDeposit:
+			s.acquire(l.method, e.Asset, Lot{e.Amount, e.Amount * e.Price, e.Timestamp})
+		case EntryWithdrawal:
+			s.take(l.method, e.Asset, e.Amount)
+		}
+	}
+	return s
+}
+
+// acquire adds a lot of an asset, with the average cost method all lots are
+// merged into one
+func (s *ledgerState) acquire(method, asset string, lot Lot) {
+	lots := s.lots[asset]
+	if method == MethodAverage && len(lots) > 0 {
+		lots[0].Amount += lot.Amount
+		lots[0].Cost += lot.Cost
+		return
+	}
+	s.lots[asset] = append(lots, lot)
+}
+
+// take removes an amount of an asset from its lots, returning the parts of
+// the lots removed. Amounts exceeding the lots held have no cost basis.
+func (s *ledgerState) take(method, asset string, amount float64) []Lot {
+	var result []Lot
+	lots := s.lots[asset]
+	for amount > 0 && len(lots) > 0 {
+		i := 0
+		if method == MethodLIFO {
+			i = len(lots) - 1
+		}
+
+		lot := lots[i]
+		if lot.Amount > amount {
+			part := Lot{amount, lot.Cost * amount / lot.Amount, lot.Acquired}
+			lots[i].Amount -= part.Amount
+			lots[i].Cost -= part.Cost
+			result = append(result, part)
+			amount = 0
+			break
+		}
+
+		result = append(result, lot)
+		amount -= lot.Amount
+		lots = append(lots[:i], lots[i+1:]...)
+	}
+	s.lots[asset] = lots
+
+	if amount > 0 {
+		result = append(result, Lot{Amount: amount})
+	}
+	if method == MethodAverage {
+		for i := range result {
+			result[i].Acquired = time.Time{}
+		}
+	}
+	return result
+}
+
+// dispose removes an amount of an asset sold for proceeds, realizing the gain
+// on each part of the lots it came from
+func (s *ledgerState) dispose(method string, e LedgerEntry, asset string, amount, proceeds float64) {
+	for _, lot := range s.take(method, asset, amount) {
+		d := Disposal{
+			Asset:    asset,
+			Exchange: e.Exchange,
+			Acquired: lot.Acquired,
+			Disposed: e.Timestamp,
+			Amount:   lot.Amount,
+			Proceeds: proceeds * lot.Amount / amount,
+			Cost:     lot.Cost,
+		}
+		d.Gain = d.Proceeds - d.Cost
+		s.realized[asset] += d.Gain
+		s.disposals = append(s.disposals, d)
+	}
+}
+
+// GetDisposals returns the disposals made between start and end inclusive, a
+// zero end is treated as now
+func (l *Ledger) GetDisposals(start, end time.Time) []Disposal {
+	result := []Disposal{}
+	for _, d := range l.replay().disposals {
+		if d.Disposed.Before(start) || (!end.IsZero() && d.Disposed.After(end)) {
+			continue
+		}
+		result = append(result, d)
+	}
+	return result
+}
+
+// GetProfitLoss returns the realized and unrealized profit and loss of every
+// asset which was held, valuing the amounts still held at the current price
+func (l *Ledger) GetProfitLoss() []AssetProfitLoss {
+	s := l.replay()
+
+	assets := make(map[string]bool)
+	for asset := range s.lots {
+		assets[asset] = true
+	}
+	for asset := range s.realized {
+		assets[asset] = true
+	}
+
+	var result []AssetProfitLoss
+	for asset := range assets {
+		x := AssetProfitLoss{Asset: asset, Realized: s.realized[asset]}
+		for _, lot := range s.lots[asset] {
+			x.Amount += lot.Amount
+			x.Cost += lot.Cost
+		}
+
+		if price, err := GetFiatPrice(asset, l.currency); err == nil {
+			x.Priced = true
+			x.Value = x.Amount * price
+			x.Unrealized = x.Value - x.Cost
+		}
+		result = append(result, x)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Asset < result[j].Asset
+	})
+	return result
+}
+
+// ExportCapitalGains writes the disposals made in a year to a CSV file
+func (l *Ledger) ExportCapitalGains(year int, path string) error {
+	start := time.Date(year, time.January, 1, 0, 0, 0, 0, time.UTC)
+	end := start.AddDate(1, 0, 0).Add(-time.Nanosecond)
+
+	data := [][]string{{
+		"Asset", "Amount", "Date Acquired", "Date Disposed",
+		"Proceeds (" + l.currency + ")", "Cost Basis (" + l.currency + ")",
+		"Gain (" + l.currency + ")", "Exchange",
+	}}
+	for _, d := range l.GetDisposals(start, end) {
+		acquired := "Various"
+		if !d.Acquired.IsZero() {
+			acquired = d.Acquired.UTC().Format("2006-01-02")
+		}
+		data = append(data, []string{
+			d.Asset,
+			strconv.FormatFloat(d.Amount, 'f', -1, 64),
+			acquired,
+			d.Disposed.UTC().Format("2006-01-02"),
+			strconv.FormatFloat(d.Proceeds, 'f', 2, 64),
+			strconv.FormatFloat(d.Cost, 'f', 2, 64),
+			strconv.FormatFloat(d.Gain, 'f', 2, 64),
+			d.Exchange,
+		})
+	}
+	return common.OutputCSV(path, data)
+}
+
+// GetMethod returns the cost basis method of the ledger
+func (l *Ledger) GetMethod() string {
+	return l.method
+}
+
+// GetCurrency returns the currency the ledger values assets in
+func (l *Ledger) GetCurrency() string {
+	return l.currency
+}
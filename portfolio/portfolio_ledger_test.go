@@ -0,0 +1,233 @@
+package portfolio
+
+import (
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mattkanwisher/cryptofiend/currency"
+	"github.com/mattkanwisher/cryptofiend/currency/pair"
+	"github.com/mattkanwisher/cryptofiend/exchanges/ticker"
+)
+
+var ledgerStart = time.Date(2017, time.March, 1, 0, 0, 0, 0, time.UTC)
+
+func newTestLedger(t *testing.T, method string) *Ledger {
+	currency.BaseCurrencies = "USD,EUR"
+	l, err := NewLedger(method, "usd")
+	if err != nil {
+		t.Fatalf("Test failed. NewLedger: Error, %s", err)
+	}
+
+	entries := []LedgerEntry{
+		{ID: "1", Type: EntryBuy, Asset: "BTC", Quote: "USD", Amount: 1, Price: 1000, Fee: 10},
+		{ID: "2", Type: EntryBuy, Asset: "BTC", Quote: "USD", Amount: 1, Price: 2000},
+		{ID: "3", Type: EntrySell, Asset: "BTC", Quote: "USD", Amount: 1.5, Price: 3000, Fee: 30},
+	}
+	for i, e := range entries {
+		e.Exchange = "Test"
+		e.Timestamp = ledgerStart.AddDate(0, i, 0)
+		_, err = l.Record(e)
+		if err != nil {
+			t.Fatalf("Test failed. Record: Error, %s", err)
+		}
+	}
+	return l
+}
+
+func findProfitLoss(result []AssetProfitLoss, asset string) AssetProfitLoss {
+	for _, x := range result {
+		if x.Asset == asset {
+			return x
+		}
+	}
+	return AssetProfitLoss{}
+}
+
+func TestNewLedger(t *testing.T) {
+	_, err := NewLedger("fifo", "USD")
+	if err != nil {
+		t.Errorf("Test failed. TestNewLedger: Error, %s", err)
+	}
+	_, err = NewLedger("HIFO", "USD")
+	if err == nil {
+		t.Error("Test failed. TestNewLedger: created a ledger with an invalid method")
+	}
+}
+
+func TestLedgerRecord(t *testing.T) {
+	l := newTestLedger(t, MethodFIFO)
+
+	recorded, err := l.Record(LedgerEntry{ID: "1", Exchange: "Test", Type: EntryBuy, Asset: "BTC",
+		Quote: "USD", Amount: 5, Price: 1})
+	if err != nil || recorded {
+		t.Errorf("Test failed. TestLedgerRecord: duplicate entry recorded %v, %v", recorded, err)
+	}
+
+	invalid := []LedgerEntry{
+		{Type: "GIFT", Asset: "BTC", Amount: 1},
+		{Type: EntryBuy, Asset: "BTC", Amount: 1, Price: 1},
+		{Type: EntrySell, Asset: "BTC", Quote: "USD", Amount: -1, Price: 1},
+		{Type: EntryDeposit, Asset: "NOPE", Amount: 1},
+	}
+	for _, e := range invalid {
+		_, err = l.Record(e)
+		if err == nil {
+			t.Errorf("Test failed. TestLedgerRecord: recorded invalid entry %+v", e)
+		}
+	}
+
+	if len(l.GetEntries()) != 3 {
+		t.Errorf("Test failed. TestLedgerRecord: unexpected entries %+v", l.GetEntries())
+	}
+}
+
+func TestLedgerFIFO(t *testing.T) {
+	l := newTestLedger(t, MethodFIFO)
+
+	disposals := l.GetDisposals(time.Time{}, time.Time{})
+	if len(disposals) != 2 {
+		t.Fatalf("Test failed. TestLedgerFIFO: unexpected disposals %+v", disposals)
+	}
+	if disposals[0].Amount != 1 || disposals[0].Cost != 1010 || disposals[0].Proceeds != 2980 ||
+		!disposals[0].Acquired.Equal(ledgerStart) {
+		t.Errorf("Test failed. TestLedgerFIFO: unexpected first disposal %+v", disposals[0])
+	}
+	if disposals[1].Amount != 0.5 || disposals[1].Cost != 1000 || disposals[1].Proceeds != 1490 {
+		t.Errorf("Test failed. TestLedgerFIFO: unexpected second disposal %+v", disposals[1])
+	}
+
+	btc := findProfitLoss(l.GetProfitLoss(), "BTC")
+	if btc.Amount != 0.5 || btc.Cost != 1000 || btc.Realized != 2460 {
+		t.Errorf("Test failed. TestLedgerFIFO: unexpected profit and loss %+v", btc)
+	}
+}
+
+func TestLedgerLIFO(t *testing.T) {
+	l := newTestLedger(t, MethodLIFO)
+
+	btc := findProfitLoss(l.GetProfitLoss(), "BTC")
+	if btc.Amount != 0.5 || btc.Cost != 505 || btc.Realized != 1965 {
+		t.Errorf("Test failed. TestLedgerLIFO: unexpected profit and loss %+v", btc)
+	}
+}
+
+func TestLedgerAverage(t *testing.T) {
+	l := newTestLedger(t, MethodAverage)
+
+	disposals := l.GetDisposals(time.Time{}, time.Time{})
+	if len(disposals) != 1 || !disposals[0].Acquired.IsZero() || disposals[0].Cost != 2257.5 {
+		t.Errorf("Test failed. TestLedgerAverage: unexpected disposals %+v", disposals)
+	}
+
+	btc := findProfitLoss(l.GetProfitLoss(), "BTC")
+	if btc.Amount != 0.5 || btc.Cost != 752.5 || btc.Realized != 2212.5 {
+		t.Errorf("Test failed. TestLedgerAverage: unexpected profit and loss %+v", btc)
+	}
+}
+
+func TestLedgerCryptoQuote(t *testing.T) {
+	l := newTestLedger(t, MethodFIFO)
+
+	// Buying ETH with BTC disposes of the BTC at the value of the ETH
+	_, err := l.Record(LedgerEntry{ID: "4", Exchange: "Test", Timestamp: ledgerStart.AddDate(0, 3, 0),
+		Type: EntryBuy, Asset: "ETH", Quote: "BTC", Amount: 10, Price: 0.05, QuoteRate: 4000})
+	if err != nil {
+		t.Fatalf("Test failed. TestLedgerCryptoQuote: Error, %s", err)
+	}
+	_, err = l.Record(LedgerEntry{ID: "5", Exchange: "Test", Timestamp: ledgerStart.AddDate(0, 4, 0),
+		Type: EntryWithdrawal, Asset: "ETH", Amount: 4})
+	if err != nil {
+		t.Fatalf("Test failed. TestLedgerCryptoQuote: Error, %s", err)
+	}
+
+	result := l.GetProfitLoss()
+	btc := findProfitLoss(result, "BTC")
+	if btc.Amount != 0 || btc.Realized != 3460 {
+		t.Errorf("Test failed. TestLedgerCryptoQuote: unexpected BTC profit and loss %+v", btc)
+	}
+	eth := findProfitLoss(result, "ETH")
+	if eth.Amount != 6 || eth.Cost != 1200 || eth.Realized != 0 {
+		t.Errorf("Test failed. TestLedgerCryptoQuote: unexpected ETH profit and loss %+v", eth)
+	}
+}
+
+func TestLedgerUnrealized(t *testing.T) {
+	l := newTestLedger(t, MethodFIFO)
+	ticker.ProcessTicker("LedgerA", pair.NewCurrencyPair("BTC", "USD"), ticker.Price{Last: 4000}, ticker.Spot)
+	defer ticker.ProcessTicker("LedgerA", pair.NewCurrencyPair("BTC", "USD"), ticker.Price{}, ticker.Spot)
+
+	price, err := GetFiatPrice("BTC", "USD")
+	if err != nil {
+		t.Fatalf("Test failed. TestLedgerUnrealized: Error, %s", err)
+	}
+
+	btc := findProfitLoss(l.GetProfitLoss(), "BTC")
+	if !btc.Priced || btc.Value != 0.5*price || math.Abs(btc.Unrealized-(0.5*price-1000)) > 1e-9 {
+		t.Errorf("Test failed. TestLedgerUnrealized: unexpected profit and loss %+v", btc)
+	}
+}
+
+func TestLedgerPersistence(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ledger")
+	if err != nil {
+		t.Fatalf("Test failed. TestLedgerPersistence: Error, %s", err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "ledger.dat")
+
+	l, _ := NewLedger(MethodFIFO, "USD")
+	err = l.Load(file)
+	if err != nil {
+		t.Fatalf("Test failed. TestLedgerPersistence: Error, %s", err)
+	}
+	for _, id := range []string{"1", "2", "1"} {
+		_, err = l.Record(LedgerEntry{ID: id, Exchange: "Test", Type: EntryBuy, Asset: "BTC",
+			Quote: "USD", Amount: 1, Price: 1000})
+		if err != nil {
+			t.Fatalf("Test failed. TestLedgerPersistence: Error, %s", err)
+		}
+	}
+
+	reloaded, _ := NewLedger(MethodFIFO, "USD")
+	err = reloaded.Load(file)
+	if err != nil {
+		t.Fatalf("Test failed. TestLedgerPersistence: Error, %s", err)
+	}
+	if len(reloaded.GetEntries()) != 2 {
+		t.Errorf("Test failed. TestLedgerPersistence: unexpected entries %+v", reloaded.GetEntries())
+	}
+	recorded, _ := reloaded.Record(LedgerEntry{ID: "2", Exchange: "Test", Type: EntryBuy, Asset: "BTC",
+		Quote: "USD", Amount: 1, Price: 1000})
+	if recorded {
+		t.Error("Test failed. TestLedgerPersistence: loaded entry recorded again")
+	}
+}
+
+func TestExportCapitalGains(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ledger")
+	if err != nil {
+		t.Fatalf("Test failed. TestExportCapitalGains: Error, %s", err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "gains.csv")
+
+	l := newTestLedger(t, MethodFIFO)
+	err = l.ExportCapitalGains(2017, file)
+	if err != nil {
+		t.Fatalf("Test failed. TestExportCapitalGains: Error, %s", err)
+	}
+
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		t.Fatalf("Test failed. TestExportCapitalGains: Error, %s", err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 3 || !strings.HasPrefix(lines[1], "BTC,1,2017-03-01,2017-05-01,2980.00,1010.00,1970.00,Test") {
+		t.Errorf("Test failed. TestExportCapitalGains: unexpected output %q", data)
+	}
+}
@@ -0,0 +1,357 @@
+package portfolio
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/mattkanwisher/cryptofiend/common"
+)
+
+// Types of balance provider
+const (
+	ProviderCryptoID   = "cryptoid"
+	ProviderEtherchain = "etherchain"
+	ProviderBitcoind   = "bitcoind"
+	ProviderElectrum   = "electrum"
+	ProviderEthereum   = "ethereum"
+)
+
+var (
+	errUnknownProvider = errors.New("unknown balance provider type")
+	errNoProviderURL   = errors.New("balance provider requires a URL")
+	errNoProviders     = errors.New("no balance providers for coin")
+)
+
+// BalanceProvider looks up the balances of addresses on a blockchain.
+// Balances are keyed by address and in the unit stored in the portfolio, wei
+// for ETH.
+type BalanceProvider interface {
+	GetName() string
+	GetBalances(coin string, addresses []string) (map[string]float64, error)
+}
+
+// ProviderConfig is a balance provider of a coin. Type is one of the provider
+// types, the self-hosted ones being JSON-RPC endpoints at URL which may
+// require a username and password.
+type ProviderConfig struct {
+	Name     string `json:",omitempty"`
+	Type     string
+	URL      string `json:",omitempty"`
+	Username string `json:",omitempty"`
+	Password string `json:",omitempty"`
+}
+
+// cachedBalance is an address balance and when it was looked up
+type cachedBalance struct {
+	balance float64
+	updated time.Time
+}
+
+// providers is the registry of the balance providers of each coin, tried in
+// order until one succeeds, and the cache of balances they returned
+var providers = struct {
+	m        sync.RWMutex
+	coins    map[string][]BalanceProvider
+	cache    map[string]cachedBalance
+	cacheTTL time.Duration
+}{
+	coins: make(map[string][]BalanceProvider),
+	cache: make(map[string]cachedBalance),
+}
+
+// NewProvider returns the balance provider described by a config
+func NewProvider(cfg ProviderConfig) (BalanceProvider, error) {
+	providerType := common.StringToLower(cfg.Type)
+	name := cfg.Name
+	if name == "" {
+		name = providerType
+	}
+
+	switch providerType {
+	case ProviderCryptoID:
+		return &CryptoIDProvider{}, nil
+	case ProviderEtherchain:
+		return &EtherchainProvider{}, nil
+	case ProviderBitcoind, ProviderElectrum, ProviderEthereum:
+		if cfg.URL == "" {
+			return nil, errNoProviderURL
+		}
+		return &RPCProvider{
+			Name:     name,
+			Type:     providerType,
+			URL:      cfg.URL,
+			Username: cfg.Username,
+			Password: cfg.Password,
+		}, nil
+	}
+	return nil, errUnknownProvider
+}
+
+// SetupProviders replaces the balance providers of each coin with the ones
+// configured, which are tried in order. Coins without providers use
+// Etherchain for ETH and CryptoID for the others. Balances are cached for
+// cacheTTL.
+func SetupProviders(coins map[string][]ProviderConfig, cacheTTL time.Duration) error {
+	registry := make(map[string][]BalanceProvider)
+	for coin, configs := range coins {
+		coin = common.StringToUpper(coin)
+		for _, cfg := range configs {
+			p, err := NewProvider(cfg)
+			if err != nil {
+				return fmt.Errorf("%s balance provider %s: %s", coin, cfg.Type, err)
+			}
+			registry[coin] = append(registry[coin], p)
+		}
+	}
+
+	providers.m.Lock()
+	defer providers.m.Unlock()
+	providers.coins = registry
+	providers.cache = make(map[string]cachedBalance)
+	providers.cacheTTL = cacheTTL
+	return nil
+}
+
+// RegisterProvider adds a balance provider to the end of the ones of a coin
+func RegisterProvider(coin string, p BalanceProvider) {
+	providers.m.Lock()
+	defer providers.m.Unlock()
+
+	coin = common.StringToUpper(coin)
+	providers.coins[coin] = append(providers.coins[coin], p)
+}
+
+// GetProviders returns the balance providers of a coin in the order they are
+// tried
+func GetProviders(coin string) []BalanceProvider {
+	coin = common.StringToUpper(coin)
+
+	providers.m.RLock()
+	defer providers.m.RUnlock()
+
+	if result, ok := providers.coins[coin]; ok {
+		return append([]BalanceProvider(nil), result...)
+	}
+	if coin == "ETH" {
+		return []BalanceProvider{&EtherchainProvider{}}
+	}
+	return []BalanceProvider{&CryptoIDProvider{}}
+}
+
+// GetBalances returns the balances of addresses of a coin. Balances looked up
+// within the cache duration are reused, the others are requested from each
+// provider of the coin in turn until one of them succeeds.
+func GetBalances(coin string, addresses []string) (map[string]float64, error) {
+	coin = common.StringToUpper(coin)
+	result := make(map[string]float64)
+	var missing []string
+
+	providers.m.RLock()
+	for _, x := range addresses {
+		cached, ok := providers.cache[coin+":"+x]
+		if ok && time.Since(cached.updated) < providers.cacheTTL {
+			result[x] = cached.balance
+			continue
+		}
+		missing = append(missing, x)
+	}
+	providers.m.RUnlock()
+
+	if len(missing) == 0 {
+		return result, nil
+	}
+
+	err := errNoProviders
+	for _, p := range GetProviders(coin) {
+		var balances map[string]float64
+		balances, err = p.GetBalances(coin, missing)
+		if err != nil {
+			log.Printf("Balance provider %s failed for %s. Error: %s\n", p.GetName(), coin, err)
+			continue
+		}
+
+		now := time.Now()
+		providers.m.Lock()
+		for address, balance := range balances {
+			result[address] = balance
+			providers.cache[coin+":"+address] = cachedBalance{balance, now}
+		}
+		providers.m.Unlock()
+		return result, nil
+	}
+	return nil, err
+}
+
+// CryptoIDProvider looks up balances with the CryptoID explorer
+type CryptoIDProvider struct{}
+
+// GetName returns the name of the provider
+func (c *CryptoIDProvider) GetName() string {
+	return ProviderCryptoID
+}
+
+// GetBalances returns the balances of addresses of a coin
+func (c *CryptoIDProvider) GetBalances(coin string, addresses []string) (map[string]float64, error) {
+	result := make(map[string]float64)
+	for _, x := range addresses {
+		balance, err := GetCryptoIDAddress(x, coin)
+		if err != nil {
+			return nil, err
+		}
+		result[x] = balance
+	}
+	return result, nil
+}
+
+// EtherchainProvider looks up ETH balances with the Etherchain explorer
+type EtherchainProvider struct{}
+
+// GetName returns the name of the provider
+func (e *EtherchainProvider) GetName() string {
+	return ProviderEtherchain
+}
+
+// GetBalances returns the balances of ETH addresses in wei
+func (e *EtherchainProvider) GetBalances(coin string, addresses []string) (map[string]float64, error) {
+	response, err := GetEthereumBalance(addresses)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(map[string]float64)
+	for _, x := range response.Data {
+		result[x.Address] = x.Balance
+	}
+	return result, nil
+}
+
+// RPCProvider looks up balances with a self-hosted node's JSON-RPC API.
+// Bitcoind scans the UTXO set for each address, Electrum asks its server for
+// the confirmed address balance and Ethereum nodes are asked for the latest
+// balance.
+type RPCProvider struct {
+	Name     string
+	Type     string
+	URL      string
+	Username string
+	Password string
+}
+
+// rpcRequest is a JSON-RPC request
+type rpcRequest struct {
+	JSONRPC string        `json:"jsonrpc"`
+	ID      int           `json:"id"`
+	Method  string        `json:"method"`
+	Params  []interface{} `json:"params"`
+}
+
+// rpcResponse is a JSON-RPC response, Result is decoded by the caller
+type rpcResponse struct {
+	Result interface{} `json:"result"`
+	Error  *struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// GetName returns the name of the provider
+func (r *RPCProvider) GetName() string {
+	return r.Name
+}
+
+// call sends a JSON-RPC request and decodes its result into result
+func (r *RPCProvider) call(method string, params []interface{}, result interface{}) error {
+	version := "1.0"
+	if r.Type == ProviderEthereum {
+		version = "2.0"
+	}
+	body, err := common.JSONEncode(rpcRequest{version, 1, method, params})
+	if err != nil {
+		return err
+	}
+
+	headers := http.Header{}
+	headers.Set("Content-Type", "application/json")
+	if r.Username != "" || r.Password != "" {
+		headers.Set("Authorization", "Basic "+common.Base64Encode([]byte(r.Username+":"+r.Password)))
+	}
+
+	resp, status, err := common.SendHTTPRequestPrivate("POST", r.URL, headers, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	response := rpcResponse{Result: result}
+	err = common.JSONDecode([]byte(resp), &response)
+	if err != nil {
+		if status != http.StatusOK {
+			return fmt.Errorf("%s returned HTTP status %d", r.Name, status)
+		}
+		return err
+	}
+	if response.Error != nil {
+		return fmt.Errorf("%s %s error %d: %s", r.Name, method, response.Error.Code,
+			response.Error.Message)
+	}
+	return nil
+}
+
+// GetBalances returns the balances of addresses of a coin
+func (r *RPCProvider) GetBalances(coin string, addresses []string) (map[string]float64, error) {
+	result := make(map[string]float64)
+	for _, x := range addresses {
+		var balance float64
+		var err error
+		switch r.Type {
+		case ProviderBitcoind:
+			var scan struct {
+				TotalAmount float64 `json:"total_amount"`
+			}
+			err = r.call("scantxoutset", []interface{}{"start", []string{"addr(" + x + ")"}}, &scan)
+			balance = scan.TotalAmount
+		case ProviderElectrum:
+			var address struct {
+				Confirmed   string `json:"confirmed"`
+				Unconfirmed string `json:"unconfirmed"`
+			}
+			err = r.call("getaddressbalance", []interface{}{x}, &address)
+			if err == nil {
+				balance, err = strconv.ParseFloat(address.Confirmed, 64)
+			}
+		case ProviderEthereum:
+			var wei string
+			err = r.call("eth_getBalance", []interface{}{x, "latest"}, &wei)
+			if err == nil {
+				balance, err = parseHexQuantity(wei)
+			}
+		default:
+			err = errUnknownProvider
+		}
+		if err != nil {
+			return nil, err
+		}
+		result[x] = balance
+	}
+	return result, nil
+}
+
+// parseHexQuantity parses a hex encoded JSON-RPC quantity such as a balance
+// in wei
+func parseHexQuantity(s string) (float64, error) {
+	if len(s) < 3 || s[:2] != "0x" {
+		return 0, fmt.Errorf("invalid quantity %q", s)
+	}
+	n, ok := new(big.Int).SetString(s[2:], 16)
+	if !ok {
+		return 0, fmt.Errorf("invalid quantity %q", s)
+	}
+	f, _ := new(big.Float).SetInt(n).Float64()
+	return f, nil
+}
@@ -0,0 +1,162 @@
+package portfolio
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+type testProvider struct {
+	name     string
+	err      error
+	balance  float64
+	requests int
+}
+
+func (p *testProvider) GetName() string {
+	return p.name
+}
+
+func (p *testProvider) GetBalances(coin string, addresses []string) (map[string]float64, error) {
+	p.requests++
+	if p.err != nil {
+		return nil, p.err
+	}
+	result := make(map[string]float64)
+	for _, x := range addresses {
+		result[x] = p.balance
+	}
+	return result, nil
+}
+
+func TestNewProvider(t *testing.T) {
+	p, err := NewProvider(ProviderConfig{Type: "Bitcoind", URL: "http://127.0.0.1:8332"})
+	if err != nil || p.GetName() != ProviderBitcoind {
+		t.Errorf("Test failed. TestNewProvider: unexpected provider %v, %v", p, err)
+	}
+
+	_, err = NewProvider(ProviderConfig{Type: ProviderEthereum})
+	if err == nil {
+		t.Error("Test failed. TestNewProvider: created a JSON-RPC provider without a URL")
+	}
+	_, err = NewProvider(ProviderConfig{Type: "blockchain"})
+	if err == nil {
+		t.Error("Test failed. TestNewProvider: created an unknown provider")
+	}
+}
+
+func TestGetBalances(t *testing.T) {
+	err := SetupProviders(nil, time.Minute)
+	if err != nil {
+		t.Fatalf("Test failed. TestGetBalances: Error, %s", err)
+	}
+	defer SetupProviders(nil, 0)
+
+	if _, ok := GetProviders("ETH")[0].(*EtherchainProvider); !ok {
+		t.Error("Test failed. TestGetBalances: ETH doesn't default to Etherchain")
+	}
+	if _, ok := GetProviders("LTC")[0].(*CryptoIDProvider); !ok {
+		t.Error("Test failed. TestGetBalances: LTC doesn't default to CryptoID")
+	}
+
+	failing := &testProvider{name: "failing", err: errors.New("unavailable")}
+	working := &testProvider{name: "working", balance: 2}
+	RegisterProvider("btc", failing)
+	RegisterProvider("BTC", working)
+
+	result, err := GetBalances("BTC", []string{"a", "b"})
+	if err != nil || result["a"] != 2 || result["b"] != 2 {
+		t.Fatalf("Test failed. TestGetBalances: unexpected balances %v, %v", result, err)
+	}
+	if failing.requests != 1 || working.requests != 1 {
+		t.Errorf("Test failed. TestGetBalances: providers not failed over %d %d",
+			failing.requests, working.requests)
+	}
+
+	// Cached balances aren't requested again
+	result, err = GetBalances("BTC", []string{"a", "c"})
+	if err != nil || len(result) != 2 || working.requests != 2 {
+		t.Errorf("Test failed. TestGetBalances: unexpected balances %v, %v", result, err)
+	}
+	result, err = GetBalances("BTC", []string{"a"})
+	if err != nil || result["a"] != 2 || failing.requests != 2 {
+		t.Errorf("Test failed. TestGetBalances: cached balance requested again")
+	}
+
+	working.err = errors.New("unavailable")
+	_, err = GetBalances("BTC", []string{"d"})
+	if err == nil {
+		t.Error("Test failed. TestGetBalances: no error when every provider failed")
+	}
+}
+
+func TestRPCProvider(t *testing.T) {
+	var methods []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, pass, _ := r.BasicAuth()
+		var request rpcRequest
+		json.NewDecoder(r.Body).Decode(&request)
+		methods = append(methods, request.Method)
+
+		switch request.Method {
+		case "scantxoutset":
+			if user != "user" || pass != "pass" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			w.Write([]byte(`{"result":{"success":true,"total_amount":1.5},"error":null,"id":1}`))
+		case "getaddressbalance":
+			w.Write([]byte(`{"result":{"confirmed":"0.25","unconfirmed":"0.1"},"id":1}`))
+		case "eth_getBalance":
+			w.Write([]byte(`{"jsonrpc":"2.0","result":"0xde0b6b3a7640000","id":1}`))
+		default:
+			w.Write([]byte(`{"result":null,"error":{"code":-32601,"message":"Method not found"},"id":1}`))
+		}
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		provider ProviderConfig
+		balance  float64
+	}{
+		{ProviderConfig{Type: ProviderBitcoind, URL: server.URL, Username: "user", Password: "pass"}, 1.5},
+		{ProviderConfig{Type: ProviderElectrum, URL: server.URL}, 0.25},
+		{ProviderConfig{Type: ProviderEthereum, URL: server.URL}, 1e18},
+	}
+	for _, test := range tests {
+		p, _ := NewProvider(test.provider)
+		result, err := p.GetBalances("BTC", []string{"address"})
+		if err != nil || result["address"] != test.balance {
+			t.Errorf("Test failed. TestRPCProvider: unexpected %s balances %v, %v",
+				test.provider.Type, result, err)
+		}
+	}
+
+	p, _ := NewProvider(ProviderConfig{Type: ProviderBitcoind, URL: server.URL})
+	_, err := p.GetBalances("BTC", []string{"address"})
+	if err == nil {
+		t.Error("Test failed. TestRPCProvider: unauthorised request succeeded")
+	}
+
+	rpc := &RPCProvider{Name: "test", URL: server.URL}
+	err = rpc.call("getbalance", nil, nil)
+	if err == nil {
+		t.Error("Test failed. TestRPCProvider: RPC error not returned")
+	}
+}
+
+func TestParseHexQuantity(t *testing.T) {
+	value, err := parseHexQuantity("0x0")
+	if err != nil || value != 0 {
+		t.Errorf("Test failed. TestParseHexQuantity: unexpected value %f, %v", value, err)
+	}
+	for _, x := range []string{"", "0x", "12", "0xzz"} {
+		_, err = parseHexQuantity(x)
+		if err == nil {
+			t.Errorf("Test failed. TestParseHexQuantity: parsed %q", x)
+		}
+	}
+}
@@ -0,0 +1,327 @@
+package portfolio
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// Types of portfolio change published to subscribers
+const (
+	ChangeAdded   = "added"
+	ChangeUpdated = "updated"
+	ChangeRemoved = "removed"
+
+	// ChangeBufferSize is the number of changes buffered for a subscriber,
+	// further changes are dropped until it catches up
+	ChangeBufferSize = 100
+)
+
+// Change reports a portfolio entry being added, updated or removed. Address is
+// the entry after the change, or before it when it was removed.
+type Change struct {
+	Type            string
+	Address         Address
+	PreviousBalance float64
+	Timestamp       time.Time
+}
+
+var changes struct {
+	m           sync.Mutex
+	subscribers []chan Change
+}
+
+// SubscribeChanges returns a channel which receives every subsequent change to
+// the portfolio
+func SubscribeChanges() <-chan Change {
+	changes.m.Lock()
+	defer changes.m.Unlock()
+
+	ch := make(chan Change, ChangeBufferSize)
+	changes.subscribers = append(changes.subscribers, ch)
+	return ch
+}
+
+// UnsubscribeChanges stops portfolio changes being sent to a channel returned
+// by SubscribeChanges and closes it
+func UnsubscribeChanges(ch <-chan Change) {
+	changes.m.Lock()
+	defer changes.m.Unlock()
+
+	for i, x := range changes.subscribers {
+		if x == ch {
+			changes.subscribers = append(changes.subscribers[:i], changes.subscribers[i+1:]...)
+			close(x)
+			return
+		}
+	}
+}
+
+// publishChange delivers a portfolio change to all subscribers
+func publishChange(changeType string, address Address, previous float64) {
+	changes.m.Lock()
+	defer changes.m.Unlock()
+
+	change := Change{
+		Type:            changeType,
+		Address:         address,
+		PreviousBalance: previous,
+		Timestamp:       time.Now(),
+	}
+	for _, ch := range changes.subscribers {
+		select {
+		case ch <- change:
+		default:
+		}
+	}
+}
+
+// Service guards the portfolio shared by the bot's routines, REST handlers and
+// events with a lock, and publishes the changes made to it. Balances are
+// looked up without holding the lock, so readers aren't kept waiting on
+// balance providers.
+type Service struct {
+	m    sync.RWMutex
+	base Base
+}
+
+// Base returns a copy of the portfolio entries
+func (s *Service) Base() Base {
+	s.m.RLock()
+	defer s.m.RUnlock()
+
+	return Base{Addresses: append([]Address(nil), s.base.Addresses...)}
+}
+
+// SeedPortfolio replaces the portfolio entries with a copy of those of port
+func (s *Service) SeedPortfolio(port Base) {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	s.base.Addresses = append([]Address(nil), port.Addresses...)
+}
+
+// GetAddressBalance returns the balance of an entry by address, coin type and
+// description
+func (s *Service) GetAddressBalance(address, coinType, description string) (float64, bool) {
+	s.m.RLock()
+	defer s.m.RUnlock()
+
+	return s.base.GetAddressBalance(address, coinType, description)
+}
+
+// AddressExists checks to see if there is an address associated with the
+// portfolio
+func (s *Service) AddressExists(address string) bool {
+	s.m.RLock()
+	defer s.m.RUnlock()
+
+	return s.base.AddressExists(address)
+}
+
+// GetPortfolioByExchange returns currency portfolio amount by exchange
+func (s *Service) GetPortfolioByExchange(exchangeName string) map[string]float64 {
+	s.m.RLock()
+	defer s.m.RUnlock()
+
+	return s.base.GetPortfolioByExchange(exchangeName)
+}
+
+// GetExchangePortfolio returns the coin totals held on exchanges
+func (s *Service) GetExchangePortfolio() map[string]float64 {
+	s.m.RLock()
+	defer s.m.RUnlock()
+
+	return s.base.GetExchangePortfolio()
+}
+
+// GetPersonalPortfolio returns the coin totals held in personal addresses
+func (s *Service) GetPersonalPortfolio() map[string]float64 {
+	s.m.RLock()
+	defer s.m.RUnlock()
+
+	return s.base.GetPersonalPortfolio()
+}
+
+// GetPortfolioSummary returns the complete portfolio summary
+func (s *Service) GetPortfolioSummary() Summary {
+	s.m.RLock()
+	defer s.m.RUnlock()
+
+	return s.base.GetPortfolioSummary()
+}
+
+// GetPortfolioGroupedCoin returns the personal addresses grouped by coin
+func (s *Service) GetPortfolioGroupedCoin() map[string][]string {
+	s.m.RLock()
+	defer s.m.RUnlock()
+
+	return s.base.GetPortfolioGroupedCoin()
+}
+
+// TakeSnapshot values a copy of the portfolio in a fiat currency
+func (s *Service) TakeSnapshot(fiat string) Snapshot {
+	p := s.Base()
+	return p.TakeSnapshot(fiat)
+}
+
+// UpdatePortfolio looks up the balances of the personal addresses of a coin
+// and stores those which changed
+func (s *Service) UpdatePortfolio(addresses []string, coinType string) bool {
+	p := s.Base()
+	success := p.UpdatePortfolio(addresses, coinType)
+
+	watched := make(map[string]bool)
+	for _, x := range addresses {
+		watched[x] = true
+	}
+	s.replace(p.Addresses, func(x Address) bool {
+		return x.CoinType == coinType && watched[x.Address] &&
+			x.Description != PortfolioAddressExchange && x.Description != PortfolioAddressToken
+	})
+	return success
+}
+
+// UpdateTokenBalances looks up the ERC-20 token balances of the personal
+// Ethereum addresses and stores those which changed
+func (s *Service) UpdateTokenBalances() bool {
+	p := s.Base()
+	success := p.UpdateTokenBalances()
+	s.replace(p.Addresses, func(x Address) bool {
+		return x.Description == PortfolioAddressToken
+	})
+	return success
+}
+
+// AddExchangeAddress adds or updates the balance held on an exchange in a coin
+func (s *Service) AddExchangeAddress(exchangeName, coinType string, balance float64) {
+	p := s.Base()
+	p.AddExchangeAddress(exchangeName, coinType, balance)
+	s.replace(p.Addresses, func(x Address) bool {
+		return x.Address == exchangeName && x.CoinType == coinType
+	})
+}
+
+// UpdateExchangeAddressBalance updates the balance held on an exchange in a
+// coin
+func (s *Service) UpdateExchangeAddressBalance(exchangeName, coinType string, balance float64) {
+	p := s.Base()
+	p.UpdateExchangeAddressBalance(exchangeName, coinType, balance)
+	s.replace(p.Addresses, func(x Address) bool {
+		return x.Address == exchangeName && x.CoinType == coinType
+	})
+}
+
+// SetExchangeBalance stores the balance held on an exchange in a currency,
+// removing it when it is zero
+func (s *Service) SetExchangeBalance(exchangeName string, balance ExchangeAccountCurrencyInfo) {
+	var updated []Address
+	if balance.TotalValue > 0 {
+		updated = append(updated, exchangeAddress(exchangeName, balance))
+	}
+	s.replace(updated, func(x Address) bool {
+		return x.Description == PortfolioAddressExchange && x.Address == exchangeName &&
+			x.CoinType == balance.CurrencyName
+	})
+}
+
+// SetExchangeTotal stores the total balance held on an exchange in a currency
+// and keeps its last known hold, for exchange streams which only report
+// totals. The hold is capped at the new total.
+func (s *Service) SetExchangeTotal(exchangeName, coinType string, total float64) {
+	balance := ExchangeAccountCurrencyInfo{CurrencyName: coinType, TotalValue: total}
+	s.m.RLock()
+	for _, x := range s.base.Addresses {
+		if x.Description == PortfolioAddressExchange && x.Address == exchangeName &&
+			x.CoinType == coinType {
+			balance.Hold = math.Min(x.Hold, total)
+			break
+		}
+	}
+	s.m.RUnlock()
+	s.SetExchangeBalance(exchangeName, balance)
+}
+
+// SyncExchangeBalances reconciles the balances held on an exchange with its
+// account info, so that currencies no longer held are removed
+func (s *Service) SyncExchangeBalances(info ExchangeAccountInfo) {
+	if info.ExchangeName == "" {
+		return
+	}
+
+	var updated []Address
+	for _, x := range info.Currencies {
+		if x.TotalValue > 0 {
+			updated = append(updated, exchangeAddress(info.ExchangeName, x))
+		}
+	}
+	s.replace(updated, func(x Address) bool {
+		return x.Description == PortfolioAddressExchange && x.Address == info.ExchangeName
+	})
+}
+
+// exchangeAddress returns the portfolio entry of an exchange balance
+func exchangeAddress(exchangeName string, balance ExchangeAccountCurrencyInfo) Address {
+	return Address{
+		Address:     exchangeName,
+		CoinType:    balance.CurrencyName,
+		Balance:     balance.TotalValue,
+		Hold:        balance.Hold,
+		Description: PortfolioAddressExchange,
+	}
+}
+
+// addressKey identifies a portfolio entry
+type addressKey struct {
+	address, coinType, description string
+}
+
+func keyOf(x Address) addressKey {
+	return addressKey{x.Address, x.CoinType, x.Description}
+}
+
+// replace swaps the entries matched by match for the matching entries of
+// updated in place, appending the new ones and publishing each change
+func (s *Service) replace(updated []Address, match func(Address) bool) {
+	next := make(map[addressKey]Address)
+	var added []Address
+	for _, x := range updated {
+		if !match(x) {
+			continue
+		}
+		if _, ok := next[keyOf(x)]; !ok {
+			added = append(added, x)
+		}
+		next[keyOf(x)] = x
+	}
+
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	var result []Address
+	seen := make(map[addressKey]bool)
+	for _, x := range s.base.Addresses {
+		if !match(x) {
+			result = append(result, x)
+			continue
+		}
+		key := keyOf(x)
+		y, ok := next[key]
+		if !ok || seen[key] {
+			publishChange(ChangeRemoved, x, x.Balance)
+			continue
+		}
+		seen[key] = true
+		result = append(result, y)
+		if x.Balance != y.Balance || x.Hold != y.Hold {
+			publishChange(ChangeUpdated, y, x.Balance)
+		}
+	}
+	for _, x := range added {
+		if !seen[keyOf(x)] {
+			result = append(result, next[keyOf(x)])
+			publishChange(ChangeAdded, next[keyOf(x)], 0)
+		}
+	}
+	s.base.Addresses = result
+}
@@ -0,0 +1,148 @@
+package portfolio
+
+import (
+	"sync"
+	"testing"
+)
+
+func receiveChanges(ch <-chan Change) []Change {
+	var result []Change
+	for {
+		select {
+		case x := <-ch:
+			result = append(result, x)
+		default:
+			return result
+		}
+	}
+}
+
+func TestSyncExchangeBalances(t *testing.T) {
+	ch := SubscribeChanges()
+	defer UnsubscribeChanges(ch)
+
+	var s Service
+	s.SeedPortfolio(Base{Addresses: []Address{
+		{Address: "ANX", CoinType: "BTC", Balance: 1, Description: PortfolioAddressExchange},
+		{Address: "someaddress", CoinType: "LTC", Balance: 2, Description: PortfolioAddressPersonal},
+		{Address: "ANX", CoinType: "LTC", Balance: 3, Description: PortfolioAddressExchange},
+		{Address: "Bitfinex", CoinType: "LTC", Balance: 4, Description: PortfolioAddressExchange},
+	}})
+
+	s.SyncExchangeBalances(ExchangeAccountInfo{
+		ExchangeName: "ANX",
+		Currencies: []ExchangeAccountCurrencyInfo{
+			{CurrencyName: "BTC", TotalValue: 1.5, Hold: 0.5},
+			{CurrencyName: "ETH", TotalValue: 2},
+			{CurrencyName: "DASH"},
+		},
+	})
+
+	expected := []Address{
+		{Address: "ANX", CoinType: "BTC", Balance: 1.5, Hold: 0.5, Description: PortfolioAddressExchange},
+		{Address: "someaddress", CoinType: "LTC", Balance: 2, Description: PortfolioAddressPersonal},
+		{Address: "Bitfinex", CoinType: "LTC", Balance: 4, Description: PortfolioAddressExchange},
+		{Address: "ANX", CoinType: "ETH", Balance: 2, Description: PortfolioAddressExchange},
+	}
+	result := s.Base().Addresses
+	if len(result) != len(expected) {
+		t.Fatalf("Test failed. TestSyncExchangeBalances: unexpected addresses %v", result)
+	}
+	for i := range expected {
+		if result[i] != expected[i] {
+			t.Errorf("Test failed. TestSyncExchangeBalances: address %d is %v", i, result[i])
+		}
+	}
+
+	received := receiveChanges(ch)
+	if len(received) != 3 {
+		t.Fatalf("Test failed. TestSyncExchangeBalances: unexpected changes %v", received)
+	}
+	if received[0].Type != ChangeUpdated || received[0].PreviousBalance != 1 ||
+		received[1].Type != ChangeRemoved || received[1].Address.CoinType != "LTC" ||
+		received[2].Type != ChangeAdded || received[2].Address.CoinType != "ETH" {
+		t.Errorf("Test failed. TestSyncExchangeBalances: unexpected changes %v", received)
+	}
+
+	s.SyncExchangeBalances(ExchangeAccountInfo{ExchangeName: "ANX", Currencies: []ExchangeAccountCurrencyInfo{
+		{CurrencyName: "BTC", TotalValue: 1.5, Hold: 0.5},
+		{CurrencyName: "ETH", TotalValue: 2},
+	}})
+	if received = receiveChanges(ch); len(received) != 0 {
+		t.Errorf("Test failed. TestSyncExchangeBalances: unchanged balances published %v", received)
+	}
+}
+
+func TestSetExchangeBalance(t *testing.T) {
+	ch := SubscribeChanges()
+	defer UnsubscribeChanges(ch)
+
+	var s Service
+	s.SetExchangeBalance("ANX", ExchangeAccountCurrencyInfo{CurrencyName: "BTC", TotalValue: 1, Hold: 0.25})
+	s.SetExchangeBalance("ANX", ExchangeAccountCurrencyInfo{CurrencyName: "LTC", TotalValue: 2})
+	s.SetExchangeBalance("ANX", ExchangeAccountCurrencyInfo{CurrencyName: "BTC", TotalValue: 1, Hold: 0.5})
+	if balance, _ := s.GetAddressBalance("ANX", "BTC", PortfolioAddressExchange); balance != 1 {
+		t.Errorf("Test failed. TestSetExchangeBalance: unexpected balance %f", balance)
+	}
+	if s.Base().Addresses[0].Hold != 0.5 {
+		t.Errorf("Test failed. TestSetExchangeBalance: unexpected hold %f", s.Base().Addresses[0].Hold)
+	}
+
+	s.SetExchangeBalance("ANX", ExchangeAccountCurrencyInfo{CurrencyName: "BTC"})
+	if s.AddressExists("ANX") != true || len(s.Base().Addresses) != 1 {
+		t.Errorf("Test failed. TestSetExchangeBalance: unexpected addresses %v", s.Base().Addresses)
+	}
+
+	received := receiveChanges(ch)
+	types := []string{ChangeAdded, ChangeAdded, ChangeUpdated, ChangeRemoved}
+	if len(received) != len(types) {
+		t.Fatalf("Test failed. TestSetExchangeBalance: unexpected changes %v", received)
+	}
+	for i := range types {
+		if received[i].Type != types[i] {
+			t.Errorf("Test failed. TestSetExchangeBalance: change %d is %v", i, received[i])
+		}
+	}
+}
+
+func TestSetExchangeTotal(t *testing.T) {
+	var s Service
+	s.SetExchangeBalance("ANX", ExchangeAccountCurrencyInfo{CurrencyName: "BTC", TotalValue: 1, Hold: 0.25})
+
+	s.SetExchangeTotal("ANX", "BTC", 2)
+	x := s.Base().Addresses[0]
+	if x.Balance != 2 || x.Hold != 0.25 {
+		t.Errorf("Test failed. TestSetExchangeTotal: unexpected balance %f hold %f", x.Balance, x.Hold)
+	}
+
+	s.SetExchangeTotal("ANX", "BTC", 0.1)
+	if x = s.Base().Addresses[0]; x.Hold != 0.1 {
+		t.Errorf("Test failed. TestSetExchangeTotal: hold %f not capped at the total", x.Hold)
+	}
+
+	s.SetExchangeTotal("ANX", "LTC", 3)
+	if len(s.Base().Addresses) != 2 || s.Base().Addresses[1].Hold != 0 {
+		t.Errorf("Test failed. TestSetExchangeTotal: unexpected addresses %v", s.Base().Addresses)
+	}
+}
+
+func TestServiceConcurrency(t *testing.T) {
+	var s Service
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(2)
+		go func(i int) {
+			defer wg.Done()
+			s.SetExchangeBalance("ANX", ExchangeAccountCurrencyInfo{CurrencyName: "BTC", TotalValue: float64(i + 1)})
+		}(i)
+		go func() {
+			defer wg.Done()
+			s.GetPortfolioSummary()
+		}()
+	}
+	wg.Wait()
+
+	if len(s.Base().Addresses) != 1 {
+		t.Errorf("Test failed. TestServiceConcurrency: unexpected addresses %v", s.Base().Addresses)
+	}
+}
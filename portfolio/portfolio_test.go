@@ -380,7 +380,7 @@ func TestStartPortfolioWatcher(t *testing.T) {
 
 func TestGetPortfolio(t *testing.T) {
 	ptrBASE := GetPortfolio()
-	if reflect.TypeOf(ptrBASE).String() != "*portfolio.Base" {
+	if reflect.TypeOf(ptrBASE).String() != "*portfolio.Service" {
 		t.Error("Test Failed - portfolio_test.go - GetoPortfolio error")
 	}
 }
@@ -0,0 +1,175 @@
+package portfolio
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"strings"
+	"sync"
+
+	"github.com/mattkanwisher/cryptofiend/common"
+	"github.com/mattkanwisher/cryptofiend/portfolio/hdwallet"
+)
+
+// ERC-20 function selectors
+const (
+	erc20BalanceOf = "0x70a08231"
+	erc20Decimals  = "0x313ce567"
+)
+
+var errNoTokenRPC = errors.New("no Ethereum JSON-RPC endpoint for token balances")
+
+// TokenConfig is an ERC-20 token tracked in the portfolio. Decimals is looked
+// up from the contract if it isn't set.
+type TokenConfig struct {
+	Symbol   string
+	Contract string
+	Decimals int `json:",omitempty"`
+}
+
+// tokens is the registry of the ERC-20 tokens tracked and the Ethereum node
+// their balances are looked up with
+var tokens struct {
+	m    sync.RWMutex
+	rpc  *RPCProvider
+	list []TokenConfig
+}
+
+// SetupTokens replaces the ERC-20 tokens tracked, whose balances are looked up
+// with the Ethereum JSON-RPC endpoint at url
+func SetupTokens(url string, list []TokenConfig) {
+	tokens.m.Lock()
+	defer tokens.m.Unlock()
+
+	tokens.rpc = nil
+	if url != "" {
+		tokens.rpc = &RPCProvider{Name: "tokens", Type: ProviderEthereum, URL: url}
+	}
+	tokens.list = nil
+	for _, x := range list {
+		x.Symbol = common.StringToUpper(x.Symbol)
+		tokens.list = append(tokens.list, x)
+	}
+}
+
+// GetTokens returns the ERC-20 tokens tracked
+func GetTokens() []TokenConfig {
+	tokens.m.RLock()
+	defer tokens.m.RUnlock()
+
+	return append([]TokenConfig(nil), tokens.list...)
+}
+
+// callContract calls a constant function of a contract and returns its result
+// as an integer
+func callContract(rpc *RPCProvider, contract, data string) (*big.Int, error) {
+	var result string
+	call := map[string]string{"to": contract, "data": data}
+	err := rpc.call("eth_call", []interface{}{call, "latest"}, &result)
+	if err != nil {
+		return nil, err
+	}
+	if result == "0x" {
+		return nil, fmt.Errorf("%s returned no data", contract)
+	}
+
+	n, ok := new(big.Int).SetString(strings.TrimPrefix(result, "0x"), 16)
+	if !ok {
+		return nil, fmt.Errorf("invalid %s result %q", contract, result)
+	}
+	return n, nil
+}
+
+// GetTokenBalance returns the balance of an ERC-20 token held by an Ethereum
+// address in whole tokens
+func GetTokenBalance(token TokenConfig, address string) (float64, error) {
+	tokens.m.RLock()
+	rpc := tokens.rpc
+	tokens.m.RUnlock()
+	if rpc == nil {
+		return 0, errNoTokenRPC
+	}
+
+	owner := common.StringToLower(strings.TrimPrefix(address, "0x"))
+	if len(owner) != 40 {
+		return 0, fmt.Errorf("invalid Ethereum address %s", address)
+	}
+	balance, err := callContract(rpc, token.Contract, erc20BalanceOf+fmt.Sprintf("%064s", owner))
+	if err != nil {
+		return 0, err
+	}
+
+	decimals := token.Decimals
+	if decimals <= 0 {
+		n, err := callContract(rpc, token.Contract, erc20Decimals)
+		if err != nil {
+			return 0, err
+		}
+		decimals = int(n.Int64())
+		setTokenDecimals(token.Contract, decimals)
+	}
+
+	scale := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(decimals)), nil)
+	result, _ := new(big.Float).Quo(new(big.Float).SetInt(balance), new(big.Float).SetInt(scale)).Float64()
+	return result, nil
+}
+
+// setTokenDecimals stores the decimals looked up from a contract so that
+// they're only requested once
+func setTokenDecimals(contract string, decimals int) {
+	tokens.m.Lock()
+	defer tokens.m.Unlock()
+
+	for i := range tokens.list {
+		if tokens.list[i].Contract == contract {
+			tokens.list[i].Decimals = decimals
+		}
+	}
+}
+
+// SetTokenBalance sets the balance of an ERC-20 token held by an Ethereum
+// address, removing it when it is zero
+func (p *Base) SetTokenBalance(address, symbol string, balance float64) {
+	for x := range p.Addresses {
+		if p.Addresses[x].Address == address && p.Addresses[x].CoinType == symbol &&
+			p.Addresses[x].Description == PortfolioAddressToken {
+			if balance <= 0 {
+				p.Addresses = append(p.Addresses[:x], p.Addresses[x+1:]...)
+				return
+			}
+			p.Addresses[x].Balance = balance
+			return
+		}
+	}
+	if balance > 0 {
+		p.Addresses = append(p.Addresses, Address{Address: address, CoinType: symbol,
+			Balance: balance, Description: PortfolioAddressToken})
+	}
+}
+
+// UpdateTokenBalances looks up the balances of the tracked ERC-20 tokens held
+// by the personal Ethereum addresses of the portfolio, so that they show up as
+// coins in its summary
+func (p *Base) UpdateTokenBalances() bool {
+	var addresses []string
+	for _, x := range p.GetPortfolioGroupedCoin()["ETH"] {
+		if !hdwallet.IsExtendedKey(x) {
+			addresses = append(addresses, x)
+		}
+	}
+
+	success := true
+	for _, token := range GetTokens() {
+		for _, address := range addresses {
+			balance, err := GetTokenBalance(token, address)
+			if err != nil {
+				log.Printf("Failed to get %s balance of %s. Error: %s\n", token.Symbol, address, err)
+				success = false
+				continue
+			}
+			p.SetTokenBalance(address, token.Symbol, balance)
+		}
+	}
+	return success
+}
@@ -0,0 +1,120 @@
+package portfolio
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+const (
+	testTokenOwner    = "0xb794f5ea0ba39494ce839613fffba74279579268"
+	testTokenContract = "0xd26114cd6EE289AccF82350c8d8487fedB8A0C07"
+)
+
+func newTestTokenServer(t *testing.T, calls *int) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request rpcRequest
+		json.NewDecoder(r.Body).Decode(&request)
+		*calls++
+
+		call, _ := request.Params[0].(map[string]interface{})
+		data, _ := call["data"].(string)
+		switch {
+		case request.Method != "eth_call" || call["to"] != testTokenContract:
+			w.Write([]byte(`{"jsonrpc":"2.0","error":{"code":-32601,"message":"Method not found"},"id":1}`))
+		case data == erc20Decimals:
+			w.Write([]byte(`{"jsonrpc":"2.0","result":"0x0000000000000000000000000000000000000000000000000000000000000012","id":1}`))
+		case strings.HasPrefix(data, erc20BalanceOf) && strings.HasSuffix(data, testTokenOwner[2:]) &&
+			len(data) == len(erc20BalanceOf)+64:
+			// 2.5e18
+			w.Write([]byte(`{"jsonrpc":"2.0","result":"0x00000000000000000000000000000000000000000000000022b1c8c1227a0000","id":1}`))
+		default:
+			t.Errorf("Test failed. unexpected eth_call data %s", data)
+			w.Write([]byte(`{"jsonrpc":"2.0","result":"0x","id":1}`))
+		}
+	}))
+}
+
+func TestGetTokenBalance(t *testing.T) {
+	var calls int
+	server := newTestTokenServer(t, &calls)
+	defer server.Close()
+	defer SetupTokens("", nil)
+
+	token := TokenConfig{Symbol: "omg", Contract: testTokenContract}
+	SetupTokens("", []TokenConfig{token})
+	_, err := GetTokenBalance(token, testTokenOwner)
+	if err != errNoTokenRPC {
+		t.Errorf("Test failed. TestGetTokenBalance: unexpected error %v", err)
+	}
+
+	SetupTokens(server.URL, []TokenConfig{token})
+	if GetTokens()[0].Symbol != "OMG" {
+		t.Errorf("Test failed. TestGetTokenBalance: unexpected tokens %v", GetTokens())
+	}
+	balance, err := GetTokenBalance(token, testTokenOwner)
+	if err != nil || balance != 2.5 {
+		t.Errorf("Test failed. TestGetTokenBalance: unexpected balance %f, %v", balance, err)
+	}
+	if calls != 2 || GetTokens()[0].Decimals != 18 {
+		t.Errorf("Test failed. TestGetTokenBalance: decimals not stored after %d calls", calls)
+	}
+
+	_, err = GetTokenBalance(token, "0x1234")
+	if err == nil {
+		t.Error("Test failed. TestGetTokenBalance: invalid address accepted")
+	}
+}
+
+func TestSetTokenBalance(t *testing.T) {
+	p := Base{}
+	p.AddAddress(testTokenOwner, "ETH", PortfolioAddressPersonal, 1)
+	p.SetTokenBalance(testTokenOwner, "OMG", 2)
+	p.SetTokenBalance(testTokenOwner, "OMG", 3)
+	if len(p.Addresses) != 2 {
+		t.Fatalf("Test failed. TestSetTokenBalance: unexpected addresses %v", p.Addresses)
+	}
+	if balance, _ := p.GetAddressBalance(testTokenOwner, "OMG", PortfolioAddressToken); balance != 3 {
+		t.Errorf("Test failed. TestSetTokenBalance: unexpected balance %f", balance)
+	}
+
+	p.UpdateAddressBalance(testTokenOwner, 5)
+	if balance, _ := p.GetAddressBalance(testTokenOwner, "OMG", PortfolioAddressToken); balance != 3 {
+		t.Errorf("Test failed. TestSetTokenBalance: token balance overwritten with %f", balance)
+	}
+
+	p.SetTokenBalance(testTokenOwner, "OMG", 0)
+	if len(p.Addresses) != 1 || p.Addresses[0].CoinType != "ETH" {
+		t.Errorf("Test failed. TestSetTokenBalance: unexpected addresses %v", p.Addresses)
+	}
+}
+
+func TestUpdateTokenBalances(t *testing.T) {
+	var calls int
+	server := newTestTokenServer(t, &calls)
+	defer server.Close()
+	defer SetupTokens("", nil)
+	SetupTokens(server.URL, []TokenConfig{{Symbol: "OMG", Contract: testTokenContract, Decimals: 18}})
+
+	p := Base{}
+	p.AddAddress(testTokenOwner, "ETH", PortfolioAddressPersonal, 2e18)
+	if !p.UpdateTokenBalances() {
+		t.Fatal("Test failed. TestUpdateTokenBalances: update failed")
+	}
+	if calls != 1 {
+		t.Errorf("Test failed. TestUpdateTokenBalances: %d calls made", calls)
+	}
+	if coins := p.GetPortfolioGroupedCoin(); len(coins) != 1 {
+		t.Errorf("Test failed. TestUpdateTokenBalances: token grouped as a coin %v", coins)
+	}
+
+	holdings := make(map[string]float64)
+	for _, x := range p.GetPortfolioSummary().Totals {
+		holdings[x.Coin] = x.Balance
+	}
+	if holdings["ETH"] != 2 || holdings["OMG"] != 2.5 {
+		t.Errorf("Test failed. TestUpdateTokenBalances: unexpected totals %v", holdings)
+	}
+}
@@ -0,0 +1,309 @@
+package rebalance
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/mattkanwisher/cryptofiend/common"
+	"github.com/mattkanwisher/cryptofiend/currency/pair"
+	exchange "github.com/mattkanwisher/cryptofiend/exchanges"
+)
+
+// Const values for the rebalancer
+const (
+	ErrTradeTooSmall = "Rebalance trade is below the exchange's minimum amount or total."
+
+	// weightTolerance is how far the target weights may add up from 100
+	weightTolerance = 0.01
+	// minValue is the smallest value left unmatched which is reported
+	minValue = 1e-8
+)
+
+var (
+	errNoTargets      = errors.New("no target allocations")
+	errInvalidWeights = errors.New("target weights must add up to 100")
+	errNoValue        = errors.New("the coins targeted have no value")
+)
+
+// Target is the percentage of the portfolio value a coin should make up, and
+// the drift from it in percentage points tolerated before rebalancing
+type Target struct {
+	Coin      string  `json:"coin"`
+	Weight    float64 `json:"weight"`
+	Tolerance float64 `json:"tolerance"`
+}
+
+// Market is a currency pair which can be traded on an exchange to rebalance,
+// with its best prices, the exchange's limits and its taker fee percentage.
+// Pair is in the format used by the exchange so that orders can be placed
+// with it.
+type Market struct {
+	Exchange       string
+	Pair           pair.CurrencyPair
+	Bid            float64
+	Ask            float64
+	MinAmount      float64
+	MinTotal       float64
+	AmountDecimals int32
+	TakerFee       float64
+}
+
+// Input is the state of the portfolio a plan is worked out from. Holdings is
+// the total amount of each coin held, including offline, Prices the value of
+// one unit of each coin in Currency and Balances the amount of each coin
+// available for trading on each exchange.
+type Input struct {
+	Currency string
+	Holdings map[string]float64
+	Prices   map[string]float64
+	Balances map[string]map[string]float64
+	Markets  []Market
+}
+
+// Allocation is the current and target share of a coin in the portfolio
+type Allocation struct {
+	Coin   string  `json:"coin"`
+	Amount float64 `json:"amount"`
+	Value  float64 `json:"value"`
+	Weight float64 `json:"weight"`
+	Target float64 `json:"target"`
+	Drift  float64 `json:"drift"`
+	InBand bool    `json:"in_band"`
+}
+
+// Trade is an order moving value from one coin to another. Amount is in the
+// base currency of Pair and Price is the limit price, Value and Fee are in
+// the plan currency.
+type Trade struct {
+	Exchange string             `json:"exchange"`
+	Pair     pair.CurrencyPair  `json:"pair"`
+	Side     exchange.OrderSide `json:"side"`
+	From     string             `json:"from"`
+	To       string             `json:"to"`
+	Amount   float64            `json:"amount"`
+	Price    float64            `json:"price"`
+	Value    float64            `json:"value"`
+	Fee      float64            `json:"fee"`
+}
+
+// Plan is the set of trades which bring the portfolio back to its target
+// allocations. Unmatched is the value of each coin which couldn't be traded,
+// for lack of a market, balance or because of exchange limits.
+type Plan struct {
+	Currency    string             `json:"currency"`
+	Total       float64            `json:"total"`
+	Rebalance   bool               `json:"rebalance"`
+	Allocations []Allocation       `json:"allocations"`
+	Trades      []Trade            `json:"trades"`
+	Unmatched   map[string]float64 `json:"unmatched,omitempty"`
+	Timestamp   time.Time          `json:"timestamp"`
+}
+
+// flow is the value of a coin to be sold or bought
+type flow struct {
+	coin  string
+	value float64
+}
+
+// NewPlan works out the trades needed to bring the coins targeted back to
+// their weights. Only the value of the targeted coins is allocated, other
+// coins are left alone. Nothing is traded unless a coin has drifted outside
+// its tolerance band, in which case every coin is traded back to its target.
+// Each surplus is matched with the largest deficits through markets trading
+// one against the other, limited by the balance available on the exchange.
+func NewPlan(targets []Target, input Input) (Plan, error) {
+	plan := Plan{
+		Currency:  common.StringToUpper(input.Currency),
+		Trades:    []Trade{},
+		Timestamp: time.Now(),
+	}
+	if len(targets) == 0 {
+		return plan, errNoTargets
+	}
+
+	var weights float64
+	for _, x := range targets {
+		weights += x.Weight
+	}
+	if math.Abs(weights-100) > weightTolerance {
+		return plan, errInvalidWeights
+	}
+
+	for _, x := range targets {
+		amount := input.Holdings[x.Coin]
+		price, ok := input.Prices[x.Coin]
+		if !ok || price <= 0 {
+			return plan, fmt.Errorf("no price for %s", x.Coin)
+		}
+		plan.Total += amount * price
+	}
+	if plan.Total <= 0 {
+		return plan, errNoValue
+	}
+
+	var sells, buys []flow
+	for _, x := range targets {
+		a := Allocation{
+			Coin:   x.Coin,
+			Amount: input.Holdings[x.Coin],
+			Target: x.Weight,
+		}
+		a.Value = a.Amount * input.Prices[x.Coin]
+		a.Weight = a.Value / plan.Total * 100
+		a.Drift = a.Weight - a.Target
+		a.InBand = math.Abs(a.Drift) <= x.Tolerance
+		if !a.InBand {
+			plan.Rebalance = true
+		}
+		plan.Allocations = append(plan.Allocations, a)
+
+		delta := a.Target/100*plan.Total - a.Value
+		if delta < 0 {
+			sells = append(sells, flow{x.Coin, -delta})
+		} else if delta > 0 {
+			buys = append(buys, flow{x.Coin, delta})
+		}
+	}
+	if !plan.Rebalance {
+		return plan, nil
+	}
+
+	largestFirst := func(flows []flow) {
+		sort.Slice(flows, func(i, j int) bool {
+			if flows[i].value != flows[j].value {
+				return flows[i].value > flows[j].value
+			}
+			return flows[i].coin < flows[j].coin
+		})
+	}
+	largestFirst(sells)
+	largestFirst(buys)
+
+	markets := append([]Market(nil), input.Markets...)
+	sort.SliceStable(markets, func(i, j int) bool {
+		if markets[i].Exchange != markets[j].Exchange {
+			return markets[i].Exchange < markets[j].Exchange
+		}
+		return markets[i].Pair.Pair().String() < markets[j].Pair.Pair().String()
+	})
+
+	balances := make(map[string]map[string]float64)
+	for exchangeName, coins := range input.Balances {
+		balances[exchangeName] = make(map[string]float64)
+		for coin, x := range coins {
+			balances[exchangeName][coin] = x
+		}
+	}
+
+	for i := range sells {
+		for j := range buys {
+			for _, m := range markets {
+				if sells[i].value <= minValue || buys[j].value <= minValue {
+					break
+				}
+				value := math.Min(sells[i].value, buys[j].value)
+				t, spent, ok := newTrade(m, sells[i].coin, buys[j].coin, value,
+					balances[m.Exchange][sells[i].coin], input.Prices)
+				if !ok {
+					continue
+				}
+				plan.Trades = append(plan.Trades, t)
+				balances[m.Exchange][sells[i].coin] -= spent
+				sells[i].value -= t.Value
+				buys[j].value -= t.Value
+			}
+		}
+	}
+
+	for _, x := range append(sells, buys...) {
+		if x.value > minValue {
+			if plan.Unmatched == nil {
+				plan.Unmatched = make(map[string]float64)
+			}
+			plan.Unmatched[x.coin] = x.value
+		}
+	}
+	return plan, nil
+}
+
+// newTrade returns the trade through a market moving up to value from one
+// coin to another, using no more than available of the coin sold, along with
+// the amount of it spent. It fails if the market doesn't trade the two coins
+// or the trade would be below the exchange's limits.
+func newTrade(m Market, from, to string, value, available float64, prices map[string]float64) (Trade, float64, bool) {
+	p := m.Pair.Normalise()
+	fee := m.TakerFee / 100
+	value = math.Min(value, available*prices[from])
+
+	t := Trade{Exchange: m.Exchange, Pair: m.Pair, From: from, To: to}
+	var spent float64
+	switch {
+	case p.FirstCurrency.String() == from && p.SecondCurrency.String() == to && m.Bid > 0:
+		t.Side = exchange.OrderSideSell
+		t.Price = m.Bid
+		t.Amount = roundDown(value/prices[from], m.AmountDecimals)
+		spent = t.Amount
+		t.Fee = t.Amount * t.Price * fee * prices[to]
+	case p.FirstCurrency.String() == to && p.SecondCurrency.String() == from && m.Ask > 0:
+		t.Side = exchange.OrderSideBuy
+		t.Price = m.Ask
+		t.Amount = roundDown(value/prices[from]/t.Price/(1+fee), m.AmountDecimals)
+		spent = t.Amount * t.Price * (1 + fee)
+		t.Fee = t.Amount * t.Price * fee * prices[from]
+	default:
+		return t, 0, false
+	}
+
+	if t.Amount <= 0 || t.Amount < m.MinAmount || t.Amount*t.Price < m.MinTotal {
+		return t, 0, false
+	}
+	t.Value = spent * prices[from]
+	return t, spent, true
+}
+
+// roundDown rounds an amount down to a number of decimal places, -1 leaves it
+// unchanged
+func roundDown(amount float64, places int32) float64 {
+	if places < 0 {
+		return amount
+	}
+	scale := math.Pow(10, float64(places))
+	return math.Floor(amount*scale+1e-9) / scale
+}
+
+// Execute places a limit order for a trade of a plan and publishes it to
+// order event subscribers
+func Execute(exch exchange.IBotExchangeEx, t Trade) (string, error) {
+	if t.Amount <= 0 {
+		return "", errors.New(ErrTradeTooSmall)
+	}
+
+	orderID, err := exch.NewOrder(t.Pair, t.Amount, t.Price, t.Side, exchange.OrderTypeExchangeLimit)
+	if err != nil {
+		return "", err
+	}
+
+	order := exchange.Order{
+		CurrencyPair:    t.Pair,
+		Type:            exchange.OrderTypeExchangeLimit,
+		Side:            t.Side,
+		Amount:          t.Amount,
+		RemainingAmount: t.Amount,
+		Rate:            t.Price,
+		CreatedAt:       time.Now().Unix(),
+		Status:          exchange.OrderStatusActive,
+		OrderID:         orderID,
+	}
+	exchange.PublishOrderEvent(exch.GetName(), exchange.OrderEventPlaced, order)
+	// Orders filled immediately aren't given an ID to track them by
+	if orderID == "" {
+		order.FilledAmount = t.Amount
+		order.RemainingAmount = 0
+		order.Status = exchange.OrderStatusFilled
+		exchange.PublishOrderEvent(exch.GetName(), exchange.OrderEventFilled, order)
+	}
+	return orderID, nil
+}
@@ -0,0 +1,161 @@
+package rebalance
+
+import (
+	"math"
+	"testing"
+
+	"github.com/mattkanwisher/cryptofiend/currency/pair"
+	exchange "github.com/mattkanwisher/cryptofiend/exchanges"
+)
+
+type testExchange struct {
+	exchange.IBotExchangeEx
+	amount float64
+	price  float64
+	side   exchange.OrderSide
+}
+
+func (e *testExchange) GetName() string {
+	return "Test"
+}
+
+func (e *testExchange) NewOrder(p pair.CurrencyPair, amount, price float64, side exchange.OrderSide, orderType exchange.OrderType) (string, error) {
+	e.amount, e.price, e.side = amount, price, side
+	return "1", nil
+}
+
+func testTargets() []Target {
+	return []Target{
+		{Coin: "BTC", Weight: 50, Tolerance: 5},
+		{Coin: "ETH", Weight: 50, Tolerance: 5},
+	}
+}
+
+func testInput() Input {
+	return Input{
+		Currency: "usd",
+		Holdings: map[string]float64{"BTC": 1.5, "ETH": 5},
+		Prices:   map[string]float64{"BTC": 1000, "ETH": 100},
+		Balances: map[string]map[string]float64{
+			"A": {"BTC": 0.2},
+			"B": {"BTC": 1},
+		},
+		Markets: []Market{
+			{Exchange: "B", Pair: pair.NewCurrencyPair("ETH", "BTC"), Bid: 0.099, Ask: 0.1,
+				AmountDecimals: 2, TakerFee: 0.2},
+			{Exchange: "A", Pair: pair.NewCurrencyPair("ETH", "XBT"), Bid: 0.099, Ask: 0.1,
+				AmountDecimals: 2},
+			{Exchange: "A", Pair: pair.NewCurrencyPair("LTC", "BTC"), Bid: 0.01, Ask: 0.011},
+		},
+	}
+}
+
+func TestNewPlan(t *testing.T) {
+	plan, err := NewPlan(testTargets(), testInput())
+	if err != nil {
+		t.Fatalf("Test failed. TestNewPlan: Error, %s", err)
+	}
+	if plan.Currency != "USD" || plan.Total != 2000 || !plan.Rebalance {
+		t.Errorf("Test failed. TestNewPlan: unexpected plan %+v", plan)
+	}
+	if plan.Allocations[0].Weight != 75 || plan.Allocations[0].Drift != 25 || plan.Allocations[0].InBand {
+		t.Errorf("Test failed. TestNewPlan: unexpected allocation %+v", plan.Allocations[0])
+	}
+	if len(plan.Trades) != 2 {
+		t.Fatalf("Test failed. TestNewPlan: unexpected trades %+v", plan.Trades)
+	}
+
+	// The balance available on A limits the first trade, B makes up the rest
+	a := plan.Trades[0]
+	if a.Exchange != "A" || a.Side != exchange.OrderSideBuy || a.From != "BTC" || a.To != "ETH" ||
+		a.Amount != 2 || a.Price != 0.1 || a.Fee != 0 {
+		t.Errorf("Test failed. TestNewPlan: unexpected first trade %+v", a)
+	}
+	b := plan.Trades[1]
+	if b.Exchange != "B" || b.Amount != 2.99 || math.Abs(b.Value-299.598) > 1e-9 ||
+		math.Abs(b.Fee-0.598) > 1e-9 {
+		t.Errorf("Test failed. TestNewPlan: unexpected second trade %+v", b)
+	}
+	if math.Abs(plan.Unmatched["BTC"]-0.402) > 1e-9 || math.Abs(plan.Unmatched["ETH"]-0.402) > 1e-9 {
+		t.Errorf("Test failed. TestNewPlan: unexpected unmatched values %v", plan.Unmatched)
+	}
+}
+
+func TestNewPlanInBand(t *testing.T) {
+	input := testInput()
+	input.Holdings = map[string]float64{"BTC": 1.05, "ETH": 9.5}
+
+	plan, err := NewPlan(testTargets(), input)
+	if err != nil {
+		t.Fatalf("Test failed. TestNewPlanInBand: Error, %s", err)
+	}
+	if plan.Rebalance || len(plan.Trades) != 0 || !plan.Allocations[1].InBand {
+		t.Errorf("Test failed. TestNewPlanInBand: unexpected plan %+v", plan)
+	}
+}
+
+func TestNewPlanLimits(t *testing.T) {
+	input := testInput()
+	for i := range input.Markets {
+		input.Markets[i].MinAmount = 10
+	}
+
+	plan, err := NewPlan(testTargets(), input)
+	if err != nil {
+		t.Fatalf("Test failed. TestNewPlanLimits: Error, %s", err)
+	}
+	if len(plan.Trades) != 0 || plan.Unmatched["BTC"] != 500 || plan.Unmatched["ETH"] != 500 {
+		t.Errorf("Test failed. TestNewPlanLimits: unexpected plan %+v", plan)
+	}
+}
+
+func TestNewPlanErrors(t *testing.T) {
+	_, err := NewPlan(nil, testInput())
+	if err == nil {
+		t.Error("Test failed. TestNewPlanErrors: planned without targets")
+	}
+
+	targets := testTargets()
+	targets[0].Weight = 60
+	_, err = NewPlan(targets, testInput())
+	if err == nil {
+		t.Error("Test failed. TestNewPlanErrors: planned with invalid weights")
+	}
+
+	input := testInput()
+	delete(input.Prices, "ETH")
+	_, err = NewPlan(testTargets(), input)
+	if err == nil {
+		t.Error("Test failed. TestNewPlanErrors: planned without prices")
+	}
+}
+
+func TestExecute(t *testing.T) {
+	events := exchange.SubscribeOrderEvents()
+	exch := &testExchange{}
+	trade := Trade{Exchange: "Test", Pair: pair.NewCurrencyPair("ETH", "BTC"),
+		Side: exchange.OrderSideBuy, Amount: 2, Price: 0.1}
+
+	id, err := Execute(exch, trade)
+	if err != nil || id != "1" {
+		t.Fatalf("Test failed. TestExecute: unexpected result %s, %v", id, err)
+	}
+	if exch.amount != 2 || exch.price != 0.1 || exch.side != exchange.OrderSideBuy {
+		t.Errorf("Test failed. TestExecute: unexpected order %+v", exch)
+	}
+
+	select {
+	case event := <-events:
+		if event.Type != exchange.OrderEventPlaced || event.Order.OrderID != "1" {
+			t.Errorf("Test failed. TestExecute: unexpected event %+v", event)
+		}
+	default:
+		t.Error("Test failed. TestExecute: order event not published")
+	}
+
+	trade.Amount = 0
+	_, err = Execute(exch, trade)
+	if err == nil {
+		t.Error("Test failed. TestExecute: executed an empty trade")
+	}
+}
@@ -82,12 +82,120 @@ func NewRouter(exchanges []exchange.IBotExchange) *mux.Router {
 			"/exchanges/{exchangeName}/latest/{currency}",
 			RESTGetTicker,
 		},
+		Route{
+			"IndividualExchangeTickerChange",
+			"GET",
+			"/exchanges/{exchangeName}/ticker/change/{currency}",
+			RESTGetTickerChange,
+		},
+		Route{
+			"CurrencyWindowStats",
+			"GET",
+			"/stats/{currency}",
+			RESTGetWindowStats,
+		},
+		Route{
+			"ArbitrageOpportunities",
+			"GET",
+			"/arbitrage/opportunities",
+			RESTGetArbitrageOpportunities,
+		},
+		Route{
+			"TriangularOpportunities",
+			"GET",
+			"/arbitrage/triangular",
+			RESTGetTriangularOpportunities,
+		},
+		Route{
+			"GetEvents",
+			"GET",
+			"/events",
+			RESTGetEvents,
+		},
+		Route{
+			"AddEvent",
+			"POST",
+			"/events",
+			RESTAddEvent,
+		},
+		Route{
+			"RemoveEvent",
+			"DELETE",
+			"/events/{id:[0-9]+}",
+			RESTRemoveEvent,
+		},
+		Route{
+			"EnableEvent",
+			"POST",
+			"/events/{id:[0-9]+}/enable",
+			RESTEnableEvent,
+		},
+		Route{
+			"DisableEvent",
+			"POST",
+			"/events/{id:[0-9]+}/disable",
+			RESTDisableEvent,
+		},
 		Route{
 			"GetPortfolio",
 			"GET",
 			"/portfolio/all",
 			RESTGetPortfolio,
 		},
+		Route{
+			"GetPortfolioHistory",
+			"GET",
+			"/portfolio/history",
+			RESTGetPortfolioHistory,
+		},
+		Route{
+			"GetPortfolioProfitLoss",
+			"GET",
+			"/portfolio/pnl",
+			RESTGetPortfolioProfitLoss,
+		},
+		Route{
+			"GetRebalancePlan",
+			"GET",
+			"/portfolio/rebalance",
+			RESTGetRebalancePlan,
+		},
+		Route{
+			"ExecuteRebalancePlan",
+			"POST",
+			"/portfolio/rebalance",
+			RESTExecuteRebalancePlan,
+		},
+		Route{
+			"GetLedgerProfitLoss",
+			"GET",
+			"/ledger/pnl",
+			RESTGetLedgerProfitLoss,
+		},
+		Route{
+			"GetLedgerDisposals",
+			"GET",
+			"/ledger/disposals",
+			RESTGetLedgerDisposals,
+		},
+		Route{
+			"GetLedgerEntries",
+			"GET",
+			"/ledger/entries",
+			RESTGetLedgerEntries,
+		},
+		Route{
+			"AddLedgerEntry",
+			"POST",
+			"/ledger/entries",
+			RESTAddLedgerEntry,
+		},
+		Route{
+			"ExportCapitalGains",
+			"POST",
+			"/ledger/capitalgains/{year:[0-9]+}",
+			RESTExportCapitalGains,
+		},
 		Route{
 			"AllActiveExchangesAndOrderbooks",
 			"GET",
@@ -100,6 +208,12 @@ func NewRouter(exchanges []exchange.IBotExchange) *mux.Router {
 			"/exchanges/{exchangeName}/orderbook/latest/{currency}",
 			RESTGetOrderbook,
 		},
+		Route{
+			"ConsolidatedOrderbook",
+			"GET",
+			"/exchanges/orderbook/consolidated/{currency}",
+			RESTGetConsolidatedOrderbook,
+		},
 		Route{
 			"ws",
 			"GET",
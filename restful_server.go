@@ -2,14 +2,23 @@ package main
 
 import (
 	"encoding/json"
+	"fmt"
 	"log"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/mattkanwisher/cryptofiend/config"
+	"github.com/mattkanwisher/cryptofiend/currency/pair"
+	"github.com/mattkanwisher/cryptofiend/events"
 	exchange "github.com/mattkanwisher/cryptofiend/exchanges"
+	"github.com/mattkanwisher/cryptofiend/exchanges/arbitrage"
 	"github.com/mattkanwisher/cryptofiend/exchanges/orderbook"
+	"github.com/mattkanwisher/cryptofiend/exchanges/stats"
 	"github.com/mattkanwisher/cryptofiend/exchanges/ticker"
+	"github.com/mattkanwisher/cryptofiend/portfolio"
+	"github.com/mattkanwisher/cryptofiend/portfolio/rebalance"
 )
 
 // AllEnabledExchangeOrderbooks holds the enabled exchange orderbooks
@@ -113,6 +122,33 @@ func RESTGetOrderbook(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// RESTGetConsolidatedOrderbook returns the merged orderbooks of all enabled
+// exchanges for a given currency, fees are included when the fees query
+// parameter is true
+func RESTGetConsolidatedOrderbook(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	currency := vars["currency"]
+	assetType := r.URL.Query().Get("assetType")
+	feeAdjusted := r.URL.Query().Get("fees") == "true"
+
+	if assetType == "" {
+		assetType = orderbook.Spot
+	}
+
+	response, err := GetConsolidatedOrderbook(pair.NewCurrencyPairFromString(currency),
+		assetType, feeAdjusted)
+	if err != nil {
+		log.Printf("Failed to fetch consolidated orderbook for currency: %s\n",
+			currency)
+		return
+	}
+
+	err = RESTfulJSONResponse(w, r, response)
+	if err != nil {
+		RESTfulError(r.Method, err)
+	}
+}
+
 // GetAllActiveOrderbooks returns all enabled exchanges orderbooks
 func GetAllActiveOrderbooks() []EnabledExchangeOrderbooks {
 	var orderbookData []EnabledExchangeOrderbooks
@@ -204,6 +240,413 @@ func RESTGetTicker(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// defaultTickerChangeWindows are the windows returned by RESTGetTickerChange
+// when none is requested
+var defaultTickerChangeWindows = []string{"5m", "1h", "24h"}
+
+// RESTGetTickerChange returns the price change, range and volatility of a
+// ticker over the window query parameter, or over 5m, 1h and 24h if it isn't
+// given
+func RESTGetTickerChange(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	currency := vars["currency"]
+	exchangeName := vars["exchangeName"]
+	assetType := r.URL.Query().Get("assetType")
+
+	if assetType == "" {
+		assetType = ticker.Spot
+	}
+
+	windows := defaultTickerChangeWindows
+	if window := r.URL.Query().Get("window"); window != "" {
+		windows = []string{window}
+	}
+
+	response := make(map[string]ticker.Change)
+	for _, x := range windows {
+		window, err := time.ParseDuration(x)
+		if err != nil || window <= 0 {
+			http.Error(w, fmt.Sprintf("invalid ticker change window %q", x), http.StatusBadRequest)
+			return
+		}
+
+		change, err := ticker.GetChange(exchangeName,
+			pair.NewCurrencyPairFromString(currency), assetType, window)
+		if err != nil {
+			continue
+		}
+		response[x] = change
+	}
+
+	err := RESTfulJSONResponse(w, r, response)
+	if err != nil {
+		RESTfulError(r.Method, err)
+	}
+}
+
+// RESTGetWindowStats returns the cross exchange stats of a currency over the
+// window query parameter, which defaults to an hour
+func RESTGetWindowStats(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	currency := vars["currency"]
+	assetType := r.URL.Query().Get("assetType")
+
+	if assetType == "" {
+		assetType = ticker.Spot
+	}
+
+	window := time.Hour
+	if x := r.URL.Query().Get("window"); x != "" {
+		var err error
+		window, err = time.ParseDuration(x)
+		if err != nil || window <= 0 {
+			http.Error(w, fmt.Sprintf("invalid stats window %q", x), http.StatusBadRequest)
+			return
+		}
+	}
+
+	response, err := stats.GetWindowStats(pair.NewCurrencyPairFromString(currency),
+		assetType, window)
+	if err != nil {
+		log.Printf("Failed to fetch stats for currency: %s\n", currency)
+		return
+	}
+
+	err = RESTfulJSONResponse(w, r, response)
+	if err != nil {
+		RESTfulError(r.Method, err)
+	}
+}
+
+// parseTimeParam parses a time query parameter given as a unix timestamp or in
+// RFC 3339 format, returning the zero time if it is empty
+func parseTimeParam(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if unix, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.Unix(unix, 0), nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
+
+// RESTGetPortfolioHistory returns the portfolio snapshots taken between the
+// start and end query parameters, all of them if neither is given
+func RESTGetPortfolioHistory(w http.ResponseWriter, r *http.Request) {
+	start, err := parseTimeParam(r.URL.Query().Get("start"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	end, err := parseTimeParam(r.URL.Query().Get("end"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	err = RESTfulJSONResponse(w, r, portfolio.GetSnapshots(start, end))
+	if err != nil {
+		RESTfulError(r.Method, err)
+	}
+}
+
+// RESTGetPortfolioProfitLoss returns the daily and weekly change in value of
+// the portfolio
+func RESTGetPortfolioProfitLoss(w http.ResponseWriter, r *http.Request) {
+	daily, err := portfolio.GetProfitLoss(time.Hour * 24)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	weekly, err := portfolio.GetProfitLoss(time.Hour * 24 * 7)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	response := map[string]portfolio.ProfitLoss{"daily": daily, "weekly": weekly}
+	err = RESTfulJSONResponse(w, r, response)
+	if err != nil {
+		RESTfulError(r.Method, err)
+	}
+}
+
+// RESTGetRebalancePlan returns the trades which would bring the portfolio back
+// to its target allocations
+func RESTGetRebalancePlan(w http.ResponseWriter, r *http.Request) {
+	plan, err := GetRebalancePlan()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	err = RESTfulJSONResponse(w, r, plan)
+	if err != nil {
+		RESTfulError(r.Method, err)
+	}
+}
+
+// RESTExecuteRebalancePlan works out a new rebalancing plan and places its
+// orders, returning the plan with the IDs of the orders placed. The request is
+// refused with the plan only unless rebalancing and execution are enabled.
+func RESTExecuteRebalancePlan(w http.ResponseWriter, r *http.Request) {
+	plan, err := GetRebalancePlan()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	response := struct {
+		Plan     rebalance.Plan `json:"plan"`
+		OrderIDs []string       `json:"order_ids"`
+		Error    string         `json:"error,omitempty"`
+	}{Plan: plan}
+
+	// Orders are only placed with the same settings the rebalance routine
+	// requires, otherwise only the plan is returned
+	if !bot.config.Rebalance.Enabled || !bot.config.Rebalance.Execute {
+		response.Error = errRebalanceExecuteDisabled.Error()
+		w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+		w.WriteHeader(http.StatusForbidden)
+		err = json.NewEncoder(w).Encode(response)
+		if err != nil {
+			RESTfulError(r.Method, err)
+		}
+		return
+	}
+
+	if plan.Rebalance {
+		response.OrderIDs, err = ExecuteRebalancePlan(plan)
+		if err != nil {
+			log.Printf("Failed to execute rebalance plan, orders placed: %v. Error: %s\n", response.OrderIDs, err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	err = RESTfulJSONResponse(w, r, response)
+	if err != nil {
+		RESTfulError(r.Method, err)
+	}
+}
+
+// RESTGetLedgerProfitLoss returns the realized and unrealized profit and loss
+// of each asset in the ledger
+func RESTGetLedgerProfitLoss(w http.ResponseWriter, r *http.Request) {
+	if bot.ledger == nil {
+		http.Error(w, errLedgerDisabled.Error(), http.StatusNotFound)
+		return
+	}
+
+	response := struct {
+		Method   string                      `json:"method"`
+		Currency string                      `json:"currency"`
+		Assets   []portfolio.AssetProfitLoss `json:"assets"`
+	}{bot.ledger.GetMethod(), bot.ledger.GetCurrency(), bot.ledger.GetProfitLoss()}
+
+	err := RESTfulJSONResponse(w, r, response)
+	if err != nil {
+		RESTfulError(r.Method, err)
+	}
+}
+
+// RESTGetLedgerDisposals returns the disposals made between the start and end
+// query parameters, all of them if neither is given
+func RESTGetLedgerDisposals(w http.ResponseWriter, r *http.Request) {
+	if bot.ledger == nil {
+		http.Error(w, errLedgerDisabled.Error(), http.StatusNotFound)
+		return
+	}
+
+	start, err := parseTimeParam(r.URL.Query().Get("start"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	end, err := parseTimeParam(r.URL.Query().Get("end"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	err = RESTfulJSONResponse(w, r, bot.ledger.GetDisposals(start, end))
+	if err != nil {
+		RESTfulError(r.Method, err)
+	}
+}
+
+// RESTGetLedgerEntries returns the trades and transfers recorded in the ledger
+func RESTGetLedgerEntries(w http.ResponseWriter, r *http.Request) {
+	if bot.ledger == nil {
+		http.Error(w, errLedgerDisabled.Error(), http.StatusNotFound)
+		return
+	}
+
+	err := RESTfulJSONResponse(w, r, bot.ledger.GetEntries())
+	if err != nil {
+		RESTfulError(r.Method, err)
+	}
+}
+
+// RESTAddLedgerEntry records the trade or transfer in the request body, such
+// as a deposit from outside the tracked exchanges
+func RESTAddLedgerEntry(w http.ResponseWriter, r *http.Request) {
+	if bot.ledger == nil {
+		http.Error(w, errLedgerDisabled.Error(), http.StatusNotFound)
+		return
+	}
+
+	var entry portfolio.LedgerEntry
+	err := json.NewDecoder(r.Body).Decode(&entry)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	recorded, err := bot.ledger.Record(entry)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	err = RESTfulJSONResponse(w, r, map[string]bool{"recorded": recorded})
+	if err != nil {
+		RESTfulError(r.Method, err)
+	}
+}
+
+// RESTExportCapitalGains writes the disposals of the year in the path to a CSV
+// file and returns its name
+func RESTExportCapitalGains(w http.ResponseWriter, r *http.Request) {
+	if bot.ledger == nil {
+		http.Error(w, errLedgerDisabled.Error(), http.StatusNotFound)
+		return
+	}
+
+	year, _ := strconv.Atoi(mux.Vars(r)["year"])
+	file := fmt.Sprintf("capital_gains_%d.csv", year)
+	err := bot.ledger.ExportCapitalGains(year, file)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	err = RESTfulJSONResponse(w, r, map[string]string{"file": file})
+	if err != nil {
+		RESTfulError(r.Method, err)
+	}
+}
+
+// RESTGetArbitrageOpportunities returns the latest arbitrage opportunities,
+// the most profitable first
+func RESTGetArbitrageOpportunities(w http.ResponseWriter, r *http.Request) {
+	err := RESTfulJSONResponse(w, r, arbitrage.GetOpportunities())
+	if err != nil {
+		RESTfulError(r.Method, err)
+	}
+}
+
+// RESTGetTriangularOpportunities returns the latest triangular arbitrage
+// cycles, the most profitable first
+func RESTGetTriangularOpportunities(w http.ResponseWriter, r *http.Request) {
+	err := RESTfulJSONResponse(w, r, arbitrage.GetCycles())
+	if err != nil {
+		RESTfulError(r.Method, err)
+	}
+}
+
+// RESTEventResponse is the reply to requests adding or changing an event
+type RESTEventResponse struct {
+	ID int `json:"id"`
+}
+
+// RESTGetEvents returns the events with their conditions, actions and trigger
+// state
+func RESTGetEvents(w http.ResponseWriter, r *http.Request) {
+	err := RESTfulJSONResponse(w, r, events.GetEventConfigs())
+	if err != nil {
+		RESTfulError(r.Method, err)
+	}
+}
+
+// RESTAddEvent adds the event in the request body and returns its ID
+func RESTAddEvent(w http.ResponseWriter, r *http.Request) {
+	var event config.EventConfig
+	err := json.NewDecoder(r.Body).Decode(&event)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	id, err := events.AddEventConfig(event)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	err = updateEventsConfig()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	err = RESTfulJSONResponse(w, r, RESTEventResponse{ID: id})
+	if err != nil {
+		RESTfulError(r.Method, err)
+	}
+}
+
+// RESTRemoveEvent removes the event with the ID in the path
+func RESTRemoveEvent(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+	if !events.RemoveEvent(id) {
+		http.Error(w, events.ErrEventNotFound.Error(), http.StatusNotFound)
+		return
+	}
+	err := updateEventsConfig()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	err = RESTfulJSONResponse(w, r, RESTEventResponse{ID: id})
+	if err != nil {
+		RESTfulError(r.Method, err)
+	}
+}
+
+// RESTEnableEvent enables the event with the ID in the path
+func RESTEnableEvent(w http.ResponseWriter, r *http.Request) {
+	restSetEventEnabled(w, r, true)
+}
+
+// RESTDisableEvent disables the event with the ID in the path
+func RESTDisableEvent(w http.ResponseWriter, r *http.Request) {
+	restSetEventEnabled(w, r, false)
+}
+
+func restSetEventEnabled(w http.ResponseWriter, r *http.Request, enabled bool) {
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+	err := events.EnableEvent(id, enabled)
+	if err == events.ErrEventNotFound {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	err = updateEventsConfig()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	err = RESTfulJSONResponse(w, r, RESTEventResponse{ID: id})
+	if err != nil {
+		RESTfulError(r.Method, err)
+	}
+}
+
 // GetAllActiveTickers returns all enabled exchange tickers
 func GetAllActiveTickers() []EnabledExchangeCurrencies {
 	var tickerData []EnabledExchangeCurrencies
@@ -5,13 +5,18 @@ import (
 	"log"
 	"time"
 
+	"github.com/mattkanwisher/cryptofiend/common"
 	"github.com/mattkanwisher/cryptofiend/currency"
 	"github.com/mattkanwisher/cryptofiend/currency/pair"
 	"github.com/mattkanwisher/cryptofiend/currency/symbol"
+	"github.com/mattkanwisher/cryptofiend/events"
 	exchange "github.com/mattkanwisher/cryptofiend/exchanges"
+	"github.com/mattkanwisher/cryptofiend/exchanges/arbitrage"
 	"github.com/mattkanwisher/cryptofiend/exchanges/orderbook"
 	"github.com/mattkanwisher/cryptofiend/exchanges/stats"
 	"github.com/mattkanwisher/cryptofiend/exchanges/ticker"
+	"github.com/mattkanwisher/cryptofiend/portfolio"
+	"github.com/mattkanwisher/cryptofiend/webhooks"
 )
 
 func printCurrencyFormat(price float64) string {
@@ -26,7 +31,10 @@ func printCurrencyFormat(price float64) string {
 func printConvertCurrencyFormat(origCurrency string, origPrice float64) string {
 	displayCurrency := bot.config.FiatDisplayCurrency
 	conv, err := currency.ConvertCurrency(origPrice, origCurrency, displayCurrency)
-	if err != nil {
+	stale := ""
+	if currency.IsStale(err) {
+		stale = " [stale rate]"
+	} else if err != nil {
 		log.Printf("Failed to convert currency: %s", err)
 	}
 
@@ -40,10 +48,11 @@ func printConvertCurrencyFormat(origCurrency string, origPrice float64) string {
 		log.Printf("Failed to get original currency symbol: %s", err)
 	}
 
-	return fmt.Sprintf("%s%.2f %s (%s%.2f %s)",
+	return fmt.Sprintf("%s%.2f %s%s (%s%.2f %s)",
 		displaySymbol,
 		conv,
 		displayCurrency,
+		stale,
 		origSymbol,
 		origPrice,
 		origCurrency,
@@ -59,7 +68,6 @@ func printSummary(result ticker.Price, p pair.CurrencyPair, assetType, exchangeN
 		return
 	}
 
-	stats.Add(exchangeName, p, assetType, result.Last, result.Volume)
 	if currency.IsFiatCurrency(p.SecondCurrency.String()) && p.SecondCurrency.String() != bot.config.FiatDisplayCurrency {
 		origCurrency := p.SecondCurrency.Upper().String()
 		log.Printf("%s %s %s: Last %s Ask %s Bid %s High %s Low %s Volume %.8f",
@@ -199,17 +207,11 @@ func TickerUpdaterRoutine() {
 							result, err = bot.exchanges[x].UpdateTicker(currency,
 								assetTypes[z])
 							printSummary(result, currency, assetTypes[z], exchangeName, err)
-							if err == nil {
-								relayWebsocketEvent(result, "ticker_update", assetTypes[z], exchangeName)
-							}
 						}
 					} else {
 						result, err = bot.exchanges[x].UpdateTicker(currency,
 							assetTypes[0])
 						printSummary(result, currency, assetTypes[0], exchangeName, err)
-						if err == nil {
-							relayWebsocketEvent(result, "ticker_update", assetTypes[0], exchangeName)
-						}
 					}
 				}
 			}
@@ -227,6 +229,13 @@ func OrderbookUpdaterRoutine() {
 					continue
 				}
 
+				// Full orderbooks kept up to date by the websocket feed would
+				// only be replaced with older snapshots
+				if exch, ok := bot.exchanges[x].(exchange.IStreamingExchange); ok &&
+					exch.IsWebsocketEnabled() && exch.StreamsFullOrderbook() {
+					continue
+				}
+
 				exchangeName := bot.exchanges[x].GetName()
 				enabledCurrencies := bot.exchanges[x].GetEnabledCurrencies()
 				var result orderbook.Base
@@ -265,3 +274,472 @@ func OrderbookUpdaterRoutine() {
 		time.Sleep(time.Second * 10)
 	}
 }
+
+// WebsocketStateRoutine relays exchange websocket connection state changes to
+// websocket clients
+func WebsocketStateRoutine() {
+	log.Println("Starting websocket state routine")
+	for event := range exchange.SubscribeWebsocketState() {
+		state := struct {
+			State   string `json:"state"`
+			Attempt int    `json:"attempt"`
+			Error   string `json:"error,omitempty"`
+		}{State: event.State, Attempt: event.Attempt}
+
+		if event.Error != nil {
+			state.Error = event.Error.Error()
+		}
+		relayWebsocketEvent(state, "websocket_state", "", event.Exchange)
+	}
+}
+
+// TickerRelayRoutine adds ticker changes to the exchange stats and relays them
+// to websocket clients, whether they were polled or pushed by an exchange
+// stream
+func TickerRelayRoutine() {
+	log.Println("Starting ticker relay routine")
+	for event := range ticker.Subscribe() {
+		stats.Add(event.Exchange, event.Pair, event.AssetType, event.Price.Last,
+			event.Price.Volume)
+		relayWebsocketEvent(event.Price, "ticker_update", event.AssetType,
+			event.Exchange)
+	}
+}
+
+// ArbitrageRoutine periodically scans for cross exchange and triangular
+// arbitrage opportunities and relays those found to websocket clients
+func ArbitrageRoutine() {
+	log.Println("Starting arbitrage routine")
+	go func() {
+		for o := range arbitrage.Subscribe() {
+			relayWebsocketEvent(o, "arbitrage_opportunity", orderbook.Spot, "")
+		}
+	}()
+	go func() {
+		for c := range arbitrage.SubscribeCycles() {
+			relayWebsocketEvent(c, "triangular_opportunity", orderbook.Spot, c.Exchange)
+		}
+	}()
+
+	for {
+		ScanArbitrage()
+		if bot.config.Arbitrage.Triangular {
+			ScanTriangularArbitrage()
+		}
+		time.Sleep(time.Second * bot.config.Arbitrage.ScanDelay)
+	}
+}
+
+// orderPollDelay is how often orders placed by the bot are checked for fills
+const orderPollDelay = time.Second * 30
+
+// OrderTrackerRoutine polls the orders placed by the bot until they are filled
+// or cancelled, publishing the change, and refreshes the balances of an
+// exchange when one of its orders is filled
+func OrderTrackerRoutine() {
+	log.Println("Starting order tracker routine")
+	orders := exchange.SubscribeOrderEvents()
+	tracked := make(map[string]exchange.OrderEvent)
+	poll := time.NewTicker(orderPollDelay)
+
+	for {
+		select {
+		case event := <-orders:
+			key := event.Exchange + ":" + event.Order.OrderID
+			switch event.Type {
+			case exchange.OrderEventPlaced:
+				if event.Order.OrderID != "" && event.Order.Status == exchange.OrderStatusActive {
+					tracked[key] = event
+				}
+			case exchange.OrderEventFilled:
+				delete(tracked, key)
+				refreshAccountInfo(event.Exchange)
+			case exchange.OrderEventCancelled:
+				delete(tracked, key)
+			}
+		case <-poll.C:
+			for key, event := range tracked {
+				exch, err := GetTradingExchange(event.Exchange)
+				if err != nil {
+					log.Printf("Failed to track %s order %s. Error: %s\n", event.Exchange,
+						event.Order.OrderID, err)
+					delete(tracked, key)
+					continue
+				}
+
+				order, err := exch.GetOrder(event.Order.OrderID, event.Order.CurrencyPair)
+				if err != nil {
+					log.Printf("Failed to get %s order %s. Error: %s\n", event.Exchange,
+						event.Order.OrderID, err)
+					continue
+				}
+
+				switch order.Status {
+				case exchange.OrderStatusFilled:
+					delete(tracked, key)
+					exchange.PublishOrderEvent(event.Exchange, exchange.OrderEventFilled, *order)
+				case exchange.OrderStatusAborted:
+					delete(tracked, key)
+					exchange.PublishOrderEvent(event.Exchange, exchange.OrderEventCancelled, *order)
+				}
+			}
+		}
+	}
+}
+
+// refreshAccountInfo updates the portfolio with the balances of an exchange
+func refreshAccountInfo(exchangeName string) {
+	exch, err := GetTradingExchange(exchangeName)
+	if err != nil {
+		return
+	}
+
+	info, err := exch.GetExchangeAccountInfo()
+	if err != nil {
+		log.Printf("Failed to refresh %s account info. Error: %s\n", exchangeName, err)
+		return
+	}
+	SeedExchangeAccountInfo([]exchange.AccountInfo{info})
+}
+
+// exchangeSyncDelay is how often the exchange balances of the portfolio are
+// reconciled with the account info of each exchange
+const exchangeSyncDelay = time.Minute * 5
+
+// PortfolioSyncRoutine keeps the exchange balances of the portfolio up to
+// date, storing the balance updates pushed by exchange streams and
+// periodically reconciling the balances of every authenticated exchange
+func PortfolioSyncRoutine() {
+	log.Println("Starting portfolio sync routine")
+	changes := portfolio.SubscribeChanges()
+	poll := time.NewTicker(exchangeSyncDelay)
+
+	for _, exch := range bot.exchanges {
+		streaming, ok := exch.(exchange.IPrivateStreamingExchange)
+		if !ok || !exch.IsEnabled() {
+			continue
+		}
+		private, err := streaming.SubscribePrivate()
+		if err != nil {
+			continue
+		}
+		go func(exchangeName string) {
+			for event := range private {
+				if event.Type != exchange.PrivateEventBalance || event.Balance == nil {
+					continue
+				}
+				// Some streams, e.g. Bitfinex wallet updates, only carry the
+				// total so the hold from the last account info is kept
+				if event.Balance.Hold == 0 && event.Balance.Available == 0 {
+					bot.portfolio.SetExchangeTotal(exchangeName, event.Balance.CurrencyName,
+						event.Balance.TotalValue)
+					continue
+				}
+				bot.portfolio.SetExchangeBalance(exchangeName, portfolio.ExchangeAccountCurrencyInfo{
+					CurrencyName: event.Balance.CurrencyName,
+					TotalValue:   event.Balance.TotalValue,
+					Hold:         event.Balance.Hold,
+				})
+			}
+		}(exch.GetName())
+	}
+
+	for {
+		select {
+		case change := <-changes:
+			if change.Address.Description != portfolio.PortfolioAddressExchange {
+				continue
+			}
+			switch change.Type {
+			case portfolio.ChangeAdded:
+				log.Printf("Portfolio: Added %s %s entry with balance %f.\n",
+					change.Address.Address, change.Address.CoinType, change.Address.Balance)
+			case portfolio.ChangeUpdated:
+				log.Printf("Portfolio: Updated %s %s entry with balance %f.\n",
+					change.Address.Address, change.Address.CoinType, change.Address.Balance)
+			case portfolio.ChangeRemoved:
+				log.Printf("Portfolio: Removed %s %s entry.\n",
+					change.Address.Address, change.Address.CoinType)
+			}
+		case <-poll.C:
+			for _, exch := range bot.exchanges {
+				if exch != nil && exch.IsEnabled() && exch.GetAuthenticatedAPISupport() {
+					refreshAccountInfo(exch.GetName())
+				}
+			}
+		}
+	}
+}
+
+// webhookOrder is the data posted to webhooks for order activity
+type webhookOrder struct {
+	Exchange        string               `json:"exchange"`
+	Pair            string               `json:"pair"`
+	OrderID         string               `json:"order_id"`
+	Side            exchange.OrderSide   `json:"side"`
+	Type            exchange.OrderType   `json:"type"`
+	Price           float64              `json:"price"`
+	Amount          float64              `json:"amount"`
+	FilledAmount    float64              `json:"filled_amount"`
+	RemainingAmount float64              `json:"remaining_amount"`
+	Status          exchange.OrderStatus `json:"status"`
+}
+
+// webhookBalance is the data posted to webhooks for balance changes
+type webhookBalance struct {
+	Exchange  string  `json:"exchange"`
+	Currency  string  `json:"currency"`
+	Total     float64 `json:"total"`
+	Hold      float64 `json:"hold"`
+	Available float64 `json:"available"`
+}
+
+// WebhookRoutine posts order activity, triggered events, changes to the
+// exchange balances of the portfolio and websocket connection state changes to
+// the configured webhooks
+func WebhookRoutine() {
+	log.Println("Starting webhook routine")
+	orders := exchange.SubscribeOrderEvents()
+	triggered := events.Subscribe()
+	states := exchange.SubscribeWebsocketState()
+	balances := portfolio.SubscribeChanges()
+
+	for {
+		select {
+		case change := <-balances:
+			if change.Address.Description != portfolio.PortfolioAddressExchange {
+				continue
+			}
+			balance := webhookBalance{
+				Exchange: change.Address.Address,
+				Currency: change.Address.CoinType,
+			}
+			if change.Type != portfolio.ChangeRemoved {
+				balance.Total = change.Address.Balance
+				balance.Hold = change.Address.Hold
+				balance.Available = change.Address.Balance - change.Address.Hold
+			}
+			webhooks.Publish(webhooks.BalanceChanged, balance)
+		case event := <-orders:
+			eventType := webhooks.OrderPlaced
+			switch event.Type {
+			case exchange.OrderEventFilled:
+				eventType = webhooks.OrderFilled
+			case exchange.OrderEventCancelled:
+				eventType = webhooks.OrderCancelled
+			}
+			webhooks.Publish(eventType, webhookOrder{
+				Exchange:        event.Exchange,
+				Pair:            event.Order.CurrencyPair.Pair().String(),
+				OrderID:         event.Order.OrderID,
+				Side:            event.Order.Side,
+				Type:            event.Order.Type,
+				Price:           event.Order.Rate,
+				Amount:          event.Order.Amount,
+				FilledAmount:    event.Order.FilledAmount,
+				RemainingAmount: event.Order.RemainingAmount,
+				Status:          event.Order.Status,
+			})
+		case event := <-triggered:
+			webhooks.Publish(webhooks.EventTriggered, struct {
+				ID        int    `json:"id"`
+				Exchange  string `json:"exchange"`
+				Condition string `json:"condition"`
+				Action    string `json:"action"`
+			}{event.ID, event.Exchange, event.String(), event.Action})
+		case event := <-states:
+			state := struct {
+				Exchange string `json:"exchange"`
+				State    string `json:"state"`
+				Attempt  int    `json:"attempt"`
+				Error    string `json:"error,omitempty"`
+			}{Exchange: event.Exchange, State: event.State, Attempt: event.Attempt}
+
+			if event.Error != nil {
+				state.Error = event.Error.Error()
+			}
+			webhooks.Publish(webhooks.ExchangeConnectivity, state)
+		}
+	}
+}
+
+// transferPollDelay is how often exchanges are polled for deposits and
+// withdrawals
+const transferPollDelay = time.Minute * 10
+
+// ledgerEntryFromFill returns the ledger entry of a fill pushed by an
+// exchange stream
+func ledgerEntryFromFill(exchangeName string, fill exchange.Fill, timestamp time.Time) portfolio.LedgerEntry {
+	entry := portfolio.LedgerEntry{
+		Exchange:  exchangeName,
+		Timestamp: timestamp,
+		Type:      portfolio.EntryBuy,
+		Asset:     fill.CurrencyPair.FirstCurrency.String(),
+		Quote:     fill.CurrencyPair.SecondCurrency.String(),
+		Amount:    fill.Amount,
+		Price:     fill.Price,
+	}
+	if fill.TradeID != "" {
+		entry.ID = "fill:" + fill.TradeID
+	}
+	if fill.Side == exchange.OrderSideSell {
+		entry.Type = portfolio.EntrySell
+	}
+	if fill.Fee > 0 && fill.FeeCurrency != "" {
+		price, err := portfolio.GetFiatPrice(fill.FeeCurrency, bot.ledger.GetCurrency())
+		if err != nil {
+			log.Printf("Failed to value %s fill fee in %s. Error: %s\n", exchangeName,
+				fill.FeeCurrency, err)
+		}
+		entry.Fee = fill.Fee * price
+	}
+	return entry
+}
+
+// ledgerEntryFromOrder returns the ledger entry of an order filled on an
+// exchange without a private stream
+func ledgerEntryFromOrder(exchangeName string, order exchange.Order, timestamp time.Time) portfolio.LedgerEntry {
+	entry := portfolio.LedgerEntry{
+		ID:        "order:" + order.OrderID,
+		Exchange:  exchangeName,
+		Timestamp: timestamp,
+		Type:      portfolio.EntryBuy,
+		Asset:     order.CurrencyPair.FirstCurrency.String(),
+		Quote:     order.CurrencyPair.SecondCurrency.String(),
+		Amount:    order.FilledAmount,
+		Price:     order.Rate,
+	}
+	if entry.Amount == 0 {
+		entry.Amount = order.Amount
+	}
+	if order.Side == exchange.OrderSideSell {
+		entry.Type = portfolio.EntrySell
+	}
+	return entry
+}
+
+// recordLedgerEntry records an entry in the ledger, logging failures
+func recordLedgerEntry(entry portfolio.LedgerEntry) {
+	_, err := bot.ledger.Record(entry)
+	if err != nil {
+		log.Printf("Failed to record %s %s %s in ledger. Error: %s\n", entry.Exchange,
+			entry.Asset, common.StringToLower(entry.Type), err)
+	}
+}
+
+// LedgerRoutine records the trades and transfers of the enabled exchanges in
+// the cost basis ledger. Fills are taken from private streams where the
+// exchange has one and from filled orders otherwise, deposits and withdrawals
+// are polled from exchanges able to list them.
+func LedgerRoutine() {
+	log.Println("Starting ledger routine")
+	orders := exchange.SubscribeOrderEvents()
+	streamed := make(map[string]bool)
+
+	for _, exch := range bot.exchanges {
+		streaming, ok := exch.(exchange.IPrivateStreamingExchange)
+		if !ok || !exch.IsEnabled() {
+			continue
+		}
+		private, err := streaming.SubscribePrivate()
+		if err != nil {
+			continue
+		}
+		streamed[exch.GetName()] = true
+		go func(exchangeName string) {
+			for event := range private {
+				if event.Type != exchange.PrivateEventFill || event.Fill == nil {
+					continue
+				}
+				recordLedgerEntry(ledgerEntryFromFill(exchangeName, *event.Fill, event.Timestamp))
+			}
+		}(exch.GetName())
+	}
+
+	go func() {
+		since := make(map[string]time.Time)
+		for {
+			for _, exch := range bot.exchanges {
+				transfers, ok := exch.(exchange.ITransferHistoryExchange)
+				if !ok || !exch.IsEnabled() {
+					continue
+				}
+
+				exchangeName := exch.GetName()
+				polled := time.Now()
+				result, err := transfers.GetTransferHistory(since[exchangeName])
+				if err != nil {
+					log.Printf("Failed to get %s transfer history. Error: %s\n", exchangeName, err)
+					continue
+				}
+				// Overlap the previous poll, entries already recorded are
+				// skipped by the ledger
+				since[exchangeName] = polled.Add(-transferPollDelay)
+
+				for _, x := range result {
+					entry := portfolio.LedgerEntry{
+						ID:        "transfer:" + x.ID,
+						Exchange:  exchangeName,
+						Timestamp: x.Timestamp,
+						Type:      portfolio.EntryDeposit,
+						Asset:     x.Currency,
+						Amount:    x.Amount,
+					}
+					if !x.Deposit {
+						entry.Type = portfolio.EntryWithdrawal
+						entry.Amount += x.Fee
+					}
+					recordLedgerEntry(entry)
+				}
+			}
+			time.Sleep(transferPollDelay)
+		}
+	}()
+
+	for event := range orders {
+		if event.Type != exchange.OrderEventFilled || streamed[event.Exchange] {
+			continue
+		}
+		recordLedgerEntry(ledgerEntryFromOrder(event.Exchange, event.Order, event.Timestamp))
+	}
+}
+
+// RebalanceRoutine works out a plan to bring the portfolio back to its target
+// allocations every interval, logging the trades and placing them if enabled
+// in the config
+func RebalanceRoutine() {
+	log.Println("Starting rebalance routine")
+	for {
+		time.Sleep(time.Second * bot.config.Rebalance.Interval)
+		if !bot.config.Rebalance.Enabled {
+			continue
+		}
+
+		plan, err := GetRebalancePlan()
+		if err != nil {
+			log.Printf("Failed to work out rebalance plan. Error: %s\n", err)
+			continue
+		}
+		if !plan.Rebalance {
+			continue
+		}
+
+		for _, t := range plan.Trades {
+			log.Printf("Rebalance: %s %f %s at %f on %s.\n", t.Side, t.Amount,
+				t.Pair.Pair().String(), t.Price, t.Exchange)
+		}
+		for coin, value := range plan.Unmatched {
+			log.Printf("Rebalance: %f %s of %s can't be traded.\n", value, plan.Currency, coin)
+		}
+		if !bot.config.Rebalance.Execute || len(plan.Trades) == 0 {
+			continue
+		}
+
+		orderIDs, err := ExecuteRebalancePlan(plan)
+		if err != nil {
+			log.Printf("Failed to execute rebalance plan, orders placed: %v. Error: %s\n", orderIDs, err)
+			continue
+		}
+		log.Printf("Executed rebalance plan, orders: %v\n", orderIDs)
+	}
+}
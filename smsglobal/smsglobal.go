@@ -127,13 +127,19 @@ func (s *Base) RemoveContact(contact Contact) {
 	}
 }
 
-// SendMessageToAll sends a message to all enabled contacts in cfg
-func (s *Base) SendMessageToAll(message string) {
+// SendMessageToAll sends a message to all enabled contacts in cfg, returning
+// the last error if it couldn't be sent to every contact
+func (s *Base) SendMessageToAll(message string) error {
+	var result error
 	for x := range s.Contacts {
 		if s.Contacts[x].Enabled {
-			s.SendMessage(s.Contacts[x].Name, message)
+			err := s.SendMessage(s.Contacts[x].Number, message)
+			if err != nil {
+				result = err
+			}
 		}
 	}
+	return result
 }
 
 // SendMessage sends a message to an individual contact
@@ -124,7 +124,10 @@ func TestSendMessageToAll(t *testing.T) {
 	var contacts []Contact
 	contacts = append(contacts, contact)
 	result := New("bob", "pw", "Skynet", contacts)
-	result.SendMessageToAll("hello world")
+	err := result.SendMessageToAll("hello world")
+	if err != nil {
+		t.Error("Test failed. TestSendMessageToAll: Incorrect values")
+	}
 }
 
 func TestSendMessage(t *testing.T) {
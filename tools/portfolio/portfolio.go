@@ -111,7 +111,7 @@ func main() {
 		if currency.IsDefaultCurrency(y.Coin) {
 			if y.Coin != "USD" {
 				conv, err := currency.ConvertCurrency(y.Balance, y.Coin, "USD")
-				if err != nil {
+				if err != nil && !currency.IsStale(err) {
 					log.Println(err)
 				} else {
 					priceMap[y.Coin] = conv / y.Balance
@@ -73,6 +73,19 @@ export interface CurrencyPairFormat {
   Delimiter: string;
 }
 
+export interface CurrencyRateProvider {
+  Name: string;
+  APIKey?: string;
+  URL?: string;
+  Priority: number;
+}
+
+export interface CurrencyRates {
+  Providers: CurrencyRateProvider[];
+  RefreshInterval: number;
+  StaleAfter: number;
+}
+
 export interface PortfolioAddresses {
   Addresses?: any;
 }
@@ -134,7 +147,7 @@ export interface Config {
   Name: string;
   EncryptConfig?: number;
   Cryptocurrencies: string;
-  CurrencyExchangeProvider: string;
+  CurrencyRates: CurrencyRates;
   CurrencyPairFormat: CurrencyPairFormat;
   PortfolioAddresses: PortfolioAddresses;
   SMSGlobal: SMSGlobal;
@@ -0,0 +1,262 @@
+package webhooks
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/mattkanwisher/cryptofiend/common"
+	"github.com/mattkanwisher/cryptofiend/config"
+)
+
+// Types of activity posted to webhooks
+const (
+	OrderPlaced          = "order_placed"
+	OrderFilled          = "order_filled"
+	OrderCancelled       = "order_cancelled"
+	EventTriggered       = "event_triggered"
+	BalanceChanged       = "balance_changed"
+	ExchangeConnectivity = "exchange_connectivity"
+)
+
+// Headers sent with each delivery. SignatureHeader holds the hex encoded
+// HMAC-SHA256 of the body keyed by the endpoint secret.
+const (
+	SignatureHeader = "X-Cryptofiend-Signature"
+	EventHeader     = "X-Cryptofiend-Event"
+	DeliveryHeader  = "X-Cryptofiend-Delivery"
+)
+
+// QueueSize is the number of deliveries held for each endpoint before further
+// activity is dropped
+const QueueSize = 1024
+
+var errQueueFull = errors.New("webhook queue is full")
+
+// Payload is the JSON body posted for each activity
+type Payload struct {
+	ID        string      `json:"id"`
+	Type      string      `json:"type"`
+	Timestamp time.Time   `json:"timestamp"`
+	Data      interface{} `json:"data"`
+}
+
+// DeadLetter is a delivery which failed after every retry, they are appended
+// to the dead letter file one JSON object per line
+type DeadLetter struct {
+	URL       string          `json:"url"`
+	Type      string          `json:"type"`
+	Attempts  int             `json:"attempts"`
+	Error     string          `json:"error"`
+	Timestamp time.Time       `json:"timestamp"`
+	Payload   json.RawMessage `json:"payload"`
+}
+
+// delivery is an encoded payload waiting to be posted to an endpoint
+type delivery struct {
+	id        string
+	eventType string
+	body      []byte
+}
+
+// endpoint posts the deliveries queued for a webhook URL in order
+type endpoint struct {
+	url            string
+	secret         []byte
+	events         []string
+	maxRetries     int
+	retryDelay     time.Duration
+	deadLetterFile string
+	queue          chan delivery
+}
+
+var publisher struct {
+	m         sync.RWMutex
+	endpoints []*endpoint
+}
+
+// deadLetters serialises writes to the dead letter file
+var deadLetters sync.Mutex
+
+var nextID uint64
+
+// Setup starts posting activity to the enabled endpoints in the config,
+// replacing any endpoints already set up
+func Setup(cfg config.WebhooksConfig) {
+	publisher.m.Lock()
+	defer publisher.m.Unlock()
+
+	for _, x := range publisher.endpoints {
+		close(x.queue)
+	}
+	publisher.endpoints = nil
+
+	if !cfg.Enabled {
+		return
+	}
+
+	for _, x := range cfg.Endpoints {
+		if !x.Enabled {
+			continue
+		}
+		e := &endpoint{
+			url:            x.URL,
+			secret:         []byte(x.Secret),
+			events:         x.Events,
+			maxRetries:     cfg.MaxRetries,
+			retryDelay:     time.Second * cfg.RetryDelay,
+			deadLetterFile: cfg.DeadLetterFile,
+			queue:          make(chan delivery, QueueSize),
+		}
+		publisher.endpoints = append(publisher.endpoints, e)
+		go e.run()
+	}
+	log.Printf("Webhooks enabled. Number of endpoints %d.\n", len(publisher.endpoints))
+}
+
+// IsEnabled returns whether there are any endpoints to post activity to
+func IsEnabled() bool {
+	publisher.m.RLock()
+	defer publisher.m.RUnlock()
+
+	return len(publisher.endpoints) > 0
+}
+
+// Publish queues activity on every endpoint which accepts its type
+func Publish(eventType string, data interface{}) {
+	publisher.m.RLock()
+	defer publisher.m.RUnlock()
+
+	if len(publisher.endpoints) == 0 {
+		return
+	}
+
+	p := Payload{
+		ID:        fmt.Sprintf("%d-%d", time.Now().Unix(), atomic.AddUint64(&nextID, 1)),
+		Type:      eventType,
+		Timestamp: time.Now(),
+		Data:      data,
+	}
+	body, err := common.JSONEncode(p)
+	if err != nil {
+		log.Printf("Failed to encode %s webhook. Error: %s\n", eventType, err)
+		return
+	}
+
+	d := delivery{id: p.ID, eventType: eventType, body: body}
+	for _, e := range publisher.endpoints {
+		if !e.accepts(eventType) {
+			continue
+		}
+		select {
+		case e.queue <- d:
+		default:
+			e.writeDeadLetter(d, 0, errQueueFull)
+		}
+	}
+}
+
+// Sign returns the signature of a body for an endpoint secret
+func Sign(body, secret []byte) string {
+	return common.HexEncodeToString(common.GetHMAC(common.HashSHA256, body, secret))
+}
+
+// accepts returns whether activity of a type is posted to the endpoint
+func (e *endpoint) accepts(eventType string) bool {
+	if len(e.events) == 0 {
+		return true
+	}
+	for _, x := range e.events {
+		if x == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// run posts queued deliveries, retrying those which fail with exponential
+// backoff before writing them to the dead letter file
+func (e *endpoint) run() {
+	for d := range e.queue {
+		err := e.post(d)
+		delay := e.retryDelay
+		attempts := 1
+		for ; err != nil && attempts <= e.maxRetries; attempts++ {
+			log.Printf("Failed to post %s webhook to %s, retrying in %s. Error: %s\n",
+				d.eventType, e.url, delay, err)
+			time.Sleep(delay)
+			delay *= 2
+			err = e.post(d)
+		}
+		if err != nil {
+			e.writeDeadLetter(d, attempts, err)
+		}
+	}
+}
+
+// post sends a delivery to the endpoint, failing on non 2xx responses
+func (e *endpoint) post(d delivery) error {
+	headers := http.Header{}
+	headers.Set("Content-Type", "application/json")
+	headers.Set(EventHeader, d.eventType)
+	headers.Set(DeliveryHeader, d.id)
+	headers.Set(SignatureHeader, Sign(d.body, e.secret))
+
+	resp, status, err := common.SendHTTPRequest2("POST", e.url, headers, bytes.NewReader(d.body))
+	if err != nil {
+		return err
+	}
+	if status < 200 || status > 299 {
+		return &common.HTTPRequestError{
+			StatusCode: status,
+			Message:    fmt.Sprintf("webhook returned status %d: %s", status, resp),
+		}
+	}
+	return nil
+}
+
+// writeDeadLetter appends a delivery which couldn't be posted to the dead
+// letter file
+func (e *endpoint) writeDeadLetter(d delivery, attempts int, reason error) {
+	log.Printf("Webhook %s to %s dead lettered after %d attempts. Error: %s\n",
+		d.id, e.url, attempts, reason)
+
+	if e.deadLetterFile == "" {
+		return
+	}
+
+	deadLetters.Lock()
+	defer deadLetters.Unlock()
+
+	line, err := common.JSONEncode(DeadLetter{
+		URL:       e.url,
+		Type:      d.eventType,
+		Attempts:  attempts,
+		Error:     reason.Error(),
+		Timestamp: time.Now(),
+		Payload:   d.body,
+	})
+	if err != nil {
+		log.Printf("Failed to encode webhook dead letter. Error: %s\n", err)
+		return
+	}
+
+	f, err := os.OpenFile(e.deadLetterFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		log.Printf("Failed to open webhook dead letter file. Error: %s\n", err)
+		return
+	}
+	defer f.Close()
+
+	_, err = f.Write(append(line, '\n'))
+	if err != nil {
+		log.Printf("Failed to write webhook dead letter. Error: %s\n", err)
+	}
+}
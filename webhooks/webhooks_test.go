@@ -0,0 +1,144 @@
+package webhooks
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mattkanwisher/cryptofiend/config"
+)
+
+type testRequest struct {
+	header http.Header
+	body   []byte
+}
+
+// testServer records the requests posted to it, failing the first failures
+func testServer(failures int) (*httptest.Server, chan testRequest) {
+	var m sync.Mutex
+	requests := make(chan testRequest, 16)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		m.Lock()
+		defer m.Unlock()
+		requests <- testRequest{r.Header, body}
+		if failures > 0 {
+			failures--
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	return server, requests
+}
+
+func waitRequest(t *testing.T, requests chan testRequest) testRequest {
+	select {
+	case r := <-requests:
+		return r
+	case <-time.After(time.Second * 5):
+		t.Fatal("Test failed. webhook not posted")
+	}
+	return testRequest{}
+}
+
+func TestSign(t *testing.T) {
+	signature := Sign([]byte("The quick brown fox jumps over the lazy dog"), []byte("key"))
+	if signature != "f7bc83f430538424b13298e6aa6fb143ef4d59a14946175997479dbc2d1a3cd8" {
+		t.Errorf("Test failed. TestSign: unexpected signature %s", signature)
+	}
+}
+
+func TestPublish(t *testing.T) {
+	server, requests := testServer(0)
+	defer server.Close()
+
+	Setup(config.WebhooksConfig{
+		Enabled: true,
+		Endpoints: []config.WebhookConfig{
+			{URL: server.URL, Secret: "secret", Events: []string{OrderPlaced}, Enabled: true},
+			{URL: server.URL, Secret: "disabled"},
+		},
+	})
+	defer Setup(config.WebhooksConfig{})
+
+	if !IsEnabled() {
+		t.Fatal("Test failed. TestPublish: webhooks not enabled")
+	}
+
+	Publish(OrderFilled, "ignored")
+	Publish(OrderPlaced, map[string]string{"order_id": "1"})
+	r := waitRequest(t, requests)
+
+	var p struct {
+		ID   string
+		Type string
+		Data map[string]string
+	}
+	err := json.Unmarshal(r.body, &p)
+	if err != nil {
+		t.Fatalf("Test failed. TestPublish: Error, %s", err)
+	}
+	if p.Type != OrderPlaced || p.Data["order_id"] != "1" {
+		t.Errorf("Test failed. TestPublish: unexpected payload %s", r.body)
+	}
+	if r.header.Get(EventHeader) != OrderPlaced || r.header.Get(DeliveryHeader) != p.ID {
+		t.Error("Test failed. TestPublish: unexpected headers")
+	}
+	if r.header.Get(SignatureHeader) != Sign(r.body, []byte("secret")) {
+		t.Error("Test failed. TestPublish: invalid signature")
+	}
+
+	select {
+	case r = <-requests:
+		t.Errorf("Test failed. TestPublish: unexpected webhook %s", r.body)
+	case <-time.After(time.Millisecond * 100):
+	}
+}
+
+func TestDeadLetter(t *testing.T) {
+	server, requests := testServer(10)
+	defer server.Close()
+
+	dir, err := ioutil.TempDir("", "webhooks")
+	if err != nil {
+		t.Fatalf("Test failed. TestDeadLetter: Error, %s", err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "deadletter.log")
+
+	Setup(config.WebhooksConfig{
+		Enabled:        true,
+		MaxRetries:     1,
+		DeadLetterFile: file,
+		Endpoints: []config.WebhookConfig{
+			{URL: server.URL, Secret: "secret", Enabled: true},
+		},
+	})
+	defer Setup(config.WebhooksConfig{})
+
+	Publish(BalanceChanged, "balance")
+	waitRequest(t, requests)
+	waitRequest(t, requests)
+
+	var data []byte
+	for i := 0; i < 50 && len(data) == 0; i++ {
+		time.Sleep(time.Millisecond * 20)
+		data, _ = ioutil.ReadFile(file)
+	}
+
+	var d DeadLetter
+	err = json.Unmarshal([]byte(strings.TrimSpace(string(data))), &d)
+	if err != nil {
+		t.Fatalf("Test failed. TestDeadLetter: Error, %s", err)
+	}
+	if d.URL != server.URL || d.Type != BalanceChanged || d.Attempts != 2 ||
+		!strings.Contains(string(d.Payload), `"data":"balance"`) {
+		t.Errorf("Test failed. TestDeadLetter: unexpected dead letter %s", data)
+	}
+}
@@ -10,6 +10,10 @@ import (
 	"github.com/mattkanwisher/cryptofiend/common"
 	"github.com/mattkanwisher/cryptofiend/config"
 	"github.com/mattkanwisher/cryptofiend/currency"
+	"github.com/mattkanwisher/cryptofiend/currency/pair"
+	"github.com/mattkanwisher/cryptofiend/events"
+	"github.com/mattkanwisher/cryptofiend/exchanges/arbitrage"
+	"github.com/mattkanwisher/cryptofiend/exchanges/orderbook"
 )
 
 // Const vars for websocket
@@ -48,6 +52,21 @@ type WebsocketOrderbookTickerRequest struct {
 	AssetType string `json:"assetType"`
 }
 
+// WebsocketConsolidatedOrderbookRequest is a struct used for consolidated
+// orderbook requests
+type WebsocketConsolidatedOrderbookRequest struct {
+	Currency    string `json:"currency"`
+	AssetType   string `json:"assetType"`
+	FeeAdjusted bool   `json:"feeAdjusted"`
+}
+
+// WebsocketEventIDRequest is a struct used for requests removing, enabling or
+// disabling an event
+type WebsocketEventIDRequest struct {
+	ID      int  `json:"id"`
+	Enabled bool `json:"enabled"`
+}
+
 // WebsocketClientHub stores an array of websocket clients
 var WebsocketClientHub []WebsocketClient
 
@@ -141,6 +160,15 @@ var wsHandlers = map[string]wsCommandHandler{
 	"getorderbook":     wsGetOrderbook,
 	"getexchangerates": wsGetExchangeRates,
 	"getportfolio":     wsGetPortfolio,
+
+	"getconsolidatedorderbook":   wsGetConsolidatedOrderbook,
+	"getarbitrageopportunities":  wsGetArbitrageOpportunities,
+	"gettriangularopportunities": wsGetTriangularOpportunities,
+
+	"getevents":   wsGetEvents,
+	"addevent":    wsAddEvent,
+	"removeevent": wsRemoveEvent,
+	"enableevent": wsEnableEvent,
 }
 
 func wsGetConfig(wsClient *websocket.Conn, data interface{}) error {
@@ -252,15 +280,150 @@ func wsGetOrderbook(wsClient *websocket.Conn, data interface{}) error {
 	return wsClient.WriteJSON(wsResp)
 }
 
+func wsGetConsolidatedOrderbook(wsClient *websocket.Conn, data interface{}) error {
+	wsResp := WebsocketEventResponse{
+		Event: "GetConsolidatedOrderbook",
+	}
+	var orderbookReq WebsocketConsolidatedOrderbookRequest
+	err := common.JSONDecode(data.([]byte), &orderbookReq)
+	if err != nil {
+		wsResp.Error = err.Error()
+		wsClient.WriteJSON(wsResp)
+		return err
+	}
+
+	if orderbookReq.AssetType == "" {
+		orderbookReq.AssetType = orderbook.Spot
+	}
+
+	result, err := GetConsolidatedOrderbook(
+		pair.NewCurrencyPairFromString(orderbookReq.Currency),
+		orderbookReq.AssetType, orderbookReq.FeeAdjusted)
+
+	if err != nil {
+		wsResp.Error = err.Error()
+		wsClient.WriteJSON(wsResp)
+		return err
+	}
+	wsResp.Data = result
+	return wsClient.WriteJSON(wsResp)
+}
+
+func wsGetArbitrageOpportunities(wsClient *websocket.Conn, data interface{}) error {
+	wsResp := WebsocketEventResponse{
+		Event: "GetArbitrageOpportunities",
+		Data:  arbitrage.GetOpportunities(),
+	}
+	return wsClient.WriteJSON(wsResp)
+}
+
+func wsGetTriangularOpportunities(wsClient *websocket.Conn, data interface{}) error {
+	wsResp := WebsocketEventResponse{
+		Event: "GetTriangularOpportunities",
+		Data:  arbitrage.GetCycles(),
+	}
+	return wsClient.WriteJSON(wsResp)
+}
+
+func wsGetEvents(wsClient *websocket.Conn, data interface{}) error {
+	wsResp := WebsocketEventResponse{
+		Event: "GetEvents",
+		Data:  events.GetEventConfigs(),
+	}
+	return wsClient.WriteJSON(wsResp)
+}
+
+func wsAddEvent(wsClient *websocket.Conn, data interface{}) error {
+	wsResp := WebsocketEventResponse{
+		Event: "AddEvent",
+	}
+	var event config.EventConfig
+	err := common.JSONDecode(data.([]byte), &event)
+	if err != nil {
+		wsResp.Error = err.Error()
+		wsClient.WriteJSON(wsResp)
+		return err
+	}
+
+	id, err := events.AddEventConfig(event)
+	if err != nil {
+		wsResp.Error = err.Error()
+		wsClient.WriteJSON(wsResp)
+		return err
+	}
+	err = updateEventsConfig()
+	if err != nil {
+		wsResp.Error = err.Error()
+		wsClient.WriteJSON(wsResp)
+		return err
+	}
+
+	wsResp.Data = WebsocketEventIDRequest{ID: id, Enabled: true}
+	return wsClient.WriteJSON(wsResp)
+}
+
+func wsRemoveEvent(wsClient *websocket.Conn, data interface{}) error {
+	wsResp := WebsocketEventResponse{
+		Event: "RemoveEvent",
+	}
+	var eventReq WebsocketEventIDRequest
+	err := common.JSONDecode(data.([]byte), &eventReq)
+	if err != nil {
+		wsResp.Error = err.Error()
+		wsClient.WriteJSON(wsResp)
+		return err
+	}
+
+	if !events.RemoveEvent(eventReq.ID) {
+		wsResp.Error = events.ErrEventNotFound.Error()
+		wsClient.WriteJSON(wsResp)
+		return events.ErrEventNotFound
+	}
+	err = updateEventsConfig()
+	if err != nil {
+		wsResp.Error = err.Error()
+		wsClient.WriteJSON(wsResp)
+		return err
+	}
+
+	wsResp.Data = WebsocketResponseSuccess
+	return wsClient.WriteJSON(wsResp)
+}
+
+func wsEnableEvent(wsClient *websocket.Conn, data interface{}) error {
+	wsResp := WebsocketEventResponse{
+		Event: "EnableEvent",
+	}
+	var eventReq WebsocketEventIDRequest
+	err := common.JSONDecode(data.([]byte), &eventReq)
+	if err != nil {
+		wsResp.Error = err.Error()
+		wsClient.WriteJSON(wsResp)
+		return err
+	}
+
+	err = events.EnableEvent(eventReq.ID, eventReq.Enabled)
+	if err != nil {
+		wsResp.Error = err.Error()
+		wsClient.WriteJSON(wsResp)
+		return err
+	}
+	err = updateEventsConfig()
+	if err != nil {
+		wsResp.Error = err.Error()
+		wsClient.WriteJSON(wsResp)
+		return err
+	}
+
+	wsResp.Data = WebsocketResponseSuccess
+	return wsClient.WriteJSON(wsResp)
+}
+
 func wsGetExchangeRates(wsClient *websocket.Conn, data interface{}) error {
 	wsResp := WebsocketEventResponse{
 		Event: "GetExchangeRates",
 	}
-	if currency.YahooEnabled {
-		wsResp.Data = currency.CurrencyStore
-	} else {
-		wsResp.Data = currency.CurrencyStoreFixer
-	}
+	wsResp.Data = currency.GetRates()
 	return wsClient.WriteJSON(wsResp)
 }
 